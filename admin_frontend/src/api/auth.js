@@ -58,6 +58,30 @@ export default {
     })
   },
   /*
+  getNotifications returns promise which is resolved with email notification preferences of currently logged user
+   */
+  getNotifications () {
+    return new Promise((resolve, reject) => {
+      Vue.http.get('/api/me/notifications/').then((response) => {
+        resolve(response.data.result)
+      }, (response) => {
+        reject(response)
+      })
+    })
+  },
+  /*
+  updateNotifications updates email notification preferences of currently logged user
+   */
+  updateNotifications (data) {
+    return new Promise((resolve, reject) => {
+      Vue.http.post('/api/me/notifications/', data).then((response) => {
+        resolve(response.data.result)
+      }, (response) => {
+        reject(response)
+      })
+    })
+  },
+  /*
   updateUser updates user on backend
    */
   updateUser (user, id) {
@@ -83,6 +107,30 @@ export default {
     })
   },
   /*
+  listLockouts returns promise which is resolved with locked usernames and ip addresses
+   */
+  listLockouts () {
+    return new Promise((resolve, reject) => {
+      Vue.http.get('/api/lockout/').then((response) => {
+        resolve(response.data.result)
+      }, (response) => {
+        reject(response)
+      })
+    })
+  },
+  /*
+  unlock removes lockout of given username or ip address
+   */
+  unlock (lockout) {
+    return new Promise((resolve, reject) => {
+      Vue.http.post('/api/lockout/', {username: lockout.username || '', ip: lockout.ip || ''}).then((response) => {
+        resolve(response.data)
+      }, (response) => {
+        reject(response)
+      })
+    })
+  },
+  /*
   login calls login with given username and password and returns promise.
   resolve resolves with token
   reject rejects with response
@@ -91,14 +139,69 @@ export default {
     return new Promise((resolve, reject) => {
       Vue.http.post('/api/login/', JSON.stringify({username: username, password: password})).then((response) => {
         window.localStorage.setItem('auth_token', response.headers.get('authorization'))
+        window.localStorage.setItem('refresh_token', response.data.result.refresh_token)
         resolve(response.headers.get('authorization'))
       }, (response) => {
         reject(response)
       })
     })
   },
+  /*
+  loginMethods returns promise which is resolved with available login methods (e.g. oidc)
+   */
+  loginMethods () {
+    return new Promise((resolve, reject) => {
+      Vue.http.get('/api/login/').then((response) => {
+        resolve(response.data.result)
+      }, (response) => {
+        reject(response)
+      })
+    })
+  },
+  /*
+  loginToken stores tokens returned from single sign on
+   */
+  loginToken (token, refreshToken) {
+    window.localStorage.setItem('auth_token', 'Bearer ' + token)
+    window.localStorage.setItem('refresh_token', refreshToken || '')
+  },
+  /*
+  refresh exchanges refresh token for new access token, returns promise
+   */
+  refresh () {
+    return new Promise((resolve, reject) => {
+      var refreshToken = window.localStorage.getItem('refresh_token')
+      if (!refreshToken) {
+        reject()
+        return
+      }
+      Vue.http.post('/api/login/refresh/', JSON.stringify({refresh_token: refreshToken})).then((response) => {
+        window.localStorage.setItem('auth_token', response.headers.get('authorization'))
+        window.localStorage.setItem('refresh_token', response.data.result.refresh_token)
+        resolve(response.headers.get('authorization'))
+      }, (response) => {
+        reject(response)
+      })
+    })
+  },
+  /*
+  logout signs out current session on server and removes tokens
+   */
   logout () {
-    window.localStorage.removeItem('auth_token')
+    var clear = () => {
+      window.localStorage.removeItem('auth_token')
+      window.localStorage.removeItem('refresh_token')
+    }
+    return Vue.http.post('/api/me/logout/').then(clear, clear)
+  },
+  /*
+  logoutAll signs out all sessions of current user
+   */
+  logoutAll () {
+    return Vue.http.post('/api/me/logout/all/').then(() => {
+      window.localStorage.removeItem('auth_token')
+      window.localStorage.removeItem('refresh_token')
+    })
   },
   interceptor (router) {
     return (request, next) => {
@@ -107,9 +210,19 @@ export default {
         request.headers.set('Authorization', authToken)
       }
       next((response) => {
-        // Check for expired token response, if expired, navigate to login
+        // Check for expired token response, try to refresh it once, otherwise navigate to login
         if (response.status === 401) {
-          router.push({name: 'login'})
+          if (request.retried || request.url.indexOf('/api/login/') === 0) {
+            router.push({name: 'login'})
+            return
+          }
+          return this.refresh().then((token) => {
+            request.retried = true
+            request.headers.set('Authorization', token)
+            return Vue.http(request)
+          }, () => {
+            router.push({name: 'login'})
+          })
         } else {
           return response
         }
@@ -0,0 +1,227 @@
+/*
+Audit log
+
+Security relevant and repository changing actions are recorded to audit log. Entry is built with NewAuditEntry and
+saved with Save method. Failure to write audit entry never fails the action itself, it's only logged.
+
+	NewAuditEntry(AUDIT_ACTION_USER_UPDATE).
+		Request(r).
+		Target(AUDIT_TARGET_USER, user.ID, user.Username).
+		Diff(before, user).
+		Save(cfg)
+
+Changes are computed from json representation of objects, so fields hidden from json (e.g. password hashes) never
+reach audit log.
+*/
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os/user"
+	"reflect"
+
+	"github.com/uber-go/zap"
+)
+
+var (
+	// fields that are not tracked in changes
+	auditIgnoredFields = []string{"created_at", "updated_at"}
+)
+
+/*
+AuditChange is single changed field
+*/
+type AuditChange struct {
+	Before interface{} `json:"before"`
+	After  interface{} `json:"after"`
+}
+
+/*
+NewAuditEntry returns new audit entry for given action
+*/
+func NewAuditEntry(action string) *AuditEntry {
+	return &AuditEntry{
+		log: AuditLog{
+			Action: action,
+		},
+		changes: map[string]AuditChange{},
+	}
+}
+
+/*
+AuditEntry builds audit log record
+*/
+type AuditEntry struct {
+	log     AuditLog
+	changes map[string]AuditChange
+}
+
+/*
+Request sets actor (user from context), ip address and user agent from http request
+*/
+func (a *AuditEntry) Request(r *http.Request) *AuditEntry {
+	a.log.Source = AUDIT_SOURCE_HTTP
+	a.log.IP = RemoteIP(r)
+	a.log.UserAgent = StringTruncate(r.UserAgent(), 256)
+
+	if actor, err := ContextGetTokenUser(r.Context()); err == nil {
+		a.Actor(actor)
+	}
+
+	return a
+}
+
+/*
+CLI marks entry as run from command line, actor is operating system user
+*/
+func (a *AuditEntry) CLI() *AuditEntry {
+	a.log.Source = AUDIT_SOURCE_CLI
+
+	if current, err := user.Current(); err == nil {
+		a.log.ActorUsername = current.Username
+	}
+
+	return a
+}
+
+/*
+Actor sets user that performed action
+*/
+func (a *AuditEntry) Actor(actor User) *AuditEntry {
+	a.log.ActorID = actor.ID
+	a.log.ActorUsername = actor.Username
+	return a
+}
+
+/*
+Target sets object that action was performed on
+*/
+func (a *AuditEntry) Target(targetType string, id interface{}, name string) *AuditEntry {
+	a.log.TargetType = targetType
+	a.log.TargetID = fmt.Sprintf("%v", id)
+	a.log.Target = StringTruncate(name, 256)
+	return a
+}
+
+/*
+Change records change of single field
+*/
+func (a *AuditEntry) Change(field string, before, after interface{}) *AuditEntry {
+	a.changes[field] = AuditChange{Before: before, After: after}
+	return a
+}
+
+/*
+Diff records all fields that differ between before and after. When before is nil (object was created), all fields
+of after are recorded.
+*/
+func (a *AuditEntry) Diff(before, after interface{}) *AuditEntry {
+	b, aa := auditFields(before), auditFields(after)
+
+	for key, value := range aa {
+		if StringListContains(auditIgnoredFields, key) {
+			continue
+		}
+		if old, ok := b[key]; !ok || !reflect.DeepEqual(old, value) {
+			a.Change(key, b[key], value)
+		}
+	}
+
+	for key, value := range b {
+		if _, ok := aa[key]; !ok && !StringListContains(auditIgnoredFields, key) {
+			a.Change(key, value, nil)
+		}
+	}
+
+	return a
+}
+
+/*
+Save stores entry to database. Errors are logged and returned, callers usually ignore them.
+*/
+func (a *AuditEntry) Save(cfg Config) (err error) {
+	if len(a.changes) > 0 {
+		var body []byte
+		if body, err = json.Marshal(a.changes); err != nil {
+			return
+		}
+		a.log.Changes = string(body)
+	}
+
+	if err = cfg.Manager().AuditLog().Create(&a.log); err != nil {
+		cfg.Logger().Error("cannot write audit log",
+			zap.String("action", a.log.Action),
+			zap.String("target", a.log.Target),
+			zap.String("error", err.Error()),
+		)
+	}
+
+	return
+}
+
+/*
+AuditLoginFailure records failed login. Requests rejected by login throttle are not recorded (throttle bounds number
+of entries), failure that caused lockout carries time when lockout ends.
+*/
+func AuditLoginFailure(cfg Config, r *http.Request, username string) {
+	entry := NewAuditEntry(AUDIT_ACTION_LOGIN_FAILED).
+		Request(r).
+		Target(AUDIT_TARGET_USER, "", username)
+
+	if err := cfg.Auth().Throttle().Check(username, RemoteIP(r)); err != nil {
+		if te, ok := err.(ThrottleError); ok {
+			entry.Change("locked_until", nil, te.Until)
+		}
+	}
+
+	entry.Save(cfg)
+}
+
+/*
+auditFields returns json representation of value as map
+*/
+func auditFields(value interface{}) (result map[string]interface{}) {
+	result = map[string]interface{}{}
+	if value == nil {
+		return
+	}
+
+	body, err := json.Marshal(value)
+	if err != nil {
+		return
+	}
+
+	json.Unmarshal(body, &result)
+	return
+}
+
+/*
+ExportAuditLog writes audit log entries matching filter to writer as json lines (one json object per line), format
+suitable for SIEM ingestion. Entries are written oldest first and fetched from database in batches.
+*/
+func ExportAuditLog(cfg Config, w io.Writer, filter Filter) (err error) {
+	encoder := json.NewEncoder(w)
+	last := uint(0)
+
+	for {
+		entries := []AuditLog{}
+		queryset := filter.Apply(cfg.DB()).Where("id > ?", last).Order("id ASC").Limit(AUDIT_EXPORT_BATCH)
+		if err = queryset.Find(&entries).Error; err != nil {
+			return
+		}
+
+		for _, entry := range entries {
+			if err = encoder.Encode(entry); err != nil {
+				return
+			}
+			last = entry.ID
+		}
+
+		if len(entries) < AUDIT_EXPORT_BATCH {
+			return
+		}
+	}
+}
@@ -0,0 +1,108 @@
+package core
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAuditEntryDiff(t *testing.T) {
+	user := User{ID: 1, Username: "user", Email: "user@example.com", Password: "hash", IsActive: true}
+
+	changed := user
+	changed.Email = "other@example.com"
+	changed.IsAdmin = true
+	changed.UpdatedAt = time.Now()
+
+	password := user
+	password.Password = "other hash"
+
+	tc := []struct {
+		name    string
+		before  interface{}
+		after   interface{}
+		changes string
+	}{
+		{
+			"unchanged",
+			user,
+			user,
+			`{}`,
+		},
+		{
+			"changed fields",
+			user,
+			changed,
+			`{"email":{"before":"user@example.com","after":"other@example.com"},"is_admin":{"before":false,"after":true}}`,
+		},
+		{
+			"hidden password",
+			user,
+			password,
+			`{}`,
+		},
+		{
+			"created",
+			nil,
+			map[string]interface{}{"id": 2, "code": "MIT", "created_at": time.Now()},
+			`{"code":{"before":null,"after":"MIT"},"id":{"before":null,"after":2}}`,
+		},
+		{
+			"deleted",
+			map[string]interface{}{"id": 2, "code": "MIT", "created_at": time.Now()},
+			nil,
+			`{"code":{"before":"MIT","after":null},"id":{"before":2,"after":null}}`,
+		},
+	}
+
+	for _, tt := range tc {
+		t.Run(tt.name, func(st *testing.T) {
+			entry := NewAuditEntry(AUDIT_ACTION_USER_UPDATE).Diff(tt.before, tt.after)
+
+			body, err := json.Marshal(entry.changes)
+			if err != nil {
+				st.Fatalf("cannot marshal changes: %v", err)
+			}
+			if string(body) != tt.changes {
+				st.Errorf("Diff recorded %s, expected %s", body, tt.changes)
+			}
+		})
+	}
+}
+
+func TestAuditEntrySave(t *testing.T) {
+	cfg, fake := newTestConfig(t, func(query string, args []driver.Value) testDBResult {
+		return testDBResult{Columns: []string{"id"}, Rows: [][]driver.Value{{int64(1)}}}
+	})
+
+	err := NewAuditEntry(AUDIT_ACTION_USER_UPDATE).
+		Actor(User{ID: 1, Username: "admin"}).
+		Target(AUDIT_TARGET_USER, 2, "user").
+		Diff(User{ID: 2, Username: "user"}, User{ID: 2, Username: "user", IsAdmin: true}).
+		Save(cfg)
+	if err != nil {
+		t.Fatalf("Save returned error: %v", err)
+	}
+
+	queries := fake.Queries(`INSERT INTO "audit_log"`)
+	if len(queries) != 1 {
+		t.Fatalf("expected single insert, got %v", fake.queries)
+	}
+
+	found := false
+	for _, args := range fake.args {
+		for _, arg := range args {
+			if value, ok := arg.(string); ok && strings.Contains(value, `"is_admin"`) {
+				found = true
+				if value != `{"is_admin":{"before":false,"after":true}}` {
+					t.Errorf("stored changes are %v", value)
+				}
+			}
+		}
+	}
+	if !found {
+		t.Errorf("changes were not stored")
+	}
+}
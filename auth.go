@@ -0,0 +1,135 @@
+/*
+Authentication backends
+
+gopypi can authenticate users against multiple backends. Backends are tried in order given by `auth.backends`
+configuration value, first backend that successfully authenticates user wins.
+
+List of backends:
+
+* local - verifies password against hash stored in database
+* ldap - binds to ldap server and provisions users into database on first login
+*/
+package core
+
+import (
+	"strings"
+
+	"github.com/jinzhu/gorm"
+)
+
+/*
+AuthBackend authenticates user by username and password
+*/
+type AuthBackend interface {
+
+	// Authenticate returns user for given credentials or error
+	Authenticate(username, password string) (User, error)
+}
+
+/*
+authBackendChain tries all backends in given order
+*/
+type authBackendChain []AuthBackend
+
+/*
+Authenticate returns user from first backend that authenticates given credentials. If all backends fail, error from
+last backend is returned.
+*/
+func (a authBackendChain) Authenticate(username, password string) (user User, err error) {
+	err = ErrLoginUsername
+
+	for _, backend := range a {
+		if user, err = backend.Authenticate(username, password); err == nil {
+			return
+		}
+	}
+
+	return
+}
+
+/*
+localAuthBackend verifies password against hash stored in database
+*/
+type localAuthBackend struct {
+	config Config
+}
+
+/*
+Authenticate finds user by username and verifies password. Password is rehashed when stored hash is outdated.
+*/
+func (l *localAuthBackend) Authenticate(username, password string) (user User, err error) {
+	user = User{}
+
+	if err = l.config.DB().First(&user, "username = ?", username).Error; err != nil {
+		return
+	}
+
+	manager := l.config.Manager().User()
+
+	if !manager.VerifyPassword(user, password) {
+		return user, ErrLoginPassword
+	}
+
+	// rehash password with default hasher when algorithm or its parameters are outdated
+	if manager.MustUpdatePassword(user) {
+		manager.SetPassword(&user, password)
+		if err = l.config.DB().Model(&user).UpdateColumn("password", user.Password).Error; err != nil {
+			return
+		}
+	}
+
+	return
+}
+
+/*
+provisionUser creates user in database if not exists and updates information and permissions. It's used by external
+authentication backends which are source of truth for users. Users created this way have unusable local password.
+When permissions are nil, permissions of existing user are left untouched.
+*/
+func provisionUser(cfg Config, username, email, firstName, lastName string, permissions []string) (user User, err error) {
+	user = User{}
+	if err = cfg.DB().First(&user, "username = ?", username).Error; err != nil {
+		if err != gorm.ErrRecordNotFound {
+			return
+		}
+		err = nil
+		user.Username = username
+		user.IsActive = true
+	}
+
+	updateProvisionedUser(&user, email, firstName, lastName, permissions)
+	err = cfg.DB().Save(&user).Error
+
+	return
+}
+
+/*
+updateProvisionedUser updates information of user from external authentication backend, blank values are ignored.
+When permissions are nil, permissions of user are left untouched.
+*/
+func updateProvisionedUser(user *User, email, firstName, lastName string, permissions []string) {
+	if email = strings.TrimSpace(email); email != "" {
+		user.Email = email
+	}
+	if firstName = strings.TrimSpace(firstName); firstName != "" {
+		user.FirstName = firstName
+	}
+	if lastName = strings.TrimSpace(lastName); lastName != "" {
+		user.LastName = lastName
+	}
+
+	if permissions != nil {
+		SetUserPermissions(user, permissions)
+	}
+}
+
+/*
+SetUserPermissions sets user permission flags from list of permission names
+*/
+func SetUserPermissions(user *User, permissions []string) {
+	user.IsAdmin = StringListContains(permissions, USER_PERMISSION_ADMIN)
+	user.CanList = StringListContains(permissions, USER_PERMISSION_LIST)
+	user.CanDownload = StringListContains(permissions, USER_PERMISSION_DOWNLOAD)
+	user.CanCreate = StringListContains(permissions, USER_PERMISSION_CREATE)
+	user.CanUpdate = StringListContains(permissions, USER_PERMISSION_UPDATE)
+}
@@ -0,0 +1,132 @@
+package core
+
+import (
+	"crypto/tls"
+	"net/url"
+	"strings"
+
+	"github.com/go-ldap/ldap/v3"
+)
+
+/*
+ldapAuthBackend authenticates users against ldap directory.
+
+Authentication is done in following steps:
+
+* bind with service account (or anonymous when bind_dn is not set)
+* search for user with configured filter
+* bind as found user with given password
+* map group memberships to permissions and create/update user in database
+
+Directory is source of truth for permissions, so permissions are updated on every successful login.
+*/
+type ldapAuthBackend struct {
+	config Config
+}
+
+/*
+Authenticate authenticates user against ldap server and returns provisioned user
+*/
+func (l *ldapAuthBackend) Authenticate(username, password string) (user User, err error) {
+	// ldap servers treat bind with empty password as unauthenticated bind, which succeeds
+	if strings.TrimSpace(username) == "" || password == "" {
+		return user, ErrLoginPassword
+	}
+
+	cfg := l.config.Auth().LDAP()
+
+	var conn *ldap.Conn
+	if conn, err = l.dial(cfg); err != nil {
+		return
+	}
+	defer conn.Close()
+
+	// bind with service account
+	if cfg.BindDN() != "" {
+		err = conn.Bind(cfg.BindDN(), cfg.BindPassword())
+	} else {
+		err = conn.UnauthenticatedBind("")
+	}
+	if err != nil {
+		return
+	}
+
+	request := ldap.NewSearchRequest(
+		cfg.BaseDN(),
+		ldap.ScopeWholeSubtree, ldap.NeverDerefAliases, 2, 0, false,
+		LDAPUserFilter(cfg.UserFilter(), username),
+		[]string{
+			"dn",
+			cfg.Attribute("email"),
+			cfg.Attribute("first_name"),
+			cfg.Attribute("last_name"),
+			cfg.Attribute("groups"),
+		},
+		nil,
+	)
+
+	var result *ldap.SearchResult
+	if result, err = conn.Search(request); err != nil {
+		return
+	}
+
+	// username must be unique in directory
+	if len(result.Entries) != 1 {
+		return user, ErrLDAPUserNotFound
+	}
+
+	entry := result.Entries[0]
+
+	// verify password by binding as user
+	if err = conn.Bind(entry.DN, password); err != nil {
+		return user, ErrLoginPassword
+	}
+
+	permissions := cfg.Permissions(entry.GetAttributeValues(cfg.Attribute("groups")))
+	if cfg.RequireGroup() && len(permissions) == 0 {
+		return user, ErrLDAPNoGroup
+	}
+
+	return provisionUser(
+		l.config,
+		username,
+		entry.GetAttributeValue(cfg.Attribute("email")),
+		entry.GetAttributeValue(cfg.Attribute("first_name")),
+		entry.GetAttributeValue(cfg.Attribute("last_name")),
+		permissions,
+	)
+}
+
+/*
+LDAPUserFilter returns search filter for username, special characters of username are escaped (RFC 4515) so username
+cannot change the filter
+*/
+func LDAPUserFilter(filter, username string) string {
+	return strings.Replace(filter, "{username}", ldap.EscapeFilter(username), -1)
+}
+
+/*
+dial connects to ldap server and optionally upgrades connection with StartTLS
+*/
+func (l *ldapAuthBackend) dial(cfg LDAPConfig) (conn *ldap.Conn, err error) {
+	tlsConfig := &tls.Config{
+		InsecureSkipVerify: cfg.InsecureSkipVerify(),
+	}
+
+	if parsed, errParse := url.Parse(cfg.URL()); errParse == nil {
+		tlsConfig.ServerName = parsed.Hostname()
+	}
+
+	if conn, err = ldap.DialURL(cfg.URL(), ldap.DialWithTLSConfig(tlsConfig)); err != nil {
+		return
+	}
+
+	if cfg.StartTLS() {
+		if err = conn.StartTLS(tlsConfig); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+
+	return
+}
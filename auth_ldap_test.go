@@ -0,0 +1,105 @@
+package core
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/pelletier/go-toml"
+)
+
+func TestLDAPUserFilter(t *testing.T) {
+	tc := []struct {
+		filter   string
+		username string
+		out      string
+	}{
+		{"(uid={username})", "john", "(uid=john)"},
+		{"(&(objectClass=person)(|(uid={username})(mail={username})))", "john", "(&(objectClass=person)(|(uid=john)(mail=john)))"},
+		{"(uid={username})", "*", `(uid=\2a)`},
+		{"(uid={username})", "admin)(uid=*", `(uid=admin\29\28uid=\2a)`},
+		{"(uid={username})", "*)(|(objectClass=*", `(uid=\2a\29\28|\28objectClass=\2a)`},
+		{"(uid={username})", `john\`, `(uid=john\5c)`},
+		{"(uid={username})", "john\x00", `(uid=john\00)`},
+		{"(uid={username})", "jöhn", `(uid=j\c3\b6hn)`},
+	}
+
+	for _, tt := range tc {
+		t.Run(tt.username, func(st *testing.T) {
+			result := LDAPUserFilter(tt.filter, tt.username)
+			if result != tt.out {
+				st.Errorf("LDAPUserFilter(%q, %q) returned %q, expected %q", tt.filter, tt.username, result, tt.out)
+			}
+
+			// username must not change structure of filter
+			if strings.Count(result, "(") != strings.Count(tt.filter, "(") || strings.Count(result, "*") != strings.Count(tt.filter, "*") {
+				st.Errorf("LDAPUserFilter(%q, %q) changed filter to %q", tt.filter, tt.username, result)
+			}
+		})
+	}
+}
+
+func TestGroupPermissions(t *testing.T) {
+	tree, err := toml.Load(`
+[[auth.ldap.group]]
+dn = "CN=PyPI-Admins,OU=Groups,DC=example,DC=org"
+permissions = ["admin", "list"]
+
+[[auth.ldap.group]]
+dn = "cn=developers,ou=groups,dc=example,dc=org"
+permissions = ["list", "download", "create"]
+`)
+	if err != nil {
+		t.Fatalf("cannot parse config: %v", err)
+	}
+
+	groups, err := newGroupPermissions(tree, "auth.ldap.group", "dn")
+	if err != nil {
+		t.Fatalf("newGroupPermissions returned error: %v", err)
+	}
+	if !groups.HasGroupMapping() {
+		t.Fatalf("HasGroupMapping returned false")
+	}
+
+	tc := []struct {
+		groups      []string
+		permissions []string
+	}{
+		{nil, []string{}},
+		{[]string{"cn=other,dc=example,dc=org"}, []string{}},
+		{[]string{"cn=pypi-admins,ou=groups,dc=example,dc=org"}, []string{"admin", "list"}},
+		{[]string{" CN=Developers,OU=Groups,DC=example,DC=org "}, []string{"list", "download", "create"}},
+		{[]string{"cn=developers,ou=groups,dc=example,dc=org", "cn=pypi-admins,ou=groups,dc=example,dc=org"}, []string{"list", "download", "create", "admin"}},
+	}
+
+	for _, tt := range tc {
+		result := groups.Permissions(tt.groups)
+		if strings.Join(result, ",") != strings.Join(tt.permissions, ",") {
+			t.Errorf("Permissions(%q) returned %q, expected %q", tt.groups, result, tt.permissions)
+		}
+	}
+
+	if tree, err = toml.Load("[[auth.ldap.group]]\ndn = \"cn=x\"\npermissions = [\"root\"]\n"); err != nil {
+		t.Fatalf("cannot parse config: %v", err)
+	}
+	if _, err = newGroupPermissions(tree, "auth.ldap.group", "dn"); err == nil {
+		t.Errorf("newGroupPermissions with unknown permission should return error")
+	}
+
+	if groups, _ = newGroupPermissions(tree, "auth.oidc.group", "name"); groups.HasGroupMapping() {
+		t.Errorf("HasGroupMapping without groups returned true")
+	}
+}
+
+func TestSetUserPermissions(t *testing.T) {
+	user := User{IsAdmin: true, CanUpdate: true}
+	SetUserPermissions(&user, []string{USER_PERMISSION_LIST, USER_PERMISSION_DOWNLOAD})
+
+	if user.IsAdmin || user.CanUpdate || user.CanCreate || !user.CanList || !user.CanDownload {
+		t.Errorf("SetUserPermissions set %+v", user)
+	}
+
+	SetUserPermissions(&user, []string{})
+	if user.IsAdmin || user.CanList || user.CanDownload || user.CanCreate || user.CanUpdate {
+		t.Errorf("SetUserPermissions without permissions set %+v", user)
+	}
+}
@@ -0,0 +1,413 @@
+package core
+
+import (
+	"crypto/hmac"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/jinzhu/gorm"
+)
+
+/*
+NewOIDCProvider returns provider for given OpenID Connect configuration. Discovery document and signing keys are
+fetched lazily on first usage.
+*/
+func NewOIDCProvider(cfg OIDCConfig) *OIDCProvider {
+	return &OIDCProvider{
+		config: cfg,
+		client: &http.Client{Timeout: OIDC_HTTP_TIMEOUT},
+		keys:   map[string]*rsa.PublicKey{},
+	}
+}
+
+/*
+OIDCProvider implements authorization code flow against OpenID Connect provider
+*/
+type OIDCProvider struct {
+	config OIDCConfig
+	client *http.Client
+
+	mutex     sync.Mutex
+	discovery *oidcDiscovery
+	keys      map[string]*rsa.PublicKey
+}
+
+/*
+oidcDiscovery is subset of provider discovery document
+*/
+type oidcDiscovery struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+/*
+Discover returns discovery document, document is cached after first successful call
+*/
+func (o *OIDCProvider) Discover() (result *oidcDiscovery, err error) {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+
+	if o.discovery != nil {
+		return o.discovery, nil
+	}
+
+	result = &oidcDiscovery{}
+	if err = o.getJSON(o.config.Issuer()+"/.well-known/openid-configuration", result); err != nil {
+		return nil, err
+	}
+
+	if strings.TrimRight(result.Issuer, "/") != o.config.Issuer() {
+		return nil, fmt.Errorf("oidc issuer mismatch: %v", result.Issuer)
+	}
+
+	o.discovery = result
+	return
+}
+
+/*
+AuthCodeURL returns url of provider authorization endpoint where user should be redirected
+*/
+func (o *OIDCProvider) AuthCodeURL(state, nonce string) (result string, err error) {
+	var discovery *oidcDiscovery
+	if discovery, err = o.Discover(); err != nil {
+		return
+	}
+
+	values := url.Values{}
+	values.Set("response_type", "code")
+	values.Set("client_id", o.config.ClientID())
+	values.Set("redirect_uri", o.config.RedirectURL())
+	values.Set("scope", strings.Join(o.config.Scopes(), " "))
+	values.Set("state", state)
+	values.Set("nonce", nonce)
+
+	separator := "?"
+	if strings.Contains(discovery.AuthorizationEndpoint, "?") {
+		separator = "&"
+	}
+
+	result = discovery.AuthorizationEndpoint + separator + values.Encode()
+	return
+}
+
+/*
+Exchange exchanges authorization code for id token
+*/
+func (o *OIDCProvider) Exchange(code string) (idToken string, err error) {
+	var discovery *oidcDiscovery
+	if discovery, err = o.Discover(); err != nil {
+		return
+	}
+
+	values := url.Values{}
+	values.Set("grant_type", "authorization_code")
+	values.Set("code", code)
+	values.Set("redirect_uri", o.config.RedirectURL())
+	values.Set("client_id", o.config.ClientID())
+
+	var request *http.Request
+	if request, err = http.NewRequest("POST", discovery.TokenEndpoint, strings.NewReader(values.Encode())); err != nil {
+		return
+	}
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.Header.Set("Accept", "application/json")
+	request.SetBasicAuth(url.QueryEscape(o.config.ClientID()), url.QueryEscape(o.config.ClientSecret()))
+
+	var resp *http.Response
+	if resp, err = o.client.Do(request); err != nil {
+		return
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("oidc token endpoint returned status %v", resp.StatusCode)
+	}
+
+	body := struct {
+		IDToken string `json:"id_token"`
+	}{}
+	if err = json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return
+	}
+
+	if body.IDToken == "" {
+		return "", ErrOIDCInvalidToken
+	}
+
+	return body.IDToken, nil
+}
+
+/*
+Verify verifies id token signature, issuer, audience, expiration and nonce and returns its claims
+*/
+func (o *OIDCProvider) Verify(idToken string, nonce string) (claims jwt.MapClaims, err error) {
+	var discovery *oidcDiscovery
+	if discovery, err = o.Discover(); err != nil {
+		return
+	}
+
+	claims = jwt.MapClaims{}
+	if _, err = jwt.ParseWithClaims(idToken, claims, func(t *jwt.Token) (interface{}, error) {
+		if _, ok := t.Method.(*jwt.SigningMethodRSA); !ok {
+			return nil, ErrOIDCInvalidToken
+		}
+		kid, _ := t.Header["kid"].(string)
+		return o.key(discovery, kid)
+	}); err != nil {
+		return nil, ErrOIDCInvalidToken
+	}
+
+	// parser checks expiration only when present, id token must always expire
+	if !claims.VerifyExpiresAt(time.Now().Unix(), true) {
+		return nil, ErrOIDCInvalidToken
+	}
+
+	if !claims.VerifyIssuer(discovery.Issuer, true) {
+		return nil, ErrOIDCInvalidToken
+	}
+
+	if !StringListContains(ClaimStringList(claims, "aud"), o.config.ClientID()) {
+		return nil, ErrOIDCInvalidToken
+	}
+
+	if value, _ := claims["nonce"].(string); !hmac.Equal([]byte(value), []byte(nonce)) {
+		return nil, ErrOIDCInvalidToken
+	}
+
+	return
+}
+
+/*
+key returns signing key by key id, keys are refetched when key id is not known (key rotation)
+*/
+func (o *OIDCProvider) key(discovery *oidcDiscovery, kid string) (result *rsa.PublicKey, err error) {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+
+	if result = o.findKey(kid); result != nil {
+		return
+	}
+
+	jwks := struct {
+		Keys []struct {
+			Kty string `json:"kty"`
+			Kid string `json:"kid"`
+			Use string `json:"use"`
+			N   string `json:"n"`
+			E   string `json:"e"`
+		} `json:"keys"`
+	}{}
+
+	if err = o.getJSON(discovery.JWKSURI, &jwks); err != nil {
+		return
+	}
+
+	keys := map[string]*rsa.PublicKey{}
+	for _, jwk := range jwks.Keys {
+		if jwk.Kty != "RSA" || (jwk.Use != "" && jwk.Use != "sig") {
+			continue
+		}
+
+		n, errN := base64.RawURLEncoding.DecodeString(strings.TrimRight(jwk.N, "="))
+		e, errE := base64.RawURLEncoding.DecodeString(strings.TrimRight(jwk.E, "="))
+		if errN != nil || errE != nil {
+			continue
+		}
+
+		keys[jwk.Kid] = &rsa.PublicKey{
+			N: new(big.Int).SetBytes(n),
+			E: int(new(big.Int).SetBytes(e).Int64()),
+		}
+	}
+	o.keys = keys
+
+	if result = o.findKey(kid); result == nil {
+		err = ErrOIDCInvalidToken
+	}
+
+	return
+}
+
+/*
+findKey returns key by id, when id is blank and provider publishes single key, that key is returned
+*/
+func (o *OIDCProvider) findKey(kid string) *rsa.PublicKey {
+	if key, ok := o.keys[kid]; ok {
+		return key
+	}
+	if kid == "" && len(o.keys) == 1 {
+		for _, key := range o.keys {
+			return key
+		}
+	}
+	return nil
+}
+
+/*
+getJSON fetches url and unmarshals json body into target
+*/
+func (o *OIDCProvider) getJSON(address string, target interface{}) (err error) {
+	var resp *http.Response
+	if resp, err = o.client.Get(address); err != nil {
+		return
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("oidc provider returned status %v for %v", resp.StatusCode, address)
+	}
+
+	return json.NewDecoder(resp.Body).Decode(target)
+}
+
+/*
+OIDCSecureCookie returns whether state cookie should be sent only over https. Cookie is sent back to redirect url, so
+it's derived from its scheme (TLS is usually terminated at reverse proxy, so request itself can't tell).
+*/
+func OIDCSecureCookie(cfg OIDCConfig) bool {
+	u, err := url.Parse(cfg.RedirectURL())
+	return err == nil && strings.EqualFold(u.Scheme, "https")
+}
+
+/*
+OIDCStateCookie returns cookie value that binds state and nonce to browser, value is signed with secret key
+*/
+func OIDCStateCookie(secret, state, nonce string) string {
+	payload := state + "." + nonce
+	return payload + "." + oidcSign(secret, payload)
+}
+
+/*
+OIDCVerifyStateCookie verifies cookie value against returned state and returns nonce
+*/
+func OIDCVerifyStateCookie(secret, cookie, state string) (nonce string, err error) {
+	parts := strings.Split(cookie, ".")
+	if len(parts) != 3 || state == "" {
+		return "", ErrOIDCInvalidState
+	}
+
+	payload := parts[0] + "." + parts[1]
+	if !hmac.Equal([]byte(parts[2]), []byte(oidcSign(secret, payload))) {
+		return "", ErrOIDCInvalidState
+	}
+
+	if !hmac.Equal([]byte(parts[0]), []byte(state)) {
+		return "", ErrOIDCInvalidState
+	}
+
+	return parts[1], nil
+}
+
+/*
+oidcSign returns hex encoded hmac of value
+*/
+func oidcSign(secret, value string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(value))
+	return fmt.Sprintf("%x", mac.Sum(nil))
+}
+
+/*
+ClaimString returns string value of claim
+*/
+func ClaimString(claims jwt.MapClaims, name string) string {
+	value, _ := claims[name].(string)
+	return strings.TrimSpace(value)
+}
+
+/*
+ClaimStringList returns claim value as list of strings, single string claim is returned as list with one item
+*/
+func ClaimStringList(claims jwt.MapClaims, name string) (result []string) {
+	result = []string{}
+	switch value := claims[name].(type) {
+	case string:
+		result = append(result, value)
+	case []interface{}:
+		for _, item := range value {
+			if str, ok := item.(string); ok {
+				result = append(result, str)
+			}
+		}
+	}
+	return
+}
+
+/*
+OIDCAuthenticate provisions user from verified id token claims. Users are identified by issuer and subject (sub claim)
+of id token, username claim is used only as username of newly created user. Login is never linked to existing
+account by username (it can change at identity provider and isn't guaranteed to be unique), existing local or ldap
+account must be linked explicitly by admin (oidc_issuer and oidc_subject of user).
+*/
+func OIDCAuthenticate(cfg Config, claims jwt.MapClaims) (user User, err error) {
+	oidc := cfg.Auth().OIDC()
+
+	issuer, subject := ClaimString(claims, "iss"), ClaimString(claims, "sub")
+	if subject == "" {
+		return user, ErrOIDCNoSubject
+	}
+
+	username := ClaimString(claims, oidc.Claim("username"))
+	if username == "" {
+		return user, ErrOIDCNoUsername
+	}
+
+	// permissions are synchronized only when group mapping is configured
+	var permissions []string
+	if oidc.HasGroupMapping() {
+		permissions = oidc.Permissions(ClaimStringList(claims, oidc.Claim("groups")))
+	}
+
+	if oidc.RequireGroup() && len(permissions) == 0 {
+		return user, ErrOIDCNoGroup
+	}
+
+	if err = cfg.DB().First(&user, "oidc_issuer = ? AND oidc_subject = ?", issuer, subject).Error; err != nil {
+		if err != gorm.ErrRecordNotFound {
+			return
+		}
+
+		// new account, username must not belong to anybody else
+		if !cfg.DB().First(&User{}, "username = ?", username).RecordNotFound() {
+			return user, ErrOIDCAccountExists
+		}
+
+		err = nil
+		user = User{
+			Username:    username,
+			IsActive:    true,
+			OIDCIssuer:  issuer,
+			OIDCSubject: subject,
+		}
+	}
+
+	updateProvisionedUser(
+		&user,
+		ClaimString(claims, oidc.Claim("email")),
+		ClaimString(claims, oidc.Claim("first_name")),
+		ClaimString(claims, oidc.Claim("last_name")),
+		permissions,
+	)
+	err = cfg.DB().Save(&user).Error
+
+	return
+}
+
+/*
+oidcRandom returns random hex string used for state and nonce
+*/
+func oidcRandom() string {
+	return fmt.Sprintf("%x", GenerateSalt(OIDC_STATE_BYTES))
+}
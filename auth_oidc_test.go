@@ -0,0 +1,72 @@
+package core
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"testing"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+func TestOIDCProviderVerify(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("cannot generate key: %v", err)
+	}
+
+	provider := NewOIDCProvider(&oidcConfig{issuer: "https://id.example.org", clientID: "gopypi"})
+	provider.discovery = &oidcDiscovery{Issuer: "https://id.example.org"}
+	provider.keys["key"] = &key.PublicKey
+
+	valid := func() jwt.MapClaims {
+		return jwt.MapClaims{
+			"iss":   "https://id.example.org",
+			"aud":   "gopypi",
+			"sub":   "1234",
+			"nonce": "nonce",
+			"exp":   time.Now().Add(time.Minute).Unix(),
+		}
+	}
+
+	tc := []struct {
+		name   string
+		update func(claims jwt.MapClaims)
+		method jwt.SigningMethod
+		valid  bool
+	}{
+		{"valid", func(claims jwt.MapClaims) {}, jwt.SigningMethodRS256, true},
+		{"audience list", func(claims jwt.MapClaims) { claims["aud"] = []string{"other", "gopypi"} }, jwt.SigningMethodRS256, true},
+		{"without expiration", func(claims jwt.MapClaims) { delete(claims, "exp") }, jwt.SigningMethodRS256, false},
+		{"expired", func(claims jwt.MapClaims) { claims["exp"] = time.Now().Add(-time.Minute).Unix() }, jwt.SigningMethodRS256, false},
+		{"not yet valid", func(claims jwt.MapClaims) { claims["nbf"] = time.Now().Add(time.Hour).Unix() }, jwt.SigningMethodRS256, false},
+		{"other issuer", func(claims jwt.MapClaims) { claims["iss"] = "https://evil.example.org" }, jwt.SigningMethodRS256, false},
+		{"other audience", func(claims jwt.MapClaims) { claims["aud"] = "other" }, jwt.SigningMethodRS256, false},
+		{"other nonce", func(claims jwt.MapClaims) { claims["nonce"] = "other" }, jwt.SigningMethodRS256, false},
+		{"symmetric signature", func(claims jwt.MapClaims) {}, jwt.SigningMethodHS256, false},
+	}
+
+	for _, tt := range tc {
+		t.Run(tt.name, func(st *testing.T) {
+			claims := valid()
+			tt.update(claims)
+
+			token := jwt.NewWithClaims(tt.method, claims)
+			token.Header["kid"] = "key"
+
+			var signed string
+			if tt.method == jwt.SigningMethodHS256 {
+				signed, err = token.SignedString([]byte("secret"))
+			} else {
+				signed, err = token.SignedString(key)
+			}
+			if err != nil {
+				st.Fatalf("cannot sign token: %v", err)
+			}
+
+			if _, err := provider.Verify(signed, "nonce"); (err == nil) != tt.valid {
+				st.Errorf("Verify returned %v", err)
+			}
+		})
+	}
+}
@@ -3,6 +3,10 @@ package core
 import (
 	"github.com/urfave/cli"
 	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
 )
 
 var (
@@ -42,6 +46,143 @@ func MigrateAction(c *cli.Context) (err error) {
 
 	Migrate(cfg)
 
+	NewAuditEntry(AUDIT_ACTION_MIGRATE).
+		CLI().
+		Target(AUDIT_TARGET_SYSTEM, "", "database").
+		Change("version", nil, VERSION).
+		Save(cfg)
+
+	return
+}
+
+/*
+ExportAuditAction writes audit log as json lines to file or standard output
+*/
+func ExportAuditAction(c *cli.Context) (err error) {
+	var cfg Config
+	if cfg, err = getconfig(c); err != nil {
+		return
+	}
+
+	filter := AuditLogListFilter{
+		Actor:  c.String("actor"),
+		Action: c.String("action"),
+	}
+
+	for name, target := range map[string]**time.Time{"since": &filter.Since, "until": &filter.Until} {
+		if value := c.String(name); value != "" {
+			parsed, errParse := TimeParse(value)
+			if errParse != nil {
+				return exitError("Invalid %s value: %s", name, value)
+			}
+			*target = &parsed
+		}
+	}
+
+	var w io.Writer = os.Stdout
+	if filename := c.String("file"); filename != "" {
+		var file *os.File
+		if file, err = os.OpenFile(filename, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600); err != nil {
+			return exitError("Exportaudit returned error: %s", err)
+		}
+		defer file.Close()
+		w = file
+	}
+
+	if err = ExportAuditLog(cfg, w, filter); err != nil {
+		return exitError("Exportaudit returned error: %s", err)
+	}
+
+	return
+}
+
+/*
+RunTaskAction runs single task synchronously, task is locked so it does not run concurrently with scheduler
+*/
+func RunTaskAction(c *cli.Context) (err error) {
+	var cfg Config
+	if cfg, err = getconfig(c); err != nil {
+		return
+	}
+
+	scheduler := cfg.Scheduler().Scheduler()
+
+	name := c.String("name")
+	if name == "" {
+		for _, task := range scheduler.Tasks() {
+			schedule := task.Spec
+			if schedule == "" {
+				schedule = "manual"
+			}
+			fmt.Printf("%-24s %-16s %s\n", task.Name, schedule, task.Description)
+		}
+		return
+	}
+
+	var run *TaskRun
+	if run, err = scheduler.Run(cfg, name, TASK_TRIGGER_CLI, nil); run != nil {
+		NewAuditEntry(AUDIT_ACTION_TASK_RUN).
+			CLI().
+			Target(AUDIT_TARGET_TASK, run.ID, name).
+			Change("status", nil, run.Status).
+			Save(cfg)
+	}
+	if err != nil {
+		return exitError("Runtask returned error: %s", err)
+	}
+
+	fmt.Printf("Task %s finished in %vms\n", name, run.Duration)
+	return
+}
+
+/*
+PromoteAction promotes package version from one index to another (copy or move with the same files)
+*/
+func PromoteAction(c *cli.Context) (err error) {
+	var cfg Config
+	if cfg, err = getconfig(c); err != nil {
+		return
+	}
+
+	name, versionName := c.String("package"), c.String("version")
+	if name == "" || versionName == "" || c.String("to") == "" {
+		return exitError("Please provide --package, --version and --to")
+	}
+
+	from := c.String("from")
+
+	var source, target Index
+	if source, err = cfg.Manager().Index().GetByName(from); err != nil {
+		return exitError("Promote returned error: %s: %s", err, from)
+	}
+	if target, err = cfg.Manager().Index().GetByName(c.String("to")); err != nil {
+		return exitError("Promote returned error: %s: %s", err, c.String("to"))
+	}
+
+	pack := Package{}
+	if cfg.DB().First(&pack, "name = ? AND index_id = ?", name, source.ID).RecordNotFound() {
+		return exitError("Promote returned error: %s", ErrPackageNotFound)
+	}
+
+	version := PackageVersion{}
+	if cfg.DB().Preload("Files").First(&version, "package_id = ? AND version = ?", pack.ID, versionName).RecordNotFound() {
+		return exitError("Promote returned error: version %s not found", versionName)
+	}
+
+	var promotion PackagePromotion
+	if promotion, err = PromoteVersion(cfg, pack, version, target, nil, c.Bool("move")); err != nil {
+		return exitError("Promote returned error: %s", err)
+	}
+
+	NewAuditEntry(AUDIT_ACTION_VERSION_PROMOTE).
+		CLI().
+		Target(AUDIT_TARGET_VERSION, promotion.PackageVersionID, pack.Name+" "+version.Version).
+		Change("index", source.Name, target.Name).
+		Change("move", nil, promotion.Move).
+		Change("files", nil, len(version.Files)).
+		Save(cfg)
+
+	fmt.Printf("Promoted %s %s from %s to %s\n", pack.Name, version.Version, source.Name, target.Name)
 	return
 }
 
@@ -153,6 +294,163 @@ func init() {
 				return nil
 			},
 		},
+		{
+			Name:  "importusers",
+			Usage: "Import users from htpasswd file (bcrypt, pbkdf2 and argon2id hashes are supported)",
+			Flags: []cli.Flag{
+				configflag,
+				cli.StringFlag{
+					Name:  "file",
+					Usage: "htpasswd file with username:hash lines",
+				},
+				cli.StringFlag{
+					Name:  "permissions",
+					Value: "list,download",
+					Usage: "comma separated permissions of imported users",
+				},
+			},
+			Action: func(c *cli.Context) (err error) {
+				var cfg Config
+				if cfg, err = getconfig(c); err != nil {
+					return
+				}
+
+				permissions := []string{}
+				for _, permission := range strings.Split(c.String("permissions"), ",") {
+					if permission = strings.TrimSpace(permission); permission != "" {
+						permissions = append(permissions, permission)
+					}
+				}
+
+				var cmd Command
+				cmd = &ImportUsersCommand{Config: cfg, Filename: c.String("file"), Permissions: permissions}
+
+				if err = cmd.Run(); err != nil {
+					return exitError("Importusers returned error: %s", err)
+				}
+
+				return nil
+			},
+		},
+		{
+			Name:  "createindex",
+			Usage: "Create new index, packages of bases are visible through it",
+			Flags: []cli.Flag{
+				configflag,
+				cli.StringFlag{
+					Name:  "name",
+					Usage: "name of index (e.g. \"stable\" or \"team-a/dev\")",
+				},
+				cli.StringFlag{
+					Name:  "description",
+					Usage: "description of index",
+				},
+				cli.StringFlag{
+					Name:  "bases",
+					Usage: "comma separated names of inherited indexes in lookup order",
+				},
+				cli.BoolFlag{
+					Name:  "public",
+					Usage: "index is readable by all users",
+				},
+				cli.BoolFlag{
+					Name:  "open-upload",
+					Usage: "all users can upload to index",
+				},
+				cli.StringFlag{
+					Name:  "upload-policy",
+					Value: UPLOAD_POLICY_IMMUTABLE,
+					Usage: "whether uploaded files can be replaced (immutable, overwrite_dev or overwrite)",
+				},
+			},
+			Action: func(c *cli.Context) (err error) {
+				var cfg Config
+				if cfg, err = getconfig(c); err != nil {
+					return
+				}
+
+				bases := []string{}
+				for _, base := range strings.Split(c.String("bases"), ",") {
+					if base = strings.TrimSpace(base); base != "" {
+						bases = append(bases, base)
+					}
+				}
+
+				var cmd Command
+				cmd = &CreateIndexCommand{
+					Config:       cfg,
+					Name:         c.String("name"),
+					Description:  c.String("description"),
+					Bases:        bases,
+					Public:       c.Bool("public"),
+					OpenUpload:   c.Bool("open-upload"),
+					UploadPolicy: c.String("upload-policy"),
+				}
+
+				if err = cmd.Run(); err != nil {
+					return exitError("Createindex returned error: %s", err)
+				}
+
+				return nil
+			},
+		},
+		{
+			Name:  "promote",
+			Usage: "Promote package version to another index with the same files",
+			Flags: []cli.Flag{
+				configflag,
+				cli.StringFlag{
+					Name:  "package",
+					Usage: "name of package",
+				},
+				cli.StringFlag{
+					Name:  "version",
+					Usage: "version to promote",
+				},
+				cli.StringFlag{
+					Name:  "from",
+					Value: DEFAULT_INDEX,
+					Usage: "name of source index",
+				},
+				cli.StringFlag{
+					Name:  "to",
+					Usage: "name of target index",
+				},
+				cli.BoolFlag{
+					Name:  "move",
+					Usage: "move version instead of copying it",
+				},
+			},
+			Action: PromoteAction,
+		},
+		{
+			Name:  "exportaudit",
+			Usage: "Export audit log as json lines (one json object per line)",
+			Flags: []cli.Flag{
+				configflag,
+				cli.StringFlag{
+					Name:  "file",
+					Usage: "file to append entries to (default standard output)",
+				},
+				cli.StringFlag{
+					Name:  "since",
+					Usage: "export entries created at or after given time (RFC3339 or 2006-01-02)",
+				},
+				cli.StringFlag{
+					Name:  "until",
+					Usage: "export entries created before given time (RFC3339 or 2006-01-02)",
+				},
+				cli.StringFlag{
+					Name:  "actor",
+					Usage: "export entries of given username or user id",
+				},
+				cli.StringFlag{
+					Name:  "action",
+					Usage: "export entries with given action (\"user.*\" matches all user actions)",
+				},
+			},
+			Action: ExportAuditAction,
+		},
 		CommandMakeConfig,
 		CommandMigrate,
 		CommandRunserver,
@@ -168,8 +466,91 @@ func init() {
 					return
 				}
 
-				cfg.Manager().DownloadStats().Cleanup()
+				var deleted map[string]int64
+				if deleted, err = cfg.Manager().DownloadStats().Cleanup(); err != nil {
+					return exitError("Cleanupdownloadstats returned error: %s", err)
+				}
+				println("Deleted daily download stats records:", deleted["daily"])
+				println("Deleted weekly download stats records:", deleted["weekly"])
+				println("Deleted monthly download stats records:", deleted["monthly"])
+				println("Deleted detailed download stats records:", deleted["details"])
+
+				NewAuditEntry(AUDIT_ACTION_DOWNLOAD_STATS_CLEANUP).
+					CLI().
+					Target(AUDIT_TARGET_SYSTEM, "", "download stats").
+					Change("deleted_daily", nil, deleted["daily"]).
+					Change("deleted_weekly", nil, deleted["weekly"]).
+					Change("deleted_monthly", nil, deleted["monthly"]).
+					Change("deleted_details", nil, deleted["details"]).
+					Save(cfg)
 
+				return nil
+			},
+		},
+		{
+			Name:  "cleanupsessions",
+			Usage: "Deletes expired and revoked login sessions",
+			Flags: []cli.Flag{
+				configflag,
+			},
+			Action: func(c *cli.Context) (err error) {
+				var cfg Config
+				if cfg, err = getconfig(c); err != nil {
+					return
+				}
+
+				var deleted int64
+				if deleted, err = cfg.Manager().Session().Cleanup(); err != nil {
+					return exitError("Cleanupsessions returned error: %s", err)
+				}
+				println("Deleted sessions:", deleted)
+
+				NewAuditEntry(AUDIT_ACTION_SESSION_CLEANUP).
+					CLI().
+					Target(AUDIT_TARGET_SYSTEM, "", "sessions").
+					Change("deleted", nil, deleted).
+					Save(cfg)
+
+				return nil
+			},
+		},
+		{
+			Name:  "runtask",
+			Usage: "Runs background task immediately, without name lists available tasks",
+			Flags: []cli.Flag{
+				configflag,
+				cli.StringFlag{
+					Name:  "name",
+					Usage: "name of task to run",
+				},
+			},
+			Action: RunTaskAction,
+		},
+		{
+			Name:  "sendtestemail",
+			Usage: "Sends test email to verify notifications settings",
+			Flags: []cli.Flag{
+				configflag,
+				cli.StringFlag{
+					Name:  "to",
+					Usage: "recipient email address",
+				},
+			},
+			Action: func(c *cli.Context) (err error) {
+				var cfg Config
+				if cfg, err = getconfig(c); err != nil {
+					return
+				}
+
+				to := c.String("to")
+				if to == "" {
+					return exitError("Please provide recipient with --to")
+				}
+
+				if err = SendTestEmail(cfg, to); err != nil {
+					return exitError("Sendtestemail returned error: %s", err)
+				}
+				println("Test email sent to", to)
 
 				return nil
 			},
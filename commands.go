@@ -4,9 +4,12 @@ Set of commands that can be run from command line.
 package core
 
 import (
+	"bufio"
 	"errors"
 	"fmt"
+	"os"
 	"os/user"
+	"strings"
 
 	"github.com/asaskevich/govalidator"
 )
@@ -74,6 +77,12 @@ func (c *CreateAdminCommand) Run() error {
 		return errSave
 	}
 
+	NewAuditEntry(AUDIT_ACTION_USER_CREATE).
+		CLI().
+		Target(AUDIT_TARGET_USER, user.ID, user.Username).
+		Diff(nil, user).
+		Save(c.Config)
+
 	println("Admin has been succesfully created.")
 
 	return nil
@@ -124,7 +133,159 @@ func (c *ChangePasswordCommand) Run() (err error) {
 		return fmt.Errorf("Error when saving user to database: %s", err.Error())
 	}
 
+	// sign out all sessions
+	if err = c.Config.Manager().Session().RevokeAll(user); err != nil {
+		return fmt.Errorf("Error when signing out user sessions: %s", err.Error())
+	}
+
+	NewAuditEntry(AUDIT_ACTION_USER_PASSWORD).
+		CLI().
+		Target(AUDIT_TARGET_USER, user.ID, user.Username).
+		Save(c.Config)
+
+	NewNotification(NOTIFICATION_PASSWORD, "email/password_changed.tpl.html").
+		Send(c.Config, user)
+
 	println("Password successfully changed for user", user.Username, ".")
 
 	return
 }
+
+/*
+ImportUsersCommand imports users from htpasswd file (username:hash per line). Password hashes are stored as they are
+and rehashed with default hasher on first successful login. Existing users are skipped.
+*/
+type ImportUsersCommand struct {
+	Config      Config
+	Filename    string
+	Permissions []string
+}
+
+/*
+Run imports users from file
+*/
+func (i *ImportUsersCommand) Run() (err error) {
+	for _, permission := range i.Permissions {
+		if !StringListContains(AVAILABLE_USER_PERMISSIONS, permission) {
+			return fmt.Errorf("Unknown permission %s", permission)
+		}
+	}
+
+	var file *os.File
+	if file, err = os.Open(i.Filename); err != nil {
+		return
+	}
+	defer file.Close()
+
+	imported := 0
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		parts := strings.SplitN(line, ":", 2)
+		if len(parts) != 2 {
+			println("Skipping invalid line:", line)
+			continue
+		}
+
+		username, hash := strings.TrimSpace(parts[0]), strings.TrimSpace(parts[1])
+
+		if !i.Config.Auth().Hashers().IsKnown(hash) {
+			println("Skipping user", username, "unsupported password hash:", PasswordAlgorithm(hash))
+			continue
+		}
+
+		if i.Config.Manager().User().ExistsUsername(username) {
+			println("Skipping user", username, "already exists.")
+			continue
+		}
+
+		user := User{
+			Username: username,
+			Password: hash,
+			IsActive: true,
+		}
+		SetUserPermissions(&user, i.Permissions)
+
+		if err = i.Config.DB().Save(&user).Error; err != nil {
+			return fmt.Errorf("Error when saving user %s to database: %s", username, err.Error())
+		}
+
+		NewAuditEntry(AUDIT_ACTION_USER_IMPORT).
+			CLI().
+			Target(AUDIT_TARGET_USER, user.ID, user.Username).
+			Diff(nil, user).
+			Change("password_algorithm", nil, PasswordAlgorithm(hash)).
+			Save(i.Config)
+
+		imported++
+	}
+
+	if err = scanner.Err(); err != nil {
+		return
+	}
+
+	println("Imported users:", imported)
+
+	return
+}
+
+/*
+CreateIndexCommand creates new index, bases are names of inherited indexes in lookup order
+*/
+type CreateIndexCommand struct {
+	Config       Config
+	Name         string
+	Description  string
+	Bases        []string
+	Public       bool
+	OpenUpload   bool
+	UploadPolicy string
+}
+
+/*
+Run validates and creates index
+*/
+func (c *CreateIndexCommand) Run() (err error) {
+	serializer := IndexSerializer{
+		Name:         c.Name,
+		Description:  c.Description,
+		Public:       c.Public,
+		OpenUpload:   c.OpenUpload,
+		UploadPolicy: c.UploadPolicy,
+		Bases:        c.Bases,
+	}
+
+	if vr := serializer.Validate(c.Config, 0); !vr.IsValid() {
+		var body []byte
+		if body, err = vr.MarshalJSON(); err != nil {
+			return
+		}
+		return errors.New(string(body))
+	}
+
+	index := Index{}
+	serializer.UpdateIndex(&index)
+
+	if err = c.Config.DB().Create(&index).Error; err != nil {
+		return
+	}
+
+	if err = c.Config.Manager().Index().SetBases(index, serializer.Bases); err != nil {
+		return
+	}
+
+	NewAuditEntry(AUDIT_ACTION_INDEX_CREATE).
+		CLI().
+		Target(AUDIT_TARGET_INDEX, index.ID, index.Name).
+		Diff(nil, index).
+		Change("bases", nil, serializer.Bases).
+		Save(c.Config)
+
+	println("Created index", index.Name)
+
+	return
+}
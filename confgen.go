@@ -14,6 +14,8 @@ const CONF_TEMPLATE = `
 [core]
 listen = '{{.listen}}'
 secret_key = '{{.secret_key}}'
+# ip addresses (or cidr ranges) of reverse proxies that set X-Forwarded-For or X-Real-IP
+trusted_proxies = []
 
 [database]
 driver = '{{.driver}}'
@@ -23,8 +25,22 @@ dsn = '{{.dsn}}'
 directory = '{{.packages_dir}}'
 
 [download_stats]
+archive_daily = 30
 archive_weekly = 4
 archive_monthly = 4
+archive_details = 90
+flush_interval = 10
+max_pending = 1000
+
+[metrics]
+enabled = true
+token = ""
+
+[logging]
+level = "info"
+format = "text"
+output = "stdout"
+access_log = ""
 `
 
 var tpl *template.Template
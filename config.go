@@ -12,10 +12,15 @@ import (
 
 	"bytes"
 
+	"io"
 	"io/ioutil"
 
 	"fmt"
 
+	"strings"
+
+	"time"
+
 	gbht "github.com/arschles/go-bindata-html-template"
 	"github.com/gorilla/mux"
 	"github.com/jinzhu/gorm"
@@ -34,6 +39,9 @@ type Config interface {
 	// Core returns CoreConfig
 	Core() CoreConfig
 
+	// Auth returns configuration of authentication backends
+	Auth() AuthConfig
+
 	// getter for database connection
 	DB() *gorm.DB
 
@@ -66,6 +74,21 @@ type Config interface {
 
 	// Manager returns interface that supplies multiple db managers
 	Manager(tx ...*gorm.DB) ManagerConfig
+
+	// Webhooks returns configuration of webhook deliveries
+	Webhooks() WebhooksConfig
+
+	// Notifications returns configuration of email notifications
+	Notifications() NotificationsConfig
+
+	// Scheduler returns configuration of background tasks
+	Scheduler() SchedulerConfig
+
+	// Metrics returns configuration of metrics endpoint
+	Metrics() MetricsConfig
+
+	// Logging returns configuration of application and access logs
+	Logging() LoggingConfig
 }
 
 type CoreConfig interface {
@@ -77,18 +100,207 @@ type CoreConfig interface {
 
 	// SecretKey returns secret key for hashing and crypto
 	SecretKey() string
+
+	// TrustedProxies returns reverse proxies whose X-Forwarded-For and X-Real-IP headers are trusted
+	TrustedProxies() TrustedProxies
+}
+
+type AuthConfig interface {
+	// Backend returns authentication backend that tries all configured backends in order
+	Backend() AuthBackend
+
+	// LDAP returns configuration for ldap authentication backend
+	LDAP() LDAPConfig
+
+	// OIDC returns configuration for OpenID Connect login
+	OIDC() OIDCConfig
+
+	// Throttle returns login throttle that counts failed logins
+	Throttle() *LoginThrottle
+
+	// CredentialCache returns cache of verified credentials
+	CredentialCache() *CredentialCache
+
+	// Hashers returns registry of password hashers
+	Hashers() *PasswordHashers
+}
+
+type LDAPConfig interface {
+	// URL returns address of ldap server (ldap:// or ldaps://)
+	URL() string
+
+	// StartTLS returns whether connection should be upgraded with StartTLS
+	StartTLS() bool
+
+	// InsecureSkipVerify returns whether server certificate verification is disabled
+	InsecureSkipVerify() bool
+
+	// BindDN returns dn of service account used to search users
+	BindDN() string
+
+	// BindPassword returns password of service account
+	BindPassword() string
+
+	// BaseDN returns base dn for user search
+	BaseDN() string
+
+	// UserFilter returns search filter, {username} is replaced by escaped username
+	UserFilter() string
+
+	// Attribute returns ldap attribute name for given user field (email, first_name, last_name, groups)
+	Attribute(field string) string
+
+	// RequireGroup returns whether user must be member of at least one mapped group
+	RequireGroup() bool
+
+	// Permissions returns permissions granted by given group memberships
+	Permissions(groups []string) []string
+}
+
+type OIDCConfig interface {
+	// Enabled returns whether OpenID Connect login is enabled
+	Enabled() bool
+
+	// Issuer returns issuer url, discovery document is read from {issuer}/.well-known/openid-configuration
+	Issuer() string
+
+	// ClientID returns client id registered at provider
+	ClientID() string
+
+	// ClientSecret returns client secret registered at provider
+	ClientSecret() string
+
+	// RedirectURL returns callback url registered at provider
+	RedirectURL() string
+
+	// Scopes returns requested scopes
+	Scopes() []string
+
+	// Claim returns id token claim name for given user field (username, email, first_name, last_name, groups)
+	Claim(field string) string
+
+	// RequireGroup returns whether user must be member of at least one mapped group
+	RequireGroup() bool
+
+	// HasGroupMapping returns whether any group is mapped to permissions
+	HasGroupMapping() bool
+
+	// Permissions returns permissions granted by given group memberships
+	Permissions(groups []string) []string
 }
 
 type DownloadStatsConfig interface {
 
+	// Returns how many days we should store daily statistics
+	ArchiveDaily() int
+
 	// Returns how many weeks we should store weekly statistics
 	ArchiveWeekly() int
 
 	// Returns how many months we should store monthly statistics
 	ArchiveMonthly() int
+
+	// Returns how many days we should store detailed (per file, user and installer) statistics
+	ArchiveDetails() int
+
+	// Collector returns collector that buffers downloads and writes them in batches
+	Collector() *DownloadStatsCollector
+}
+
+type WebhooksConfig interface {
+	// Timeout returns timeout of single delivery request
+	Timeout() time.Duration
+
+	// MaxAttempts returns number of attempts after which delivery is marked as failed
+	MaxAttempts() int
+
+	// RetryDelay returns delay before first retry, delay doubles with every next attempt
+	RetryDelay() time.Duration
+
+	// MaxRetryDelay returns maximum delay between attempts
+	MaxRetryDelay() time.Duration
+
+	// PollInterval returns how often dispatcher checks for pending deliveries
+	PollInterval() time.Duration
+
+	// Dispatcher returns dispatcher that sends deliveries
+	Dispatcher() *WebhookDispatcher
+}
+
+type NotificationsConfig interface {
+	// Enabled returns whether email notifications are sent
+	Enabled() bool
+
+	// From returns sender address of notifications
+	From() string
+
+	// SMTP returns configuration of smtp server
+	SMTP() SMTPConfig
+
+	// Notifier returns notifier that sends queued emails
+	Notifier() *Notifier
+}
+
+type SMTPConfig interface {
+	// Host returns hostname of smtp server
+	Host() string
+
+	// Port returns port of smtp server
+	Port() int
+
+	// TLS returns tls mode (none, starttls, tls)
+	TLS() string
+
+	// InsecureSkipVerify returns whether server certificate verification is disabled
+	InsecureSkipVerify() bool
+
+	// Username returns username for smtp authentication, empty means no authentication
+	Username() string
+
+	// Password returns password for smtp authentication
+	Password() string
+}
+
+type SchedulerConfig interface {
+	// Enabled returns whether scheduled tasks are run by server
+	Enabled() bool
+
+	// LockTTL returns maximum expected duration of task run
+	LockTTL() time.Duration
+
+	// Scheduler returns scheduler with registered tasks
+	Scheduler() *Scheduler
+}
+
+type LoggingConfig interface {
+	// Level returns minimal level of logged messages
+	Level() string
+
+	// Format returns format of application log (text or json)
+	Format() string
+
+	// Output returns where application log is written (stdout, stderr or filename)
+	Output() string
+
+	// AccessLog returns writer of access log in combined format, nil when access log is disabled
+	AccessLog() io.Writer
+}
+
+type MetricsConfig interface {
+	// Enabled returns whether metrics endpoint is available
+	Enabled() bool
+
+	// Token returns token required by metrics endpoint (blank means no authentication)
+	Token() string
+
+	// Registry returns registry of collected metrics
+	Registry() *Metrics
 }
 
 type ManagerConfig interface {
+	// AuditLogManager returns AuditLogManager instance to record and query audit log
+	AuditLog(tx ...*gorm.DB) *AuditLogManager
+
 	// ClassifierManager returns new ClassifierManager instance
 	Classifier(tx ...*gorm.DB) *ClassifierManager
 
@@ -98,9 +310,15 @@ type ManagerConfig interface {
 	// FeatureManager
 	Feature(tx ...*gorm.DB) *FeatureManager
 
+	// IndexManager returns IndexManager instance to handle indexes and their access lists
+	Index(tx ...*gorm.DB) *IndexManager
+
 	// LicenseManager returns new LicenseManager instance
 	License(tx ...*gorm.DB) *LicenseManager
 
+	// NotificationManager returns NotificationManager instance to handle notification preferences
+	Notification(tx ...*gorm.DB) *NotificationManager
+
 	// PackageManager returns new PackageManager instance
 	Package(tx ...*gorm.DB) *PackageManager
 
@@ -113,8 +331,20 @@ type ManagerConfig interface {
 	// PlatformManager returns new PlatformManager instance
 	Platform(tx ...*gorm.DB) *PlatformManager
 
+	// SearchManager returns SearchManager instance to search packages
+	Search(tx ...*gorm.DB) *SearchManager
+
+	// TaskManager returns TaskManager instance to handle task locks and run history
+	Task(tx ...*gorm.DB) *TaskManager
+
+	// SessionManager returns SessionManager instance to handle login sessions
+	Session(tx ...*gorm.DB) *SessionManager
+
 	// UserManager returns UserManager instance to query user data
 	User(tx ...*gorm.DB) *UserManager
+
+	// WebhookManager returns WebhookManager instance to handle webhooks and deliveries
+	Webhook(tx ...*gorm.DB) *WebhookManager
 }
 
 type PackagesConfig interface {
@@ -157,15 +387,6 @@ func NewConfig(tree *toml.TomlTree) (result Config, err error) {
 	}
 
 	dsn := tree.GetDefault("database.dsn", "gopypi:gopypy@/gopypi").(string)
-	packagesDir := tree.GetDefault("packages.directory", ".packages").(string)
-	secret := tree.GetDefault("core.secret_key", "").(string)
-	listen := tree.GetDefault("core.listen", "0.0.0.0:9700").(string)
-	host := tree.GetDefault("core.host", fmt.Sprintf("http://%v", listen)).(string)
-
-	if !path.IsAbs(packagesDir) {
-		cwd, _ := os.Getwd()
-		packagesDir = path.Join(cwd, packagesDir)
-	}
 
 	var db *gorm.DB
 	if db, err = gorm.Open(driver, dsn); err != nil {
@@ -181,24 +402,119 @@ func NewConfig(tree *toml.TomlTree) (result Config, err error) {
 	// setup logging
 	//db.LogMode(true)
 
+	if result, err = newConfig(tree, db); err != nil {
+		db.Close()
+	}
+
+	return
+}
+
+/*
+newConfig returns config with given (already set up) database
+*/
+func newConfig(tree *toml.TomlTree, db *gorm.DB) (result Config, err error) {
+	packagesDir := tree.GetDefault("packages.directory", ".packages").(string)
+	secret := tree.GetDefault("core.secret_key", "").(string)
+	listen := tree.GetDefault("core.listen", "0.0.0.0:9700").(string)
+	host := tree.GetDefault("core.host", fmt.Sprintf("http://%v", listen)).(string)
+
+	var proxies TrustedProxies
+	if proxies, err = ParseTrustedProxies(tomlGetStringList(tree, "core.trusted_proxies", nil)); err != nil {
+		return
+	}
+
+	if !path.IsAbs(packagesDir) {
+		cwd, _ := os.Getwd()
+		packagesDir = path.Join(cwd, packagesDir)
+	}
+
+	var (
+		lc     *loggingConfig
+		logger zap.Logger
+	)
+	if lc, logger, err = newLoggingConfig(tree); err != nil {
+		return
+	}
+
 	router := mux.NewRouter().StrictSlash(true)
 
 	dsc := &downloadStatsConfig{
-		archiveWeekly:  tree.GetDefault("download_stats.archive_weekly", 4).(int),
-		archiveMonthly: tree.GetDefault("download_stats.archive_monthly", 4).(int),
+		archiveDaily:   tomlGetInt(tree, "download_stats.archive_daily", DOWNLOAD_STATS_ARCHIVE_DAILY),
+		archiveWeekly:  tomlGetInt(tree, "download_stats.archive_weekly", 4),
+		archiveMonthly: tomlGetInt(tree, "download_stats.archive_monthly", 4),
+		archiveDetails: tomlGetInt(tree, "download_stats.archive_details", DOWNLOAD_STATS_ARCHIVE_DETAILS),
+		collector: NewDownloadStatsCollector(
+			time.Duration(tomlGetInt(tree, "download_stats.flush_interval", DOWNLOAD_STATS_FLUSH_INTERVAL))*time.Second,
+			tomlGetInt(tree, "download_stats.max_pending", DOWNLOAD_STATS_MAX_PENDING),
+		),
+	}
+
+	var ac *authConfig
+	if ac, err = newAuthConfig(tree, host, secret); err != nil {
+		return
+	}
+
+	wc := &webhooksConfig{
+		timeout:       time.Duration(tomlGetInt(tree, "webhooks.timeout", WEBHOOK_TIMEOUT)) * time.Second,
+		maxAttempts:   tomlGetInt(tree, "webhooks.max_attempts", WEBHOOK_MAX_ATTEMPTS),
+		retryDelay:    time.Duration(tomlGetInt(tree, "webhooks.retry_delay", WEBHOOK_RETRY_DELAY)) * time.Second,
+		maxRetryDelay: time.Duration(tomlGetInt(tree, "webhooks.max_retry_delay", WEBHOOK_MAX_RETRY_DELAY)) * time.Second,
+		pollInterval:  time.Duration(tomlGetInt(tree, "webhooks.poll_interval", WEBHOOK_POLL_INTERVAL)) * time.Second,
+	}
+	wc.dispatcher = NewWebhookDispatcher(wc.timeout)
+
+	nc := &notificationsConfig{
+		enabled: tomlGetBool(tree, "notifications.enabled", false),
+		from:    tomlGetString(tree, "notifications.from", "gopypi@localhost"),
+		smtp: &smtpConfig{
+			host:               tomlGetString(tree, "notifications.smtp.host", "localhost"),
+			port:               tomlGetInt(tree, "notifications.smtp.port", SMTP_PORT),
+			tls:                tomlGetString(tree, "notifications.smtp.tls", SMTP_TLS_NONE),
+			insecureSkipVerify: tomlGetBool(tree, "notifications.smtp.insecure_skip_verify", false),
+			username:           tomlGetString(tree, "notifications.smtp.username", ""),
+			password:           tomlGetString(tree, "notifications.smtp.password", ""),
+		},
+	}
+	if !StringListContains(AVAILABLE_SMTP_TLS_MODES, nc.smtp.tls) {
+		return nil, ErrUnknownSMTPTLS
+	}
+	nc.notifier = NewNotifier(tomlGetInt(tree, "notifications.queue_size", NOTIFICATIONS_QUEUE_SIZE))
+
+	sc := &schedulerConfig{
+		enabled: tomlGetBool(tree, "scheduler.enabled", true),
+		lockTTL: time.Duration(tomlGetInt(tree, "scheduler.lock_ttl", SCHEDULER_LOCK_TTL)) * time.Second,
+	}
+	sc.scheduler = NewScheduler(sc.lockTTL)
+	if err = RegisterTasks(sc.scheduler, func(name, def string) string {
+		return tomlGetString(tree, "scheduler.tasks."+name, def)
+	}); err != nil {
+		return
+	}
+
+	mc := &metricsConfig{
+		enabled:  tomlGetBool(tree, "metrics.enabled", true),
+		token:    tomlGetString(tree, "metrics.token", ""),
+		registry: NewMetrics(),
 	}
 
 	// config implementation
-	result = &config{
+	c := &config{
+		ac:          ac,
 		db:          db,
 		dsc:         dsc,
 		host:        host,
+		proxies:     proxies,
 		listen:      listen,
-		logger:      zap.New(zap.NewTextEncoder(), zap.DebugLevel),
+		logger:      logger,
 		packagesDir: packagesDir,
 		secret:      secret,
 		router:      router,
 		tplasset:    templates.Asset,
+		wc:          wc,
+		nc:          nc,
+		sc:          sc,
+		mc:          mc,
+		lc:          lc,
 		funcmap: gbht.FuncMap{
 			// add url reverse functionality
 			"reverse": func(name string, pairs ...string) (result string) {
@@ -214,6 +530,11 @@ func NewConfig(tree *toml.TomlTree) (result Config, err error) {
 		},
 	}
 
+	// auth backends need access to database
+	ac.config = c
+
+	result = c
+
 	return
 }
 
@@ -221,16 +542,23 @@ func NewConfig(tree *toml.TomlTree) (result Config, err error) {
 Config implementation
 */
 type config struct {
+	ac          *authConfig
 	logger      zap.Logger
 	db          *gorm.DB
 	funcmap     gbht.FuncMap
 	packagesDir string
 	listen      string
 	host        string
+	proxies     TrustedProxies
 	router      *mux.Router
 	secret      string
 	tplasset    func(name string) ([]byte, error)
 	dsc         *downloadStatsConfig
+	wc          *webhooksConfig
+	nc          *notificationsConfig
+	sc          *schedulerConfig
+	mc          *metricsConfig
+	lc          *loggingConfig
 }
 
 func (c *config) Core() CoreConfig {
@@ -239,6 +567,13 @@ func (c *config) Core() CoreConfig {
 	}
 }
 
+/*
+Auth returns authentication configuration
+*/
+func (c *config) Auth() AuthConfig {
+	return c.ac
+}
+
 /*
 Logger returns zap.Logger instance
 */
@@ -337,6 +672,41 @@ func (c *config) DownloadStats() DownloadStatsConfig {
 	return c.dsc
 }
 
+/*
+Webhooks returns webhooks configuration
+*/
+func (c *config) Webhooks() WebhooksConfig {
+	return c.wc
+}
+
+/*
+Notifications returns email notifications configuration
+*/
+func (c *config) Notifications() NotificationsConfig {
+	return c.nc
+}
+
+/*
+Scheduler returns scheduler configuration
+*/
+func (c *config) Scheduler() SchedulerConfig {
+	return c.sc
+}
+
+/*
+Logging returns logging configuration
+*/
+func (c *config) Logging() LoggingConfig {
+	return c.lc
+}
+
+/*
+Metrics returns metrics configuration
+*/
+func (c *config) Metrics() MetricsConfig {
+	return c.mc
+}
+
 func (c *config) Manager(tx ...*gorm.DB) ManagerConfig {
 	db := c.DB()
 	if len(tx) > 0 {
@@ -367,6 +737,48 @@ func (m *managerconfig) getDB(tx ...*gorm.DB) *gorm.DB {
 	}
 }
 
+/*
+AuditLog returns AuditLogManager instance
+*/
+func (m *managerconfig) AuditLog(tx ...*gorm.DB) *AuditLogManager {
+	return &AuditLogManager{DB: m.getDB(tx...)}
+}
+
+/*
+Notification returns NotificationManager instance
+*/
+func (m *managerconfig) Notification(tx ...*gorm.DB) *NotificationManager {
+	return &NotificationManager{DB: m.getDB(tx...)}
+}
+
+/*
+Task returns TaskManager instance
+*/
+func (m *managerconfig) Task(tx ...*gorm.DB) *TaskManager {
+	return &TaskManager{DB: m.getDB(tx...)}
+}
+
+/*
+Search returns SearchManager instance
+*/
+func (m *managerconfig) Search(tx ...*gorm.DB) *SearchManager {
+	return &SearchManager{DB: m.getDB(tx...)}
+}
+
+/*
+Index returns IndexManager instance
+*/
+func (m *managerconfig) Index(tx ...*gorm.DB) *IndexManager {
+	return &IndexManager{DB: m.getDB(tx...)}
+}
+
+/*
+Webhook returns WebhookManager instance
+*/
+func (m *managerconfig) Webhook(tx ...*gorm.DB) *WebhookManager {
+	return &WebhookManager{DB: m.getDB(tx...)}
+}
+
 /*
 Classifier returns ClassifierManager instance
 */
@@ -440,6 +852,13 @@ func (m *managerconfig) DownloadStats(tx ...*gorm.DB) *DownloadStatsManager {
 	}
 }
 
+/*
+Session returns SessionManager instance
+*/
+func (m *managerconfig) Session(tx ...*gorm.DB) *SessionManager {
+	return &SessionManager{DB: m.getDB(tx...)}
+}
+
 /*
 User returns UserManager instance
 */
@@ -447,6 +866,7 @@ func (m *managerconfig) User(tx ...*gorm.DB) *UserManager {
 	return &UserManager{
 		DB:        m.getDB(tx...),
 		SecretKey: m.config.SecretKey(),
+		Hashers:   m.config.Auth().Hashers(),
 	}
 }
 
@@ -454,8 +874,15 @@ func (m *managerconfig) User(tx ...*gorm.DB) *UserManager {
 downloadStatsConfig
 */
 type downloadStatsConfig struct {
+	archiveDaily   int
 	archiveWeekly  int
 	archiveMonthly int
+	archiveDetails int
+	collector      *DownloadStatsCollector
+}
+
+func (d *downloadStatsConfig) ArchiveDaily() int {
+	return d.archiveDaily
 }
 
 func (d *downloadStatsConfig) ArchiveWeekly() int {
@@ -466,6 +893,233 @@ func (d *downloadStatsConfig) ArchiveMonthly() int {
 	return d.archiveMonthly
 }
 
+func (d *downloadStatsConfig) ArchiveDetails() int {
+	return d.archiveDetails
+}
+
+func (d *downloadStatsConfig) Collector() *DownloadStatsCollector {
+	return d.collector
+}
+
+/*
+webhooksConfig implements WebhooksConfig
+*/
+type webhooksConfig struct {
+	timeout       time.Duration
+	maxAttempts   int
+	retryDelay    time.Duration
+	maxRetryDelay time.Duration
+	pollInterval  time.Duration
+	dispatcher    *WebhookDispatcher
+}
+
+func (w *webhooksConfig) Timeout() time.Duration {
+	return w.timeout
+}
+
+func (w *webhooksConfig) MaxAttempts() int {
+	return w.maxAttempts
+}
+
+func (w *webhooksConfig) RetryDelay() time.Duration {
+	return w.retryDelay
+}
+
+func (w *webhooksConfig) MaxRetryDelay() time.Duration {
+	return w.maxRetryDelay
+}
+
+func (w *webhooksConfig) PollInterval() time.Duration {
+	return w.pollInterval
+}
+
+func (w *webhooksConfig) Dispatcher() *WebhookDispatcher {
+	return w.dispatcher
+}
+
+/*
+notificationsConfig implements NotificationsConfig
+*/
+type notificationsConfig struct {
+	enabled  bool
+	from     string
+	smtp     *smtpConfig
+	notifier *Notifier
+}
+
+func (n *notificationsConfig) Enabled() bool {
+	return n.enabled
+}
+
+func (n *notificationsConfig) From() string {
+	return n.from
+}
+
+func (n *notificationsConfig) SMTP() SMTPConfig {
+	return n.smtp
+}
+
+func (n *notificationsConfig) Notifier() *Notifier {
+	return n.notifier
+}
+
+/*
+schedulerConfig implements SchedulerConfig
+*/
+type schedulerConfig struct {
+	enabled   bool
+	lockTTL   time.Duration
+	scheduler *Scheduler
+}
+
+func (s *schedulerConfig) Enabled() bool {
+	return s.enabled
+}
+
+func (s *schedulerConfig) LockTTL() time.Duration {
+	return s.lockTTL
+}
+
+func (s *schedulerConfig) Scheduler() *Scheduler {
+	return s.scheduler
+}
+
+/*
+newLoggingConfig reads logging configuration and returns application logger
+*/
+func newLoggingConfig(tree *toml.TomlTree) (lc *loggingConfig, logger zap.Logger, err error) {
+	lc = &loggingConfig{
+		level:  tomlGetString(tree, "logging.level", "debug"),
+		format: tomlGetString(tree, "logging.format", LOG_FORMAT_TEXT),
+		output: tomlGetString(tree, "logging.output", LOG_OUTPUT_STDOUT),
+	}
+
+	var level zap.Level
+	if level.UnmarshalText([]byte(lc.level)) != nil {
+		return nil, nil, ErrUnknownLogLevel
+	}
+
+	var encoder zap.Encoder
+	switch lc.format {
+	case LOG_FORMAT_TEXT:
+		encoder = zap.NewTextEncoder()
+	case LOG_FORMAT_JSON:
+		encoder = zap.NewJSONEncoder()
+	default:
+		return nil, nil, ErrUnknownLogFormat
+	}
+
+	var output io.Writer
+	if output, err = openLogOutput(lc.output); err != nil {
+		return
+	}
+
+	if access := tomlGetString(tree, "logging.access_log", ""); access != "" {
+		if lc.accessLog, err = openLogOutput(access); err != nil {
+			return
+		}
+	}
+
+	logger = zap.New(encoder, level, zap.Output(zap.AddSync(output)))
+	return
+}
+
+/*
+openLogOutput returns stdout, stderr or file opened for appending
+*/
+func openLogOutput(output string) (io.Writer, error) {
+	switch output {
+	case LOG_OUTPUT_STDOUT:
+		return os.Stdout, nil
+	case LOG_OUTPUT_STDERR:
+		return os.Stderr, nil
+	}
+	return os.OpenFile(output, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+}
+
+/*
+loggingConfig implements LoggingConfig
+*/
+type loggingConfig struct {
+	level     string
+	format    string
+	output    string
+	accessLog io.Writer
+}
+
+func (l *loggingConfig) Level() string {
+	return l.level
+}
+
+func (l *loggingConfig) Format() string {
+	return l.format
+}
+
+func (l *loggingConfig) Output() string {
+	return l.output
+}
+
+func (l *loggingConfig) AccessLog() io.Writer {
+	return l.accessLog
+}
+
+/*
+metricsConfig implements MetricsConfig
+*/
+type metricsConfig struct {
+	enabled  bool
+	token    string
+	registry *Metrics
+}
+
+func (m *metricsConfig) Enabled() bool {
+	return m.enabled
+}
+
+func (m *metricsConfig) Token() string {
+	return m.token
+}
+
+func (m *metricsConfig) Registry() *Metrics {
+	return m.registry
+}
+
+/*
+smtpConfig implements SMTPConfig
+*/
+type smtpConfig struct {
+	host               string
+	port               int
+	tls                string
+	insecureSkipVerify bool
+	username           string
+	password           string
+}
+
+func (s *smtpConfig) Host() string {
+	return s.host
+}
+
+func (s *smtpConfig) Port() int {
+	return s.port
+}
+
+func (s *smtpConfig) TLS() string {
+	return s.tls
+}
+
+func (s *smtpConfig) InsecureSkipVerify() bool {
+	return s.insecureSkipVerify
+}
+
+func (s *smtpConfig) Username() string {
+	return s.username
+}
+
+func (s *smtpConfig) Password() string {
+	return s.password
+}
+
 type coreconfig struct {
 	config *config
 }
@@ -482,6 +1136,10 @@ func (c *coreconfig) SecretKey() string {
 	return c.config.secret
 }
 
+func (c *coreconfig) TrustedProxies() TrustedProxies {
+	return c.config.proxies
+}
+
 type packagesconfig struct {
 	config *config
 }
@@ -496,3 +1154,337 @@ func (p *packagesconfig) JoinDirectory(parts ...string) string {
 	all = append(all, parts...)
 	return path.Join(all...)
 }
+
+/*
+newAuthConfig reads authentication configuration from toml tree
+*/
+func newAuthConfig(tree *toml.TomlTree, host, secret string) (result *authConfig, err error) {
+	result = &authConfig{
+		backends: tomlGetStringList(tree, "auth.backends", []string{AUTH_BACKEND_LOCAL}),
+		ldap: &ldapConfig{
+			url:                tomlGetString(tree, "auth.ldap.url", "ldap://localhost:389"),
+			startTLS:           tomlGetBool(tree, "auth.ldap.start_tls", false),
+			insecureSkipVerify: tomlGetBool(tree, "auth.ldap.insecure_skip_verify", false),
+			bindDN:             tomlGetString(tree, "auth.ldap.bind_dn", ""),
+			bindPassword:       tomlGetString(tree, "auth.ldap.bind_password", ""),
+			baseDN:             tomlGetString(tree, "auth.ldap.base_dn", ""),
+			userFilter:         tomlGetString(tree, "auth.ldap.user_filter", "(uid={username})"),
+			requireGroup:       tomlGetBool(tree, "auth.ldap.require_group", true),
+			attributes: map[string]string{
+				"email":      tomlGetString(tree, "auth.ldap.email_attribute", "mail"),
+				"first_name": tomlGetString(tree, "auth.ldap.first_name_attribute", "givenName"),
+				"last_name":  tomlGetString(tree, "auth.ldap.last_name_attribute", "sn"),
+				"groups":     tomlGetString(tree, "auth.ldap.group_attribute", "memberOf"),
+			},
+		},
+		oidc: &oidcConfig{
+			enabled:      tomlGetBool(tree, "auth.oidc.enabled", false),
+			issuer:       strings.TrimRight(tomlGetString(tree, "auth.oidc.issuer", ""), "/"),
+			clientID:     tomlGetString(tree, "auth.oidc.client_id", ""),
+			clientSecret: tomlGetString(tree, "auth.oidc.client_secret", ""),
+			redirectURL:  tomlGetString(tree, "auth.oidc.redirect_url", strings.TrimRight(host, "/")+"/api/oidc/callback/"),
+			scopes:       tomlGetStringList(tree, "auth.oidc.scopes", []string{"openid", "profile", "email"}),
+			requireGroup: tomlGetBool(tree, "auth.oidc.require_group", false),
+			claims: map[string]string{
+				"username":   tomlGetString(tree, "auth.oidc.username_claim", "preferred_username"),
+				"email":      tomlGetString(tree, "auth.oidc.email_claim", "email"),
+				"first_name": tomlGetString(tree, "auth.oidc.first_name_claim", "given_name"),
+				"last_name":  tomlGetString(tree, "auth.oidc.last_name_claim", "family_name"),
+				"groups":     tomlGetString(tree, "auth.oidc.groups_claim", "groups"),
+			},
+		},
+	}
+
+	for _, backend := range result.backends {
+		if !StringListContains(AVAILABLE_AUTH_BACKENDS, backend) {
+			return nil, ErrUnknownAuthBackend
+		}
+	}
+
+	// group mappings are arrays of tables [[auth.ldap.group]] and [[auth.oidc.group]]
+	if result.ldap.groupPermissions, err = newGroupPermissions(tree, "auth.ldap.group", "dn"); err != nil {
+		return
+	}
+	if result.oidc.groupPermissions, err = newGroupPermissions(tree, "auth.oidc.group", "name"); err != nil {
+		return
+	}
+
+	if result.oidc.enabled && (result.oidc.issuer == "" || result.oidc.clientID == "") {
+		return nil, ErrOIDCNotConfigured
+	}
+
+	// login throttling, durations are in seconds
+	result.throttle = NewLoginThrottle(
+		tomlGetInt(tree, "auth.throttle.max_failures", THROTTLE_MAX_FAILURES),
+		tomlGetInt(tree, "auth.throttle.ip_max_failures", THROTTLE_IP_MAX_FAILURES),
+		time.Duration(tomlGetInt(tree, "auth.throttle.lockout", THROTTLE_LOCKOUT))*time.Second,
+		time.Duration(tomlGetInt(tree, "auth.throttle.max_lockout", THROTTLE_MAX_LOCKOUT))*time.Second,
+		time.Duration(tomlGetInt(tree, "auth.throttle.window", THROTTLE_WINDOW))*time.Second,
+	)
+
+	// password hashers, new passwords are hashed with configured hasher
+	if result.hashers, err = NewPasswordHashers(secret, tomlGetString(tree, "auth.password_hasher", PASSWORD_HASHER_ARGON2ID)); err != nil {
+		return nil, err
+	}
+
+	// cache of verified credentials
+	result.cache = NewCredentialCache(
+		secret,
+		time.Duration(tomlGetInt(tree, "auth.throttle.credential_cache", THROTTLE_CREDENTIAL_CACHE))*time.Second,
+	)
+
+	return
+}
+
+/*
+groupPermissions maps group names to permissions
+*/
+type groupPermissions map[string][]string
+
+/*
+newGroupPermissions reads group mapping from array of tables with given key field and permissions
+*/
+func newGroupPermissions(tree *toml.TomlTree, key string, field string) (result groupPermissions, err error) {
+	result = groupPermissions{}
+
+	groups, ok := tree.Get(key).([]*toml.TomlTree)
+	if !ok {
+		return
+	}
+
+	for _, group := range groups {
+		name := strings.ToLower(strings.TrimSpace(tomlGetString(group, field, "")))
+		permissions := tomlGetStringList(group, "permissions", []string{})
+		for _, permission := range permissions {
+			if !StringListContains(AVAILABLE_USER_PERMISSIONS, permission) {
+				return nil, fmt.Errorf("unknown permission %v for group %v", permission, name)
+			}
+		}
+		result[name] = append(result[name], permissions...)
+	}
+
+	return
+}
+
+/*
+HasGroupMapping returns whether any group is mapped
+*/
+func (g groupPermissions) HasGroupMapping() bool {
+	return len(g) > 0
+}
+
+/*
+Permissions returns unique permissions for all groups (group names are compared case insensitive)
+*/
+func (g groupPermissions) Permissions(groups []string) (result []string) {
+	result = []string{}
+	for _, group := range groups {
+		for _, permission := range g[strings.ToLower(strings.TrimSpace(group))] {
+			if !StringListContains(result, permission) {
+				result = append(result, permission)
+			}
+		}
+	}
+	return
+}
+
+/*
+authConfig implements AuthConfig
+*/
+type authConfig struct {
+	config   *config
+	backends []string
+	ldap     *ldapConfig
+	oidc     *oidcConfig
+	throttle *LoginThrottle
+	cache    *CredentialCache
+	hashers  *PasswordHashers
+}
+
+/*
+Hashers returns registry of password hashers
+*/
+func (a *authConfig) Hashers() *PasswordHashers {
+	return a.hashers
+}
+
+/*
+Throttle returns login throttle
+*/
+func (a *authConfig) Throttle() *LoginThrottle {
+	return a.throttle
+}
+
+/*
+CredentialCache returns cache of verified credentials
+*/
+func (a *authConfig) CredentialCache() *CredentialCache {
+	return a.cache
+}
+
+/*
+Backend returns chain of all configured backends
+*/
+func (a *authConfig) Backend() AuthBackend {
+	result := authBackendChain{}
+	for _, name := range a.backends {
+		switch name {
+		case AUTH_BACKEND_LOCAL:
+			result = append(result, &localAuthBackend{config: a.config})
+		case AUTH_BACKEND_LDAP:
+			result = append(result, &ldapAuthBackend{config: a.config})
+		}
+	}
+	return result
+}
+
+func (a *authConfig) LDAP() LDAPConfig {
+	return a.ldap
+}
+
+func (a *authConfig) OIDC() OIDCConfig {
+	return a.oidc
+}
+
+/*
+ldapConfig implements LDAPConfig
+*/
+type ldapConfig struct {
+	url                string
+	startTLS           bool
+	insecureSkipVerify bool
+	bindDN             string
+	bindPassword       string
+	baseDN             string
+	userFilter         string
+	requireGroup       bool
+	attributes         map[string]string
+
+	groupPermissions
+}
+
+func (l *ldapConfig) URL() string {
+	return l.url
+}
+
+func (l *ldapConfig) StartTLS() bool {
+	return l.startTLS
+}
+
+func (l *ldapConfig) InsecureSkipVerify() bool {
+	return l.insecureSkipVerify
+}
+
+func (l *ldapConfig) BindDN() string {
+	return l.bindDN
+}
+
+func (l *ldapConfig) BindPassword() string {
+	return l.bindPassword
+}
+
+func (l *ldapConfig) BaseDN() string {
+	return l.baseDN
+}
+
+func (l *ldapConfig) UserFilter() string {
+	return l.userFilter
+}
+
+func (l *ldapConfig) Attribute(field string) string {
+	return l.attributes[field]
+}
+
+func (l *ldapConfig) RequireGroup() bool {
+	return l.requireGroup
+}
+
+/*
+oidcConfig implements OIDCConfig
+*/
+type oidcConfig struct {
+	enabled      bool
+	issuer       string
+	clientID     string
+	clientSecret string
+	redirectURL  string
+	scopes       []string
+	requireGroup bool
+	claims       map[string]string
+
+	groupPermissions
+}
+
+func (o *oidcConfig) Enabled() bool {
+	return o.enabled
+}
+
+func (o *oidcConfig) Issuer() string {
+	return o.issuer
+}
+
+func (o *oidcConfig) ClientID() string {
+	return o.clientID
+}
+
+func (o *oidcConfig) ClientSecret() string {
+	return o.clientSecret
+}
+
+func (o *oidcConfig) RedirectURL() string {
+	return o.redirectURL
+}
+
+func (o *oidcConfig) Scopes() []string {
+	return o.scopes
+}
+
+func (o *oidcConfig) Claim(field string) string {
+	return o.claims[field]
+}
+
+func (o *oidcConfig) RequireGroup() bool {
+	return o.requireGroup
+}
+
+/*
+Toml helpers, go-toml returns integers as int64 and arrays as []interface{}
+*/
+
+func tomlGetString(tree *toml.TomlTree, key string, def string) string {
+	if value, ok := tree.GetDefault(key, def).(string); ok {
+		return value
+	}
+	return def
+}
+
+func tomlGetBool(tree *toml.TomlTree, key string, def bool) bool {
+	if value, ok := tree.GetDefault(key, def).(bool); ok {
+		return value
+	}
+	return def
+}
+
+func tomlGetInt(tree *toml.TomlTree, key string, def int) int {
+	switch value := tree.GetDefault(key, def).(type) {
+	case int64:
+		return int(value)
+	case int:
+		return value
+	}
+	return def
+}
+
+func tomlGetStringList(tree *toml.TomlTree, key string, def []string) []string {
+	values, ok := tree.Get(key).([]interface{})
+	if !ok {
+		return def
+	}
+	result := make([]string, 0, len(values))
+	for _, value := range values {
+		if str, ok := value.(string); ok {
+			result = append(result, str)
+		}
+	}
+	return result
+}
@@ -0,0 +1,201 @@
+/*
+Cron schedules
+
+Schedules use standard five field cron format "minute hour day-of-month month day-of-week". Fields support "*",
+lists ("1,15"), ranges ("1-5") and steps ("0-59/15", "0-30/10", step can follow asterisk too). Day of week is 0-6
+(0 is Sunday), 7 is also accepted for Sunday. Following shortcuts are supported:
+
+	@hourly, @daily (@midnight), @weekly, @monthly, @yearly (@annually)
+	@every <duration>    e.g. "@every 30m", duration is parsed by time.ParseDuration
+
+When both day of month and day of week are restricted, time matches when either of them matches (as in cron).
+*/
+package core
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	// cron shortcuts
+	cronShortcuts = map[string]string{
+		"@yearly":   "0 0 1 1 *",
+		"@annually": "0 0 1 1 *",
+		"@monthly":  "0 0 1 * *",
+		"@weekly":   "0 0 * * 0",
+		"@daily":    "0 0 * * *",
+		"@midnight": "0 0 * * *",
+		"@hourly":   "0 * * * *",
+	}
+
+	// cron field bounds (minute, hour, day of month, month, day of week)
+	cronBounds = [5][2]int{{0, 59}, {0, 23}, {1, 31}, {1, 12}, {0, 7}}
+)
+
+/*
+Schedule returns next activation time after given time
+*/
+type Schedule interface {
+	Next(after time.Time) time.Time
+}
+
+/*
+ParseSchedule parses cron expression or shortcut
+*/
+func ParseSchedule(spec string) (result Schedule, err error) {
+	spec = strings.TrimSpace(spec)
+
+	if strings.HasPrefix(spec, "@every ") {
+		var interval time.Duration
+		if interval, err = time.ParseDuration(strings.TrimSpace(strings.TrimPrefix(spec, "@every "))); err != nil {
+			return nil, fmt.Errorf("invalid schedule %q: %v", spec, err)
+		}
+		if interval < time.Minute {
+			return nil, fmt.Errorf("invalid schedule %q: interval must be at least one minute", spec)
+		}
+		return everySchedule{interval: interval}, nil
+	}
+
+	if expanded, ok := cronShortcuts[spec]; ok {
+		spec = expanded
+	}
+
+	fields := strings.Fields(spec)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("invalid schedule %q: expected 5 fields", spec)
+	}
+
+	schedule := cronSchedule{}
+	for i, field := range fields {
+		if schedule.fields[i], err = parseCronField(field, cronBounds[i][0], cronBounds[i][1]); err != nil {
+			return nil, fmt.Errorf("invalid schedule %q: %v", spec, err)
+		}
+	}
+
+	// sunday can be written as 7
+	if schedule.fields[4]&(1<<7) != 0 {
+		schedule.fields[4] |= 1
+	}
+
+	schedule.domStar = strings.HasPrefix(fields[2], "*")
+	schedule.dowStar = strings.HasPrefix(fields[4], "*")
+
+	return schedule, nil
+}
+
+/*
+parseCronField parses single field into bit set of allowed values
+*/
+func parseCronField(field string, min, max int) (result uint64, err error) {
+	for _, part := range strings.Split(field, ",") {
+		step := 1
+		if index := strings.Index(part, "/"); index != -1 {
+			if step, err = strconv.Atoi(part[index+1:]); err != nil || step <= 0 {
+				return 0, fmt.Errorf("invalid step in %q", part)
+			}
+			part = part[:index]
+		}
+
+		start, end := min, max
+		switch {
+		case part == "*":
+		case strings.Contains(part, "-"):
+			bounds := strings.SplitN(part, "-", 2)
+			if start, err = strconv.Atoi(bounds[0]); err != nil {
+				return 0, fmt.Errorf("invalid range %q", part)
+			}
+			if end, err = strconv.Atoi(bounds[1]); err != nil {
+				return 0, fmt.Errorf("invalid range %q", part)
+			}
+		default:
+			if start, err = strconv.Atoi(part); err != nil {
+				return 0, fmt.Errorf("invalid value %q", part)
+			}
+			// single value with step means range to maximum
+			if step == 1 {
+				end = start
+			}
+		}
+
+		if start < min || end > max || start > end {
+			return 0, fmt.Errorf("value out of range %q", part)
+		}
+
+		for value := start; value <= end; value += step {
+			result |= 1 << uint(value)
+		}
+	}
+
+	return
+}
+
+/*
+cronSchedule is parsed cron expression, every field is bit set of allowed values
+*/
+type cronSchedule struct {
+	fields  [5]uint64
+	domStar bool
+	dowStar bool
+}
+
+/*
+Next returns first minute after given time that matches schedule
+*/
+func (c cronSchedule) Next(after time.Time) time.Time {
+	t := after.Truncate(time.Minute).Add(time.Minute)
+
+	// schedule that never matches (e.g. 31st of February) gives up after five years
+	limit := t.AddDate(5, 0, 0)
+
+	for t.Before(limit) {
+		if !c.has(3, int(t.Month())) {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, t.Location())
+			continue
+		}
+		if !c.matchDay(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location())
+			continue
+		}
+		if !c.has(1, t.Hour()) {
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, t.Location())
+			continue
+		}
+		if !c.has(0, t.Minute()) {
+			t = t.Add(time.Minute)
+			continue
+		}
+		return t
+	}
+
+	return time.Time{}
+}
+
+/*
+matchDay returns whether day matches day of month and day of week fields
+*/
+func (c cronSchedule) matchDay(t time.Time) bool {
+	dom, dow := c.has(2, t.Day()), c.has(4, int(t.Weekday()))
+	if c.domStar || c.dowStar {
+		return dom && dow
+	}
+	return dom || dow
+}
+
+func (c cronSchedule) has(field, value int) bool {
+	return c.fields[field]&(1<<uint(value)) != 0
+}
+
+/*
+everySchedule activates in fixed intervals. Activations are aligned to multiples of interval, so all gopypi
+instances compute the same activation times.
+*/
+type everySchedule struct {
+	interval time.Duration
+}
+
+func (e everySchedule) Next(after time.Time) time.Time {
+	return after.Truncate(e.interval).Add(e.interval)
+}
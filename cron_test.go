@@ -0,0 +1,41 @@
+package core
+
+import (
+	"testing"
+)
+
+func TestParseScheduleNext(t *testing.T) {
+	tc := []struct {
+		spec string
+		in   string
+		out  string
+	}{
+		{"@hourly", "Nov 4 2016 15:04:05", "Nov 4 2016 16:00:00"},
+		{"@daily", "Nov 4 2016 15:04:05", "Nov 5 2016 00:00:00"},
+		{"@weekly", "Nov 4 2016 15:04:05", "Nov 6 2016 00:00:00"},
+		{"@monthly", "Nov 4 2016 15:04:05", "Dec 1 2016 00:00:00"},
+		{"@every 1h", "Nov 4 2016 15:04:05", "Nov 4 2016 16:00:00"},
+		{"*/15 * * * *", "Nov 4 2016 15:04:05", "Nov 4 2016 15:15:00"},
+		{"30 2 * * 1-5", "Nov 4 2016 15:04:05", "Nov 7 2016 02:30:00"},
+		{"0 0 29 2 *", "Nov 4 2016 15:04:05", "Feb 29 2020 00:00:00"},
+		{"0 12 1 * 7", "Nov 4 2016 15:04:05", "Nov 6 2016 12:00:00"},
+	}
+
+	for _, tt := range tc {
+		t.Run(tt.spec, func(st *testing.T) {
+			schedule, err := ParseSchedule(tt.spec)
+			if err != nil {
+				st.Fatalf("ParseSchedule(%q) returned error: %v", tt.spec, err)
+			}
+			cmp(st, tt.in, tt.out, schedule.Next)
+		})
+	}
+}
+
+func TestParseScheduleInvalid(t *testing.T) {
+	for _, spec := range []string{"", "* * * *", "60 * * * *", "* * 0 * *", "*/0 * * * *", "5-1 * * * *", "@every 10s"} {
+		if _, err := ParseSchedule(spec); err == nil {
+			t.Errorf("ParseSchedule(%q) should return error", spec)
+		}
+	}
+}
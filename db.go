@@ -4,6 +4,9 @@ Various database (gorm) helpers
 package core
 
 import (
+	"fmt"
+	"time"
+
 	"github.com/jinzhu/gorm"
 	"github.com/phonkee/go-paginator"
 )
@@ -34,3 +37,126 @@ func CountQueryset(db *gorm.DB, p paginator.Paginator) *gorm.DB {
 	p.Count(count)
 	return db
 }
+
+/*
+PendingMigrations returns tables, columns and indexes that are missing in database (migrate command was not run
+after upgrade)
+*/
+func PendingMigrations(db *gorm.DB) (pending []string) {
+	dialect := db.NewScope(nil).Dialect()
+
+	for _, model := range Models() {
+		scope := db.NewScope(model)
+		table := scope.TableName()
+
+		if !dialect.HasTable(table) {
+			pending = append(pending, "table "+table)
+			continue
+		}
+
+		for _, field := range scope.GetModelStruct().StructFields {
+			if field.IsNormal && !field.IsIgnored && !dialect.HasColumn(table, field.DBName) {
+				pending = append(pending, "column "+table+"."+field.DBName)
+			}
+		}
+	}
+
+	// indexes added by migrateDownloadStats
+	for _, model := range []interface{}{DownloadStatsDaily{}, DownloadStatsWeekly{}, DownloadStatsMonthly{}, DownloadStatsYearly{}} {
+		table := db.NewScope(model).TableName()
+		if !dialect.HasIndex(table, "uix_"+table+"_version_period") {
+			pending = append(pending, "index uix_"+table+"_version_period")
+		}
+	}
+	if !dialect.HasIndex(db.NewScope(DownloadStatsDetail{}).TableName(), "uix_download_stats_detail_key") {
+		pending = append(pending, "index uix_download_stats_detail_key")
+	}
+
+	// indexes added by migrateIndexes
+	for table, index := range map[string]string{
+		"package":            "uix_package_index_name",
+		"package_index_base": "uix_package_index_base",
+		"package_index_acl":  "uix_package_index_acl",
+	} {
+		if !dialect.HasIndex(table, index) {
+			pending = append(pending, "index "+index)
+		}
+	}
+
+	return
+}
+
+/*
+migrateIndexes creates default index, moves packages created before indexes to it and adds unique indexes (package
+name is unique within index)
+*/
+func migrateIndexes(db *gorm.DB) (err error) {
+	index := Index{}
+	if db.First(&index, "name = ?", DEFAULT_INDEX).RecordNotFound() {
+		index = Index{
+			Name:        DEFAULT_INDEX,
+			Description: "Default index",
+			Public:      true,
+			OpenUpload:  true,
+		}
+		if err = db.Create(&index).Error; err != nil {
+			return
+		}
+	}
+
+	if err = db.Model(Package{}).Where("index_id = ? OR index_id IS NULL", 0).UpdateColumn("index_id", index.ID).Error; err != nil {
+		return
+	}
+
+	if err = db.Model(Package{}).AddUniqueIndex("uix_package_index_name", "index_id", "name").Error; err != nil {
+		return
+	}
+	if err = db.Model(IndexBase{}).AddUniqueIndex("uix_package_index_base", "index_id", "base_id").Error; err != nil {
+		return
+	}
+	return db.Model(IndexACL{}).AddUniqueIndex("uix_package_index_acl", "index_id", "user_id").Error
+}
+
+/*
+migrateDownloadStats merges duplicate download stats rows (created by concurrent downloads before stats were
+written in batches) and adds unique index that download stats upserts rely on.
+*/
+func migrateDownloadStats(db *gorm.DB) (err error) {
+	type duplicate struct {
+		ID               uint
+		PackageVersionID uint
+		CreatedAt        time.Time
+		Downloads        int
+	}
+
+	for _, model := range []interface{}{DownloadStatsDaily{}, DownloadStatsWeekly{}, DownloadStatsMonthly{}, DownloadStatsYearly{}} {
+		table := db.NewScope(model).TableName()
+
+		duplicates := []duplicate{}
+		if err = db.Table(table).
+			Select("MIN(id) AS id, package_version_id, created_at, SUM(downloads) AS downloads").
+			Group("package_version_id, created_at").
+			Having("COUNT(*) > 1").
+			Scan(&duplicates).Error; err != nil {
+			return
+		}
+
+		for _, dup := range duplicates {
+			if err = db.Exec(fmt.Sprintf("UPDATE %v SET downloads = ? WHERE id = ?", table), dup.Downloads, dup.ID).Error; err != nil {
+				return
+			}
+			if err = db.Exec(fmt.Sprintf("DELETE FROM %v WHERE package_version_id = ? AND created_at = ? AND id <> ?", table),
+				dup.PackageVersionID, dup.CreatedAt, dup.ID).Error; err != nil {
+				return
+			}
+		}
+
+		if err = db.Model(model).AddUniqueIndex("uix_"+table+"_version_period", "package_version_id", "created_at").Error; err != nil {
+			return
+		}
+	}
+
+	// detailed stats are written only by collector, so there are no duplicates
+	return db.Model(DownloadStatsDetail{}).AddUniqueIndex("uix_download_stats_detail_key",
+		"created_at", "package_version_file_id", "package_version_id", "user_id", "installer", "python", "system").Error
+}
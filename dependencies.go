@@ -0,0 +1,113 @@
+/*
+Dependencies
+
+Requirements of releases (Requires-Dist, PEP 508) are stored per version, so gopypi can answer both what package
+depends on and which hosted packages depend on it:
+
+	requests[security,socks] (>=2.8.1,<3) ; python_version < "3.8"
+
+is stored as name "requests", extras "security,socks", specifier ">=2.8.1,<3" and marker `python_version < "3.8"`.
+Dependencies are matched to packages by canonical name (PEP 503) regardless of index.
+*/
+package core
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+var (
+	// name, optional extras and rest of requirement
+	requirementRegexp = regexp.MustCompile(`^([A-Za-z0-9](?:[A-Za-z0-9._-]*[A-Za-z0-9])?)\s*(?:\[([^\]]*)\])?\s*(.*)$`)
+)
+
+/*
+Requirement is parsed PEP 508 requirement
+*/
+type Requirement struct {
+	Name      string
+	Extras    []string
+	Specifier string
+	URL       string
+	Marker    string
+}
+
+/*
+ParseRequirement parses PEP 508 requirement (as in Requires-Dist metadata field)
+*/
+func ParseRequirement(value string) (result Requirement, err error) {
+	value = strings.TrimSpace(value)
+
+	match := requirementRegexp.FindStringSubmatch(value)
+	if match == nil {
+		err = fmt.Errorf("%v: %q", ErrInvalidRequirement, value)
+		return
+	}
+
+	result.Name = match[1]
+	for _, extra := range strings.Split(match[2], ",") {
+		if extra = strings.TrimSpace(extra); extra != "" {
+			result.Extras = append(result.Extras, extra)
+		}
+	}
+
+	rest := strings.TrimSpace(match[3])
+	if strings.HasPrefix(rest, "@") {
+		// url requirement needs whitespace before marker separator
+		rest = strings.TrimSpace(rest[1:])
+		if index := strings.Index(rest, " ;"); index >= 0 {
+			result.URL, result.Marker = strings.TrimSpace(rest[:index]), strings.TrimSpace(rest[index+2:])
+		} else {
+			result.URL = rest
+		}
+		if result.URL == "" || strings.ContainsAny(result.URL, " \t") {
+			err = fmt.Errorf("%v: %q", ErrInvalidRequirement, value)
+		}
+		return
+	}
+
+	if index := strings.Index(rest, ";"); index >= 0 {
+		rest, result.Marker = strings.TrimSpace(rest[:index]), strings.TrimSpace(rest[index+1:])
+	}
+
+	// old style specifiers are in parentheses "foo (>=1.0)"
+	if strings.HasPrefix(rest, "(") && strings.HasSuffix(rest, ")") {
+		rest = strings.TrimSpace(rest[1 : len(rest)-1])
+	}
+
+	if rest != "" && ValidateRequiresPython(rest) != nil {
+		err = fmt.Errorf("%v: %q", ErrInvalidRequirement, value)
+		return
+	}
+
+	result.Specifier = strings.Join(strings.Fields(rest), "")
+	return
+}
+
+/*
+ParseRequirements parses list of requirements, invalid requirements are skipped
+*/
+func ParseRequirements(values []string) (result []Requirement) {
+	for _, value := range values {
+		if requirement, err := ParseRequirement(value); err == nil {
+			result = append(result, requirement)
+		}
+	}
+	return
+}
+
+/*
+Dependency returns dependency of package version for requirement
+*/
+func (r Requirement) Dependency(version PackageVersion) PackageDependency {
+	return PackageDependency{
+		PackageVersionID: version.ID,
+		Name:             r.Name,
+		CanonicalName:    CanonicalPackageName(r.Name),
+		Extras:           strings.Join(r.Extras, ","),
+		Specifier:        r.Specifier,
+		URL:              r.URL,
+		Marker:           r.Marker,
+	}
+}
@@ -0,0 +1,87 @@
+package core
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseRequirement(t *testing.T) {
+	tc := []struct {
+		in        string
+		name      string
+		extras    string
+		specifier string
+		url       string
+		marker    string
+	}{
+		{"requests", "requests", "", "", "", ""},
+		{"  requests  ", "requests", "", "", "", ""},
+		{"zope.interface", "zope.interface", "", "", "", ""},
+		{"requests>=2.8.1", "requests", "", ">=2.8.1", "", ""},
+		{"requests >= 2.8.1, < 3", "requests", "", ">=2.8.1,<3", "", ""},
+		{"requests (>=2.8.1,<3)", "requests", "", ">=2.8.1,<3", "", ""},
+		{"requests[security]", "requests", "security", "", "", ""},
+		{"requests[ security , socks ]>=2.8", "requests", "security,socks", ">=2.8", "", ""},
+		{"requests[]", "requests", "", "", "", ""},
+		{"foo ~=1.4.2", "foo", "", "~=1.4.2", "", ""},
+		{"foo ==1.0.*", "foo", "", "==1.0.*", "", ""},
+		{`pywin32 >1.0 ; sys_platform == "win32"`, "pywin32", "", ">1.0", "", `sys_platform == "win32"`},
+		{`requests[security,socks] (>=2.8.1,<3) ; python_version < "3.8"`, "requests", "security,socks", ">=2.8.1,<3", "", `python_version < "3.8"`},
+		{`typing; python_version<"3.5"`, "typing", "", "", "", `python_version<"3.5"`},
+		{"pip @ https://github.com/pypa/pip/archive/1.3.1.zip", "pip", "", "", "https://github.com/pypa/pip/archive/1.3.1.zip", ""},
+		{`pip[cli] @ file:///tmp/pip.zip ; python_version >= "3"`, "pip", "cli", "", "file:///tmp/pip.zip", `python_version >= "3"`},
+		{"pip @ https://example.org/pip.zip;sha256=abc", "pip", "", "", "https://example.org/pip.zip;sha256=abc", ""},
+	}
+
+	for _, tt := range tc {
+		t.Run(tt.in, func(st *testing.T) {
+			result, err := ParseRequirement(tt.in)
+			if err != nil {
+				st.Fatalf("ParseRequirement returned error: %v", err)
+			}
+			if result.Name != tt.name || strings.Join(result.Extras, ",") != tt.extras || result.Specifier != tt.specifier ||
+				result.URL != tt.url || result.Marker != tt.marker {
+				st.Errorf("ParseRequirement(%q) returned %+v", tt.in, result)
+			}
+		})
+	}
+}
+
+func TestParseRequirementInvalid(t *testing.T) {
+	for _, value := range []string{
+		"",
+		"   ",
+		">=1.0",
+		"-foo",
+		"foo-",
+		"foo @",
+		"foo @ ; python_version < \"3\"",
+		"foo >=",
+		"foo bar",
+		"foo (>=1.0",
+		"foo[extra",
+	} {
+		t.Run(value, func(st *testing.T) {
+			if result, err := ParseRequirement(value); err == nil {
+				st.Errorf("ParseRequirement(%q) returned %+v", value, result)
+			}
+		})
+	}
+}
+
+func TestParseRequirements(t *testing.T) {
+	result := ParseRequirements([]string{"requests>=2", "foo >=", "Django[bcrypt]"})
+	if len(result) != 2 || result[0].Name != "requests" || result[1].Name != "Django" {
+		t.Errorf("ParseRequirements returned %+v", result)
+	}
+}
+
+func TestRequirementDependency(t *testing.T) {
+	requirement, _ := ParseRequirement(`Zope.Interface[docs,test] >=4 ; python_version >= "3"`)
+	dependency := requirement.Dependency(PackageVersion{ID: 42})
+
+	if dependency.PackageVersionID != 42 || dependency.Name != "Zope.Interface" || dependency.CanonicalName != "zope-interface" ||
+		dependency.Extras != "docs,test" || dependency.Specifier != ">=4" || dependency.Marker != `python_version >= "3"` {
+		t.Errorf("Dependency returned %+v", dependency)
+	}
+}
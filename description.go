@@ -0,0 +1,247 @@
+/*
+Description rendering
+
+Descriptions of releases are rendered to html at upload time according to their content type (PEP 566):
+
+	text/markdown    CommonMark with GitHub extensions (tables, strikethrough, task lists, autolinks)
+	text/x-rst       subset of reStructuredText (sections, lists, literal blocks, code and admonition directives)
+	text/plain       preformatted text
+
+Rendered html is safe to embed in pages: it's generated from escaped text only (raw html in descriptions is shown as
+text), renderers emit only basic formatting tags and links/images are allowed only with http(s), ftp, mailto or
+relative urls.
+*/
+package core
+
+import (
+	"html"
+	"mime"
+	"net/url"
+	"strings"
+)
+
+/*
+DescriptionFormat returns description format (markdown, rst or plain) for content type. Blank content type means
+reStructuredText (PEP 566 default), unknown content types are rendered as plain text.
+*/
+func DescriptionFormat(contentType string) string {
+	if strings.TrimSpace(contentType) == "" {
+		return DESCRIPTION_FORMAT_RST
+	}
+
+	mediatype, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return DESCRIPTION_FORMAT_PLAIN
+	}
+
+	switch mediatype {
+	case DESCRIPTION_CONTENT_TYPE_MARKDOWN:
+		return DESCRIPTION_FORMAT_MARKDOWN
+	case DESCRIPTION_CONTENT_TYPE_RST:
+		return DESCRIPTION_FORMAT_RST
+	}
+	return DESCRIPTION_FORMAT_PLAIN
+}
+
+/*
+RenderDescription renders description to sanitized html according to its content type
+*/
+func RenderDescription(contentType string, text string) string {
+	text = normalizeDescription(text)
+	if strings.TrimSpace(text) == "" {
+		return ""
+	}
+
+	switch DescriptionFormat(contentType) {
+	case DESCRIPTION_FORMAT_MARKDOWN:
+		return renderMarkdown(text)
+	case DESCRIPTION_FORMAT_RST:
+		return renderRST(text)
+	}
+	return "<pre>" + html.EscapeString(text) + "</pre>\n"
+}
+
+/*
+normalizeDescription normalizes line endings, expands tabs and removes control characters (they are used as markers
+by renderers)
+*/
+func normalizeDescription(text string) string {
+	text = strings.Replace(text, "\r\n", "\n", -1)
+	text = strings.Replace(text, "\r", "\n", -1)
+	text = strings.Replace(text, "\t", "    ", -1)
+	return strings.Map(func(r rune) rune {
+		if r < 0x20 && r != '\n' {
+			return -1
+		}
+		return r
+	}, text)
+}
+
+/*
+safeURL returns url when it's allowed in rendered description
+*/
+func safeURL(raw string) (string, bool) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return "", false
+	}
+
+	parsed, err := url.Parse(raw)
+	if err != nil {
+		return "", false
+	}
+
+	switch strings.ToLower(parsed.Scheme) {
+	case "", "http", "https", "ftp", "mailto":
+		return raw, true
+	}
+	return "", false
+}
+
+/*
+renderLink returns html link, unsafe urls are rendered as text only
+*/
+func renderLink(href string, content string, title string) string {
+	safe, ok := safeURL(href)
+	if !ok {
+		return content
+	}
+
+	result := `<a href="` + html.EscapeString(safe) + `"`
+	if title != "" {
+		result += ` title="` + html.EscapeString(title) + `"`
+	}
+	return result + ` rel="nofollow">` + content + `</a>`
+}
+
+/*
+renderImage returns html image, images with unsafe urls are rendered as their alternative text
+*/
+func renderImage(src string, alt string, title string) string {
+	safe, ok := safeURL(src)
+	if !ok || strings.HasPrefix(strings.ToLower(safe), "mailto:") {
+		return html.EscapeString(alt)
+	}
+
+	result := `<img src="` + html.EscapeString(safe) + `" alt="` + html.EscapeString(alt) + `"`
+	if title != "" {
+		result += ` title="` + html.EscapeString(title) + `"`
+	}
+	return result + `>`
+}
+
+/*
+bareURLLength returns length of url starting at beginning of text (GFM autolink extension), trailing punctuation and
+unbalanced parentheses are not part of url
+*/
+func bareURLLength(text string) int {
+	lower := strings.ToLower(text)
+	if !strings.HasPrefix(lower, "http://") && !strings.HasPrefix(lower, "https://") && !strings.HasPrefix(lower, "www.") {
+		return 0
+	}
+
+	end := strings.IndexAny(text, " \n<>\"")
+	if end < 0 {
+		end = len(text)
+	}
+
+	for end > 0 {
+		last := text[end-1]
+		if strings.IndexByte("?!.,:;*_~'", last) >= 0 {
+			end--
+			continue
+		}
+		if last == ')' && strings.Count(text[:end], "(") < strings.Count(text[:end], ")") {
+			end--
+			continue
+		}
+		break
+	}
+
+	if strings.HasSuffix(lower[:end], "://") || lower[:end] == "www." {
+		return 0
+	}
+	return end
+}
+
+/*
+renderBareURL renders link for bare url
+*/
+func renderBareURL(text string) string {
+	href := text
+	if strings.HasPrefix(strings.ToLower(text), "www.") {
+		href = "http://" + text
+	}
+	return renderLink(href, html.EscapeString(text), "")
+}
+
+/*
+escapeByte writes html escaped byte
+*/
+func escapeByte(b *strings.Builder, c byte) {
+	switch c {
+	case '<':
+		b.WriteString("&lt;")
+	case '>':
+		b.WriteString("&gt;")
+	case '&':
+		b.WriteString("&amp;")
+	case '"':
+		b.WriteString("&#34;")
+	case '\'':
+		b.WriteString("&#39;")
+	default:
+		b.WriteByte(c)
+	}
+}
+
+/*
+isAlnumByte returns whether byte is ascii letter or digit (or part of multibyte character)
+*/
+func isAlnumByte(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c >= 0x80
+}
+
+/*
+indentation returns number of leading spaces
+*/
+func indentation(line string) int {
+	return len(line) - len(strings.TrimLeft(line, " "))
+}
+
+/*
+isBlank returns whether line contains only whitespace
+*/
+func isBlank(line string) bool {
+	return strings.TrimSpace(line) == ""
+}
+
+/*
+dedent removes common indentation of lines, blank lines at the end are removed
+*/
+func dedent(lines []string) []string {
+	for len(lines) > 0 && isBlank(lines[len(lines)-1]) {
+		lines = lines[:len(lines)-1]
+	}
+
+	common := -1
+	for _, line := range lines {
+		if isBlank(line) {
+			continue
+		}
+		if indent := indentation(line); common < 0 || indent < common {
+			common = indent
+		}
+	}
+
+	result := make([]string, len(lines))
+	for i, line := range lines {
+		if len(line) >= common && common > 0 {
+			line = line[common:]
+		} else if isBlank(line) {
+			line = ""
+		}
+		result[i] = line
+	}
+	return result
+}
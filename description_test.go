@@ -0,0 +1,184 @@
+package core
+
+import (
+	"html"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+var (
+	// opening or closing tag with double quoted attributes
+	testTagRegexp       = regexp.MustCompile(`^<(/?)([a-z0-9]+)((?: [a-z]+="[^"<>]*")*)>`)
+	testAttributeRegexp = regexp.MustCompile(` ([a-z]+)="([^"]*)"`)
+
+	// tags and attributes renderers are allowed to emit
+	testAllowedTags = map[string]bool{
+		"a": true, "blockquote": true, "br": true, "cite": true, "code": true, "del": true, "em": true, "h1": true, "h2": true,
+		"h3": true, "h4": true, "h5": true, "h6": true, "hr": true, "img": true, "li": true, "ol": true, "p": true,
+		"pre": true, "strong": true, "table": true, "tbody": true, "td": true, "th": true, "thead": true, "tr": true,
+		"ul": true,
+	}
+	testAllowedAttributes = map[string]bool{
+		"align": true, "alt": true, "class": true, "href": true, "rel": true, "src": true, "start": true, "title": true,
+	}
+)
+
+/*
+checkSafeHTML reports every tag or attribute that is not allowed and urls with unsafe scheme
+*/
+func checkSafeHTML(t *testing.T, input, rendered string) {
+	for i := strings.IndexByte(rendered, '<'); i >= 0; i = strings.IndexByte(rendered, '<') {
+		rendered = rendered[i:]
+		match := testTagRegexp.FindStringSubmatch(rendered)
+		if match == nil {
+			t.Errorf("%q rendered unexpected markup at %q", input, rendered)
+			return
+		}
+		if !testAllowedTags[match[2]] {
+			t.Errorf("%q rendered tag %q", input, match[2])
+		}
+
+		for _, attribute := range testAttributeRegexp.FindAllStringSubmatch(match[3], -1) {
+			if !testAllowedAttributes[attribute[1]] {
+				t.Errorf("%q rendered attribute %q", input, attribute[1])
+			}
+			if attribute[1] == "href" || attribute[1] == "src" {
+				if _, ok := safeURL(html.UnescapeString(attribute[2])); !ok {
+					t.Errorf("%q rendered unsafe url %q", input, attribute[2])
+				}
+			}
+		}
+		rendered = rendered[len(match[0]):]
+	}
+}
+
+func TestRenderDescriptionXSS(t *testing.T) {
+	for _, contentType := range []string{DESCRIPTION_CONTENT_TYPE_MARKDOWN, DESCRIPTION_CONTENT_TYPE_RST, "text/plain"} {
+		for _, input := range []string{
+			"<script>alert(1)</script>",
+			"<img src=x onerror=alert(1)>",
+			"<a href=\"javascript:alert(1)\">x</a>",
+			"[x](javascript:alert(1))",
+			"[x](JaVaScRiPt:alert(1))",
+			"[x]( javascript:alert(1))",
+			"[x](javascript&colon;alert(1))",
+			"[x](&#106;avascript:alert(1))",
+			"[x](&#x6A;avascript&#x3A;alert(1))",
+			"[x](data:text/html;base64,PHNjcmlwdD5hbGVydCgxKTwvc2NyaXB0Pg==)",
+			"[x](vbscript:msgbox(1))",
+			"![x](javascript:alert(1))",
+			"![x](data:image/svg+xml;base64,PHN2Zz4=)",
+			"[x][1]\n\n[1]: javascript:alert(1)",
+			"<javascript:alert(1)>",
+			"[x](http://example.org \"title\" onmouseover=\"alert(1)\")",
+			"[x](http://example.org/\"onmouseover=\"alert(1))",
+			"![x\" onerror=\"alert(1)](http://example.org/image.png)",
+			"```\"><script>alert(1)</script>\nx\n```",
+			"`<script>`",
+			"&lt;script&gt;alert(1)&lt;/script&gt;",
+			"&#60;script&#62;alert(1)&#60;/script&#62;",
+			"`x <javascript:alert(1)>`_",
+			"`x <data:text/html,<script>alert(1)</script>>`_",
+			".. image:: javascript:alert(1)",
+			".. image:: http://example.org/\"onerror=\"alert(1)",
+			".. raw:: html\n\n   <script>alert(1)</script>",
+			".. code-block:: \"><script>\n\n   x",
+			"http://example.org/<script>",
+		} {
+			rendered := RenderDescription(contentType, input)
+			checkSafeHTML(t, input, rendered)
+		}
+	}
+}
+
+func TestRenderDescriptionEntities(t *testing.T) {
+	tc := []struct {
+		in  string
+		out string
+	}{
+		{"&copy; &amp; &#65; &#x42;", "<p>© &amp; A B</p>\n"},
+		{"&lt;b&gt; &#60;b&#62;", "<p>&lt;b&gt; &lt;b&gt;</p>\n"},
+		{"&bogus; & &#;", "<p>&amp;bogus; &amp; &amp;#;</p>\n"},
+		{"\\&copy;", "<p>&amp;copy;</p>\n"},
+		{"`&copy;`", "<p><code>&amp;copy;</code></p>\n"},
+		{"[a &amp; b](http://example.org/?a=1&amp;b=2)", "<p><a href=\"http://example.org/?a=1&amp;b=2\" rel=\"nofollow\">a &amp; b</a></p>\n"},
+	}
+
+	for _, tt := range tc {
+		t.Run(tt.in, func(st *testing.T) {
+			if result := RenderDescription(DESCRIPTION_CONTENT_TYPE_MARKDOWN, tt.in); result != tt.out {
+				st.Errorf("RenderDescription(%q) returned %q, expected %q", tt.in, result, tt.out)
+			}
+		})
+	}
+}
+
+func TestRenderDescriptionMarkdown(t *testing.T) {
+	tc := []struct {
+		in  string
+		out string
+	}{
+		{"# Title\n\nSome *em*, **strong**, `code` and ~~del~~.",
+			"<h1>Title</h1>\n<p>Some <em>em</em>, <strong>strong</strong>, <code>code</code> and <del>del</del>.</p>\n"},
+		{"Title\n-----", "<h2>Title</h2>\n"},
+		{"- a\n- b\n\n1. one\n2. two", "<ul>\n<li>a</li>\n<li>b</li>\n</ul>\n<ol>\n<li>one</li>\n<li>two</li>\n</ol>\n"},
+		{"- [ ] todo\n- [x] done", "<ul>\n<li>&#9744; todo</li>\n<li>&#9745; done</li>\n</ul>\n"},
+		{"| a | b |\n|---|:-:|\n| 1 | 2 |",
+			"<table>\n<thead>\n<tr><th>a</th><th align=\"center\">b</th></tr>\n</thead>\n<tbody>\n<tr><td>1</td><td align=\"center\">2</td></tr>\n</tbody>\n</table>\n"},
+		{"```python\nprint('<x>')\n```", "<pre><code class=\"language-python\">print(&#39;&lt;x&gt;&#39;)</code></pre>\n"},
+		{"see https://example.org/a_(b).", "<p>see <a href=\"https://example.org/a_(b)\" rel=\"nofollow\">https://example.org/a_(b)</a>.</p>\n"},
+		{"[docs][1]\n\n[1]: https://example.org/docs", "<p><a href=\"https://example.org/docs\" rel=\"nofollow\">docs</a></p>\n"},
+		{"> quoted", "<blockquote>\n<p>quoted</p>\n</blockquote>\n"},
+	}
+
+	for _, tt := range tc {
+		t.Run(tt.in, func(st *testing.T) {
+			if result := RenderDescription(DESCRIPTION_CONTENT_TYPE_MARKDOWN, tt.in); result != tt.out {
+				st.Errorf("RenderDescription(%q) returned %q, expected %q", tt.in, result, tt.out)
+			}
+		})
+	}
+}
+
+func TestRenderDescriptionRST(t *testing.T) {
+	tc := []struct {
+		in  string
+		out string
+	}{
+		{"Title\n=====\n\nSome *em*, **strong** and ``code``.",
+			"<h1>Title</h1>\n<p>Some <em>em</em>, <strong>strong</strong> and <code>code</code>.</p>\n"},
+		{"- a\n- b", "<ul>\n<li>a</li>\n<li>b</li>\n</ul>\n"},
+		{".. code-block:: python\n\n   print(1)\n", "<pre><code class=\"language-python\">print(1)</code></pre>\n"},
+		{"`docs <https://example.org>`_", "<p><a href=\"https://example.org\" rel=\"nofollow\">docs</a></p>\n"},
+		{".. note::\n\n   Be careful.\n", "<blockquote>\n<p><strong>Note</strong></p>\n<p>Be careful.</p>\n</blockquote>\n"},
+	}
+
+	for _, tt := range tc {
+		t.Run(tt.in, func(st *testing.T) {
+			if result := RenderDescription(DESCRIPTION_CONTENT_TYPE_RST, tt.in); result != tt.out {
+				st.Errorf("RenderDescription(%q) returned %q, expected %q", tt.in, result, tt.out)
+			}
+		})
+	}
+}
+
+func TestDescriptionFormat(t *testing.T) {
+	for contentType, format := range map[string]string{
+		"":                             DESCRIPTION_FORMAT_RST,
+		"text/markdown":                DESCRIPTION_FORMAT_MARKDOWN,
+		"text/markdown; charset=UTF-8": DESCRIPTION_FORMAT_MARKDOWN,
+		"text/x-rst":                   DESCRIPTION_FORMAT_RST,
+		"text/plain":                   DESCRIPTION_FORMAT_PLAIN,
+		"text/html":                    DESCRIPTION_FORMAT_PLAIN,
+		"invalid;;":                    DESCRIPTION_FORMAT_PLAIN,
+	} {
+		if result := DescriptionFormat(contentType); result != format {
+			t.Errorf("DescriptionFormat(%q) returned %q, expected %q", contentType, result, format)
+		}
+	}
+
+	if result := RenderDescription("text/plain", "<b>x</b>"); result != "<pre>&lt;b&gt;x&lt;/b&gt;</pre>\n" {
+		t.Errorf("plain description rendered %q", result)
+	}
+}
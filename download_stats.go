@@ -0,0 +1,214 @@
+/*
+Download stats collector
+
+Downloads are not written to database during request. They are counted in memory by DownloadStatsCollector and
+written in batches (one upsert per aggregation table) every flush interval or when too many distinct downloads are
+buffered, so burst of downloads (e.g. pip install in CI) results in few database statements. Buffered downloads are
+flushed when server shuts down.
+
+	[download_stats]
+	flush_interval = 10
+	max_pending = 1000
+*/
+package core
+
+import (
+	"sync"
+	"time"
+
+	"github.com/uber-go/zap"
+)
+
+/*
+DownloadStatsKey identifies buffered downloads of package version file by single user and installer in single day.
+Aggregated stats (daily, weekly, ...) use only PackageVersionID and Day.
+*/
+type DownloadStatsKey struct {
+	PackageVersionID     uint
+	PackageVersionFileID uint
+	UserID               uint
+	Installer            string
+	Python               string
+	System               string
+	Day                  time.Time
+}
+
+/*
+Less defines stable order of keys
+*/
+func (d DownloadStatsKey) Less(other DownloadStatsKey) bool {
+	switch {
+	case !d.Day.Equal(other.Day):
+		return d.Day.Before(other.Day)
+	case d.PackageVersionID != other.PackageVersionID:
+		return d.PackageVersionID < other.PackageVersionID
+	case d.PackageVersionFileID != other.PackageVersionFileID:
+		return d.PackageVersionFileID < other.PackageVersionFileID
+	case d.UserID != other.UserID:
+		return d.UserID < other.UserID
+	case d.Installer != other.Installer:
+		return d.Installer < other.Installer
+	case d.Python != other.Python:
+		return d.Python < other.Python
+	}
+	return d.System < other.System
+}
+
+/*
+NewDownloadStatsCollector returns collector that flushes downloads in given interval or when maxPending keys are
+buffered
+*/
+func NewDownloadStatsCollector(interval time.Duration, maxPending int) *DownloadStatsCollector {
+	if interval <= 0 {
+		interval = DOWNLOAD_STATS_FLUSH_INTERVAL * time.Second
+	}
+	if maxPending <= 0 {
+		maxPending = DOWNLOAD_STATS_MAX_PENDING
+	}
+	return &DownloadStatsCollector{
+		counts:     map[DownloadStatsKey]int{},
+		interval:   interval,
+		maxPending: maxPending,
+		notify:     make(chan struct{}, 1),
+		stop:       make(chan struct{}),
+		stopped:    make(chan struct{}),
+	}
+}
+
+/*
+DownloadStatsCollector buffers downloads in memory
+*/
+type DownloadStatsCollector struct {
+	mutex      sync.Mutex
+	counts     map[DownloadStatsKey]int
+	interval   time.Duration
+	maxPending int
+	notify     chan struct{}
+	stop       chan struct{}
+	stopped    chan struct{}
+	started    sync.Once
+	stopping   sync.Once
+}
+
+/*
+Add counts single download of package version file by given user (anonymous downloads have nil user), installer is
+parsed from user agent. It never blocks on database.
+*/
+func (d *DownloadStatsCollector) Add(versionfile *PackageVersionFile, user *User, userAgent string) {
+	installer := ParseStatsInstaller(userAgent)
+	key := DownloadStatsKey{
+		PackageVersionID:     versionfile.PackageVersionID,
+		PackageVersionFileID: versionfile.ID,
+		Installer:            installer.Installer,
+		Python:               installer.Python,
+		System:               installer.System,
+		Day:                  TimeStripTime(time.Now()),
+	}
+	if user != nil {
+		key.UserID = user.ID
+	}
+
+	d.mutex.Lock()
+	d.counts[key]++
+	pending := len(d.counts)
+	d.mutex.Unlock()
+
+	if pending >= d.maxPending {
+		select {
+		case d.notify <- struct{}{}:
+		default:
+		}
+	}
+}
+
+/*
+Start runs background goroutine that flushes downloads
+*/
+func (d *DownloadStatsCollector) Start(cfg Config) {
+	d.started.Do(func() {
+		go func() {
+			defer close(d.stopped)
+
+			ticker := time.NewTicker(d.interval)
+			defer ticker.Stop()
+
+			for {
+				select {
+				case <-ticker.C:
+				case <-d.notify:
+				case <-d.stop:
+					d.flush(cfg)
+					return
+				}
+				d.flush(cfg)
+			}
+		}()
+	})
+}
+
+/*
+Stop flushes buffered downloads and stops background goroutine. When collector was not started, downloads are
+flushed directly.
+*/
+func (d *DownloadStatsCollector) Stop(cfg Config) {
+	d.stopping.Do(func() {
+		started := true
+		d.started.Do(func() {
+			started = false
+		})
+
+		if !started {
+			d.flush(cfg)
+			return
+		}
+
+		close(d.stop)
+		<-d.stopped
+	})
+}
+
+/*
+Flush writes buffered downloads to database. When write fails, downloads are returned to buffer and written with
+next flush.
+*/
+func (d *DownloadStatsCollector) Flush(cfg Config) (err error) {
+	d.mutex.Lock()
+	counts := d.counts
+	d.counts = map[DownloadStatsKey]int{}
+	d.mutex.Unlock()
+
+	if err = cfg.Manager().DownloadStats().AddDownloads(counts); err != nil {
+		d.mutex.Lock()
+		for key, count := range counts {
+			d.counts[key] += count
+		}
+		d.mutex.Unlock()
+	}
+
+	return
+}
+
+/*
+Pending returns number of buffered downloads
+*/
+func (d *DownloadStatsCollector) Pending() (result int) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	for _, count := range d.counts {
+		result += count
+	}
+	return
+}
+
+/*
+flush flushes downloads and logs error
+*/
+func (d *DownloadStatsCollector) flush(cfg Config) {
+	if err := d.Flush(cfg); err != nil {
+		cfg.Logger().Error("cannot write download stats",
+			zap.Int("pending", d.Pending()),
+			zap.String("error", err.Error()),
+		)
+	}
+}
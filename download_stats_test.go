@@ -0,0 +1,103 @@
+package core
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+/*
+testDownloadStatsHandler answers feature query with enabled download stats and fails upserts with given error
+*/
+func testDownloadStatsHandler(upsertErr error) func(query string, args []driver.Value) testDBResult {
+	return func(query string, args []driver.Value) testDBResult {
+		switch {
+		case strings.Contains(query, `FROM "feature"`):
+			return testDBResult{
+				Columns: []string{"id", "value"},
+				Rows:    [][]driver.Value{{FEATURE_DOWNLOAD_STATS, true}},
+			}
+		case strings.HasPrefix(query, "INSERT INTO"):
+			return testDBResult{RowsAffected: 1, Err: upsertErr}
+		}
+		return testDBResult{}
+	}
+}
+
+func TestDownloadStatsCollectorFlush(t *testing.T) {
+	pip := `pip/23.0 {"installer":{"name":"pip"},"python":"3.11.2","system":{"name":"Linux"}}`
+	user := &User{ID: 7}
+
+	cfg, fake := newTestConfig(t, testDownloadStatsHandler(nil))
+
+	collector := NewDownloadStatsCollector(0, 0)
+	collector.Add(&PackageVersionFile{ID: 1, PackageVersionID: 5}, user, pip)
+	collector.Add(&PackageVersionFile{ID: 1, PackageVersionID: 5}, user, pip)
+	collector.Add(&PackageVersionFile{ID: 2, PackageVersionID: 5}, nil, "curl/8.0")
+
+	if pending := collector.Pending(); pending != 3 {
+		t.Fatalf("Pending returned %v, expected 3", pending)
+	}
+	if err := collector.Flush(cfg); err != nil {
+		t.Fatalf("Flush returned error: %v", err)
+	}
+	if pending := collector.Pending(); pending != 0 {
+		t.Errorf("Pending after flush returned %v, expected 0", pending)
+	}
+
+	// aggregated tables get single row per version, details get row per file, user and installer
+	tc := []struct {
+		table string
+		rows  int
+		args  string
+	}{
+		{"download_stats_daily", 1, "[5 3]"},
+		{"download_stats_weekly", 1, "[5 3]"},
+		{"download_stats_monthly", 1, "[5 3]"},
+		{"download_stats_yearly", 1, "[5 3]"},
+		{"download_stats_detail", 2, "[1 5 7 pip 3.11 Linux 2 2 5 0 curl   1]"},
+	}
+
+	for _, tt := range tc {
+		t.Run(tt.table, func(st *testing.T) {
+			for i, query := range fake.queries {
+				if !strings.HasPrefix(query, "INSERT INTO "+tt.table+" ") {
+					continue
+				}
+				if rows := strings.Count(query, "), ("); rows+1 != tt.rows {
+					st.Errorf("upsert of %v wrote %v rows, expected %v", tt.table, rows+1, tt.rows)
+				}
+
+				// days are not compared
+				args := []string{}
+				for _, arg := range fake.args[i] {
+					switch arg.(type) {
+					case string, int64:
+						args = append(args, fmt.Sprint(arg))
+					}
+				}
+				if result := "[" + strings.Join(args, " ") + "]"; result != tt.args {
+					st.Errorf("upsert of %v has arguments %v, expected %v", tt.table, result, tt.args)
+				}
+				return
+			}
+			st.Errorf("%v was not written", tt.table)
+		})
+	}
+}
+
+func TestDownloadStatsCollectorFlushFailure(t *testing.T) {
+	cfg, _ := newTestConfig(t, testDownloadStatsHandler(fmt.Errorf("connection lost")))
+
+	collector := NewDownloadStatsCollector(0, 0)
+	collector.Add(&PackageVersionFile{ID: 1, PackageVersionID: 5}, nil, "")
+	collector.Add(&PackageVersionFile{ID: 1, PackageVersionID: 5}, nil, "")
+
+	if err := collector.Flush(cfg); err == nil {
+		t.Fatalf("Flush should return error")
+	}
+	if pending := collector.Pending(); pending != 2 {
+		t.Errorf("failed downloads should stay buffered, Pending returned %v", pending)
+	}
+}
@@ -5,28 +5,63 @@ import "errors"
 var (
 
 	// Config
-	ErrUnknownDBDriver = errors.New("Unknown database driver")
+	ErrUnknownDBDriver    = errors.New("Unknown database driver")
+	ErrUnknownAuthBackend = errors.New("Unknown authentication backend")
 
 	// Auth
-	ErrInvalidAuthHeader = errors.New("Invalid authorization header")
-	ErrLoginUsername     = errors.New("invalid username")
-	ErrLoginPassword     = errors.New("invalid password")
-	ErrTokenExpired      = errors.New("token expired")
-	ErrTokenInvalid      = errors.New("invalid token")
-	ErrTokenUserInvalid  = errors.New("invalid user in token")
+	ErrInvalidAuthHeader  = errors.New("Invalid authorization header")
+	ErrLoginUsername      = errors.New("invalid username")
+	ErrLoginPassword      = errors.New("invalid password")
+	ErrTokenExpired       = errors.New("token expired")
+	ErrTokenRevoked       = errors.New("token revoked")
+	ErrLoginThrottled     = errors.New("too many failed logins, try again later")
+	ErrTokenInvalid       = errors.New("invalid token")
+	ErrTokenUserInvalid   = errors.New("invalid user in token")
+	ErrLDAPUserNotFound   = errors.New("ldap user not found")
+	ErrLDAPNoGroup        = errors.New("ldap user is not member of any mapped group")
+	ErrOIDCNotConfigured  = errors.New("oidc issuer and client_id must be set")
+	ErrOIDCDisabled       = errors.New("oidc login is disabled")
+	ErrOIDCInvalidState   = errors.New("invalid oidc state")
+	ErrOIDCInvalidToken   = errors.New("invalid oidc id token")
+	ErrOIDCNoUsername     = errors.New("oidc id token doesn't contain username")
+	ErrOIDCNoSubject      = errors.New("oidc id token doesn't contain subject")
+	ErrOIDCAccountExists  = errors.New("user with this username already exists, admin must link it to oidc login")
+	ErrOIDCAlreadyLinked  = errors.New("oidc login is already linked to another user")
+	ErrOIDCLinkIncomplete = errors.New("both oidc_issuer and oidc_subject must be set")
+	ErrOIDCNoGroup        = errors.New("oidc user is not member of any mapped group")
+
+	// Password hashing
+	ErrUnknownPasswordHasher    = errors.New("unknown password hasher")
+	ErrInvalidPasswordHash      = errors.New("invalid password hash")
+	ErrPasswordHasherVerifyOnly = errors.New("password hasher can only verify passwords")
 
 	// Model errors
 	ErrUsernameAlreadyExists = errors.New("user with this username already exists")
 	ErrPasswordsMustMatch    = errors.New("passwords must match")
 	ErrInvalidEmail          = errors.New("invalid email address")
 	ErrEmailAlreadyExists    = errors.New("user with this email already exists")
+	ErrUserNotFound          = errors.New("user not found")
 
 	ErrLicenseNotFound  = errors.New("license not found")
-	ErrPlatformNotFound = errors.New("license not found")
+	ErrPlatformNotFound = errors.New("platform not found")
+
+	ErrPackageNotFound        = errors.New("package not found")
+	ErrPackageVersionNotFound = errors.New("package version not found")
+	ErrSearchQueryEmpty       = errors.New("search query must contain at least one word")
+	ErrInvalidVersionsFilter  = errors.New("versions filter must be \"latest\" or \"any\"")
 
-	ErrPackageNotFound = errors.New("package not found")
+	// Index errors
+	ErrIndexNotFound        = errors.New("index not found")
+	ErrIndexInvalidName     = errors.New("invalid index name, use lowercase letters, digits, '.', '_', '-' and optional '/'")
+	ErrIndexAlreadyExists   = errors.New("index with this name already exists")
+	ErrIndexCycle           = errors.New("index cannot inherit from itself")
+	ErrIndexDefault         = errors.New("default index cannot be deleted")
+	ErrIndexNotEmpty        = errors.New("index with packages cannot be deleted")
+	ErrIndexUploadForbidden = errors.New("user cannot upload to index")
 
 	// http errors
+	ErrUserInactive               = errors.New("user inactive")
+	ErrUserNotAdmin               = errors.New("only user with admin access allowed")
 	ErrUserCannotRetrievePackages = errors.New("user cannot retrieve packages")
 	ErrUserCannotDownloadPackages = errors.New("user cannot download packages")
 
@@ -34,6 +69,61 @@ var (
 	ErrPostPackageInvalidAction  = errors.New("action not recognized")
 	ErrPostPackageInvalidName    = errors.New("invalid name")
 	ErrPostPackageInvalidVersion = errors.New("invalid version")
+	ErrPostPackageMissingFile    = errors.New("missing file content")
+
+	// Distribution filename errors
+	ErrFilenameUnknownFormat   = errors.New("unknown distribution format, upload wheel (.whl), sdist (.tar.gz, .zip), egg or windows installer")
+	ErrFilenameInvalid         = errors.New("invalid distribution filename")
+	ErrFilenameNameMismatch    = errors.New("filename doesn't match package name")
+	ErrFilenameVersionMismatch = errors.New("filename doesn't match package version")
+
+	// Distribution metadata errors
+	ErrMetadataNotFound      = errors.New("distribution metadata not found")
+	ErrMetadataTooLarge      = errors.New("distribution metadata too large")
+	ErrInvalidRequirement    = errors.New("invalid requirement")
+	ErrInvalidRequiresPython = errors.New("invalid requires_python, use comma separated version specifiers (e.g. \">=3.8\")")
+
+	// Package errors
+	ErrUserCannotModifyPackage = errors.New("user cannot modify package")
+	ErrUserCannotCreatePackage = errors.New("user cannot create package")
+
+	ErrUploadPolicyUnknown  = errors.New("unknown upload policy")
+	ErrFileAlreadyExists    = errors.New("file already exists, uploaded files cannot be replaced in this index (upload new version instead)")
+	ErrFileOverwriteDevOnly = errors.New("file already exists, only files of dev versions can be replaced in this index")
+	ErrFileNameReused       = errors.New("filename was already used by deleted file and cannot be reused (upload new version instead)")
+
+	// Promotion errors
+	ErrPromoteSameIndex     = errors.New("version is already in target index")
+	ErrPromoteVersionExists = errors.New("version already exists in target index")
+
+	// Stats errors
+	ErrStatsUnknownBreakdown = errors.New("unknown stats breakdown")
+
+	// Scheduler errors
+	ErrTaskNotFound = errors.New("task not found")
+	ErrTaskLocked   = errors.New("task is already running")
+
+	// Notification errors
+	ErrUnknownSMTPTLS        = errors.New("unknown smtp tls mode")
+	ErrNotificationQueueFull = errors.New("notification queue is full")
+	ErrNotificationsDisabled = errors.New("notifications are disabled")
+
+	// Logging errors
+	ErrUnknownLogLevel  = errors.New("unknown log level")
+	ErrUnknownLogFormat = errors.New("unknown log format")
+
+	// Proxy errors
+	ErrInvalidTrustedProxy = errors.New("invalid trusted proxy, use ip address or cidr")
+
+	// Readiness errors
+	ErrMigrationsPending = errors.New("database migrations are pending")
+
+	// Metrics errors
+	ErrMetricsInvalidToken = errors.New("invalid metrics token")
+
+	// Webhook errors
+	ErrWebhookInvalidURL   = errors.New("invalid webhook url")
+	ErrWebhookUnknownEvent = errors.New("unknown webhook event")
 
 	// generic error for all methods that return single object
 	ErrObjectNotFound = errors.New("object not found")
@@ -79,6 +79,91 @@ func FFPackagesFor(user User) FilterFunc {
 	}
 }
 
+/*
+FFPackagesVisibleFor filters packages visible to given user in admin interface. Admins see all packages, other users
+only packages they author or maintain.
+*/
+func FFPackagesVisibleFor(user User) FilterFunc {
+	return func(db *gorm.DB) *gorm.DB {
+		if user.IsAdmin {
+			return db
+		}
+		return FFPackagesFor(user)(db)
+	}
+}
+
+/*
+FFPackagesInIndexes filters packages by ids of indexes they belong to
+*/
+func FFPackagesInIndexes(ids ...uint) FilterFunc {
+	return func(db *gorm.DB) *gorm.DB {
+		if len(ids) == 0 {
+			return db.Where("index_id IN (0)")
+		}
+		return db.Where("index_id IN (?)", ids)
+	}
+}
+
+/*
+FFPackageName filters packages by name compared by PEP 503 ("Foo_Bar" matches "foo-bar"). Runs of separators are not
+collapsed in sql, so exact name is matched too.
+*/
+func FFPackageName(name string) FilterFunc {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Where("package.name = ? OR "+searchCanonicalName+" = ?", name, CanonicalPackageName(name))
+	}
+}
+
+// condition matching only latest version of package (by version order)
+const latestPackageVersionCondition = "package_version.id = (SELECT latest.id FROM package_version latest " +
+	"WHERE latest.package_id = package.id ORDER BY latest.version_order DESC, latest.id DESC LIMIT 1)"
+
+/*
+FFLatestPackageVersions filters package versions joined with their packages to latest versions
+*/
+func FFLatestPackageVersions() FilterFunc {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Where(latestPackageVersionCondition)
+	}
+}
+
+/*
+FFPackagesWithFileTag filters packages that have file with given compatibility tag (blank parts of tag match anything).
+When latest is set only latest version of package is checked, lacking inverts the filter (e.g. packages without
+manylinux_2_28_aarch64 wheel in their latest version).
+*/
+func FFPackagesWithFileTag(tag CompatibilityTag, latest bool, lacking bool) FilterFunc {
+	return func(db *gorm.DB) *gorm.DB {
+		query := "SELECT 1 FROM package_version " +
+			"JOIN package_version_file ON package_version_file.package_version_id = package_version.id " +
+			"JOIN package_version_file_tag ON package_version_file_tag.package_version_file_id = package_version_file.id " +
+			"LEFT JOIN platform ON platform.id = package_version_file_tag.platform_id " +
+			"WHERE package_version.package_id = package.id"
+		args := []interface{}{}
+
+		if latest {
+			query += " AND " + latestPackageVersionCondition
+		}
+		if tag.PythonTag != "" {
+			query += " AND package_version_file_tag.python_tag = ?"
+			args = append(args, tag.PythonTag)
+		}
+		if tag.ABITag != "" {
+			query += " AND package_version_file_tag.abi_tag = ?"
+			args = append(args, tag.ABITag)
+		}
+		if tag.PlatformTag != "" {
+			query += " AND platform.name = ?"
+			args = append(args, tag.PlatformTag)
+		}
+
+		if lacking {
+			return db.Where("NOT EXISTS ("+query+")", args...)
+		}
+		return db.Where("EXISTS ("+query+")", args...)
+	}
+}
+
 /*
 FFPreload add preloads to que
 */
@@ -119,6 +204,43 @@ func FFDownloadStatsPackage(pack *Package) FilterFunc {
 	}
 }
 
+/*
+FFDownloadStatsUser filters stats by packages visible to given user (see FFPackagesVisibleFor)
+*/
+func FFDownloadStatsUser(user User) FilterFunc {
+	return func(db *gorm.DB) *gorm.DB {
+		if user.IsAdmin {
+			return db
+		}
+
+		other := db.New()
+
+		// select ids of all packages visible to user
+		pids := []uint{}
+		if err := FFPackagesFor(user)(other.Model(Package{})).Pluck("id", &pids).Error; err != nil {
+			db.Error = err
+			return db
+		}
+
+		ids := []uint{}
+		if len(pids) > 0 {
+			if err := other.Model(PackageVersion{}).Where("package_id IN (?)", pids).Pluck("id", &ids).Error; err != nil {
+				db.Error = err
+				return db
+			}
+		}
+
+		// if ids, add where clause, otherwise select none
+		if len(ids) > 0 {
+			db = db.Where("package_version_id IN (?)", ids)
+		} else {
+			db = db.Where("package_version_id IN (0)")
+		}
+
+		return db
+	}
+}
+
 /*
 FFDownloadStatsPackage filters stats by package
 */
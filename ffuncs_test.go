@@ -0,0 +1,95 @@
+package core
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestFFPackagesWithFileTag(t *testing.T) {
+	tc := []struct {
+		name     string
+		tag      CompatibilityTag
+		latest   bool
+		lacking  bool
+		contains []string
+		missing  []string
+		args     string
+	}{
+		{
+			"any version",
+			CompatibilityTag{"cp311", "cp311", "manylinux_2_28_aarch64"},
+			false,
+			false,
+			[]string{"WHERE (EXISTS (SELECT 1 FROM package_version", "package_version_file_tag.python_tag = $1",
+				"package_version_file_tag.abi_tag = $2", "platform.name = $3"},
+			[]string{"NOT EXISTS", "latest.version_order"},
+			"[cp311 cp311 manylinux_2_28_aarch64]",
+		},
+		{
+			"latest version",
+			CompatibilityTag{"cp311", "cp311", "manylinux_2_28_aarch64"},
+			true,
+			false,
+			[]string{"WHERE (EXISTS (SELECT 1 FROM package_version", latestPackageVersionCondition},
+			[]string{"NOT EXISTS"},
+			"[cp311 cp311 manylinux_2_28_aarch64]",
+		},
+		{
+			"lacking in latest version",
+			CompatibilityTag{PlatformTag: "manylinux_2_28_aarch64"},
+			true,
+			true,
+			[]string{"WHERE (NOT EXISTS (SELECT 1 FROM package_version", latestPackageVersionCondition,
+				"platform.name = $1"},
+			[]string{"python_tag", "abi_tag"},
+			"[manylinux_2_28_aarch64]",
+		},
+		{
+			"lacking in any version",
+			CompatibilityTag{PythonTag: "py3"},
+			false,
+			true,
+			[]string{"WHERE (NOT EXISTS (SELECT 1 FROM package_version", "package_version_file_tag.python_tag = $1"},
+			[]string{"latest.version_order", "abi_tag", "platform.name"},
+			"[py3]",
+		},
+		{
+			"blank tag",
+			CompatibilityTag{},
+			false,
+			true,
+			[]string{"WHERE (NOT EXISTS (SELECT 1 FROM package_version",
+				"WHERE package_version.package_id = package.id))"},
+			[]string{"python_tag", "abi_tag", "platform.name"},
+			"[]",
+		},
+	}
+
+	for _, tt := range tc {
+		t.Run(tt.name, func(st *testing.T) {
+			db, fake := newTestDB(st, "postgres", nil)
+
+			packages := []Package{}
+			ApplyFilterFuncs(db, FFPackagesWithFileTag(tt.tag, tt.latest, tt.lacking)).Find(&packages)
+
+			queries := fake.Queries("package_version_file_tag")
+			if len(queries) != 1 {
+				st.Fatalf("expected single query, got %v", fake.queries)
+			}
+			for _, part := range tt.contains {
+				if !strings.Contains(queries[0], part) {
+					st.Errorf("query %q does not contain %q", queries[0], part)
+				}
+			}
+			for _, part := range tt.missing {
+				if strings.Contains(queries[0], part) {
+					st.Errorf("query %q should not contain %q", queries[0], part)
+				}
+			}
+			if args := fmt.Sprint(fake.args[len(fake.args)-1]); args != tt.args {
+				st.Errorf("query args are %v, expected %v", args, tt.args)
+			}
+		})
+	}
+}
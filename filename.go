@@ -0,0 +1,355 @@
+/*
+Distribution filenames
+
+Uploaded files must have filename of known distribution format that matches name and version of uploaded package:
+
+	mypackage-1.0.tar.gz                             sdist (also .zip, .tar.bz2, .tar.xz, .tgz)
+	mypackage-1.0-py3-none-any.whl                   wheel (PEP 427), optional build tag after version
+	mypackage-1.0-py3.8.egg                          egg, optional python version and platform
+	mypackage-1.0.win-amd64-py3.8.exe                legacy windows installers (also .msi)
+
+Wheel tags can be compressed ("py2.py3"), they are stored as they are in filename and expanded to single tags by
+ExpandCompatibilityTags.
+*/
+package core
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+var (
+	// wheel build tag must start with digit
+	wheelBuildRegexp = regexp.MustCompile(`^[0-9][A-Za-z0-9_.]*$`)
+
+	// tags consist of letters, digits, underscores and dots (compressed tag sets)
+	wheelTagRegexp = regexp.MustCompile(`^[A-Za-z0-9_.]+$`)
+
+	// egg suffix "-py3.8" and optional "-linux-x86_64"
+	eggRegexp = regexp.MustCompile(`^(.+?)-([^-]+)(?:-(py[0-9.]+)(?:-(.+))?)?$`)
+
+	// legacy installer stem "name-version.platform" with optional "-py3.8"
+	legacyRegexp = regexp.MustCompile(`^(.+?)(?:-(py[0-9.]+))?$`)
+
+	// separators of project names (PEP 503)
+	projectNameSeparators = regexp.MustCompile(`[-_.]+`)
+
+	// PEP 440 version: epoch, release, pre, post (also implicit "-N"), dev and local segments
+	versionRegexp = regexp.MustCompile(`^v?(?:([0-9]+)!)?([0-9]+(?:\.[0-9]+)*)` +
+		`(?:[-_.]?(a|b|c|rc|alpha|beta|pre|preview)[-_.]?([0-9]*))?` +
+		`(?:-([0-9]+)|[-_.]?(post|rev|r)[-_.]?([0-9]*))?` +
+		`(?:[-_.]?(dev)[-_.]?([0-9]*))?` +
+		`(?:\+([a-z0-9]+(?:[-_.][a-z0-9]+)*))?$`)
+
+	// alternative spellings of PEP 440 pre-release segment
+	versionPreAliases = map[string]string{
+		"alpha":   "a",
+		"beta":    "b",
+		"c":       "rc",
+		"pre":     "rc",
+		"preview": "rc",
+	}
+
+	// separators of local version segment
+	versionLocalSeparators = regexp.MustCompile(`[-_.]`)
+
+	// sdist extensions, longest first
+	sdistExtensions = []string{".tar.gz", ".tar.bz2", ".tar.xz", ".tar.Z", ".tgz", ".tbz", ".zip", ".tar"}
+)
+
+/*
+DistributionFilename is parsed filename of distribution. Name and version are as they appear in filename (sdist and
+legacy filenames are split at last hyphen, Matches compares them with package name).
+*/
+type DistributionFilename struct {
+	Filename    string
+	PackageType string
+	Name        string
+	Version     string
+	BuildTag    string
+	PythonTag   string
+	ABITag      string
+	PlatformTag string
+
+	// filename without extension
+	stem string
+}
+
+/*
+ParseDistributionFilename parses filename of wheel, sdist, egg or legacy windows installer
+*/
+func ParseDistributionFilename(filename string) (result DistributionFilename, err error) {
+	result = DistributionFilename{Filename: filename}
+
+	if filename == "" || strings.ContainsAny(filename, "/\\") {
+		err = fmt.Errorf("%v: %q", ErrFilenameInvalid, filename)
+		return
+	}
+
+	switch {
+	case strings.HasSuffix(filename, ".whl"):
+		result.PackageType = PACKAGE_TYPE_WHEEL
+		result.stem = strings.TrimSuffix(filename, ".whl")
+		err = result.parseWheel()
+	case strings.HasSuffix(filename, ".egg"):
+		result.PackageType = PACKAGE_TYPE_EGG
+		result.stem = strings.TrimSuffix(filename, ".egg")
+		err = result.parseEgg()
+	case strings.HasSuffix(filename, ".exe"), strings.HasSuffix(filename, ".msi"):
+		result.PackageType = PACKAGE_TYPE_WININST
+		if strings.HasSuffix(filename, ".msi") {
+			result.PackageType = PACKAGE_TYPE_MSI
+		}
+		result.stem = filename[:len(filename)-4]
+		err = result.parseLegacy()
+	default:
+		for _, extension := range sdistExtensions {
+			if strings.HasSuffix(filename, extension) {
+				result.PackageType = PACKAGE_TYPE_SDIST
+				result.stem = strings.TrimSuffix(filename, extension)
+				err = result.parseSdist()
+				return
+			}
+		}
+		err = fmt.Errorf("%v: %q", ErrFilenameUnknownFormat, filename)
+	}
+
+	return
+}
+
+/*
+parseWheel parses "{name}-{version}(-{build})?-{python}-{abi}-{platform}"
+*/
+func (d *DistributionFilename) parseWheel() error {
+	parts := strings.Split(d.stem, "-")
+	if len(parts) != 5 && len(parts) != 6 {
+		return fmt.Errorf("%v: %q must be name-version(-build)-python-abi-platform.whl", ErrFilenameInvalid, d.Filename)
+	}
+
+	d.Name, d.Version = parts[0], parts[1]
+	if len(parts) == 6 {
+		d.BuildTag = parts[2]
+		if !wheelBuildRegexp.MatchString(d.BuildTag) {
+			return fmt.Errorf("%v: build tag %q of %q must start with digit", ErrFilenameInvalid, d.BuildTag, d.Filename)
+		}
+	}
+
+	tags := parts[len(parts)-3:]
+	for _, tag := range tags {
+		if !wheelTagRegexp.MatchString(tag) {
+			return fmt.Errorf("%v: invalid tag %q in %q", ErrFilenameInvalid, tag, d.Filename)
+		}
+	}
+	d.PythonTag, d.ABITag, d.PlatformTag = tags[0], tags[1], tags[2]
+
+	if d.Name == "" || d.Version == "" {
+		return fmt.Errorf("%v: %q", ErrFilenameInvalid, d.Filename)
+	}
+	return nil
+}
+
+/*
+parseEgg parses "{name}-{version}(-py{pyversion}(-{platform})?)?"
+*/
+func (d *DistributionFilename) parseEgg() error {
+	match := eggRegexp.FindStringSubmatch(d.stem)
+	if match == nil {
+		return fmt.Errorf("%v: %q must be name-version(-pyX.Y(-platform)).egg", ErrFilenameInvalid, d.Filename)
+	}
+
+	d.Name, d.Version, d.PythonTag, d.PlatformTag = match[1], match[2], match[3], match[4]
+	return nil
+}
+
+/*
+parseSdist splits "{name}-{version}" at last hyphen
+*/
+func (d *DistributionFilename) parseSdist() error {
+	index := strings.LastIndex(d.stem, "-")
+	if index <= 0 || index == len(d.stem)-1 {
+		return fmt.Errorf("%v: %q must be name-version with archive extension", ErrFilenameInvalid, d.Filename)
+	}
+
+	d.Name, d.Version = d.stem[:index], d.stem[index+1:]
+	d.PythonTag = "source"
+	return nil
+}
+
+/*
+parseLegacy parses "{name}-{version}.{platform}(-py{pyversion})?" of windows installers
+*/
+func (d *DistributionFilename) parseLegacy() error {
+	match := legacyRegexp.FindStringSubmatch(d.stem)
+	if match == nil {
+		return fmt.Errorf("%v: %q must be name-version.platform", ErrFilenameInvalid, d.Filename)
+	}
+
+	stem := match[1]
+	d.PythonTag = match[2]
+
+	// platform starts with "win" or "linux" after version
+	for _, platform := range []string{".win", ".linux", ".macosx"} {
+		if index := strings.LastIndex(stem, platform); index > 0 {
+			d.PlatformTag = stem[index+1:]
+			stem = stem[:index]
+			break
+		}
+	}
+
+	index := strings.LastIndex(stem, "-")
+	if index <= 0 || index == len(stem)-1 {
+		return fmt.Errorf("%v: %q must be name-version.platform", ErrFilenameInvalid, d.Filename)
+	}
+
+	d.Name, d.Version = stem[:index], stem[index+1:]
+	d.stem = stem
+	return nil
+}
+
+/*
+Matches returns error when filename doesn't belong to package name and version. Names are compared by PEP 503,
+versions by their PEP 440 components (so "1.0-dev1" matches "1.0.dev1").
+*/
+func (d *DistributionFilename) Matches(name, version string) error {
+	// sdist and legacy names can contain hyphens, split filename by length of package name
+	if d.PackageType == PACKAGE_TYPE_SDIST || d.PackageType == PACKAGE_TYPE_WININST || d.PackageType == PACKAGE_TYPE_MSI {
+		if len(d.stem) > len(name)+1 && d.stem[len(name)] == '-' && CanonicalPackageName(d.stem[:len(name)]) == CanonicalPackageName(name) {
+			d.Name, d.Version = d.stem[:len(name)], d.stem[len(name)+1:]
+		}
+	}
+
+	if CanonicalPackageName(d.Name) != CanonicalPackageName(name) {
+		return fmt.Errorf("%v: %q belongs to %q, not %q", ErrFilenameNameMismatch, d.Filename, d.Name, name)
+	}
+
+	if !EqualVersions(d.Version, version) {
+		return fmt.Errorf("%v: %q has version %q, not %q", ErrFilenameVersionMismatch, d.Filename, d.Version, version)
+	}
+
+	return nil
+}
+
+/*
+Apply stores parsed tags to package version file
+*/
+func (d DistributionFilename) Apply(file *PackageVersionFile) {
+	file.PackageType = d.PackageType
+	file.BuildTag = d.BuildTag
+	file.PythonTag = d.PythonTag
+	file.ABITag = d.ABITag
+	file.PlatformTag = d.PlatformTag
+}
+
+/*
+CanonicalPackageName returns PEP 503 normalized name (lowercase, runs of "-", "_" and "." replaced by "-")
+*/
+func CanonicalPackageName(name string) string {
+	return projectNameSeparators.ReplaceAllString(strings.ToLower(strings.TrimSpace(name)), "-")
+}
+
+/*
+EqualVersions returns whether versions are equal after PEP 440 normalization: spelling alternatives and separators of
+pre, post and dev segments, leading zeros of numbers and trailing zeros of release ("1.0" equals "1.0.0"). Local
+version ("+local") and implicit post release ("-1") stay distinct from release segments. Versions that aren't valid
+PEP 440 versions are compared case insensitively.
+*/
+func EqualVersions(first, second string) bool {
+	return NormalizeVersion(first) == NormalizeVersion(second)
+}
+
+/*
+NormalizeVersion returns normalized PEP 440 version with trailing zeros of release removed, invalid versions are
+returned lowercase.
+*/
+func NormalizeVersion(version string) string {
+	version = strings.ToLower(strings.TrimSpace(version))
+
+	match := versionRegexp.FindStringSubmatch(version)
+	if match == nil {
+		return version
+	}
+
+	result := ""
+	if match[1] != "" && versionNumber(match[1]) != "0" {
+		result = versionNumber(match[1]) + "!"
+	}
+
+	release := strings.Split(match[2], ".")
+	for i := range release {
+		release[i] = versionNumber(release[i])
+	}
+	for len(release) > 1 && release[len(release)-1] == "0" {
+		release = release[:len(release)-1]
+	}
+	result += strings.Join(release, ".")
+
+	if match[3] != "" {
+		pre := match[3]
+		if alias, ok := versionPreAliases[pre]; ok {
+			pre = alias
+		}
+		result += pre + versionNumber(match[4])
+	}
+
+	if match[5] != "" {
+		result += ".post" + versionNumber(match[5])
+	} else if match[6] != "" {
+		result += ".post" + versionNumber(match[7])
+	}
+
+	if match[8] != "" {
+		result += ".dev" + versionNumber(match[9])
+	}
+
+	if match[10] != "" {
+		local := versionLocalSeparators.Split(match[10], -1)
+		for i, part := range local {
+			if strings.Trim(part, "0123456789") == "" {
+				local[i] = versionNumber(part)
+			}
+		}
+		result += "+" + strings.Join(local, ".")
+	}
+
+	return result
+}
+
+/*
+versionNumber returns number without leading zeros, empty number is zero
+*/
+func versionNumber(number string) string {
+	if number = strings.TrimLeft(number, "0"); number == "" {
+		return "0"
+	}
+	return number
+}
+
+/*
+CompatibilityTag is single python, abi and platform tag triple
+*/
+type CompatibilityTag struct {
+	PythonTag   string
+	ABITag      string
+	PlatformTag string
+}
+
+/*
+ExpandCompatibilityTags expands compressed tag sets of wheel to all combinations of single tags. Eggs and legacy
+installers have single tag as it is in filename, sdists have no tags.
+*/
+func ExpandCompatibilityTags(packageType, python, abi, platform string) (result []CompatibilityTag) {
+	switch packageType {
+	case PACKAGE_TYPE_WHEEL:
+		for _, pythonTag := range strings.Split(python, ".") {
+			for _, abiTag := range strings.Split(abi, ".") {
+				for _, platformTag := range strings.Split(platform, ".") {
+					result = append(result, CompatibilityTag{pythonTag, abiTag, platformTag})
+				}
+			}
+		}
+	case PACKAGE_TYPE_EGG, PACKAGE_TYPE_WININST, PACKAGE_TYPE_MSI:
+		result = append(result, CompatibilityTag{python, abi, platform})
+	}
+	return
+}
@@ -0,0 +1,148 @@
+package core
+
+import (
+	"testing"
+)
+
+func TestParseDistributionFilename(t *testing.T) {
+	tc := []struct {
+		filename    string
+		packageType string
+		name        string
+		version     string
+		build       string
+		python      string
+		abi         string
+		platform    string
+	}{
+		{"mypackage-1.0-py3-none-any.whl", PACKAGE_TYPE_WHEEL, "mypackage", "1.0", "", "py3", "none", "any"},
+		{"mypackage-1.0-1b-py2.py3-none-any.whl", PACKAGE_TYPE_WHEEL, "mypackage", "1.0", "1b", "py2.py3", "none", "any"},
+		{"my_package-2.0rc1-cp38-cp38-manylinux1_x86_64.whl", PACKAGE_TYPE_WHEEL, "my_package", "2.0rc1", "", "cp38", "cp38", "manylinux1_x86_64"},
+		{"mypackage-1.0.tar.gz", PACKAGE_TYPE_SDIST, "mypackage", "1.0", "", "source", "", ""},
+		{"my-package-1.0.zip", PACKAGE_TYPE_SDIST, "my-package", "1.0", "", "source", "", ""},
+		{"mypackage-1.0.tar.bz2", PACKAGE_TYPE_SDIST, "mypackage", "1.0", "", "source", "", ""},
+		{"mypackage-1.0.egg", PACKAGE_TYPE_EGG, "mypackage", "1.0", "", "", "", ""},
+		{"mypackage-1.0-py3.8.egg", PACKAGE_TYPE_EGG, "mypackage", "1.0", "", "py3.8", "", ""},
+		{"mypackage-1.0-py3.8-linux-x86_64.egg", PACKAGE_TYPE_EGG, "mypackage", "1.0", "", "py3.8", "", "linux-x86_64"},
+		{"mypackage-1.0.win-amd64-py3.8.exe", PACKAGE_TYPE_WININST, "mypackage", "1.0", "", "py3.8", "", "win-amd64"},
+		{"mypackage-1.0.win32.exe", PACKAGE_TYPE_WININST, "mypackage", "1.0", "", "", "", "win32"},
+		{"mypackage-1.0.win-amd64.msi", PACKAGE_TYPE_MSI, "mypackage", "1.0", "", "", "", "win-amd64"},
+	}
+
+	for _, tt := range tc {
+		t.Run(tt.filename, func(st *testing.T) {
+			result, err := ParseDistributionFilename(tt.filename)
+			if err != nil {
+				st.Fatalf("ParseDistributionFilename(%q) returned error: %v", tt.filename, err)
+			}
+
+			got := []string{result.PackageType, result.Name, result.Version, result.BuildTag, result.PythonTag, result.ABITag, result.PlatformTag}
+			expected := []string{tt.packageType, tt.name, tt.version, tt.build, tt.python, tt.abi, tt.platform}
+			for i := range got {
+				if got[i] != expected[i] {
+					st.Errorf("ParseDistributionFilename(%q) returned %q, expected %q", tt.filename, got, expected)
+					break
+				}
+			}
+		})
+	}
+}
+
+func TestParseDistributionFilenameInvalid(t *testing.T) {
+	for _, filename := range []string{
+		"",
+		"dir/mypackage-1.0.tar.gz",
+		"mypackage-1.0.rpm",
+		".whl",
+		"mypackage-1.0.whl",
+		"mypackage-1.0-x-py3-none-any.whl",
+		"mypackage-1.0-py3-none-any!.whl",
+		"-1.0-py3-none-any.whl",
+		".tar.gz",
+		"mypackage.tar.gz",
+		"mypackage-.tar.gz",
+		"-1.0.tar.gz",
+		".egg",
+		".exe",
+		".msi",
+		"mypackage.exe",
+		"mypackage-.win32.exe",
+	} {
+		if _, err := ParseDistributionFilename(filename); err == nil {
+			t.Errorf("ParseDistributionFilename(%q) should return error", filename)
+		}
+	}
+}
+
+func TestDistributionFilenameMatches(t *testing.T) {
+	tc := []struct {
+		filename string
+		name     string
+		version  string
+		matches  bool
+	}{
+		{"my-package-1.0.tar.gz", "my_package", "1.0", true},
+		{"My.Package-1.0-py3-none-any.whl", "my-package", "1.0.0", true},
+		{"mypackage-1.0-dev1.tar.gz", "mypackage", "1.0.dev1", true},
+		{"mypackage-1.0.win32.exe", "mypackage", "1.0", true},
+		{"mypackage-1.0.tar.gz", "otherpackage", "1.0", false},
+		{"mypackage-1.0.tar.gz", "mypackage", "1.0.1", false},
+		{"mypackage-1.0-1.tar.gz", "mypackage", "1.0.1", false},
+	}
+
+	for _, tt := range tc {
+		t.Run(tt.filename, func(st *testing.T) {
+			result, err := ParseDistributionFilename(tt.filename)
+			if err != nil {
+				st.Fatalf("ParseDistributionFilename(%q) returned error: %v", tt.filename, err)
+			}
+			if err = result.Matches(tt.name, tt.version); (err == nil) != tt.matches {
+				st.Errorf("Matches(%q, %q) of %q returned %v", tt.name, tt.version, tt.filename, err)
+			}
+		})
+	}
+}
+
+func TestEqualVersions(t *testing.T) {
+	tc := []struct {
+		first  string
+		second string
+		equal  bool
+	}{
+		{"1.0", "1.0", true},
+		{"1.0", "1.0.0", true},
+		{"1.0.0.0", "1", true},
+		{"v1.0", "1.0", true},
+		{"01.002", "1.2", true},
+		{"0!1.0", "1.0", true},
+		{"1.0a1", "1.0alpha1", true},
+		{"1.0b2", "1.0-beta.2", true},
+		{"1.0c1", "1.0rc1", true},
+		{"1.0pre1", "1.0rc1", true},
+		{"1.0-1", "1.0.post1", true},
+		{"1.0r1", "1.0.post1", true},
+		{"1.0.post", "1.0.post0", true},
+		{"1.0-dev1", "1.0.dev1", true},
+		{"1.0+Ubuntu-1", "1.0+ubuntu.1", true},
+		{"1.0+01", "1.0+1", true},
+		{"1.0+1", "1.0.1", false},
+		{"1.0-1", "1.0.1", false},
+		{"1.0+1", "1.0-1", false},
+		{"1.0+1", "1.0", false},
+		{"1.0", "1.0.1", false},
+		{"1.0a1", "1.0b1", false},
+		{"1.0.dev1", "1.0", false},
+		{"1!1.0", "1.0", false},
+		{"1.0.10", "1.0.1", false},
+		{"latest", "LATEST", true},
+		{"latest", "1.0", false},
+	}
+
+	for _, tt := range tc {
+		t.Run(tt.first+" "+tt.second, func(st *testing.T) {
+			if result := EqualVersions(tt.first, tt.second); result != tt.equal {
+				st.Errorf("EqualVersions(%q, %q) returned %v", tt.first, tt.second, result)
+			}
+		})
+	}
+}
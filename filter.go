@@ -5,6 +5,8 @@ package core
 
 import (
 	"net/http"
+	"strings"
+	"time"
 
 	"github.com/gorilla/schema"
 	"github.com/jinzhu/gorm"
@@ -55,3 +57,78 @@ func (u UserListFilter) Apply(queryset *gorm.DB) *gorm.DB {
 	}
 	return queryset
 }
+
+/*
+NewAuditLogListFilter returns new AuditLogListFilter
+*/
+func NewAuditLogListFilter(r *http.Request) Filter {
+	query := r.URL.Query()
+
+	result := AuditLogListFilter{
+		Actor:      strings.TrimSpace(query.Get("actor")),
+		Action:     strings.TrimSpace(query.Get("action")),
+		TargetType: strings.TrimSpace(query.Get("target_type")),
+		TargetID:   strings.TrimSpace(query.Get("target_id")),
+		Source:     strings.TrimSpace(query.Get("source")),
+	}
+
+	if since, err := TimeParse(query.Get("since")); err == nil {
+		result.Since = &since
+	}
+	if until, err := TimeParse(query.Get("until")); err == nil {
+		result.Until = &until
+	}
+
+	return result
+}
+
+/*
+AuditLogListFilter filters audit log from url. Actor can be given as username or user id, action ending with ".*"
+matches all actions with given prefix (e.g. "user.*").
+*/
+type AuditLogListFilter struct {
+	Actor      string
+	Action     string
+	TargetType string
+	TargetID   string
+	Source     string
+	Since      *time.Time
+	Until      *time.Time
+}
+
+/*
+Apply applies filter to queryset
+*/
+func (a AuditLogListFilter) Apply(queryset *gorm.DB) *gorm.DB {
+	filters := []FilterFunc{}
+
+	if a.Actor != "" {
+		if id := Atoui(a.Actor); id > 0 {
+			filters = append(filters, FFWhere("actor_id = ? OR actor_username = ?", id, a.Actor))
+		} else {
+			filters = append(filters, FFWhere("actor_username = ?", a.Actor))
+		}
+	}
+	if strings.HasSuffix(a.Action, ".*") {
+		filters = append(filters, FFWhere("action LIKE ?", strings.TrimSuffix(a.Action, "*")+"%"))
+	} else if a.Action != "" {
+		filters = append(filters, FFWhere("action = ?", a.Action))
+	}
+	if a.TargetType != "" {
+		filters = append(filters, FFWhere("target_type = ?", a.TargetType))
+	}
+	if a.TargetID != "" {
+		filters = append(filters, FFWhere("target_id = ?", a.TargetID))
+	}
+	if a.Source != "" {
+		filters = append(filters, FFWhere("source = ?", a.Source))
+	}
+	if a.Since != nil {
+		filters = append(filters, FFWhere("created_at >= ?", *a.Since))
+	}
+	if a.Until != nil {
+		filters = append(filters, FFWhere("created_at < ?", *a.Until))
+	}
+
+	return ApplyFilterFuncs(queryset, filters...)
+}
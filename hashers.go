@@ -0,0 +1,528 @@
+/*
+Password hashers
+
+Password hashes are stored with algorithm prefix, hasher is selected by this prefix. Prefix is either first part
+of hash ("argon2id$...", "scrypt$...", "pbkdf2_sha256$...") or, for hashes in modular crypt format, part between
+first two dollar signs ("$2y$...", "$pbkdf2-sha256$...").
+
+New passwords are hashed with default hasher (argon2id). Other hashers are kept so users imported from other
+systems (pypiserver/devpi htpasswd, Django, passlib) can log in, their passwords are rehashed with default hasher
+on successful login.
+*/
+package core
+
+import (
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"hash"
+	"strconv"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+	"golang.org/x/crypto/pbkdf2"
+	"golang.org/x/crypto/scrypt"
+)
+
+/*
+PasswordHasher hashes and verifies passwords in single format
+*/
+type PasswordHasher interface {
+
+	// Algorithms returns list of prefixes this hasher handles
+	Algorithms() []string
+
+	// Encode returns encoded hash of password, verify only hashers return ErrPasswordHasherVerifyOnly
+	Encode(password string) (string, error)
+
+	// Verify returns whether password matches encoded hash
+	Verify(password, encoded string) bool
+
+	// MustUpdate returns whether encoded hash was created with outdated parameters
+	MustUpdate(encoded string) bool
+}
+
+/*
+NewPasswordHashers returns registry with all available hashers, secret is used by scrypt hasher. def is algorithm
+used to hash new passwords.
+*/
+func NewPasswordHashers(secret string, def string) (result *PasswordHashers, err error) {
+	result = &PasswordHashers{
+		hashers: map[string]PasswordHasher{},
+	}
+
+	result.Register(&argon2Hasher{
+		time:    PASSWORD_ARGON2_TIME,
+		memory:  PASSWORD_ARGON2_MEMORY,
+		threads: PASSWORD_ARGON2_THREADS,
+	})
+	result.Register(&scryptHasher{secret: secret})
+	result.Register(&bcryptHasher{})
+	result.Register(&pbkdf2Hasher{})
+	result.Register(&passlibPBKDF2Hasher{})
+
+	// only some hashers can be used to hash new passwords
+	if !StringListContains(AVAILABLE_PASSWORD_HASHERS, def) {
+		return nil, ErrUnknownPasswordHasher
+	}
+
+	if result.def, err = result.get(def); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+/*
+PasswordHashers is registry of password hashers keyed by algorithm prefix
+*/
+type PasswordHashers struct {
+	hashers map[string]PasswordHasher
+	def     PasswordHasher
+}
+
+/*
+Register registers hasher for all its algorithm prefixes
+*/
+func (p *PasswordHashers) Register(hasher PasswordHasher) {
+	for _, algorithm := range hasher.Algorithms() {
+		p.hashers[algorithm] = hasher
+	}
+}
+
+/*
+Encode hashes password with default hasher
+*/
+func (p *PasswordHashers) Encode(password string) (string, error) {
+	return p.def.Encode(password)
+}
+
+/*
+Verify verifies password against encoded hash with hasher selected by hash prefix
+*/
+func (p *PasswordHashers) Verify(password, encoded string) bool {
+	hasher, err := p.Get(encoded)
+	if err != nil {
+		return false
+	}
+	return hasher.Verify(password, encoded)
+}
+
+/*
+MustUpdate returns whether encoded hash should be rehashed with default hasher
+*/
+func (p *PasswordHashers) MustUpdate(encoded string) bool {
+	hasher, err := p.Get(encoded)
+	if err != nil {
+		return true
+	}
+	if hasher != p.def {
+		return true
+	}
+	return hasher.MustUpdate(encoded)
+}
+
+/*
+Get returns hasher for encoded hash
+*/
+func (p *PasswordHashers) Get(encoded string) (PasswordHasher, error) {
+	return p.get(PasswordAlgorithm(encoded))
+}
+
+/*
+IsKnown returns whether encoded hash can be verified by any registered hasher
+*/
+func (p *PasswordHashers) IsKnown(encoded string) bool {
+	_, err := p.Get(encoded)
+	return err == nil
+}
+
+/*
+get returns hasher by algorithm
+*/
+func (p *PasswordHashers) get(algorithm string) (PasswordHasher, error) {
+	if hasher, ok := p.hashers[algorithm]; ok {
+		return hasher, nil
+	}
+	return nil, ErrUnknownPasswordHasher
+}
+
+/*
+PasswordAlgorithm returns algorithm prefix of encoded hash
+*/
+func PasswordAlgorithm(encoded string) string {
+	if strings.HasPrefix(encoded, "$") {
+		encoded = encoded[1:]
+	}
+	return strings.SplitN(encoded, "$", 2)[0]
+}
+
+/*
+argon2Hasher hashes passwords with argon2id, format is:
+
+	argon2id$v=19$m=65536,t=3,p=2$salt$hash
+
+Hashes in modular crypt format ($argon2id$v=19$...) as produced by passlib and argon2 cli are accepted too.
+*/
+type argon2Hasher struct {
+	time    uint32
+	memory  uint32
+	threads uint8
+}
+
+/*
+Algorithms returns argon2id
+*/
+func (a *argon2Hasher) Algorithms() []string {
+	return []string{"argon2id"}
+}
+
+/*
+Encode hashes password with argon2id
+*/
+func (a *argon2Hasher) Encode(password string) (string, error) {
+	salt := GenerateSalt(PASSWORD_ARGON2_SALT_BYTES)
+	hash := argon2.IDKey([]byte(password), salt, a.time, a.memory, a.threads, PASSWORD_ARGON2_HASH_BYTES)
+
+	return fmt.Sprintf("argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2.Version, a.memory, a.time, a.threads,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(hash),
+	), nil
+}
+
+/*
+Verify verifies password against argon2id hash
+*/
+func (a *argon2Hasher) Verify(password, encoded string) bool {
+	memory, time, threads, salt, hash, err := a.decode(encoded)
+	if err != nil {
+		return false
+	}
+
+	other := argon2.IDKey([]byte(password), salt, time, memory, threads, uint32(len(hash)))
+	return subtle.ConstantTimeCompare(hash, other) == 1
+}
+
+/*
+MustUpdate returns true when hash parameters differ from current ones
+*/
+func (a *argon2Hasher) MustUpdate(encoded string) bool {
+	memory, time, threads, _, _, err := a.decode(encoded)
+	if err != nil {
+		return true
+	}
+	return memory != a.memory || time != a.time || threads != a.threads || strings.HasPrefix(encoded, "$")
+}
+
+/*
+decode parses argon2id hash
+*/
+func (a *argon2Hasher) decode(encoded string) (memory, time uint32, threads uint8, salt, hash []byte, err error) {
+	parts := strings.Split(strings.TrimPrefix(encoded, "$"), "$")
+	if len(parts) != 5 || parts[0] != "argon2id" {
+		err = ErrInvalidPasswordHash
+		return
+	}
+
+	var version int
+	if _, err = fmt.Sscanf(parts[1], "v=%d", &version); err != nil || version != argon2.Version {
+		err = ErrInvalidPasswordHash
+		return
+	}
+
+	if _, err = fmt.Sscanf(parts[2], "m=%d,t=%d,p=%d", &memory, &time, &threads); err != nil {
+		err = ErrInvalidPasswordHash
+		return
+	}
+
+	if salt, err = base64.RawStdEncoding.DecodeString(strings.TrimRight(parts[3], "=")); err != nil {
+		return
+	}
+	if hash, err = base64.RawStdEncoding.DecodeString(strings.TrimRight(parts[4], "=")); err != nil {
+		return
+	}
+
+	if len(hash) == 0 || len(hash) > PASSWORD_ARGON2_MAX_HASH_BYTES || time == 0 || threads == 0 {
+		err = ErrInvalidPasswordHash
+	}
+
+	// reject parameters that would exhaust memory or cpu
+	if time > PASSWORD_ARGON2_MAX_TIME || memory > PASSWORD_ARGON2_MAX_MEMORY || threads > PASSWORD_ARGON2_MAX_THREADS {
+		err = ErrInvalidPasswordHash
+	}
+
+	return
+}
+
+/*
+scryptHasher is original gopypi hasher, format is:
+
+	scrypt$iterations$salt$hash
+
+Secret key is appended to salt, so hashes cannot be verified without it.
+*/
+type scryptHasher struct {
+	secret string
+}
+
+/*
+Algorithms returns scrypt
+*/
+func (s *scryptHasher) Algorithms() []string {
+	return []string{"scrypt"}
+}
+
+/*
+Encode hashes password with scrypt
+*/
+func (s *scryptHasher) Encode(password string) (result string, err error) {
+	salt := GenerateSalt(PASSWORD_SALT_BYTES)
+
+	var hash []byte
+	if hash, err = scrypt.Key([]byte(password), append(salt, []byte(s.secret)...), PASSWORD_ITERATIONS, 8, 1, PASSWORD_HASH_BYTES); err != nil {
+		return
+	}
+
+	result = fmt.Sprintf("scrypt$%v$%v$%v", PASSWORD_ITERATIONS,
+		base64.StdEncoding.EncodeToString(salt),
+		base64.StdEncoding.EncodeToString(hash),
+	)
+	return
+}
+
+/*
+Verify verifies password against scrypt hash
+*/
+func (s *scryptHasher) Verify(password, encoded string) bool {
+	parts := strings.SplitN(encoded, "$", 4)
+	if len(parts) != 4 {
+		return false
+	}
+
+	iterations, err := strconv.Atoi(parts[1])
+	if err != nil || iterations > PASSWORD_MAX_ITERATIONS {
+		return false
+	}
+
+	salt, err := base64.StdEncoding.DecodeString(parts[2])
+	if err != nil {
+		return false
+	}
+
+	hash, err := base64.StdEncoding.DecodeString(parts[3])
+	if err != nil || len(hash) == 0 || len(hash) > PASSWORD_HASH_BYTES {
+		return false
+	}
+
+	other, err := scrypt.Key([]byte(password), append(salt, []byte(s.secret)...), iterations, 8, 1, len(hash))
+	if err != nil {
+		return false
+	}
+
+	return subtle.ConstantTimeCompare(hash, other) == 1
+}
+
+/*
+MustUpdate returns true when iterations differ from current ones
+*/
+func (s *scryptHasher) MustUpdate(encoded string) bool {
+	parts := strings.SplitN(encoded, "$", 4)
+	return len(parts) != 4 || parts[1] != strconv.Itoa(PASSWORD_ITERATIONS)
+}
+
+/*
+bcryptHasher verifies bcrypt hashes ($2a$, $2b$, $2y$ as produced by htpasswd -B) and Django bcrypt hashes
+(bcrypt$$2b$...).
+*/
+type bcryptHasher struct{}
+
+/*
+Algorithms returns bcrypt prefixes
+*/
+func (b *bcryptHasher) Algorithms() []string {
+	return []string{"2a", "2b", "2y", "bcrypt"}
+}
+
+/*
+Encode hashes password with bcrypt
+*/
+func (b *bcryptHasher) Encode(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), PASSWORD_BCRYPT_COST)
+	return string(hash), err
+}
+
+/*
+Verify verifies password against bcrypt hash
+*/
+func (b *bcryptHasher) Verify(password, encoded string) bool {
+	// reject cost that would exhaust cpu
+	if cost, err := bcrypt.Cost(b.normalize(encoded)); err != nil || cost > PASSWORD_BCRYPT_MAX_COST {
+		return false
+	}
+
+	return bcrypt.CompareHashAndPassword(b.normalize(encoded), []byte(password)) == nil
+}
+
+/*
+MustUpdate returns true when cost differs from current one
+*/
+func (b *bcryptHasher) MustUpdate(encoded string) bool {
+	cost, err := bcrypt.Cost(b.normalize(encoded))
+	return err != nil || cost != PASSWORD_BCRYPT_COST
+}
+
+/*
+normalize strips Django prefix and converts $2y$/$2b$ to $2a$, they differ only in bug fixes of other
+implementations.
+*/
+func (b *bcryptHasher) normalize(encoded string) []byte {
+	encoded = strings.TrimPrefix(encoded, "bcrypt$")
+	if strings.HasPrefix(encoded, "$2y$") || strings.HasPrefix(encoded, "$2b$") {
+		encoded = "$2a$" + encoded[4:]
+	}
+	return []byte(encoded)
+}
+
+/*
+pbkdf2Hasher handles Django PBKDF2 hashes, format is:
+
+	pbkdf2_sha256$iterations$salt$hash
+
+Salt is used as is, hash is base64 encoded.
+*/
+type pbkdf2Hasher struct{}
+
+/*
+Algorithms returns Django pbkdf2 prefixes
+*/
+func (p *pbkdf2Hasher) Algorithms() []string {
+	return []string{"pbkdf2_sha256", "pbkdf2_sha1"}
+}
+
+/*
+Encode hashes password with pbkdf2 sha256 in Django format
+*/
+func (p *pbkdf2Hasher) Encode(password string) (string, error) {
+	salt := base64.RawURLEncoding.EncodeToString(GenerateSalt(PASSWORD_SALT_BYTES / 2))
+	hash := pbkdf2.Key([]byte(password), []byte(salt), PASSWORD_PBKDF2_ITERATIONS, sha256.Size, sha256.New)
+	return fmt.Sprintf("pbkdf2_sha256$%d$%s$%s", PASSWORD_PBKDF2_ITERATIONS, salt, base64.StdEncoding.EncodeToString(hash)), nil
+}
+
+/*
+Verify verifies password against Django pbkdf2 hash
+*/
+func (p *pbkdf2Hasher) Verify(password, encoded string) bool {
+	parts := strings.SplitN(encoded, "$", 4)
+	if len(parts) != 4 {
+		return false
+	}
+
+	iterations, err := strconv.Atoi(parts[1])
+	if err != nil || iterations <= 0 || iterations > PASSWORD_PBKDF2_MAX_ITERATIONS {
+		return false
+	}
+
+	digest := sha256.New
+	if parts[0] == "pbkdf2_sha1" {
+		digest = sha1.New
+	}
+
+	// every block of digest size costs another round of iterations
+	hash, err := base64.StdEncoding.DecodeString(parts[3])
+	if err != nil || len(hash) == 0 || len(hash) > digest().Size() {
+		return false
+	}
+
+	other := pbkdf2.Key([]byte(password), []byte(parts[2]), iterations, len(hash), digest)
+	return subtle.ConstantTimeCompare(hash, other) == 1
+}
+
+/*
+MustUpdate returns true when hash is not sha256 or iterations differ from current ones
+*/
+func (p *pbkdf2Hasher) MustUpdate(encoded string) bool {
+	parts := strings.SplitN(encoded, "$", 4)
+	return len(parts) != 4 || parts[0] != "pbkdf2_sha256" || parts[1] != strconv.Itoa(PASSWORD_PBKDF2_ITERATIONS)
+}
+
+/*
+passlibPBKDF2Hasher verifies passlib PBKDF2 hashes (used by pypiserver and devpi tools), format is:
+
+	$pbkdf2-sha256$rounds$salt$checksum
+
+Salt and checksum are encoded in passlib adapted base64 ("." instead of "+", without padding).
+*/
+type passlibPBKDF2Hasher struct{}
+
+/*
+Algorithms returns passlib pbkdf2 prefixes
+*/
+func (p *passlibPBKDF2Hasher) Algorithms() []string {
+	return []string{"pbkdf2", "pbkdf2-sha256", "pbkdf2-sha512"}
+}
+
+/*
+Encode is not supported, passlib hashes are only verified
+*/
+func (p *passlibPBKDF2Hasher) Encode(password string) (string, error) {
+	return "", ErrPasswordHasherVerifyOnly
+}
+
+/*
+Verify verifies password against passlib pbkdf2 hash
+*/
+func (p *passlibPBKDF2Hasher) Verify(password, encoded string) bool {
+	parts := strings.Split(strings.TrimPrefix(encoded, "$"), "$")
+	if len(parts) != 4 {
+		return false
+	}
+
+	rounds, err := strconv.Atoi(parts[1])
+	if err != nil || rounds <= 0 || rounds > PASSWORD_PBKDF2_MAX_ITERATIONS {
+		return false
+	}
+
+	salt, err := p.decode(parts[2])
+	if err != nil {
+		return false
+	}
+
+	var digest func() hash.Hash
+	switch parts[0] {
+	case "pbkdf2":
+		digest = sha1.New
+	case "pbkdf2-sha256":
+		digest = sha256.New
+	case "pbkdf2-sha512":
+		digest = sha512.New
+	default:
+		return false
+	}
+
+	checksum, err := p.decode(parts[3])
+	if err != nil || len(checksum) == 0 || len(checksum) > digest().Size() {
+		return false
+	}
+
+	other := pbkdf2.Key([]byte(password), salt, rounds, len(checksum), digest)
+	return subtle.ConstantTimeCompare(checksum, other) == 1
+}
+
+/*
+MustUpdate always returns true, passlib hashes are always rehashed
+*/
+func (p *passlibPBKDF2Hasher) MustUpdate(encoded string) bool {
+	return true
+}
+
+/*
+decode decodes passlib adapted base64
+*/
+func (p *passlibPBKDF2Hasher) decode(value string) ([]byte, error) {
+	return base64.RawStdEncoding.DecodeString(strings.Replace(strings.TrimRight(value, "="), ".", "+", -1))
+}
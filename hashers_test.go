@@ -0,0 +1,157 @@
+package core
+
+import (
+	"strings"
+	"testing"
+)
+
+const testPassword = "secret password"
+
+func TestPasswordHashersRoundTrip(t *testing.T) {
+	for _, algorithm := range AVAILABLE_PASSWORD_HASHERS {
+		t.Run(algorithm, func(st *testing.T) {
+			hashers, err := NewPasswordHashers("secret key", algorithm)
+			if err != nil {
+				st.Fatalf("NewPasswordHashers returned error: %v", err)
+			}
+
+			encoded, err := hashers.Encode(testPassword)
+			if err != nil {
+				st.Fatalf("Encode returned error: %v", err)
+			}
+			if strings.Contains(encoded, testPassword) {
+				st.Fatalf("Encode returned plain password")
+			}
+
+			if !hashers.Verify(testPassword, encoded) {
+				st.Errorf("Verify of correct password returned false")
+			}
+			if hashers.Verify("wrong password", encoded) {
+				st.Errorf("Verify of wrong password returned true")
+			}
+			if hashers.MustUpdate(encoded) {
+				st.Errorf("MustUpdate of fresh hash returned true")
+			}
+
+			other, _ := hashers.Encode(testPassword)
+			if other == encoded {
+				st.Errorf("Encode should use random salt")
+			}
+		})
+	}
+}
+
+func TestPasswordHashersImported(t *testing.T) {
+	hashers, _ := NewPasswordHashers("secret key", PASSWORD_HASHER_ARGON2ID)
+
+	for _, encoded := range []string{
+		// Django
+		"pbkdf2_sha1$1000$seasalt$Qpgl5Ax8DV04k6MEfby7zy3OcGQ=",
+		"pbkdf2_sha256$1000$seasalt$AI5KO42+GKO7VcfVgBWK95qXqNHEcM8LlOzeKKNI08U=",
+
+		// passlib
+		"$pbkdf2$1000$AAECAwQFBgcICQoLDA0ODw$Cvthcjgw8mwmmnW60tec5O/0uqM",
+		"$pbkdf2-sha256$1000$AAECAwQFBgcICQoLDA0ODw$WHg3A342vStOAf2PSE4obyo0vubq6WzS5kRqmQz8GEE",
+		"$pbkdf2-sha512$1000$AAECAwQFBgcICQoLDA0ODw$IWYMAMj9J5D.ggobTceapCOXRSXTaa06F5RqCAvx40W2aG75qZt73gP1DT1nAQkJXDFg8tmY8LXzSV0WF5A4aQ",
+	} {
+		t.Run(PasswordAlgorithm(encoded), func(st *testing.T) {
+			if !hashers.Verify(testPassword, encoded) {
+				st.Errorf("Verify of correct password returned false")
+			}
+			if hashers.Verify("wrong password", encoded) {
+				st.Errorf("Verify of wrong password returned true")
+			}
+			if !hashers.MustUpdate(encoded) {
+				st.Errorf("MustUpdate of imported hash returned false")
+			}
+		})
+	}
+}
+
+func TestPasswordHashersBcryptVariants(t *testing.T) {
+	hashers, _ := NewPasswordHashers("secret key", PASSWORD_HASHER_BCRYPT)
+
+	encoded, err := hashers.Encode(testPassword)
+	if err != nil {
+		t.Fatalf("Encode returned error: %v", err)
+	}
+
+	// htpasswd -B and Django bcrypt hashes
+	for _, variant := range []string{"$2y$" + encoded[4:], "$2b$" + encoded[4:], "bcrypt$" + encoded} {
+		if !hashers.Verify(testPassword, variant) {
+			t.Errorf("Verify of %q returned false", PasswordAlgorithm(variant))
+		}
+	}
+}
+
+func TestPasswordHashersMustUpdate(t *testing.T) {
+	hashers, _ := NewPasswordHashers("secret key", PASSWORD_HASHER_ARGON2ID)
+
+	encoded, _ := hashers.Encode(testPassword)
+	scrypt, _ := (&scryptHasher{secret: "secret key"}).Encode(testPassword)
+
+	tc := []struct {
+		name    string
+		encoded string
+		update  bool
+	}{
+		{"current", encoded, false},
+		{"modular crypt", "$" + encoded, true},
+		{"outdated memory", strings.Replace(encoded, "m=65536", "m=32768", 1), true},
+		{"outdated time", strings.Replace(encoded, "t=3", "t=2", 1), true},
+		{"other hasher", scrypt, true},
+		{"unknown", "md5$salt$hash", true},
+		{"empty", "", true},
+	}
+
+	for _, tt := range tc {
+		t.Run(tt.name, func(st *testing.T) {
+			if result := hashers.MustUpdate(tt.encoded); result != tt.update {
+				st.Errorf("MustUpdate(%q) returned %v", tt.encoded, result)
+			}
+		})
+	}
+}
+
+func TestPasswordHashersLimits(t *testing.T) {
+	hashers, _ := NewPasswordHashers("secret key", PASSWORD_HASHER_ARGON2ID)
+
+	argon2, _ := hashers.Encode(testPassword)
+
+	for name, encoded := range map[string]string{
+		"argon2 memory":      strings.Replace(argon2, "m=65536", "m=4194304", 1),
+		"argon2 time":        strings.Replace(argon2, "t=3", "t=1000", 1),
+		"argon2 threads":     strings.Replace(argon2, "p=2", "p=255", 1),
+		"argon2 hash length": argon2 + strings.Repeat("A", 1024),
+		"scrypt iterations":  "scrypt$1048576$c2FsdA==$aGFzaA==",
+		"bcrypt cost":        "$2a$31$" + strings.Repeat("a", 53),
+		"pbkdf2 iterations":  "pbkdf2_sha256$1000000000$seasalt$AI5KO42+GKO7VcfVgBWK95qXqNHEcM8LlOzeKKNI08U=",
+		"pbkdf2 hash length": "pbkdf2_sha1$1000$seasalt$" + strings.Repeat("AAAA", 1024),
+		"passlib rounds":     "$pbkdf2-sha256$1000000000$AAECAwQFBgcICQoLDA0ODw$WHg3A342vStOAf2PSE4obyo0vubq6WzS5kRqmQz8GEE",
+		"passlib length":     "$pbkdf2$1000$AAECAwQFBgcICQoLDA0ODw$" + strings.Repeat("AAAA", 1024),
+	} {
+		t.Run(name, func(st *testing.T) {
+			if hashers.Verify(testPassword, encoded) {
+				st.Errorf("Verify of hash over limits returned true")
+			}
+			if !hashers.MustUpdate(encoded) {
+				st.Errorf("MustUpdate of hash over limits returned false")
+			}
+		})
+	}
+}
+
+func TestPasswordAlgorithm(t *testing.T) {
+	for encoded, algorithm := range map[string]string{
+		"argon2id$v=19$m=65536,t=3,p=2$salt$hash":  "argon2id",
+		"$argon2id$v=19$m=65536,t=3,p=2$salt$hash": "argon2id",
+		"$2y$05$hash":                   "2y",
+		"pbkdf2_sha256$1000$salt$hash":  "pbkdf2_sha256",
+		"$pbkdf2-sha256$1000$salt$hash": "pbkdf2-sha256",
+		"":                              "",
+	} {
+		if result := PasswordAlgorithm(encoded); result != algorithm {
+			t.Errorf("PasswordAlgorithm(%q) returned %q, expected %q", encoded, result, algorithm)
+		}
+	}
+}
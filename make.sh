@@ -1,6 +1,8 @@
 #!/usr/bin/env bash
 
 version=$(grep -F "VERSION = " settings.go | cut -d\" -f2)
+commit=$(git rev-parse --short HEAD 2>/dev/null)
+ldflags="-s -X github.com/phonkee/gopypi.COMMIT=$commit"
 
 echo "Build frontend app"
 
@@ -12,12 +14,12 @@ cd -
 echo "Cross compiling gopypi version: $version"
 
 echo "Compiling for linux-amd64..."
-env GOOS=linux GOARCH=amd64 go build -ldflags "-s" -o build/gopypi-linux-amd64-$version ./gopypi
+env GOOS=linux GOARCH=amd64 go build -ldflags "$ldflags" -o build/gopypi-linux-amd64-$version ./gopypi
 echo "Compiling for linux-arm64..."
-env GOOS=linux GOARCH=arm64 go build -ldflags "-s" -o build/gopypi-linux-arm64-$version ./gopypi
+env GOOS=linux GOARCH=arm64 go build -ldflags "$ldflags" -o build/gopypi-linux-arm64-$version ./gopypi
 echo "Compiling for darwin-amd64..."
-env GOOS=darwin GOARCH=amd64 go build -ldflags "-s" -o build/gopypi-darwin-amd64-$version ./gopypi
+env GOOS=darwin GOARCH=amd64 go build -ldflags "$ldflags" -o build/gopypi-darwin-amd64-$version ./gopypi
 echo "Compiling for freebsd-amd64..."
-env GOOS=freebsd GOARCH=amd64 go build -ldflags "-s" -o build/gopypi-freebsd-amd64-$version ./gopypi
+env GOOS=freebsd GOARCH=amd64 go build -ldflags "$ldflags" -o build/gopypi-freebsd-amd64-$version ./gopypi
 echo "Compiling for windows-amd64..."
-env GOOS=windows GOARCH=amd64 go build -ldflags "-s" -o build/gopypi-windows-amd64-$version.exe ./gopypi
+env GOOS=windows GOARCH=amd64 go build -ldflags "$ldflags" -o build/gopypi-windows-amd64-$version.exe ./gopypi
@@ -4,10 +4,9 @@ Managers is set of manager structs that provide querying methods
 package core
 
 import (
-	"encoding/base64"
+	"crypto/sha256"
 	"fmt"
 
-	"strconv"
 	"strings"
 
 	"sort"
@@ -19,7 +18,6 @@ import (
 	"github.com/blang/semver"
 	"github.com/gorilla/mux"
 	"github.com/jinzhu/gorm"
-	"golang.org/x/crypto/scrypt"
 )
 
 /*
@@ -53,6 +51,20 @@ func (p *Manager) List(target interface{}, filter ...FilterFunc) *gorm.DB {
 	return queryset.Find(target)
 }
 
+/*
+AuditLogManager database manager
+*/
+type AuditLogManager struct {
+	DB *gorm.DB
+}
+
+/*
+Create stores audit log entry
+*/
+func (a *AuditLogManager) Create(entry *AuditLog) error {
+	return a.DB.Create(entry).Error
+}
+
 /*
 ClassifierManager database manager
 */
@@ -195,6 +207,34 @@ func (p *PackageManager) IsMaintainer(pack *Package, user *User) bool {
 	return false
 }
 
+/*
+CanModify returns whether user can modify package versions (yank, delete). Admins can modify all packages,
+author always and maintainers with update permission.
+*/
+func (p *PackageManager) CanModify(pack *Package, user *User) bool {
+	if user.IsAdmin || pack.AuthorID == user.ID {
+		return true
+	}
+	return user.CanUpdate && p.IsMaintainer(pack, user)
+}
+
+/*
+UploadPolicy returns upload policy of package, packages without policy use policy of their index (immutable by
+default)
+*/
+func (p *PackageManager) UploadPolicy(pack Package) string {
+	if pack.UploadPolicy != "" {
+		return pack.UploadPolicy
+	}
+
+	index := Index{}
+	if p.DB.Select("upload_policy").First(&index, "id = ?", pack.IndexID).Error == nil && index.UploadPolicy != "" {
+		return index.UploadPolicy
+	}
+
+	return UPLOAD_POLICY_IMMUTABLE
+}
+
 /*
 Item for ordering Package Versions
 */
@@ -267,6 +307,94 @@ type PackageVersionManager struct {
 	*Manager
 }
 
+/*
+Delete deletes package version along with its files, file tags, dependencies, classifiers and download stats from
+database. Files on disk must be removed by caller.
+*/
+func (p *PackageVersionManager) Delete(version PackageVersion) (err error) {
+	tx := p.DB.Begin()
+
+	if err = tx.Where("package_version_file_id IN (SELECT id FROM package_version_file WHERE package_version_id = ?)", version.ID).Delete(PackageVersionFileTag{}).Error; err != nil {
+		tx.Rollback()
+		return
+	}
+
+	for _, model := range []interface{}{PackageVersionFile{}, PackageDependency{}, DownloadStatsWeekly{}, DownloadStatsMonthly{}, DownloadStatsYearly{}} {
+		if err = tx.Where("package_version_id = ?", version.ID).Delete(model).Error; err != nil {
+			tx.Rollback()
+			return
+		}
+	}
+
+	if err = tx.Model(&version).Association("Classifiers").Clear().Error; err != nil {
+		tx.Rollback()
+		return
+	}
+
+	if err = tx.Delete(&version).Error; err != nil {
+		tx.Rollback()
+		return
+	}
+
+	return tx.Commit().Error
+}
+
+/*
+SetDescription sets description of package version from metadata and renders it
+*/
+func (p *PackageVersionManager) SetDescription(version *PackageVersion, meta DistributionMetadata) (err error) {
+	description := strings.TrimSpace(meta.LongDescription())
+	if description == "" {
+		return
+	}
+
+	if version.DescriptionContentType == "" {
+		version.DescriptionContentType = meta.Get("Description-Content-Type")
+	}
+	version.Description = description
+	version.DescriptionHTML = RenderDescription(version.DescriptionContentType, description)
+
+	return p.DB.Model(version).UpdateColumns(map[string]interface{}{
+		"description":              version.Description,
+		"description_content_type": version.DescriptionContentType,
+		"description_html":         version.DescriptionHTML,
+	}).Error
+}
+
+/*
+SetKeywords stores keywords of package version when it doesn't have any yet
+*/
+func (p *PackageVersionManager) SetKeywords(version *PackageVersion, keywords string) (err error) {
+	if keywords = strings.TrimSpace(keywords); keywords == "" || version.Keywords != "" {
+		return
+	}
+
+	version.Keywords = keywords
+	return p.DB.Model(version).UpdateColumn("keywords", keywords).Error
+}
+
+/*
+SetMissingDependencies stores dependencies of package version when it doesn't have any yet
+*/
+func (p *PackageVersionManager) SetMissingDependencies(version PackageVersion, requirements []Requirement) (err error) {
+	if len(requirements) == 0 {
+		return
+	}
+
+	count := 0
+	if err = p.DB.Model(PackageDependency{}).Where("package_version_id = ?", version.ID).Count(&count).Error; err != nil || count > 0 {
+		return
+	}
+
+	for _, requirement := range requirements {
+		dependency := requirement.Dependency(version)
+		if err = p.DB.Create(&dependency).Error; err != nil {
+			return
+		}
+	}
+	return
+}
+
 /*
 PackageVersionFileManager database manager
 */
@@ -291,6 +419,115 @@ func (p *PackageVersionFileManager) GetRelativeFilename(pvf *PackageVersionFile)
 	return path.Join(pvf.RelativePath, pvf.Filename)
 }
 
+/*
+GetPackage returns package that file belongs to
+*/
+func (p *PackageVersionFileManager) GetPackage(pvf *PackageVersionFile) (pack Package, err error) {
+	version := PackageVersion{}
+	if err = p.DB.Select("package_id").First(&version, "id = ?", pvf.PackageVersionID).Error; err != nil {
+		return
+	}
+
+	err = p.DB.First(&pack, "id = ?", version.PackageID).Error
+	return
+}
+
+/*
+CheckUpload returns error when file cannot be uploaded to version of package by upload policy. Exists is whether file
+with the same name already exists in version (upload would replace it).
+*/
+func (p *PackageVersionFileManager) CheckUpload(policy string, pack Package, version PackageVersion, filename string, exists bool) error {
+	if policy == UPLOAD_POLICY_OVERWRITE || (policy == UPLOAD_POLICY_OVERWRITE_DEV && IsDevVersion(version.Version)) {
+		return nil
+	}
+
+	if exists {
+		if policy == UPLOAD_POLICY_OVERWRITE_DEV {
+			return ErrFileOverwriteDevOnly
+		}
+		return ErrFileAlreadyExists
+	}
+
+	// filename of deleted (or moved) file cannot be reused
+	if p.FilenameUsed(pack.IndexID, filename) {
+		return ErrFileNameReused
+	}
+
+	return nil
+}
+
+/*
+Exists returns whether file with given filename exists in version
+*/
+func (p *PackageVersionFileManager) Exists(version PackageVersion, filename string) bool {
+	count := 0
+	p.DB.Model(PackageVersionFile{}).Where("package_version_id = ? AND filename = ?", version.ID, filename).Count(&count)
+	return count > 0
+}
+
+/*
+FilenameUsed returns whether filename was ever uploaded to index
+*/
+func (p *PackageVersionFileManager) FilenameUsed(indexID uint, filename string) bool {
+	count := 0
+	p.DB.Model(PackageFileHistory{}).Where("index_id = ? AND filename = ?", indexID, filename).Count(&count)
+	return count > 0
+}
+
+/*
+RecordHistory records upload, replacement or deletion of file, previous is md5 digest of replaced file
+*/
+func (p *PackageVersionFileManager) RecordHistory(action string, pack Package, version PackageVersion, file PackageVersionFile, previous string, user *User) error {
+	history := PackageFileHistory{
+		IndexID:           pack.IndexID,
+		PackageID:         pack.ID,
+		Version:           version.Version,
+		Filename:          file.Filename,
+		Action:            action,
+		MD5Digest:         file.MD5Digest,
+		PreviousMD5Digest: previous,
+	}
+	if user != nil {
+		history.UserID = user.ID
+		history.Username = user.Username
+	}
+	return p.DB.Create(&history).Error
+}
+
+/*
+UpdateTags replaces compatibility tags of file by tags parsed from its filename, platforms are created when needed
+*/
+func (p *PackageVersionFileManager) UpdateTags(pvf *PackageVersionFile) (err error) {
+	if err = p.DB.Where("package_version_file_id = ?", pvf.ID).Delete(PackageVersionFileTag{}).Error; err != nil {
+		return
+	}
+
+	pvf.Tags = []PackageVersionFileTag{}
+	for _, tag := range ExpandCompatibilityTags(pvf.PackageType, pvf.PythonTag, pvf.ABITag, pvf.PlatformTag) {
+		fileTag := PackageVersionFileTag{
+			PackageVersionFileID: pvf.ID,
+			PythonTag:            tag.PythonTag,
+			ABITag:               tag.ABITag,
+		}
+
+		if tag.PlatformTag != "" {
+			platforms := []Platform{}
+			if err = (&PlatformManager{DB: p.DB}).ListOrCreate(&platforms, []string{tag.PlatformTag}); err != nil {
+				return
+			}
+			fileTag.Platform = &platforms[0]
+			fileTag.PlatformID = platforms[0].ID
+		}
+
+		if err = p.DB.Create(&fileTag).Error; err != nil {
+			return
+		}
+		pvf.Tags = append(pvf.Tags, fileTag)
+	}
+
+	return
+}
+
 /*
 GetDownloadURL returns full url for downloading package
 */
@@ -310,7 +547,7 @@ type PlatformManager struct {
 Get returns platform by set fields
 */
 func (p *PlatformManager) Get(platform *Platform) *gorm.DB {
-	return p.DB.FirstOrCreate(&platform, platform).Attrs(platform)
+	return p.DB.Where(platform).First(platform)
 }
 
 /*
@@ -322,7 +559,7 @@ func (p *PlatformManager) List(platforms *[]Platform, filter ...FilterFunc) *gor
 }
 
 /*
-Return multiple platforms
+ListOrCreate returns platforms by their names, platforms that don't exist yet are created
 */
 func (p *PlatformManager) ListOrCreate(target *[]Platform, platforms []string) error {
 
@@ -332,12 +569,10 @@ func (p *PlatformManager) ListOrCreate(target *[]Platform, platforms []string) e
 			Name: platform,
 		}
 
-		if err := p.Get(&po).Error; err != nil {
-			if err != gorm.ErrRecordNotFound {
-				return err
-			}
-			*target = append(*target, po)
+		if err := p.DB.FirstOrCreate(&po, Platform{Name: platform}).Error; err != nil {
+			return err
 		}
+		*target = append(*target, po)
 	}
 
 	return nil
@@ -352,107 +587,144 @@ type DownloadStatsManager struct {
 }
 
 /*
-AddDownload adds download for given package version
+AddDownloads adds buffered download counts to daily, weekly, monthly, yearly and detailed stats in single transaction.
+Counts are keyed by file, user, installer and day of download. When download stats feature is disabled, counts are
+dropped.
 */
-func (s *DownloadStatsManager) AddDownload(version *PackageVersion) (err error) {
+func (d *DownloadStatsManager) AddDownloads(counts map[DownloadStatsKey]int) (err error) {
+	if len(counts) == 0 {
+		return
+	}
 
 	var enabled bool
-
-	if enabled, err = s.Config.Manager().Feature().IsEnabledFeature(FEATURE_DOWNLOAD_STATS); err != nil {
+	if enabled, err = d.Config.Manager().Feature().IsEnabledFeature(FEATURE_DOWNLOAD_STATS); err != nil || !enabled {
 		return
 	}
 
-	// check if download stats are enabled
-	if !enabled {
-		return
+	aggregations := []struct {
+		model   interface{}
+		align   func(time.Time) time.Time
+		enabled bool
+	}{
+		{DownloadStatsDaily{}, TimeStripTime, d.Config.DownloadStats().ArchiveDaily() > 0},
+		{DownloadStatsWeekly{}, TimeAlignWeek, d.Config.DownloadStats().ArchiveWeekly() > 0},
+		{DownloadStatsMonthly{}, TimeAlignMonth, d.Config.DownloadStats().ArchiveMonthly() > 0},
+		// yearly statistics are stored every time
+		{DownloadStatsYearly{}, TimeAlignYear, true},
 	}
 
-	// this is now
-	now := time.Now()
+	versionColumns := []string{"package_version_id", "created_at"}
+	versionValues := func(key DownloadStatsKey) []interface{} {
+		return []interface{}{key.PackageVersionID, key.Day}
+	}
 
-	// weekly stats are stored
-	if s.Config.DownloadStats().ArchiveWeekly() > 0 {
-		ds := DownloadStatsWeekly{}
-		ds.PackageVersion = version
-		ds.CreatedAt = TimeAlignWeek(now)
+	tx := d.DB.Begin()
 
-		attrs := DownloadStatsWeekly{}
-		attrs.PackageVersion = version
-		attrs.CreatedAt = TimeAlignWeek(now)
+	for _, aggregation := range aggregations {
+		if !aggregation.enabled {
+			continue
+		}
 
-		if err = s.DB.Where("package_version_id = ? AND created_at = ?", version.ID, ds.CreatedAt).Attrs(attrs).FirstOrCreate(&ds).Error; err != nil {
-			return
+		// version stats don't track file, user nor installer
+		aligned := map[DownloadStatsKey]int{}
+		for key, count := range counts {
+			aligned[DownloadStatsKey{PackageVersionID: key.PackageVersionID, Day: aggregation.align(key.Day)}] += count
 		}
 
-		// update downloads for given download stats
-		if err = s.DB.Model(&ds).Updates(map[string]interface{}{"downloads": gorm.Expr("downloads + 1")}).Error; err != nil {
+		if err = d.upsert(tx, tx.NewScope(aggregation.model).TableName(), versionColumns, aligned, versionValues); err != nil {
+			tx.Rollback()
 			return
 		}
 	}
 
-	// weekly stats are stored
-	if s.Config.DownloadStats().ArchiveMonthly() > 0 {
-		ds := DownloadStatsMonthly{}
-		ds.PackageVersion = version
-		ds.CreatedAt = TimeAlignMonth(now)
-
-		attrs := DownloadStatsMonthly{}
-		attrs.PackageVersion = version
-		attrs.CreatedAt = TimeAlignMonth(now)
-
-		if err = s.DB.Where("package_version_id = ? AND created_at = ?", version.ID, ds.CreatedAt).Attrs(attrs).FirstOrCreate(&ds).Error; err != nil {
-			return
+	if d.Config.DownloadStats().ArchiveDetails() > 0 {
+		detailColumns := []string{"created_at", "package_version_file_id", "package_version_id", "user_id", "installer", "python", "system"}
+		detailValues := func(key DownloadStatsKey) []interface{} {
+			return []interface{}{key.Day, key.PackageVersionFileID, key.PackageVersionID, key.UserID, key.Installer, key.Python, key.System}
 		}
 
-		// update downloads for given download stats
-		if err = s.DB.Model(&ds).Updates(map[string]interface{}{"downloads": gorm.Expr("downloads + 1")}).Error; err != nil {
+		if err = d.upsert(tx, tx.NewScope(DownloadStatsDetail{}).TableName(), detailColumns, counts, detailValues); err != nil {
+			tx.Rollback()
 			return
 		}
 	}
 
-	// Yearly statistics are store everytime
-	ds := DownloadStatsYearly{}
-	ds.PackageVersion = version
-	ds.CreatedAt = TimeAlignYear(now)
-
-	attrs := DownloadStatsYearly{}
-	attrs.PackageVersion = version
-	attrs.CreatedAt = TimeAlignYear(now)
+	return tx.Commit().Error
+}
 
-	if err = s.DB.Where("package_version_id = ? AND created_at = ?", version.ID, ds.CreatedAt).Attrs(attrs).FirstOrCreate(&ds).Error; err != nil {
-		return
+/*
+upsert increments downloads in given table, rows that don't exist are created. Columns identify row (table has
+unique index on them), values returns column values for given key. Rows are written in batches with single
+statement.
+*/
+func (d *DownloadStatsManager) upsert(db *gorm.DB, table string, columns []string, counts map[DownloadStatsKey]int, values func(DownloadStatsKey) []interface{}) (err error) {
+	keys := make([]DownloadStatsKey, 0, len(counts))
+	for key := range counts {
+		keys = append(keys, key)
 	}
 
-	// update downloads for given download stats
-	if err = s.DB.Model(&ds).Updates(map[string]interface{}{"downloads": gorm.Expr("downloads + 1")}).Error; err != nil {
+	// same order in all instances prevents deadlocks
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i].Less(keys[j])
+	})
+
+	var conflict string
+	switch db.NewScope(nil).Dialect().GetName() {
+	case "postgres":
+		conflict = fmt.Sprintf("ON CONFLICT (%v) DO UPDATE SET downloads = %v.downloads + EXCLUDED.downloads",
+			strings.Join(columns, ", "), table)
+	case "mysql":
+		conflict = "ON DUPLICATE KEY UPDATE downloads = downloads + VALUES(downloads)"
+	default:
+		// dialect without upsert support, update existing row or insert new one
+		where := strings.Join(columns, " = ? AND ") + " = ?"
+		for _, key := range keys {
+			queryset := db.Exec(fmt.Sprintf("UPDATE %v SET downloads = downloads + ? WHERE %v", table, where),
+				append([]interface{}{counts[key]}, values(key)...)...)
+			if err = queryset.Error; err != nil {
+				return
+			}
+			if queryset.RowsAffected == 0 {
+				if err = db.Exec(fmt.Sprintf("INSERT INTO %v (%v, downloads) VALUES (%v?)", table, strings.Join(columns, ", "),
+					strings.Repeat("?, ", len(columns))), append(values(key), counts[key])...).Error; err != nil {
+					return
+				}
+			}
+		}
 		return
 	}
 
-	return
-}
+	placeholder := "(" + strings.Repeat("?, ", len(columns)) + "?)"
 
-/*
-AddDownloadFile adds download by PackageVersionFile
-*/
-func (d *DownloadStatsManager) AddDownloadFile(versionfile *PackageVersionFile) (err error) {
+	for start := 0; start < len(keys); start += DOWNLOAD_STATS_BATCH {
+		end := start + DOWNLOAD_STATS_BATCH
+		if end > len(keys) {
+			end = len(keys)
+		}
 
-	pv := PackageVersion{}
+		placeholders := []string{}
+		args := []interface{}{}
+		for _, key := range keys[start:end] {
+			placeholders = append(placeholders, placeholder)
+			args = append(append(args, values(key)...), counts[key])
+		}
 
-	if err = d.DB.First(&pv, "id = ?", versionfile.PackageVersionID).Error; err != nil {
-		return err
+		query := fmt.Sprintf("INSERT INTO %v (%v, downloads) VALUES %v %v",
+			table, strings.Join(columns, ", "), strings.Join(placeholders, ", "), conflict)
+		if err = db.Exec(query, args...).Error; err != nil {
+			return
+		}
 	}
 
-	// add download by package version
-	err = d.AddDownload(&pv)
-
 	return
 }
 
 /*
 GetSum returns count of all downloads
 */
-func (d *DownloadStatsManager) GetCount(target interface{}) (err error) {
-	err = d.DB.Table("download_stats_yearly").Select("sum(downloads) as total").Row().Scan(target)
+func (d *DownloadStatsManager) GetCount(target interface{}, filter ...FilterFunc) (err error) {
+	db := ApplyFilterFuncs(d.DB.Table("download_stats_yearly").Select("sum(downloads) as total"), filter...)
+	err = db.Row().Scan(target)
 	return
 }
 
@@ -480,9 +752,16 @@ func (d *DownloadStatsManager) GetAllStats(target map[string][]StatsDownloadItem
 		return
 	}
 
+	// get daily stats
+	targetDaily := []StatsDownloadItem{}
+	if err = d.GetStats(STATS_DOWNLOAD_DAILY, &targetDaily, filter...).Error; err != nil {
+		return
+	}
+
 	target["all"] = targetYearly
 	target["weekly"] = targetWeekly
 	target["monthly"] = targetMonthly
+	target["daily"] = targetDaily
 
 	return
 }
@@ -498,6 +777,8 @@ func (d *DownloadStatsManager) GetStats(aggregation StatsAggregation, target *[]
 		m = DownloadStatsYearly{}
 	case STATS_DOWNLOAD_MONTHLY:
 		m = DownloadStatsMonthly{}
+	case STATS_DOWNLOAD_DAILY:
+		m = DownloadStatsDaily{}
 	default:
 		panic("unknown aggregation")
 	}
@@ -515,18 +796,707 @@ func (d *DownloadStatsManager) GetStats(aggregation StatsAggregation, target *[]
 }
 
 /*
-Cleanup deletes weekly and monthly stats from database, yearly stats will stay forever
+GetBreakdown returns detailed download stats grouped by given dimension (see AVAILABLE_STATS_BREAKDOWNS), ordered by
+downloads. Versions, files and users are returned with their ids and names.
+*/
+func (d *DownloadStatsManager) GetBreakdown(by string, target *[]StatsBreakdownItem, filter ...FilterFunc) (err error) {
+	columns := map[string]string{
+		STATS_BREAKDOWN_VERSION:   "package_version_id",
+		STATS_BREAKDOWN_FILE:      "package_version_file_id",
+		STATS_BREAKDOWN_USER:      "user_id",
+		STATS_BREAKDOWN_INSTALLER: "installer",
+		STATS_BREAKDOWN_PYTHON:    "python",
+		STATS_BREAKDOWN_SYSTEM:    "system",
+	}
+
+	column, ok := columns[by]
+	if !ok {
+		return ErrStatsUnknownBreakdown
+	}
+
+	rows := []struct {
+		Value     string
+		Downloads int
+	}{}
+
+	db := d.DB.Model(DownloadStatsDetail{}).
+		Select(column + " AS value, sum(downloads) AS downloads").
+		Group(column).
+		Order("downloads DESC")
+
+	if err = ApplyFilterFuncs(db, filter...).Scan(&rows).Error; err != nil {
+		return
+	}
+
+	// versions, files and users are grouped by id
+	identified := by == STATS_BREAKDOWN_VERSION || by == STATS_BREAKDOWN_FILE || by == STATS_BREAKDOWN_USER
+
+	*target = make([]StatsBreakdownItem, 0, len(rows))
+	ids := []uint{}
+	for _, row := range rows {
+		item := StatsBreakdownItem{Name: row.Value, Downloads: row.Downloads}
+		if identified {
+			item.ID, item.Name = Atoui(row.Value), ""
+			ids = append(ids, item.ID)
+		}
+		*target = append(*target, item)
+	}
+
+	// resolve names of versions, files and users
+	names := map[uint]string{}
+	if len(ids) > 0 {
+		switch by {
+		case STATS_BREAKDOWN_VERSION:
+			versions := []PackageVersion{}
+			if err = d.DB.Preload("Package").Where("id IN (?)", ids).Find(&versions).Error; err != nil {
+				return
+			}
+			for _, version := range versions {
+				if version.Package != nil {
+					names[version.ID] = version.Package.Name + " " + version.Version
+				}
+			}
+		case STATS_BREAKDOWN_FILE:
+			files := []PackageVersionFile{}
+			if err = d.DB.Where("id IN (?)", ids).Find(&files).Error; err != nil {
+				return
+			}
+			for _, file := range files {
+				names[file.ID] = file.Filename
+			}
+		case STATS_BREAKDOWN_USER:
+			users := []User{}
+			if err = d.DB.Where("id IN (?)", ids).Find(&users).Error; err != nil {
+				return
+			}
+			for _, user := range users {
+				names[user.ID] = user.Username
+			}
+		}
+	}
+
+	for i := range *target {
+		item := &(*target)[i]
+		if name, ok := names[item.ID]; ok {
+			item.Name = name
+		} else if by == STATS_BREAKDOWN_USER && item.ID == 0 {
+			item.Name = STATS_ANONYMOUS
+		}
+		if item.Name == "" {
+			item.Name = STATS_UNKNOWN
+		}
+	}
+
+	return
+}
+
+/*
+Cleanup deletes daily, weekly, monthly and detailed stats older than configured archive from database, yearly stats
+will stay forever
  */
-func (d *DownloadStatsManager ) Cleanup()  {
+func (d *DownloadStatsManager) Cleanup() (deleted map[string]int64, err error) {
+	day := time.Hour * 24
+
+	tables := []struct {
+		name   string
+		model  interface{}
+		before time.Time
+	}{
+		{"daily", DownloadStatsDaily{}, time.Now().Add(-(day * time.Duration(d.Config.DownloadStats().ArchiveDaily())))},
+		{"weekly", DownloadStatsWeekly{}, time.Now().Add(-(day * time.Duration(7*d.Config.DownloadStats().ArchiveWeekly())))},
+		{"monthly", DownloadStatsMonthly{}, time.Now().Add(-(day * time.Duration(7*4*d.Config.DownloadStats().ArchiveMonthly())))},
+		{"details", DownloadStatsDetail{}, time.Now().Add(-(day * time.Duration(d.Config.DownloadStats().ArchiveDetails())))},
+	}
 
-	weekly := time.Now().Add(-(time.Hour * time.Duration(24 * 7 * d.Config.DownloadStats().ArchiveWeekly())))
-	monthly := time.Now().Add(-(time.Hour * time.Duration(24 * 7 * 4 * d.Config.DownloadStats().ArchiveMonthly())))
+	deleted = map[string]int64{}
+	for _, table := range tables {
+		queryset := d.Config.DB().Delete(table.model, "created_at < ?", table.before)
+		if err = queryset.Error; err != nil {
+			return
+		}
+		deleted[table.name] = queryset.RowsAffected
+	}
 
-	wd := d.Config.DB().Delete(DownloadStatsWeekly{}, "created_at < ?", weekly).RowsAffected
-	println("Deleted weekly download stats records:", wd)
+	return
+}
+
+/*
+SessionManager handles login sessions and refresh tokens
+*/
+type SessionManager struct {
+	DB *gorm.DB
+}
+
+/*
+Create creates new session for user and returns it along with refresh token. Refresh token is returned only here,
+database stores only its hash.
+*/
+func (s *SessionManager) Create(user User, userAgent, remoteAddr string) (session Session, refresh string, err error) {
+	refresh = fmt.Sprintf("%x", GenerateSalt(TOKEN_REFRESH_BYTES))
+
+	session = Session{
+		UserID:       user.ID,
+		Key:          fmt.Sprintf("%x", GenerateSalt(SESSION_KEY_BYTES)),
+		RefreshToken: s.hashRefreshToken(refresh),
+		UserAgent:    StringTruncate(userAgent, 256),
+		RemoteAddr:   StringTruncate(remoteAddr, 64),
+		ExpiresAt:    time.Now().Add(time.Duration(TOKEN_REFRESH_EXPIRATION) * time.Second),
+	}
+
+	err = s.DB.Create(&session).Error
+	return
+}
+
+/*
+Get returns valid session by key (jti claim of access token)
+*/
+func (s *SessionManager) Get(key string) (session Session, err error) {
+	if err = s.DB.First(&session, "session_key = ?", key).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			err = ErrTokenRevoked
+		}
+		return
+	}
+
+	if !session.IsValid() {
+		err = ErrTokenRevoked
+	}
+
+	return
+}
+
+/*
+Refresh finds valid session by refresh token and rotates refresh token, so every refresh token can be used only once.
+Rotation is single conditional update, so when same refresh token is used concurrently only one request succeeds.
+*/
+func (s *SessionManager) Refresh(refresh string) (session Session, newRefresh string, err error) {
+	if strings.TrimSpace(refresh) == "" {
+		return session, "", ErrTokenInvalid
+	}
+
+	hash := s.hashRefreshToken(refresh)
+	if err = s.DB.First(&session, "refresh_token = ?", hash).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			err = ErrTokenInvalid
+		}
+		return
+	}
+
+	if !session.IsValid() {
+		return session, "", ErrTokenRevoked
+	}
+
+	newRefresh = fmt.Sprintf("%x", GenerateSalt(TOKEN_REFRESH_BYTES))
+	now := time.Now()
 
-	md := d.Config.DB().Delete(DownloadStatsMonthly{}, "created_at < ?", monthly).RowsAffected
-	println("Deleted monthly download stats records:", md)
+	queryset := s.DB.Model(&Session{}).
+		Where("id = ? AND refresh_token = ? AND revoked_at IS NULL", session.ID, hash).
+		Updates(map[string]interface{}{
+			"refresh_token": s.hashRefreshToken(newRefresh),
+			"last_used_at":  now,
+		})
+	if err = queryset.Error; err != nil {
+		return session, "", err
+	}
+
+	// refresh token was already rotated (or session revoked) by concurrent request
+	if queryset.RowsAffected != 1 {
+		return session, "", ErrTokenInvalid
+	}
+
+	session.RefreshToken = s.hashRefreshToken(newRefresh)
+	session.LastUsedAt = now
+
+	return
+}
+
+/*
+List returns all valid sessions of given user
+*/
+func (s *SessionManager) List(user User, target *[]Session) *gorm.DB {
+	return s.DB.
+		Where("user_id = ? AND revoked_at IS NULL AND expires_at > ?", user.ID, time.Now()).
+		Order("last_used_at DESC").
+		Find(target)
+}
+
+/*
+Revoke revokes single session
+*/
+func (s *SessionManager) Revoke(session Session) error {
+	return s.DB.Model(&Session{}).Where("id = ? AND revoked_at IS NULL", session.ID).Update("revoked_at", time.Now()).Error
+}
+
+/*
+RevokeAll revokes all sessions of given user, sessions with ids in except are left untouched.
+*/
+func (s *SessionManager) RevokeAll(user User, except ...uint) error {
+	queryset := s.DB.Model(&Session{}).Where("user_id = ? AND revoked_at IS NULL", user.ID)
+	if len(except) > 0 {
+		queryset = queryset.Where("id NOT IN (?)", except)
+	}
+	return queryset.Update("revoked_at", time.Now()).Error
+}
+
+/*
+Cleanup deletes expired and revoked sessions from database
+*/
+func (s *SessionManager) Cleanup() (deleted int64, err error) {
+	queryset := s.DB.Delete(Session{}, "expires_at < ? OR revoked_at IS NOT NULL", time.Now())
+	return queryset.RowsAffected, queryset.Error
+}
+
+/*
+hashRefreshToken returns hash of refresh token that is stored in database
+*/
+func (s *SessionManager) hashRefreshToken(refresh string) string {
+	return fmt.Sprintf("%x", sha256.Sum256([]byte(refresh)))
+}
+
+/*
+NotificationManager database manager for notification preferences
+*/
+type NotificationManager struct {
+	DB *gorm.DB
+}
+
+/*
+Preference returns notification preference of user, when user has no stored preference all notifications are
+enabled.
+*/
+func (n *NotificationManager) Preference(user User) (result NotificationPreference, err error) {
+	queryset := n.DB.First(&result, "user_id = ?", user.ID)
+	if queryset.RecordNotFound() {
+		return NotificationPreference{
+			UserID:     user.ID,
+			NewVersion: true,
+			Maintainer: true,
+			Password:   true,
+		}, nil
+	}
+	return result, queryset.Error
+}
+
+/*
+SetPreference stores notification preference of user
+*/
+func (n *NotificationManager) SetPreference(user User, preference *NotificationPreference) (err error) {
+	current := NotificationPreference{}
+	if queryset := n.DB.First(&current, "user_id = ?", user.ID); queryset.Error != nil && !queryset.RecordNotFound() {
+		return queryset.Error
+	}
+
+	preference.ID = current.ID
+	preference.UserID = user.ID
+	return n.DB.Save(preference).Error
+}
+
+/*
+Recipients returns users that want notification of given kind. Inactive users and users without email are skipped.
+*/
+func (n *NotificationManager) Recipients(users []User, kind string) (result []User) {
+	result = []User{}
+	for _, user := range users {
+		if !user.IsActive || user.Email == "" {
+			continue
+		}
+		if preference, err := n.Preference(user); err != nil || !preference.Wants(kind) {
+			continue
+		}
+		result = append(result, user)
+	}
+	return
+}
+
+/*
+TaskManager database manager for task locks and task run history
+*/
+type TaskManager struct {
+	DB *gorm.DB
+}
+
+/*
+Acquire tries to lock task for given owner. When slot is given, lock is acquired only when this activation was not
+claimed yet, so task scheduled by multiple instances runs only once. Manual runs pass nil slot.
+*/
+func (t *TaskManager) Acquire(name, owner string, slot *time.Time, ttl time.Duration) bool {
+	now := time.Now()
+
+	lock := TaskLock{}
+	if t.DB.First(&lock, "name = ?", name).RecordNotFound() {
+		lock = TaskLock{Name: name, Owner: owner, LockedUntil: now.Add(ttl)}
+		if slot != nil {
+			lock.ScheduledAt = *slot
+		}
+		// name is primary key, when other instance was faster create fails
+		return t.DB.Create(&lock).Error == nil
+	}
+
+	values := map[string]interface{}{"owner": owner, "locked_until": now.Add(ttl)}
+	queryset := t.DB.Model(TaskLock{}).Where("name = ? AND locked_until < ?", name, now)
+	if slot != nil {
+		values["scheduled_at"] = *slot
+		queryset = queryset.Where("scheduled_at < ?", *slot)
+	}
+
+	queryset = queryset.Updates(values)
+	return queryset.Error == nil && queryset.RowsAffected == 1
+}
+
+/*
+Release unlocks task locked by given owner
+*/
+func (t *TaskManager) Release(name, owner string) error {
+	return t.DB.Model(TaskLock{}).Where("name = ? AND owner = ?", name, owner).
+		Update("locked_until", time.Now()).Error
+}
+
+/*
+LastRun returns last run of task
+*/
+func (t *TaskManager) LastRun(name string) (run *TaskRun) {
+	result := TaskRun{}
+	if t.DB.Order("id DESC").First(&result, "task = ?", name).Error != nil {
+		return nil
+	}
+	return &result
+}
+
+/*
+IndexManager database manager for indexes, their bases and access lists
+*/
+type IndexManager struct {
+	DB *gorm.DB
+}
+
+/*
+Default returns default index (packages uploaded to / and listed in /simple)
+*/
+func (i *IndexManager) Default() (index Index, err error) {
+	return i.GetByName(DEFAULT_INDEX)
+}
+
+/*
+GetByName returns index by name
+*/
+func (i *IndexManager) GetByName(name string) (index Index, err error) {
+	if i.DB.First(&index, "name = ?", name).RecordNotFound() {
+		err = ErrIndexNotFound
+	}
+	return
+}
+
+/*
+Resolve returns index followed by its bases in lookup order. Bases are walked depth first by position, every index
+is returned only once (so misconfigured cycles don't loop).
+*/
+func (i *IndexManager) Resolve(index Index) (result []Index, err error) {
+	result = []Index{}
+	visited := map[uint]bool{}
+
+	var walk func(index Index) error
+	walk = func(index Index) (err error) {
+		if visited[index.ID] {
+			return
+		}
+		visited[index.ID] = true
+		result = append(result, index)
+
+		bases := []IndexBase{}
+		if err = i.DB.Preload("Base").Where("index_id = ?", index.ID).Order("position ASC").Find(&bases).Error; err != nil {
+			return
+		}
+
+		for _, base := range bases {
+			if base.Base == nil {
+				continue
+			}
+			if err = walk(*base.Base); err != nil {
+				return
+			}
+		}
+		return
+	}
+
+	err = walk(index)
+	return
+}
+
+/*
+ResolveIDs returns ids of index and all its bases in lookup order
+*/
+func (i *IndexManager) ResolveIDs(index Index) (result []uint, err error) {
+	var indexes []Index
+	if indexes, err = i.Resolve(index); err != nil {
+		return
+	}
+
+	result = make([]uint, 0, len(indexes))
+	for _, item := range indexes {
+		result = append(result, item.ID)
+	}
+	return
+}
+
+/*
+GetACL returns access list entry of user for index
+*/
+func (i *IndexManager) GetACL(index Index, user User) (acl IndexACL, found bool) {
+	found = !i.DB.First(&acl, "index_id = ? AND user_id = ?", index.ID, user.ID).RecordNotFound()
+	return
+}
+
+/*
+CanRead returns whether user can read index. Admins can read all indexes, public indexes are readable by all users.
+*/
+func (i *IndexManager) CanRead(index Index, user User) bool {
+	if user.IsAdmin || index.Public {
+		return true
+	}
+	acl, found := i.GetACL(index, user)
+	return found && acl.CanRead
+}
+
+/*
+CanUpload returns whether user can upload to index. Package permissions (author, maintainers) are checked
+separately.
+*/
+func (i *IndexManager) CanUpload(index Index, user User) bool {
+	if user.IsAdmin || index.OpenUpload {
+		return true
+	}
+	acl, found := i.GetACL(index, user)
+	return found && acl.CanUpload
+}
+
+/*
+Readable returns all indexes readable by user ordered by name
+*/
+func (i *IndexManager) Readable(target *[]Index, user User) (err error) {
+	indexes := []Index{}
+	if err = i.DB.Order("name ASC").Find(&indexes).Error; err != nil {
+		return
+	}
+
+	*target = []Index{}
+	for _, index := range indexes {
+		if i.CanRead(index, user) {
+			*target = append(*target, index)
+		}
+	}
+	return
+}
+
+/*
+CanReadPackage returns whether user can read package through any readable index (its own or index that inherits it)
+*/
+func (i *IndexManager) CanReadPackage(pack Package, user User) bool {
+	indexes := []Index{}
+	if i.Readable(&indexes, user) != nil {
+		return false
+	}
+
+	for _, index := range indexes {
+		if index.ID == pack.IndexID {
+			return true
+		}
+	}
+
+	for _, index := range indexes {
+		ids, err := i.ResolveIDs(index)
+		if err != nil {
+			return false
+		}
+		for _, id := range ids {
+			if id == pack.IndexID {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+/*
+SetBases replaces bases of index with indexes given by name (in lookup order). Index cannot inherit from itself,
+not even through its bases.
+*/
+func (i *IndexManager) SetBases(index Index, names []string) (err error) {
+	bases := []IndexBase{}
+	for position, name := range names {
+		var base Index
+		if base, err = i.GetByName(name); err != nil {
+			return
+		}
+
+		var ids []uint
+		if ids, err = i.ResolveIDs(base); err != nil {
+			return
+		}
+		for _, id := range ids {
+			if id == index.ID {
+				return ErrIndexCycle
+			}
+		}
+
+		bases = append(bases, IndexBase{IndexID: index.ID, BaseID: base.ID, Position: position})
+	}
+
+	tx := i.DB.Begin()
+	if err = tx.Where("index_id = ?", index.ID).Delete(IndexBase{}).Error; err != nil {
+		tx.Rollback()
+		return
+	}
+	for _, base := range bases {
+		if err = tx.Create(&base).Error; err != nil {
+			tx.Rollback()
+			return
+		}
+	}
+	return tx.Commit().Error
+}
+
+/*
+ListBases returns bases of index ordered by position with base names filled
+*/
+func (i *IndexManager) ListBases(index Index) (bases []IndexBase, err error) {
+	bases = []IndexBase{}
+	if err = i.DB.Preload("Base").Where("index_id = ?", index.ID).Order("position ASC").Find(&bases).Error; err != nil {
+		return
+	}
+	for j := range bases {
+		if bases[j].Base != nil {
+			bases[j].BaseName = bases[j].Base.Name
+		}
+	}
+	return
+}
+
+/*
+Delete deletes index along with its access list and inheritance. Default index and indexes with packages cannot be
+deleted.
+*/
+func (i *IndexManager) Delete(index Index) (err error) {
+	if index.Name == DEFAULT_INDEX {
+		return ErrIndexDefault
+	}
+
+	var count int
+	if err = i.DB.Model(Package{}).Where("index_id = ?", index.ID).Count(&count).Error; err != nil {
+		return
+	}
+	if count > 0 {
+		return ErrIndexNotEmpty
+	}
+
+	tx := i.DB.Begin()
+	if err = tx.Where("index_id = ? OR base_id = ?", index.ID, index.ID).Delete(IndexBase{}).Error; err != nil {
+		tx.Rollback()
+		return
+	}
+	if err = tx.Where("index_id = ?", index.ID).Delete(IndexACL{}).Error; err != nil {
+		tx.Rollback()
+		return
+	}
+	if err = tx.Delete(&index).Error; err != nil {
+		tx.Rollback()
+		return
+	}
+	return tx.Commit().Error
+}
+
+/*
+WebhookManager database manager for webhooks and their deliveries
+*/
+type WebhookManager struct {
+	DB *gorm.DB
+}
+
+/*
+ListForEvent returns active webhooks subscribed to event, global webhooks are always included, package webhooks only
+for given package
+*/
+func (w *WebhookManager) ListForEvent(target *[]Webhook, event string, packageID uint) *gorm.DB {
+	queryset := w.DB.Where("is_active = ?", true)
+	if packageID > 0 {
+		queryset = queryset.Where("package_id IS NULL OR package_id = ?", packageID)
+	} else {
+		queryset = queryset.Where("package_id IS NULL")
+	}
+
+	if queryset = queryset.Find(target); queryset.Error != nil {
+		return queryset
+	}
+
+	result := []Webhook{}
+	for _, webhook := range *target {
+		if webhook.Subscribes(event) {
+			result = append(result, webhook)
+		}
+	}
+	*target = result
+
+	return queryset
+}
+
+/*
+CreateDelivery stores pending delivery of payload to webhook
+*/
+func (w *WebhookManager) CreateDelivery(webhook Webhook, event, payload string) (delivery WebhookDelivery, err error) {
+	delivery = WebhookDelivery{
+		WebhookID:     webhook.ID,
+		Event:         event,
+		Payload:       payload,
+		Status:        WEBHOOK_DELIVERY_PENDING,
+		NextAttemptAt: time.Now(),
+	}
+	err = w.DB.Create(&delivery).Error
+	return
+}
+
+/*
+Redeliver creates new pending delivery with payload of given delivery
+*/
+func (w *WebhookManager) Redeliver(delivery WebhookDelivery) (WebhookDelivery, error) {
+	return w.CreateDelivery(Webhook{ID: delivery.WebhookID}, delivery.Event, delivery.Payload)
+}
+
+/*
+ListDue returns pending deliveries that should be sent now
+*/
+func (w *WebhookManager) ListDue(target *[]WebhookDelivery, limit int) *gorm.DB {
+	return w.DB.
+		Where("status = ? AND next_attempt_at <= ?", WEBHOOK_DELIVERY_PENDING, time.Now()).
+		Order("next_attempt_at ASC").
+		Limit(limit).
+		Find(target)
+}
+
+/*
+Claim postpones next attempt of delivery, so other dispatchers don't send it at the same time. Returns false when
+delivery was already claimed.
+*/
+func (w *WebhookManager) Claim(delivery WebhookDelivery, until time.Time) bool {
+	queryset := w.DB.Model(WebhookDelivery{}).
+		Where("id = ? AND status = ? AND next_attempt_at = ?", delivery.ID, WEBHOOK_DELIVERY_PENDING, delivery.NextAttemptAt).
+		UpdateColumn("next_attempt_at", until)
+	return queryset.Error == nil && queryset.RowsAffected == 1
+}
+
+/*
+Delete deletes webhook along with its deliveries
+*/
+func (w *WebhookManager) Delete(webhook Webhook) (err error) {
+	tx := w.DB.Begin()
+	if err = tx.Where("webhook_id = ?", webhook.ID).Delete(WebhookDelivery{}).Error; err != nil {
+		tx.Rollback()
+		return
+	}
+	if err = tx.Delete(&webhook).Error; err != nil {
+		tx.Rollback()
+		return
+	}
+	return tx.Commit().Error
 }
 
 /*
@@ -535,6 +1505,7 @@ UserManager groups functionality to query user model instances
 type UserManager struct {
 	DB        *gorm.DB
 	SecretKey string
+	Hashers   *PasswordHashers
 }
 
 /*
@@ -559,31 +1530,20 @@ func (u *UserManager) Get(user *User) *gorm.DB {
 }
 
 /*
-SetPassword sets password for given user
+SetPassword sets password for given user, password is hashed with default hasher
 */
 func (u *UserManager) SetPassword(user *User, password string) {
-	salt := GenerateSalt(PASSWORD_SALT_BYTES)
-	strsalt := base64.StdEncoding.EncodeToString(salt)
-
-	var (
-		err     error
-		hash    []byte
-		strhash string
-	)
-
-	finalSalt := append(salt, []byte(u.SecretKey)...)
-
-	if hash, err = scrypt.Key([]byte(password), finalSalt, PASSWORD_ITERATIONS, 8, 1, PASSWORD_HASH_BYTES); err != nil {
+	encoded, err := u.Hashers.Encode(password)
+	if err != nil {
 		panic(err)
 	}
 
-	strhash = base64.StdEncoding.EncodeToString(hash)
-	user.Password = fmt.Sprintf("scrypt$%v$%v$%v", PASSWORD_ITERATIONS, strsalt, strhash)
+	user.Password = encoded
 	return
 }
 
 /*
-VerifyPassword verifies password for user
+VerifyPassword verifies password for user, hasher is selected by algorithm prefix of stored hash
 */
 func (u *UserManager) VerifyPassword(user User, password string) bool {
 	// unusable password
@@ -591,36 +1551,17 @@ func (u *UserManager) VerifyPassword(user User, password string) bool {
 		return false
 	}
 
-	splitted := strings.SplitN(user.Password, "$", 4)
-
-	// invalid password
-	if len(splitted) != 4 {
-		return false
-	}
-
-	// algorithm currently not used (in the future we will have probably them)
-	iterations, salt, hash := splitted[1], splitted[2], splitted[3]
-
-	var (
-		err     error
-		iterint int
-	)
-	if iterint, err = strconv.Atoi(iterations); err != nil {
-		return false
-	}
-
-	saltbyte, errsalt := base64.StdEncoding.DecodeString(salt)
-	if errsalt != nil {
-		return false
-	}
-
-	finalSalt := append(saltbyte, []byte(u.SecretKey)...)
+	return u.Hashers.Verify(password, user.Password)
+}
 
-	one, err := scrypt.Key([]byte(password), finalSalt, iterint, 8, 1, PASSWORD_HASH_BYTES)
-	if err != nil {
+/*
+MustUpdatePassword returns whether user password hash should be rehashed with default hasher (other algorithm or
+outdated parameters).
+*/
+func (u *UserManager) MustUpdatePassword(user User) bool {
+	if strings.TrimSpace(user.Password) == "" {
 		return false
 	}
 
-	dest := base64.StdEncoding.EncodeToString(one)
-	return dest == hash
+	return u.Hashers.MustUpdate(user.Password)
 }
@@ -0,0 +1,466 @@
+package core
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+/*
+sessionRow returns database row of session with given refresh token hash
+*/
+func sessionRow(refreshHash string, revokedAt interface{}) testDBResult {
+	now := time.Now()
+	return testDBResult{
+		Columns: []string{"id", "user_id", "session_key", "refresh_token", "user_agent", "remote_addr", "created_at",
+			"last_used_at", "expires_at", "revoked_at"},
+		Rows: [][]driver.Value{
+			{int64(1), int64(2), "key", refreshHash, "pip", "10.0.0.1", now, now, now.Add(time.Hour), revokedAt},
+		},
+	}
+}
+
+func TestSessionManagerCreate(t *testing.T) {
+	db, fake := newTestDB(t, "postgres", func(query string, args []driver.Value) testDBResult {
+		return testDBResult{Columns: []string{"id"}, Rows: [][]driver.Value{{int64(1)}}}
+	})
+	manager := &SessionManager{DB: db}
+
+	session, refresh, err := manager.Create(User{ID: 2}, "pip", "10.0.0.1")
+	if err != nil {
+		t.Fatalf("Create returned error: %v", err)
+	}
+	if refresh == "" || session.RefreshToken != manager.hashRefreshToken(refresh) {
+		t.Errorf("Create should store hash of refresh token")
+	}
+	for _, args := range fake.args {
+		for _, arg := range args {
+			if arg == refresh {
+				t.Errorf("plain refresh token was sent to database")
+			}
+		}
+	}
+}
+
+func TestSessionManagerRefresh(t *testing.T) {
+	manager := &SessionManager{}
+	hash := manager.hashRefreshToken("refresh")
+
+	tc := []struct {
+		name     string
+		token    string
+		row      testDBResult
+		affected int64
+		err      error
+	}{
+		{"empty", " ", testDBResult{}, 0, ErrTokenInvalid},
+		{"unknown", "refresh", testDBResult{Columns: []string{"id"}}, 0, ErrTokenInvalid},
+		{"revoked", "refresh", sessionRow(hash, time.Now()), 0, ErrTokenRevoked},
+		{"rotated concurrently", "refresh", sessionRow(hash, nil), 0, ErrTokenInvalid},
+		{"valid", "refresh", sessionRow(hash, nil), 1, nil},
+	}
+
+	for _, tt := range tc {
+		t.Run(tt.name, func(st *testing.T) {
+			db, fake := newTestDB(st, "postgres", func(query string, args []driver.Value) testDBResult {
+				if strings.HasPrefix(query, "UPDATE") {
+					return testDBResult{RowsAffected: tt.affected}
+				}
+				return tt.row
+			})
+			manager.DB = db
+
+			session, refresh, err := manager.Refresh(tt.token)
+			if err != tt.err {
+				st.Fatalf("Refresh returned error %v, expected %v", err, tt.err)
+			}
+			if err != nil {
+				if refresh != "" {
+					st.Errorf("Refresh returned new refresh token with error")
+				}
+				return
+			}
+
+			if refresh == "" || refresh == tt.token || session.RefreshToken != manager.hashRefreshToken(refresh) {
+				st.Errorf("Refresh should rotate refresh token")
+			}
+
+			// rotation must be conditional on old refresh token
+			updates := fake.Queries("UPDATE")
+			if len(updates) != 1 || !strings.Contains(updates[0], "refresh_token = ") || !strings.Contains(updates[0], "revoked_at IS NULL") {
+				st.Fatalf("Refresh executed %q", updates)
+			}
+			found := false
+			for _, arg := range fake.args[len(fake.args)-1] {
+				found = found || arg == hash
+			}
+			if !found {
+				st.Errorf("rotation is not conditional on old refresh token")
+			}
+		})
+	}
+}
+
+/*
+countResult returns result of count query
+*/
+func countResult(count int64) testDBResult {
+	return testDBResult{Columns: []string{"count"}, Rows: [][]driver.Value{{count}}}
+}
+
+func TestPackageVersionFileManagerCheckUpload(t *testing.T) {
+	tc := []struct {
+		policy  string
+		version string
+		exists  bool
+		used    bool
+		err     error
+	}{
+		{UPLOAD_POLICY_IMMUTABLE, "1.0", false, false, nil},
+		{UPLOAD_POLICY_IMMUTABLE, "1.0", true, true, ErrFileAlreadyExists},
+		{UPLOAD_POLICY_IMMUTABLE, "1.0", false, true, ErrFileNameReused},
+		{UPLOAD_POLICY_IMMUTABLE, "1.0.dev1", true, true, ErrFileAlreadyExists},
+		{UPLOAD_POLICY_OVERWRITE_DEV, "1.0.dev1", true, true, nil},
+		{UPLOAD_POLICY_OVERWRITE_DEV, "1.0.dev1", false, true, nil},
+		{UPLOAD_POLICY_OVERWRITE_DEV, "1.0", true, true, ErrFileOverwriteDevOnly},
+		{UPLOAD_POLICY_OVERWRITE_DEV, "1.0", false, true, ErrFileNameReused},
+		{UPLOAD_POLICY_OVERWRITE_DEV, "1.0", false, false, nil},
+		{UPLOAD_POLICY_OVERWRITE, "1.0", true, true, nil},
+		{UPLOAD_POLICY_OVERWRITE, "1.0", false, true, nil},
+	}
+
+	for _, tt := range tc {
+		t.Run(tt.policy+" "+tt.version, func(st *testing.T) {
+			db, _ := newTestDB(st, "postgres", func(query string, args []driver.Value) testDBResult {
+				if tt.used && strings.Contains(query, "package_file_history") {
+					return countResult(1)
+				}
+				return countResult(0)
+			})
+			manager := &PackageVersionFileManager{Manager: &Manager{DB: db}}
+
+			err := manager.CheckUpload(tt.policy, Package{IndexID: 1}, PackageVersion{Version: tt.version}, "pkg-1.0.tar.gz", tt.exists)
+			if err != tt.err {
+				st.Errorf("CheckUpload(exists=%v, used=%v) returned %v, expected %v", tt.exists, tt.used, err, tt.err)
+			}
+		})
+	}
+}
+
+func TestPackageManagerUploadPolicy(t *testing.T) {
+	tc := []struct {
+		name    string
+		pack    string
+		index   string
+		queried bool
+		out     string
+	}{
+		{"package", UPLOAD_POLICY_OVERWRITE, UPLOAD_POLICY_IMMUTABLE, false, UPLOAD_POLICY_OVERWRITE},
+		{"index", "", UPLOAD_POLICY_OVERWRITE_DEV, true, UPLOAD_POLICY_OVERWRITE_DEV},
+		{"default", "", "", true, UPLOAD_POLICY_IMMUTABLE},
+	}
+
+	for _, tt := range tc {
+		t.Run(tt.name, func(st *testing.T) {
+			db, fake := newTestDB(st, "postgres", func(query string, args []driver.Value) testDBResult {
+				return testDBResult{Columns: []string{"upload_policy"}, Rows: [][]driver.Value{{tt.index}}}
+			})
+			manager := &PackageManager{DB: db}
+
+			if result := manager.UploadPolicy(Package{IndexID: 1, UploadPolicy: tt.pack}); result != tt.out {
+				st.Errorf("UploadPolicy returned %q, expected %q", result, tt.out)
+			}
+			if queried := len(fake.Queries("package_index")) > 0; queried != tt.queried {
+				st.Errorf("UploadPolicy queried index: %v", queried)
+			}
+		})
+	}
+}
+
+func TestPackageVersionFileManagerExists(t *testing.T) {
+	db, fake := newTestDB(t, "postgres", func(query string, args []driver.Value) testDBResult {
+		return countResult(1)
+	})
+	manager := &PackageVersionFileManager{Manager: &Manager{DB: db}}
+
+	if !manager.Exists(PackageVersion{ID: 3}, "pkg-1.0.tar.gz") {
+		t.Errorf("Exists returned false")
+	}
+	if queries := fake.Queries("package_version_file"); len(queries) != 1 || !strings.Contains(queries[0], "filename = ") {
+		t.Errorf("Exists executed %q", queries)
+	}
+}
+
+/*
+testIndexes is graph of indexes with their bases and access lists answering queries of IndexManager
+*/
+type testIndexes struct {
+	indexes []Index
+	bases   map[uint][]uint
+	acl     []IndexACL
+}
+
+/*
+handler answers queries of index tables
+*/
+func (g testIndexes) handler(query string, args []driver.Value) testDBResult {
+	indexRows := func(match func(Index) bool) testDBResult {
+		result := testDBResult{Columns: []string{"id", "name", "public", "open_upload"}}
+		for _, index := range g.indexes {
+			if match(index) {
+				result.Rows = append(result.Rows, []driver.Value{int64(index.ID), index.Name, index.Public, index.OpenUpload})
+			}
+		}
+		return result
+	}
+	hasArg := func(id uint) bool {
+		for _, arg := range args {
+			if arg == int64(id) {
+				return true
+			}
+		}
+		return false
+	}
+
+	switch {
+	case strings.HasPrefix(query, "INSERT"):
+		return testDBResult{Columns: []string{"id"}, Rows: [][]driver.Value{{int64(1)}}, RowsAffected: 1}
+	case strings.HasPrefix(query, "DELETE"):
+		return testDBResult{}
+	case strings.Contains(query, `FROM "package_index_base"`):
+		result := testDBResult{Columns: []string{"id", "index_id", "base_id", "position"}}
+		id := uint(args[0].(int64))
+		for position, base := range g.bases[id] {
+			result.Rows = append(result.Rows, []driver.Value{int64(position + 1), int64(id), int64(base), int64(position)})
+		}
+		return result
+	case strings.Contains(query, `FROM "package_index_acl"`):
+		result := testDBResult{Columns: []string{"id", "index_id", "user_id", "can_read", "can_upload"}}
+		for _, acl := range g.acl {
+			if acl.IndexID == uint(args[0].(int64)) && acl.UserID == uint(args[1].(int64)) {
+				result.Rows = append(result.Rows, []driver.Value{int64(1), int64(acl.IndexID), int64(acl.UserID), acl.CanRead, acl.CanUpload})
+			}
+		}
+		return result
+	case strings.Contains(query, `FROM "package_index"`) && strings.Contains(query, "name = "):
+		return indexRows(func(index Index) bool { return index.Name == args[0] })
+	case strings.Contains(query, `FROM "package_index"`) && strings.Contains(query, "WHERE"):
+		return indexRows(func(index Index) bool { return hasArg(index.ID) })
+	case strings.Contains(query, `FROM "package_index"`):
+		return indexRows(func(index Index) bool { return true })
+	}
+	return testDBResult{}
+}
+
+/*
+newTestIndexes returns indexes a (1), b (2), c (3) and d (4) where a inherits b and c, and both b and c inherit d
+*/
+func newTestIndexes() testIndexes {
+	return testIndexes{
+		indexes: []Index{{ID: 1, Name: "a"}, {ID: 2, Name: "b"}, {ID: 3, Name: "c"}, {ID: 4, Name: "d"}},
+		bases:   map[uint][]uint{1: {2, 3}, 2: {4}, 3: {4}},
+	}
+}
+
+func TestIndexManagerResolve(t *testing.T) {
+	tc := []struct {
+		name  string
+		bases map[uint][]uint
+		index uint
+		ids   []uint
+	}{
+		{"without bases", map[uint][]uint{}, 1, []uint{1}},
+		{"depth first by position", newTestIndexes().bases, 1, []uint{1, 2, 4, 3}},
+		{"base", newTestIndexes().bases, 2, []uint{2, 4}},
+		{"reversed positions", map[uint][]uint{1: {3, 2}, 2: {4}}, 1, []uint{1, 3, 2, 4}},
+		{"cycle", map[uint][]uint{1: {2}, 2: {3}, 3: {1}}, 1, []uint{1, 2, 3}},
+		{"self", map[uint][]uint{1: {1, 2}}, 1, []uint{1, 2}},
+	}
+
+	for _, tt := range tc {
+		t.Run(tt.name, func(st *testing.T) {
+			graph := newTestIndexes()
+			graph.bases = tt.bases
+			db, _ := newTestDB(st, "postgres", graph.handler)
+			manager := &IndexManager{DB: db}
+
+			ids, err := manager.ResolveIDs(Index{ID: tt.index})
+			if err != nil {
+				st.Fatalf("ResolveIDs returned error: %v", err)
+			}
+			if fmt.Sprint(ids) != fmt.Sprint(tt.ids) {
+				st.Errorf("ResolveIDs returned %v, expected %v", ids, tt.ids)
+			}
+		})
+	}
+}
+
+func TestIndexManagerSetBases(t *testing.T) {
+	tc := []struct {
+		name     string
+		existing map[uint][]uint
+		index    uint
+		bases    []string
+		err      error
+	}{
+		{"valid", map[uint][]uint{1: {2}, 2: {3}}, 4, []string{"b", "c"}, nil},
+		{"none", newTestIndexes().bases, 1, []string{}, nil},
+		{"self", newTestIndexes().bases, 1, []string{"a"}, ErrIndexCycle},
+		{"direct cycle", newTestIndexes().bases, 2, []string{"a"}, ErrIndexCycle},
+		{"indirect cycle", newTestIndexes().bases, 4, []string{"a"}, ErrIndexCycle},
+		{"unknown", newTestIndexes().bases, 1, []string{"unknown"}, ErrIndexNotFound},
+	}
+
+	for _, tt := range tc {
+		t.Run(tt.name, func(st *testing.T) {
+			graph := newTestIndexes()
+			graph.bases = tt.existing
+			db, fake := newTestDB(st, "postgres", graph.handler)
+			manager := &IndexManager{DB: db}
+
+			if err := manager.SetBases(Index{ID: tt.index}, tt.bases); err != tt.err {
+				st.Fatalf("SetBases returned %v, expected %v", err, tt.err)
+			}
+
+			writes := len(fake.Queries("DELETE")) + len(fake.Queries("INSERT"))
+			if tt.err != nil && writes > 0 {
+				st.Errorf("SetBases changed bases despite error")
+			}
+			if tt.err == nil && len(fake.Queries("INSERT")) != len(tt.bases) {
+				st.Errorf("SetBases inserted %v bases, expected %v", len(fake.Queries("INSERT")), len(tt.bases))
+			}
+		})
+	}
+}
+
+func TestIndexManagerACL(t *testing.T) {
+	graph := testIndexes{
+		indexes: []Index{
+			{ID: 1, Name: "private"},
+			{ID: 2, Name: "public", Public: true},
+			{ID: 3, Name: "open", OpenUpload: true},
+		},
+		acl: []IndexACL{
+			{IndexID: 1, UserID: 10, CanRead: true},
+			{IndexID: 1, UserID: 11, CanRead: true, CanUpload: true},
+			{IndexID: 1, UserID: 12},
+		},
+	}
+	db, _ := newTestDB(t, "postgres", graph.handler)
+	manager := &IndexManager{DB: db}
+
+	tc := []struct {
+		name   string
+		index  Index
+		user   User
+		read   bool
+		upload bool
+	}{
+		{"admin", graph.indexes[0], User{ID: 1, IsAdmin: true}, true, true},
+		{"without acl", graph.indexes[0], User{ID: 2}, false, false},
+		{"read acl", graph.indexes[0], User{ID: 10}, true, false},
+		{"upload acl", graph.indexes[0], User{ID: 11}, true, true},
+		{"empty acl", graph.indexes[0], User{ID: 12}, false, false},
+		{"public", graph.indexes[1], User{ID: 2}, true, false},
+		{"open upload", graph.indexes[2], User{ID: 2}, false, true},
+	}
+
+	for _, tt := range tc {
+		t.Run(tt.name, func(st *testing.T) {
+			if result := manager.CanRead(tt.index, tt.user); result != tt.read {
+				st.Errorf("CanRead returned %v", result)
+			}
+			if result := manager.CanUpload(tt.index, tt.user); result != tt.upload {
+				st.Errorf("CanUpload returned %v", result)
+			}
+		})
+	}
+}
+
+func TestIndexManagerCanReadPackage(t *testing.T) {
+	// public index "team" inherits private index "internal", private index "secret" is standalone
+	graph := testIndexes{
+		indexes: []Index{
+			{ID: 1, Name: "team", Public: true},
+			{ID: 2, Name: "internal"},
+			{ID: 3, Name: "secret"},
+		},
+		bases: map[uint][]uint{1: {2}},
+		acl:   []IndexACL{{IndexID: 3, UserID: 10, CanRead: true}},
+	}
+	db, _ := newTestDB(t, "postgres", graph.handler)
+	manager := &IndexManager{DB: db}
+
+	tc := []struct {
+		name  string
+		index uint
+		user  User
+		read  bool
+	}{
+		{"own index", 1, User{ID: 2}, true},
+		{"inherited index", 2, User{ID: 2}, true},
+		{"private index", 3, User{ID: 2}, false},
+		{"private index with acl", 3, User{ID: 10}, true},
+		{"admin", 3, User{ID: 1, IsAdmin: true}, true},
+	}
+
+	for _, tt := range tc {
+		t.Run(tt.name, func(st *testing.T) {
+			if result := manager.CanReadPackage(Package{IndexID: tt.index}, tt.user); result != tt.read {
+				st.Errorf("CanReadPackage returned %v", result)
+			}
+		})
+	}
+}
+
+func TestNotificationManagerRecipients(t *testing.T) {
+	// user 4 wants only maintainer notifications, preference of user 5 cannot be read
+	db, _ := newTestDB(t, "postgres", func(query string, args []driver.Value) testDBResult {
+		if !strings.Contains(query, "notification_preference") {
+			return testDBResult{}
+		}
+		switch args[0] {
+		case int64(4):
+			return testDBResult{
+				Columns: []string{"id", "user_id", "new_version", "maintainer", "password"},
+				Rows:    [][]driver.Value{{int64(1), int64(4), false, true, false}},
+			}
+		case int64(5):
+			return testDBResult{Err: fmt.Errorf("connection lost")}
+		}
+		return testDBResult{}
+	})
+	manager := &NotificationManager{DB: db}
+
+	users := []User{
+		{ID: 1, Username: "default", Email: "default@example.com", IsActive: true},
+		{ID: 2, Username: "inactive", Email: "inactive@example.com"},
+		{ID: 3, Username: "without email", IsActive: true},
+		{ID: 4, Username: "maintainer only", Email: "maintainer@example.com", IsActive: true},
+		{ID: 5, Username: "broken", Email: "broken@example.com", IsActive: true},
+	}
+
+	tc := []struct {
+		kind       string
+		recipients string
+	}{
+		{NOTIFICATION_NEW_VERSION, "default"},
+		{NOTIFICATION_MAINTAINER, "default,maintainer only"},
+		{NOTIFICATION_PASSWORD, "default"},
+		{"unknown", ""},
+	}
+
+	for _, tt := range tc {
+		t.Run(tt.kind, func(st *testing.T) {
+			names := []string{}
+			for _, user := range manager.Recipients(users, tt.kind) {
+				names = append(names, user.Username)
+			}
+			if result := strings.Join(names, ","); result != tt.recipients {
+				st.Errorf("Recipients returned %q, expected %q", result, tt.recipients)
+			}
+		})
+	}
+}
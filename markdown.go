@@ -0,0 +1,746 @@
+/*
+Markdown renderer
+
+Renderer of CommonMark subset with GitHub extensions used by READMEs: ATX and setext headings, paragraphs with hard
+line breaks, fenced and indented code, block quotes, nested ordered and unordered lists (with task list items),
+thematic breaks and tables. Inline: code spans, emphasis, strikethrough, inline and reference links, images,
+autolinks, backslash escapes and entities (decoded and escaped again). Raw html is escaped.
+*/
+package core
+
+import (
+	"html"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var (
+	markdownFenceRegexp      = regexp.MustCompile("^( {0,3})(`{3,}|~{3,})\\s*([^`\\s]*)")
+	markdownHeadingRegexp    = regexp.MustCompile(`^ {0,3}(#{1,6})(?:[ ]+(.*?))?(?:[ ]+#+)?[ ]*$`)
+	markdownListItemRegexp   = regexp.MustCompile(`^( {0,3})([-*+]|[0-9]{1,9}[.)])( +|$)(.*)$`)
+	markdownBlockquoteRegexp = regexp.MustCompile(`^ {0,3}> ?(.*)$`)
+	markdownSetextRegexp     = regexp.MustCompile(`^ {0,3}(=+|-+)[ ]*$`)
+	markdownTableDelimiter   = regexp.MustCompile(`^ *\|? *:?-+:? *(\| *:?-+:? *)*\|? *$`)
+	markdownReferenceRegexp  = regexp.MustCompile(`^ {0,3}\[([^\]]+)\]:[ ]*<?([^\s>]+)>?(?:[ ]+["'(](.*)["')])?[ ]*$`)
+	markdownAutolinkRegexp   = regexp.MustCompile(`^<((?:https?|ftp|mailto):[^\s<>]*|[^\s@<>]+@[^\s@<>]+\.[^\s@<>]+)>`)
+	markdownInfoRegexp       = regexp.MustCompile(`[^A-Za-z0-9_+-]`)
+	markdownEntityRegexp     = regexp.MustCompile(`^&(?:#[0-9]{1,7}|#[xX][0-9a-fA-F]{1,6}|[A-Za-z][A-Za-z0-9]{1,31});`)
+)
+
+// hard line break marker (control characters are removed from description before rendering)
+const markdownBreak = "\x01"
+
+/*
+markdownReference is link reference definition
+*/
+type markdownReference struct {
+	URL   string
+	Title string
+}
+
+/*
+markdown renders single document
+*/
+type markdown struct {
+	references map[string]markdownReference
+}
+
+/*
+renderMarkdown renders markdown text to html
+*/
+func renderMarkdown(text string) string {
+	m := &markdown{references: map[string]markdownReference{}}
+	lines := m.collectReferences(strings.Split(text, "\n"))
+	return m.blocks(lines, false)
+}
+
+/*
+collectReferences removes link reference definitions (outside of code blocks) and stores them
+*/
+func (m *markdown) collectReferences(lines []string) (result []string) {
+	fence := ""
+	for _, line := range lines {
+		if match := markdownFenceRegexp.FindStringSubmatch(line); match != nil {
+			if fence == "" {
+				fence = match[2]
+			} else if strings.HasPrefix(match[2], fence[:1]) && len(match[2]) >= len(fence) && match[3] == "" {
+				fence = ""
+			}
+		} else if fence == "" {
+			if match := markdownReferenceRegexp.FindStringSubmatch(line); match != nil {
+				label := normalizeReferenceLabel(match[1])
+				if _, ok := m.references[label]; !ok {
+					m.references[label] = markdownReference{URL: match[2], Title: match[3]}
+				}
+				continue
+			}
+		}
+		result = append(result, line)
+	}
+	return
+}
+
+/*
+normalizeReferenceLabel returns case insensitive label with collapsed whitespace
+*/
+func normalizeReferenceLabel(label string) string {
+	return strings.ToLower(strings.Join(strings.Fields(label), " "))
+}
+
+/*
+isThematicBreak returns whether line is thematic break ("---", "* * *", "___")
+*/
+func isThematicBreak(line string) bool {
+	if indentation(line) > 3 {
+		return false
+	}
+	stripped := strings.Replace(strings.TrimSpace(line), " ", "", -1)
+	if len(stripped) < 3 {
+		return false
+	}
+	for _, char := range []string{"-", "*", "_"} {
+		if strings.Count(stripped, char) == len(stripped) {
+			return true
+		}
+	}
+	return false
+}
+
+/*
+startsBlock returns whether line interrupts paragraph
+*/
+func (m *markdown) startsBlock(line string) bool {
+	if markdownFenceRegexp.MatchString(line) || markdownBlockquoteRegexp.MatchString(line) || isThematicBreak(line) {
+		return true
+	}
+	if match := markdownHeadingRegexp.FindStringSubmatch(line); match != nil {
+		return true
+	}
+	if match := markdownListItemRegexp.FindStringSubmatch(line); match != nil && strings.TrimSpace(match[4]) != "" {
+		return !strings.ContainsAny(match[2], ".)") || match[2][:len(match[2])-1] == "1"
+	}
+	return false
+}
+
+/*
+blocks renders block level elements, paragraphs of tight lists are not wrapped
+*/
+func (m *markdown) blocks(lines []string, tight bool) string {
+	var b strings.Builder
+
+	for i := 0; i < len(lines); {
+		line := lines[i]
+
+		switch {
+		case isBlank(line):
+			i++
+
+		case markdownFenceRegexp.MatchString(line):
+			i = m.fencedCode(&b, lines, i)
+
+		case markdownHeadingRegexp.MatchString(line):
+			match := markdownHeadingRegexp.FindStringSubmatch(line)
+			level := strconv.Itoa(len(match[1]))
+			b.WriteString("<h" + level + ">" + m.inline(strings.TrimSpace(match[2])) + "</h" + level + ">\n")
+			i++
+
+		case isThematicBreak(line):
+			b.WriteString("<hr>\n")
+			i++
+
+		case indentation(line) >= 4:
+			start := i
+			for i < len(lines) && (isBlank(lines[i]) || indentation(lines[i]) >= 4) {
+				i++
+			}
+			code := []string{}
+			for _, codeLine := range lines[start:i] {
+				if len(codeLine) >= 4 {
+					codeLine = codeLine[4:]
+				} else {
+					codeLine = ""
+				}
+				code = append(code, codeLine)
+			}
+			for len(code) > 0 && code[len(code)-1] == "" {
+				code = code[:len(code)-1]
+			}
+			b.WriteString("<pre><code>" + html.EscapeString(strings.Join(code, "\n")) + "</code></pre>\n")
+
+		case markdownBlockquoteRegexp.MatchString(line):
+			quoted := []string{}
+			for i < len(lines) {
+				if match := markdownBlockquoteRegexp.FindStringSubmatch(lines[i]); match != nil {
+					quoted = append(quoted, match[1])
+				} else if !isBlank(lines[i]) && len(quoted) > 0 && !isBlank(quoted[len(quoted)-1]) && !m.startsBlock(lines[i]) {
+					// lazy continuation of paragraph
+					quoted = append(quoted, lines[i])
+				} else {
+					break
+				}
+				i++
+			}
+			b.WriteString("<blockquote>\n" + m.blocks(quoted, false) + "</blockquote>\n")
+
+		case markdownListItemRegexp.MatchString(line):
+			i = m.list(&b, lines, i)
+
+		case i+1 < len(lines) && strings.Contains(line, "|") && markdownTableDelimiter.MatchString(lines[i+1]) &&
+			len(splitTableRow(line)) == len(splitTableRow(lines[i+1])):
+			i = m.table(&b, lines, i)
+
+		default:
+			i = m.paragraph(&b, lines, i, tight)
+		}
+	}
+
+	return b.String()
+}
+
+/*
+fencedCode renders fenced code block starting at line i, returns index of next line
+*/
+func (m *markdown) fencedCode(b *strings.Builder, lines []string, i int) int {
+	match := markdownFenceRegexp.FindStringSubmatch(lines[i])
+	indent, fence, info := len(match[1]), match[2], markdownInfoRegexp.ReplaceAllString(match[3], "")
+
+	code := []string{}
+	for i++; i < len(lines); i++ {
+		closing := strings.TrimSpace(lines[i])
+		if indentation(lines[i]) < 4 && strings.HasPrefix(closing, fence) && strings.Trim(closing, fence[:1]) == "" {
+			i++
+			break
+		}
+
+		line := lines[i]
+		if remove := indentation(line); remove > 0 {
+			if remove > indent {
+				remove = indent
+			}
+			line = line[remove:]
+		}
+		code = append(code, line)
+	}
+
+	if info != "" {
+		b.WriteString(`<pre><code class="language-` + info + `">`)
+	} else {
+		b.WriteString("<pre><code>")
+	}
+	b.WriteString(html.EscapeString(strings.Join(code, "\n")) + "</code></pre>\n")
+	return i
+}
+
+/*
+list renders list starting at line i, returns index of next line
+*/
+func (m *markdown) list(b *strings.Builder, lines []string, i int) int {
+	first := markdownListItemRegexp.FindStringSubmatch(lines[i])
+	ordered := strings.ContainsAny(first[2], ".)")
+	delimiter := first[2][len(first[2])-1:]
+
+	items := [][]string{}
+	loose := false
+	blank := false
+
+	for i < len(lines) {
+		line := lines[i]
+		match := markdownListItemRegexp.FindStringSubmatch(line)
+
+		// new item of the same list
+		if match != nil && strings.ContainsAny(match[2], ".)") == ordered && strings.HasSuffix(match[2], delimiter) && !isThematicBreak(line) {
+			if blank && len(items) > 0 {
+				loose = true
+			}
+			// content of item is indented by width of marker, code in item starts after single space
+			width := len(match[1]) + len(match[2]) + len(match[3])
+			content := match[4]
+			if match[4] == "" {
+				width = len(match[1]) + len(match[2]) + 1
+			} else if len(match[3]) > 4 {
+				width = len(match[1]) + len(match[2]) + 1
+				content = strings.Repeat(" ", len(match[3])-1) + match[4]
+			}
+			items = append(items, []string{content})
+			blank = false
+			i++
+
+			// continuation lines of item
+			for i < len(lines) {
+				next := lines[i]
+				if isBlank(next) {
+					items[len(items)-1] = append(items[len(items)-1], "")
+					blank = true
+					i++
+					continue
+				}
+				if indentation(next) >= width {
+					if blank {
+						loose = loose || !m.onlyNestedList(items[len(items)-1])
+					}
+					items[len(items)-1] = append(items[len(items)-1], next[width:])
+					blank = false
+					i++
+					continue
+				}
+				if !blank && !m.startsBlock(next) && !markdownListItemRegexp.MatchString(next) {
+					// lazy continuation of paragraph
+					items[len(items)-1] = append(items[len(items)-1], strings.TrimLeft(next, " "))
+					i++
+					continue
+				}
+				break
+			}
+			continue
+		}
+		break
+	}
+
+	if ordered {
+		start, _ := strconv.Atoi(first[2][:len(first[2])-1])
+		if start != 1 {
+			b.WriteString(`<ol start="` + strconv.Itoa(start) + `">` + "\n")
+		} else {
+			b.WriteString("<ol>\n")
+		}
+	} else {
+		b.WriteString("<ul>\n")
+	}
+
+	for _, item := range items {
+		content := item
+		prefix := ""
+		if len(content) > 0 {
+			lower := strings.ToLower(content[0])
+			if strings.HasPrefix(lower, "[ ] ") {
+				prefix, content[0] = "&#9744; ", content[0][4:]
+			} else if strings.HasPrefix(lower, "[x] ") {
+				prefix, content[0] = "&#9745; ", content[0][4:]
+			}
+		}
+		rendered := m.blocks(content, !loose)
+		b.WriteString("<li>" + prefix + strings.TrimSuffix(rendered, "\n") + "</li>\n")
+	}
+
+	if ordered {
+		b.WriteString("</ol>\n")
+	} else {
+		b.WriteString("</ul>\n")
+	}
+
+	// trailing blank lines belong to list
+	return i
+}
+
+/*
+onlyNestedList returns whether item content after first line is nested list (blank lines in nested lists don't make
+outer list loose)
+*/
+func (m *markdown) onlyNestedList(item []string) bool {
+	for _, line := range item[1:] {
+		if !isBlank(line) {
+			return markdownListItemRegexp.MatchString(line)
+		}
+	}
+	return false
+}
+
+/*
+table renders GFM table starting at line i, returns index of next line
+*/
+func (m *markdown) table(b *strings.Builder, lines []string, i int) int {
+	header := splitTableRow(lines[i])
+	aligns := []string{}
+	for _, cell := range splitTableRow(lines[i+1]) {
+		left, right := strings.HasPrefix(cell, ":"), strings.HasSuffix(cell, ":")
+		switch {
+		case left && right:
+			aligns = append(aligns, "center")
+		case right:
+			aligns = append(aligns, "right")
+		case left:
+			aligns = append(aligns, "left")
+		default:
+			aligns = append(aligns, "")
+		}
+	}
+
+	cell := func(tag string, index int, content string) string {
+		if aligns[index] != "" {
+			return "<" + tag + ` align="` + aligns[index] + `">` + m.inline(content) + "</" + tag + ">"
+		}
+		return "<" + tag + ">" + m.inline(content) + "</" + tag + ">"
+	}
+
+	b.WriteString("<table>\n<thead>\n<tr>")
+	for index, content := range header {
+		b.WriteString(cell("th", index, content))
+	}
+	b.WriteString("</tr>\n</thead>\n")
+
+	i += 2
+	body := false
+	for ; i < len(lines) && !isBlank(lines[i]) && strings.Contains(lines[i], "|") && !m.startsBlock(lines[i]); i++ {
+		if !body {
+			b.WriteString("<tbody>\n")
+			body = true
+		}
+		row := splitTableRow(lines[i])
+		b.WriteString("<tr>")
+		for index := range header {
+			content := ""
+			if index < len(row) {
+				content = row[index]
+			}
+			b.WriteString(cell("td", index, content))
+		}
+		b.WriteString("</tr>\n")
+	}
+	if body {
+		b.WriteString("</tbody>\n")
+	}
+	b.WriteString("</table>\n")
+	return i
+}
+
+/*
+splitTableRow splits table row to trimmed cells, escaped pipes are part of cell
+*/
+func splitTableRow(line string) (cells []string) {
+	line = strings.TrimSpace(line)
+	line = strings.TrimPrefix(line, "|")
+	if strings.HasSuffix(line, "|") && !strings.HasSuffix(line, "\\|") {
+		line = line[:len(line)-1]
+	}
+
+	current := ""
+	for i := 0; i < len(line); i++ {
+		if line[i] == '\\' && i+1 < len(line) && line[i+1] == '|' {
+			current += "|"
+			i++
+			continue
+		}
+		if line[i] == '|' {
+			cells = append(cells, strings.TrimSpace(current))
+			current = ""
+			continue
+		}
+		current += line[i : i+1]
+	}
+	return append(cells, strings.TrimSpace(current))
+}
+
+/*
+paragraph renders paragraph (or setext heading) starting at line i, returns index of next line
+*/
+func (m *markdown) paragraph(b *strings.Builder, lines []string, i int, tight bool) int {
+	collected := []string{strings.TrimSpace(lines[i])}
+	raw := []string{lines[i]}
+
+	for i++; i < len(lines); i++ {
+		line := lines[i]
+		if isBlank(line) {
+			break
+		}
+
+		// setext heading underline
+		if match := markdownSetextRegexp.FindStringSubmatch(line); match != nil {
+			tag := "h1"
+			if strings.HasPrefix(match[1], "-") {
+				tag = "h2"
+			}
+			b.WriteString("<" + tag + ">" + m.inline(strings.Join(collected, "\n")) + "</" + tag + ">\n")
+			return i + 1
+		}
+
+		if m.startsBlock(line) {
+			break
+		}
+		collected = append(collected, strings.TrimSpace(line))
+		raw = append(raw, line)
+	}
+
+	// hard line breaks: two trailing spaces or backslash
+	for index := range collected[:len(collected)-1] {
+		if strings.HasSuffix(raw[index], "  ") {
+			collected[index] += markdownBreak
+		} else if strings.HasSuffix(collected[index], "\\") {
+			collected[index] = strings.TrimSuffix(collected[index], "\\") + markdownBreak
+		}
+	}
+
+	content := m.inline(strings.Join(collected, "\n"))
+	if tight {
+		b.WriteString(content + "\n")
+	} else {
+		b.WriteString("<p>" + content + "</p>\n")
+	}
+	return i
+}
+
+/*
+inline renders inline elements of text
+*/
+func (m *markdown) inline(text string) string {
+	var b strings.Builder
+
+	for i := 0; i < len(text); {
+		c := text[i]
+
+		switch {
+		case c == '\\' && i+1 < len(text) && strings.IndexByte("!\"#$%&'()*+,-./:;<=>?@[\\]^_`{|}~", text[i+1]) >= 0:
+			escapeByte(&b, text[i+1])
+			i += 2
+
+		case c == markdownBreak[0]:
+			b.WriteString("<br>")
+			i++
+
+		case c == '`':
+			i = m.codeSpan(&b, text, i)
+
+		case c == '!' && i+1 < len(text) && text[i+1] == '[':
+			if label, href, title, end, ok := m.link(text, i+1); ok {
+				b.WriteString(renderImage(html.UnescapeString(href), html.UnescapeString(label), html.UnescapeString(title)))
+				i = end
+			} else {
+				b.WriteString("!")
+				i++
+			}
+
+		case c == '[':
+			if label, href, title, end, ok := m.link(text, i); ok {
+				b.WriteString(renderLink(html.UnescapeString(href), m.inline(label), html.UnescapeString(title)))
+				i = end
+			} else {
+				b.WriteString("[")
+				i++
+			}
+
+		case c == '<' && markdownAutolinkRegexp.MatchString(text[i:]):
+			match := markdownAutolinkRegexp.FindStringSubmatch(text[i:])
+			href := match[1]
+			if !strings.Contains(href, ":") {
+				href = "mailto:" + href
+			}
+			b.WriteString(renderLink(href, html.EscapeString(match[1]), ""))
+			i += len(match[0])
+
+		case c == '*' || c == '_' || c == '~':
+			i = m.emphasis(&b, text, i)
+
+		case c == '&' && markdownEntityRegexp.MatchString(text[i:]):
+			// unknown named entity stays as text (its ampersand is escaped)
+			entity := markdownEntityRegexp.FindString(text[i:])
+			b.WriteString(html.EscapeString(html.UnescapeString(entity)))
+			i += len(entity)
+
+		case (i == 0 || !isAlnumByte(text[i-1])) && bareURLLength(text[i:]) > 0:
+			length := bareURLLength(text[i:])
+			b.WriteString(renderBareURL(text[i : i+length]))
+			i += length
+
+		default:
+			escapeByte(&b, c)
+			i++
+		}
+	}
+
+	return b.String()
+}
+
+/*
+codeSpan renders code span starting at i, returns index after it
+*/
+func (m *markdown) codeSpan(b *strings.Builder, text string, i int) int {
+	n := 0
+	for i+n < len(text) && text[i+n] == '`' {
+		n++
+	}
+
+	for j := i + n; j < len(text); {
+		if text[j] != '`' {
+			j++
+			continue
+		}
+		k := 0
+		for j+k < len(text) && text[j+k] == '`' {
+			k++
+		}
+		if k == n {
+			code := strings.Replace(text[i+n:j], "\n", " ", -1)
+			code = strings.Replace(code, markdownBreak, "", -1)
+			if len(code) > 1 && code[0] == ' ' && code[len(code)-1] == ' ' && strings.TrimSpace(code) != "" {
+				code = code[1 : len(code)-1]
+			}
+			b.WriteString("<code>" + html.EscapeString(code) + "</code>")
+			return j + k
+		}
+		j += k
+	}
+
+	b.WriteString(text[i : i+n])
+	return i + n
+}
+
+/*
+link parses link starting at "[" on index i. Returns label, url, title and index after link.
+*/
+func (m *markdown) link(text string, i int) (label, href, title string, end int, ok bool) {
+	depth := 0
+	closing := -1
+	for j := i; j < len(text) && closing < 0; j++ {
+		switch text[j] {
+		case '\\':
+			j++
+		case '`':
+			// brackets in code spans don't count
+			if k := strings.IndexByte(text[j+1:], '`'); k >= 0 {
+				j += k + 1
+			}
+		case '[':
+			depth++
+		case ']':
+			depth--
+			if depth == 0 {
+				closing = j
+			}
+		}
+	}
+	if closing < 0 {
+		return
+	}
+	label = text[i+1 : closing]
+
+	// inline link
+	if closing+1 < len(text) && text[closing+1] == '(' {
+		depth = 0
+		for j := closing + 1; j < len(text); j++ {
+			switch text[j] {
+			case '\\':
+				j++
+			case '(':
+				depth++
+			case ')':
+				depth--
+				if depth == 0 {
+					href, title = parseLinkDestination(text[closing+2 : j])
+					return label, href, title, j + 1, true
+				}
+			}
+		}
+		return
+	}
+
+	// full, collapsed and shortcut reference links
+	reference := label
+	end = closing + 1
+	if closing+1 < len(text) && text[closing+1] == '[' {
+		if k := strings.IndexByte(text[closing+1:], ']'); k >= 0 {
+			if inner := text[closing+2 : closing+1+k]; inner != "" {
+				reference = inner
+			}
+			end = closing + 2 + k
+		}
+	}
+
+	if found, exists := m.references[normalizeReferenceLabel(reference)]; exists {
+		return label, found.URL, found.Title, end, true
+	}
+	return
+}
+
+/*
+parseLinkDestination splits inline link destination to url and optional title
+*/
+func parseLinkDestination(value string) (href string, title string) {
+	value = strings.TrimSpace(value)
+	if strings.HasPrefix(value, "<") {
+		if k := strings.IndexByte(value, '>'); k >= 0 {
+			href, value = value[1:k], strings.TrimSpace(value[k+1:])
+		}
+	} else if k := strings.IndexAny(value, " \n"); k >= 0 {
+		href, value = value[:k], strings.TrimSpace(value[k+1:])
+	} else {
+		href, value = value, ""
+	}
+
+	if len(value) >= 2 && strings.IndexByte("\"'(", value[0]) >= 0 {
+		title = value[1 : len(value)-1]
+	}
+	return
+}
+
+/*
+emphasis renders emphasis, strong emphasis or strikethrough starting at i, returns index after it
+*/
+func (m *markdown) emphasis(b *strings.Builder, text string, i int) int {
+	c := text[i]
+	n := 0
+	for i+n < len(text) && text[i+n] == c {
+		n++
+	}
+
+	// opening delimiter must be followed by non whitespace, intraword underscores are literal
+	if i+n >= len(text) || text[i+n] == ' ' || text[i+n] == '\n' || (c == '_' && i > 0 && isAlnumByte(text[i-1])) {
+		b.WriteString(text[i : i+n])
+		return i + n
+	}
+
+	tags := map[int][]string{1: {"<em>", "</em>"}, 2: {"<strong>", "</strong>"}, 3: {"<em><strong>", "</strong></em>"}}
+	if c == '~' {
+		tags = map[int][]string{1: {"<del>", "</del>"}, 2: {"<del>", "</del>"}}
+	}
+
+	size := n
+	if size > 3 {
+		size = 3
+	}
+	for ; size > 0; size-- {
+		if _, ok := tags[size]; !ok {
+			continue
+		}
+		if closing := findEmphasisCloser(text, i+size, c, size); closing > 0 {
+			b.WriteString(text[i : i+n-size])
+			b.WriteString(tags[size][0] + m.inline(text[i+n:closing]) + tags[size][1])
+			return closing + size
+		}
+	}
+
+	b.WriteString(text[i : i+n])
+	return i + n
+}
+
+/*
+findEmphasisCloser finds closing delimiter run of given size, code spans and shorter or longer runs are skipped
+*/
+func findEmphasisCloser(text string, start int, c byte, size int) int {
+	for j := start; j < len(text); {
+		switch text[j] {
+		case '\\':
+			j += 2
+			continue
+		case '`':
+			if k := strings.IndexByte(text[j+1:], '`'); k >= 0 {
+				j += k + 2
+				continue
+			}
+		case c:
+			k := 0
+			for j+k < len(text) && text[j+k] == c {
+				k++
+			}
+			after := j + k
+			// run of three can close both strong and emphasis
+			if (k == size || k >= 3 && k >= size) && j > start && text[j-1] != ' ' && text[j-1] != '\n' &&
+				(c != '_' || after >= len(text) || !isAlnumByte(text[after])) {
+				return j + k - size
+			}
+			j += k
+			continue
+		}
+		j++
+	}
+	return -1
+}
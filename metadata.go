@@ -0,0 +1,239 @@
+/*
+Distribution metadata
+
+Core metadata (PEP 345, PEP 566) is read from uploaded files: "*.dist-info/METADATA" of wheels, "EGG-INFO/PKG-INFO"
+of eggs and "PKG-INFO" in top level directory of sdists. Metadata is stored in email header format, so it's parsed
+by net/mail and multiple use fields (Classifier, Requires-Dist) are available by GetAll.
+
+	meta, err := ReadDistributionMetadata(filename, dist)
+	requiresPython := meta.Get("Requires-Python")
+*/
+package core
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/bzip2"
+	"compress/gzip"
+	"io"
+	"io/ioutil"
+	"net/mail"
+	"os"
+	"path"
+	"regexp"
+	"strings"
+)
+
+var (
+	// single clause of version specifier (PEP 440), e.g. ">=3.8" or "!=3.0.*"
+	versionSpecifierRegexp = regexp.MustCompile(`^\s*(~=|===|==|!=|<=|>=|<|>)\s*[A-Za-z0-9.*+!_-]+\s*$`)
+)
+
+/*
+DistributionMetadata is core metadata of distribution
+*/
+type DistributionMetadata struct {
+	Header      mail.Header
+	Description string
+}
+
+/*
+Get returns first value of metadata field
+*/
+func (d DistributionMetadata) Get(key string) string {
+	if d.Header == nil {
+		return ""
+	}
+	return strings.TrimSpace(d.Header.Get(key))
+}
+
+/*
+GetAll returns all values of multiple use metadata field
+*/
+func (d DistributionMetadata) GetAll(key string) (result []string) {
+	if d.Header == nil {
+		return
+	}
+	for _, value := range d.Header[key] {
+		if value = strings.TrimSpace(value); value != "" {
+			result = append(result, value)
+		}
+	}
+	return
+}
+
+/*
+LongDescription returns description from message body (metadata 2.1) or from Description field
+*/
+func (d DistributionMetadata) LongDescription() string {
+	if d.Description != "" {
+		return d.Description
+	}
+	return d.Get("Description")
+}
+
+/*
+ParseDistributionMetadata parses content of METADATA or PKG-INFO file
+*/
+func ParseDistributionMetadata(content []byte) (result DistributionMetadata, err error) {
+	// metadata must end header section with blank line, files without body don't have it
+	if !bytes.Contains(content, []byte("\n\n")) {
+		content = append(bytes.TrimRight(content, "\n"), '\n', '\n')
+	}
+
+	var message *mail.Message
+	if message, err = mail.ReadMessage(bytes.NewReader(content)); err != nil {
+		return
+	}
+
+	result.Header = message.Header
+
+	var body []byte
+	if body, err = ioutil.ReadAll(message.Body); err != nil {
+		return
+	}
+
+	result.Description = strings.TrimSpace(string(body))
+	return
+}
+
+/*
+ReadDistributionMetadata reads metadata from distribution file stored in filename, dist is parsed filename of
+distribution (stored file can have different name).
+*/
+func ReadDistributionMetadata(filename string, dist DistributionFilename) (result DistributionMetadata, err error) {
+	var content []byte
+
+	switch {
+	case dist.PackageType == PACKAGE_TYPE_WHEEL:
+		content, err = readZipMetadata(filename, func(name string) bool {
+			dir, base := path.Split(name)
+			return base == "METADATA" && strings.Count(dir, "/") == 1 && strings.HasSuffix(dir, ".dist-info/")
+		})
+	case dist.PackageType == PACKAGE_TYPE_EGG:
+		content, err = readZipMetadata(filename, func(name string) bool {
+			return name == "EGG-INFO/PKG-INFO"
+		})
+	case dist.PackageType == PACKAGE_TYPE_SDIST && strings.HasSuffix(dist.Filename, ".zip"):
+		content, err = readZipMetadata(filename, isSdistMetadata)
+	case dist.PackageType == PACKAGE_TYPE_SDIST:
+		content, err = readTarMetadata(filename, dist.Filename)
+	default:
+		err = ErrMetadataNotFound
+	}
+
+	if err != nil {
+		return
+	}
+
+	return ParseDistributionMetadata(content)
+}
+
+/*
+ValidateRequiresPython returns error when value is not comma separated list of version specifiers
+*/
+func ValidateRequiresPython(value string) error {
+	if strings.TrimSpace(value) == "" {
+		return nil
+	}
+	for _, clause := range strings.Split(value, ",") {
+		if !versionSpecifierRegexp.MatchString(clause) {
+			return ErrInvalidRequiresPython
+		}
+	}
+	return nil
+}
+
+/*
+isSdistMetadata returns whether archive member is PKG-INFO in top level directory of sdist
+*/
+func isSdistMetadata(name string) bool {
+	dir, base := path.Split(strings.TrimPrefix(name, "./"))
+	return base == "PKG-INFO" && strings.Count(dir, "/") == 1
+}
+
+/*
+readZipMetadata returns content of first zip member that matches
+*/
+func readZipMetadata(filename string, match func(name string) bool) (content []byte, err error) {
+	var reader *zip.ReadCloser
+	if reader, err = zip.OpenReader(filename); err != nil {
+		return
+	}
+	defer reader.Close()
+
+	for _, file := range reader.File {
+		if !match(file.Name) {
+			continue
+		}
+
+		var rc io.ReadCloser
+		if rc, err = file.Open(); err != nil {
+			return
+		}
+		defer rc.Close()
+
+		return readLimited(rc)
+	}
+
+	err = ErrMetadataNotFound
+	return
+}
+
+/*
+readTarMetadata returns content of PKG-INFO from tar archive, compression is decided by original filename
+*/
+func readTarMetadata(filename string, original string) (content []byte, err error) {
+	var file *os.File
+	if file, err = os.Open(filename); err != nil {
+		return
+	}
+	defer file.Close()
+
+	var reader io.Reader = file
+	switch {
+	case strings.HasSuffix(original, ".tar.gz"), strings.HasSuffix(original, ".tgz"):
+		var gz *gzip.Reader
+		if gz, err = gzip.NewReader(file); err != nil {
+			return
+		}
+		defer gz.Close()
+		reader = gz
+	case strings.HasSuffix(original, ".tar.bz2"), strings.HasSuffix(original, ".tbz"):
+		reader = bzip2.NewReader(file)
+	case strings.HasSuffix(original, ".tar"):
+	default:
+		// xz and compress are not supported by standard library
+		err = ErrMetadataNotFound
+		return
+	}
+
+	archive := tar.NewReader(reader)
+	for {
+		var header *tar.Header
+		if header, err = archive.Next(); err != nil {
+			if err == io.EOF {
+				err = ErrMetadataNotFound
+			}
+			return
+		}
+
+		if header.Typeflag == tar.TypeReg && isSdistMetadata(header.Name) {
+			return readLimited(archive)
+		}
+	}
+}
+
+/*
+readLimited reads at most METADATA_MAX_SIZE bytes
+*/
+func readLimited(reader io.Reader) (content []byte, err error) {
+	if content, err = ioutil.ReadAll(io.LimitReader(reader, METADATA_MAX_SIZE+1)); err != nil {
+		return
+	}
+	if len(content) > METADATA_MAX_SIZE {
+		err = ErrMetadataTooLarge
+	}
+	return
+}
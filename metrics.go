@@ -0,0 +1,262 @@
+/*
+Metrics
+
+Server exposes operational metrics at /metrics in Prometheus text format. Metrics are kept in memory of single
+instance, database pool stats and buffered downloads are read when metrics are scraped.
+
+	[metrics]
+	enabled = true
+	token = ""
+
+When token is set, scraper has to send it in "Authorization: Bearer <token>" header.
+*/
+package core
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+var (
+	// buckets of request durations in seconds
+	metricsDurationBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+	// buckets of upload and task durations in seconds
+	metricsLongDurationBuckets = []float64{0.1, 0.5, 1, 5, 10, 30, 60, 300, 900, 3600}
+
+	// escapes label values
+	metricsLabelReplacer = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+)
+
+/*
+NewMetrics returns registry with all gopypi metrics
+*/
+func NewMetrics() *Metrics {
+	m := &Metrics{}
+
+	m.HTTPRequests = m.counter("gopypi_http_requests_total", "Number of http requests by route name, method and status.", "route", "method", "status")
+	m.HTTPDuration = m.histogram("gopypi_http_request_duration_seconds", "Duration of http requests by route name and method.", metricsDurationBuckets, "route", "method")
+	m.UploadBytes = m.counter("gopypi_upload_bytes_total", "Size of uploaded package files.")
+	m.UploadDuration = m.histogram("gopypi_upload_duration_seconds", "Duration of package file uploads.", metricsLongDurationBuckets)
+	m.Downloads = m.counter("gopypi_downloads_total", "Number of downloaded package files by package.", "package")
+	m.AuthFailures = m.counter("gopypi_auth_failures_total", "Number of failed authentications by method and reason.", "method", "reason")
+	m.StorageErrors = m.counter("gopypi_storage_errors_total", "Number of package storage errors by operation.", "operation")
+	m.TaskRuns = m.counter("gopypi_task_runs_total", "Number of background task runs by task and status.", "task", "status")
+	m.TaskDuration = m.histogram("gopypi_task_duration_seconds", "Duration of background task runs.", metricsLongDurationBuckets, "task")
+
+	return m
+}
+
+/*
+Metrics is registry of metrics collected by server
+*/
+type Metrics struct {
+	HTTPRequests   *MetricVec
+	HTTPDuration   *MetricVec
+	UploadBytes    *MetricVec
+	UploadDuration *MetricVec
+	Downloads      *MetricVec
+	AuthFailures   *MetricVec
+	StorageErrors  *MetricVec
+	TaskRuns       *MetricVec
+	TaskDuration   *MetricVec
+
+	vecs []*MetricVec
+}
+
+/*
+Write writes all metrics in Prometheus text format. Gauges (database pool, buffered downloads, queues) are read from
+config.
+*/
+func (m *Metrics) Write(w io.Writer, cfg Config) (err error) {
+	buf := bufio.NewWriter(w)
+
+	for _, vec := range m.vecs {
+		vec.write(buf)
+	}
+
+	gauges := []struct {
+		name  string
+		help  string
+		value float64
+	}{
+		{"gopypi_download_stats_pending", "Number of downloads buffered in memory.", float64(cfg.DownloadStats().Collector().Pending())},
+	}
+
+	if db := cfg.DB().DB(); db != nil {
+		stats := db.Stats()
+		gauges = append(gauges, []struct {
+			name  string
+			help  string
+			value float64
+		}{
+			{"gopypi_db_open_connections", "Number of open database connections.", float64(stats.OpenConnections)},
+			{"gopypi_db_in_use_connections", "Number of database connections in use.", float64(stats.InUse)},
+			{"gopypi_db_idle_connections", "Number of idle database connections.", float64(stats.Idle)},
+			{"gopypi_db_max_open_connections", "Maximum number of open database connections.", float64(stats.MaxOpenConnections)},
+			{"gopypi_db_wait_count", "Total number of connections waited for.", float64(stats.WaitCount)},
+			{"gopypi_db_wait_duration_seconds", "Total time blocked waiting for new connection.", stats.WaitDuration.Seconds()},
+		}...)
+	}
+
+	for _, gauge := range gauges {
+		fmt.Fprintf(buf, "# HELP %v %v\n# TYPE %v gauge\n%v %v\n", gauge.name, gauge.help, gauge.name, gauge.name, metricsFormatFloat(gauge.value))
+	}
+
+	return buf.Flush()
+}
+
+func (m *Metrics) counter(name, help string, labels ...string) *MetricVec {
+	vec := &MetricVec{name: name, help: help, typ: "counter", labels: labels, values: map[string]*metricValue{}}
+	m.vecs = append(m.vecs, vec)
+	return vec
+}
+
+func (m *Metrics) histogram(name, help string, buckets []float64, labels ...string) *MetricVec {
+	vec := &MetricVec{name: name, help: help, typ: "histogram", labels: labels, buckets: buckets, values: map[string]*metricValue{}}
+	m.vecs = append(m.vecs, vec)
+	return vec
+}
+
+/*
+MetricVec is counter or histogram partitioned by label values
+*/
+type MetricVec struct {
+	name    string
+	help    string
+	typ     string
+	labels  []string
+	buckets []float64
+	mutex   sync.Mutex
+	values  map[string]*metricValue
+}
+
+/*
+metricValue is value of metric for single combination of label values
+*/
+type metricValue struct {
+	labels []string
+	sum    float64
+	count  uint64
+	counts []uint64
+}
+
+/*
+Inc increments counter with given label values
+*/
+func (m *MetricVec) Inc(labels ...string) {
+	m.Add(1, labels...)
+}
+
+/*
+Add adds value to counter with given label values
+*/
+func (m *MetricVec) Add(value float64, labels ...string) {
+	m.mutex.Lock()
+	m.get(labels).sum += value
+	m.mutex.Unlock()
+}
+
+/*
+Observe adds value to histogram with given label values
+*/
+func (m *MetricVec) Observe(value float64, labels ...string) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	v := m.get(labels)
+	v.sum += value
+	v.count++
+	for i, bucket := range m.buckets {
+		if value <= bucket {
+			v.counts[i]++
+		}
+	}
+}
+
+/*
+get returns value for label values, caller holds mutex. Missing label values are blank.
+*/
+func (m *MetricVec) get(labels []string) *metricValue {
+	values := make([]string, len(m.labels))
+	copy(values, labels)
+
+	key := strings.Join(values, "\xff")
+	if v, ok := m.values[key]; ok {
+		return v
+	}
+
+	v := &metricValue{labels: values, counts: make([]uint64, len(m.buckets))}
+	m.values[key] = v
+	return v
+}
+
+/*
+write writes metric in text format, values are sorted by labels
+*/
+func (m *MetricVec) write(w io.Writer) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	fmt.Fprintf(w, "# HELP %v %v\n# TYPE %v %v\n", m.name, m.help, m.name, m.typ)
+
+	keys := make([]string, 0, len(m.values))
+	for key := range m.values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	// metric without labels has always value
+	if len(m.labels) == 0 && len(keys) == 0 {
+		m.get(nil)
+		keys = append(keys, "")
+	}
+
+	for _, key := range keys {
+		v := m.values[key]
+		if m.typ == "counter" {
+			fmt.Fprintf(w, "%v%v %v\n", m.name, m.format(v.labels, ""), metricsFormatFloat(v.sum))
+			continue
+		}
+
+		for i, bucket := range m.buckets {
+			fmt.Fprintf(w, "%v_bucket%v %v\n", m.name, m.format(v.labels, metricsFormatFloat(bucket)), v.counts[i])
+		}
+		fmt.Fprintf(w, "%v_bucket%v %v\n", m.name, m.format(v.labels, "+Inf"), v.count)
+		fmt.Fprintf(w, "%v_sum%v %v\n", m.name, m.format(v.labels, ""), metricsFormatFloat(v.sum))
+		fmt.Fprintf(w, "%v_count%v %v\n", m.name, m.format(v.labels, ""), v.count)
+	}
+}
+
+/*
+format returns labels in text format, le is histogram bucket (blank for other values)
+*/
+func (m *MetricVec) format(values []string, le string) string {
+	pairs := []string{}
+	for i, label := range m.labels {
+		pairs = append(pairs, fmt.Sprintf(`%v="%v"`, label, metricsLabelReplacer.Replace(values[i])))
+	}
+	if le != "" {
+		pairs = append(pairs, fmt.Sprintf(`le="%v"`, le))
+	}
+	if len(pairs) == 0 {
+		return ""
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+/*
+metricsFormatFloat formats value as Prometheus does
+*/
+func metricsFormatFloat(value float64) string {
+	if math.IsInf(value, 1) {
+		return "+Inf"
+	}
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
@@ -0,0 +1,121 @@
+package core
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestMetricVecWrite(t *testing.T) {
+	m := &Metrics{}
+	counter := m.counter("test_total", "Test counter.", "name", "status")
+	histogram := m.histogram("test_seconds", "Test histogram.", []float64{0.1, 1})
+	empty := m.counter("test_empty_total", "Test counter without labels.")
+
+	counter.Inc("b", "200")
+	counter.Inc("a", "500")
+	counter.Add(2, "a", "500")
+	counter.Inc("quote\"\nline\\", "")
+	counter.Inc("missing")
+	histogram.Observe(0.05)
+	histogram.Observe(0.5)
+	histogram.Observe(5)
+
+	tc := []struct {
+		vec      *MetricVec
+		expected string
+	}{
+		{
+			counter,
+			`# HELP test_total Test counter.
+# TYPE test_total counter
+test_total{name="a",status="500"} 3
+test_total{name="b",status="200"} 1
+test_total{name="missing",status=""} 1
+test_total{name="quote\"\nline\\",status=""} 1
+`,
+		},
+		{
+			histogram,
+			`# HELP test_seconds Test histogram.
+# TYPE test_seconds histogram
+test_seconds_bucket{le="0.1"} 1
+test_seconds_bucket{le="1"} 2
+test_seconds_bucket{le="+Inf"} 3
+test_seconds_sum 5.55
+test_seconds_count 3
+`,
+		},
+		{
+			empty,
+			`# HELP test_empty_total Test counter without labels.
+# TYPE test_empty_total counter
+test_empty_total 0
+`,
+		},
+	}
+
+	for _, tt := range tc {
+		t.Run(tt.vec.name, func(st *testing.T) {
+			buf := &bytes.Buffer{}
+			tt.vec.write(buf)
+			if buf.String() != tt.expected {
+				st.Errorf("write returned\n%v\nexpected\n%v", buf.String(), tt.expected)
+			}
+		})
+	}
+}
+
+func TestMetricsView(t *testing.T) {
+	tc := []struct {
+		name    string
+		enabled bool
+		token   string
+		header  string
+		status  int
+	}{
+		{"disabled", false, "", "", http.StatusNotFound},
+		{"without token", true, "", "", http.StatusOK},
+		{"valid token", true, "secret", "Bearer secret", http.StatusOK},
+		{"invalid token", true, "secret", "Bearer other", http.StatusUnauthorized},
+		{"missing token", true, "secret", "", http.StatusUnauthorized},
+	}
+
+	for _, tt := range tc {
+		t.Run(tt.name, func(st *testing.T) {
+			handler, cfg, _ := newTestServer(st, nil)
+			cfg.mc.enabled = tt.enabled
+			cfg.mc.token = tt.token
+
+			// request that is counted by common middleware
+			handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/healthz/", nil))
+
+			r := httptest.NewRequest("GET", "/metrics/", nil)
+			if tt.header != "" {
+				r.Header.Set("Authorization", tt.header)
+			}
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, r)
+
+			if w.Code != tt.status {
+				st.Fatalf("metrics returned status %v, expected %v", w.Code, tt.status)
+			}
+			if tt.status != http.StatusOK {
+				return
+			}
+
+			for _, line := range []string{
+				`gopypi_http_requests_total{route="healthz",method="GET",status="200"} 1`,
+				`gopypi_http_request_duration_seconds_count{route="healthz",method="GET"} 1`,
+				"gopypi_download_stats_pending 0",
+				"# TYPE gopypi_db_open_connections gauge",
+			} {
+				if !strings.Contains(w.Body.String(), line+"\n") {
+					st.Errorf("metrics do not contain %q:\n%v", line, w.Body.String())
+				}
+			}
+		})
+	}
+}
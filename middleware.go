@@ -6,7 +6,7 @@ import (
 	"context"
 	"fmt"
 	"runtime/debug"
-	"strings"
+	"strconv"
 	"time"
 
 	"github.com/gorilla/mux"
@@ -76,9 +76,6 @@ bypassAuth is function that when returns true, auth is bypassed (!WARNING!)
 */
 func BasicAuthLoginRequired(cfg Config, bypassAuth func(r *http.Request) bool, permfuncs ...func(User) error) alice.Constructor {
 
-	// for later usage
-	db := cfg.DB()
-
 	return func(h http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			// try bypass function
@@ -92,17 +89,22 @@ func BasicAuthLoginRequired(cfg Config, bypassAuth func(r *http.Request) bool, p
 			username, password, err := getUsernamePassword(r)
 
 			if err != nil {
+				cfg.Metrics().Registry().AuthFailures.Inc("basic", "missing_credentials")
 				response.New(http.StatusUnauthorized).Error("Not authorized").Write(w, r)
 				return
 			}
-			user := User{}
 
-			if db.First(&user, "username = ?", username).RecordNotFound() {
-				response.New(http.StatusForbidden).Write(w, r)
-				return
-			}
+			var user User
 
-			if !cfg.Manager().User().VerifyPassword(user, password) {
+			// authenticate user against configured backends (throttled)
+			if user, err = AuthenticateRequest(cfg, r, username, password); err != nil {
+				if te, ok := err.(ThrottleError); ok {
+					cfg.Metrics().Registry().AuthFailures.Inc("basic", "throttled")
+					response.New(http.StatusTooManyRequests).Header("Retry-After", strconv.Itoa(te.RetryAfter())).Error(te).Write(w, r)
+					return
+				}
+				cfg.Metrics().Registry().AuthFailures.Inc("basic", "invalid_credentials")
+				AuditLoginFailure(cfg, r, username)
 				response.New(http.StatusForbidden).Write(w, r)
 				return
 			}
@@ -127,6 +129,35 @@ func BasicAuthLoginRequired(cfg Config, bypassAuth func(r *http.Request) bool, p
 	}
 }
 
+/*
+RequestIDMiddleware assigns request id to every request. Valid X-Request-ID header sent by client (or proxy) is
+used, otherwise new id is generated. Request id is returned in X-Request-ID response header. Ip address of client is
+resolved with trusted proxies.
+*/
+func RequestIDMiddleware(cfg Config) alice.Constructor {
+	return func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			info := &RequestInfo{
+				ID:       r.Header.Get(REQUEST_ID_HEADER),
+				RemoteIP: cfg.Core().TrustedProxies().ClientIP(r),
+			}
+			if !validRequestID(info.ID) {
+				info.ID = NewRequestID()
+			}
+
+			w.Header().Set(REQUEST_ID_HEADER, info.ID)
+
+			*r = *r.WithContext(context.WithValue(r.Context(), CONTEXT_REQUEST_INFO, info))
+
+			h.ServeHTTP(w, r)
+		})
+	}
+}
+
+/*
+CommonMiddleware resolves route name, recovers from panics, logs requests (with request id and authenticated
+user), writes access log and records request metrics
+*/
 func CommonMiddleware(cfg Config, router *mux.Router) alice.Constructor {
 	return func(h http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -148,52 +179,83 @@ func CommonMiddleware(cfg Config, router *mux.Router) alice.Constructor {
 				context.WithValue(r.Context(), CONTEXT_ROUTE_NAME, routeName),
 			)
 
+			recorder := &responseRecorder{
+				ResponseWriter: w,
+				requestID:      ContextGetRequestID(r.Context()),
+			}
+
+			// log request, panic recovery passes recovered value
+			finish := func(rec interface{}) {
+				recorder.finish()
+
+				// nothing was written (e.g. mux not found handler was not called)
+				if recorder.status == 0 {
+					recorder.status = http.StatusNotFound
+				}
+
+				var username string
+				if info := ContextGetRequestInfo(r.Context()); info != nil {
+					username = info.Username
+				}
+
+				fields := []zap.Field{
+					zap.String("request_id", recorder.requestID),
+					zap.String("path", r.URL.Path),
+					zap.String("method", r.Method),
+					zap.Stringer("duration", time.Now().Sub(now)),
+					zap.String("name", routeName),
+					zap.Int("status", recorder.status),
+					zap.Int("size", recorder.size),
+					zap.String("remote", RemoteIP(r)),
+					zap.String("user", username),
+				}
+				if rec != nil {
+					fields = append(fields, zap.String("error", fmt.Sprintf("%+v", rec)))
+				}
+				cfg.Logger().Info("request", fields...)
+
+				if access := cfg.Logging().AccessLog(); access != nil {
+					writeAccessLog(access, r, username, recorder.status, recorder.size, now)
+				}
+
+				observeRequest(cfg, r, routeName, recorder.status, now)
+			}
+
 			// panic recovery support
 			defer func() {
 				if rec := recover(); rec != nil {
-					cfg.Logger().Info("request",
-						zap.String("path", r.URL.Path),
-						zap.String("method", r.Method),
-						zap.Stringer("duration", time.Now().Sub(now)),
-						zap.String("name", routeName),
-						zap.String("error", fmt.Sprintf("%+v", rec)),
-						zap.Int("status", http.StatusInternalServerError),
-						zap.String("remote", r.RemoteAddr),
-					)
-					cfg.Logger().Debug("stack trace:",
+					RequestLogger(cfg, r).Debug("stack trace:",
 						zap.String("stack", string(debug.Stack())),
 					)
-					// write json http internal server error
-					response.New(http.StatusInternalServerError).Error(rec).Write(w, r)
+					// write json http internal server error (unless response was already started)
+					if recorder.status == 0 {
+						response.New(http.StatusInternalServerError).Error(rec).Write(recorder, r)
+					}
+					finish(rec)
 				}
 			}()
 
 			// call chain
-			h.ServeHTTP(w, r)
+			h.ServeHTTP(recorder, r)
 
-			// get status from headers
-			status := strings.TrimSpace(w.Header().Get(response.STATUS_HEADER))
-			if status != "" {
-				w.Header().Del(response.STATUS_HEADER)
-			} else {
-				status = "404"
-			}
-
-			// log request
-			cfg.Logger().Info("request",
-				zap.String("path", r.URL.Path),
-				zap.String("method", r.Method),
-				zap.Stringer("duration", time.Now().Sub(now)),
-				zap.String("name", routeName),
-				zap.String("status", status),
-				zap.String("remote", r.RemoteAddr),
-			)
+			finish(nil)
 		})
 	}
 }
 
 /*
-TokenAuthLoginRequired is token auth verification. It reads `gopypi-token`from headers
+observeRequest records request in metrics. Requests without route are recorded with blank route name, so unknown
+paths don't create new metrics.
+*/
+func observeRequest(cfg Config, r *http.Request, routeName string, status int, start time.Time) {
+	metrics := cfg.Metrics().Registry()
+	metrics.HTTPRequests.Inc(routeName, r.Method, strconv.Itoa(status))
+	metrics.HTTPDuration.Observe(time.Now().Sub(start).Seconds(), routeName, r.Method)
+}
+
+/*
+TokenAuthLoginRequired is token auth verification. It reads `gopypi-token`from headers and checks that session
+(jti claim) of the token was not revoked.
 */
 func TokenAuthLoginRequired(cfg Config, permissions ...func(User) error) alice.Constructor {
 
@@ -207,29 +269,39 @@ func TokenAuthLoginRequired(cfg Config, permissions ...func(User) error) alice.C
 
 			// parse token and get claims
 			if claims, err = ParseToken(r, cfg.Core().SecretKey()); err != nil {
+				cfg.Metrics().Registry().AuthFailures.Inc("token", "invalid_token")
 				response.New(http.StatusUnauthorized).Error(err).Write(w, r)
 				return
 			}
 
+			// check that session was not revoked
+			var session Session
+			if session, err = cfg.Manager().Session().Get(claims.Id); err != nil || session.UserID != claims.UserID {
+				cfg.Metrics().Registry().AuthFailures.Inc("token", "revoked")
+				response.New(http.StatusUnauthorized).Error(ErrTokenRevoked).Write(w, r)
+				return
+			}
+
 			// prepare user to be read from database
 			user := User{}
 
 			if cfg.DB().First(&user, "id = ?", claims.UserID).RecordNotFound() {
-				response.New(http.StatusBadRequest).Error(err).Write(w, r)
+				response.New(http.StatusBadRequest).Error(ErrTokenUserInvalid).Write(w, r)
 				return
 			}
 
-			// call permissions callback
+			// call permissions callback, user is authenticated so forbidden is returned
 			if len(permissions) > 0 {
 				for _, permission := range permissions {
 					if err = permission(user); err != nil {
-						response.New(http.StatusUnauthorized).Error(err).Write(w, r)
+						response.New(http.StatusForbidden).Error(err).Write(w, r)
 						return
 					}
 				}
 			}
 
-			*r = *r.WithContext(ContextSetTokenUser(r.Context(), user))
+			ctx := ContextSetTokenUser(r.Context(), user)
+			*r = *r.WithContext(ContextSetTokenSession(ctx, session))
 
 			// call next
 			h.ServeHTTP(w, r)
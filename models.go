@@ -1,6 +1,8 @@
 package core
 
 import (
+	"encoding/json"
+	"strings"
 	"time"
 
 	"path/filepath"
@@ -8,18 +10,38 @@ import (
 	"github.com/jinzhu/gorm"
 )
 
+/*
+Models returns all models stored in database
+*/
+func Models() []interface{} {
+	return []interface{}{
+		Index{}, IndexBase{}, IndexACL{},
+		Package{}, PackageVersion{}, PackageVersionFile{}, PackageVersionFileTag{}, PackageDependency{}, PackagePromotion{}, PackageFileHistory{},
+		User{}, Session{}, NotificationPreference{},
+		Classifier{},
+		License{},
+		Platform{},
+		DownloadStatsDaily{}, DownloadStatsWeekly{}, DownloadStatsMonthly{}, DownloadStatsYearly{},
+		DownloadStatsDetail{},
+		Feature{},
+		AuditLog{},
+		Webhook{}, WebhookDelivery{},
+		TaskLock{}, TaskRun{},
+	}
+}
+
 /*
 Migrate runs AutoMigrate on all models.
 */
 func Migrate(config Config) (err error) {
 	db := config.DB().Debug()
-	db.AutoMigrate(Package{}, PackageVersion{}, PackageVersionFile{})
-	db.AutoMigrate(User{})
-	db.AutoMigrate(Classifier{})
-	db.AutoMigrate(License{})
-	db.AutoMigrate(Platform{})
-	db.AutoMigrate(DownloadStatsWeekly{}, DownloadStatsMonthly{}, DownloadStatsYearly{})
-	db.AutoMigrate(Feature{})
+	db.AutoMigrate(Models()...)
+	if err = migrateDownloadStats(db); err != nil {
+		return
+	}
+	if err = migrateIndexes(db); err != nil {
+		return
+	}
 
 	// create all features
 	if err = createFeatures(db); err != nil {
@@ -54,6 +76,38 @@ type DownloadStats struct {
 	CreatedAt        time.Time       `json:"created_at"`
 }
 
+/*
+DownloadStatsDaily represents download stats aggregated by day
+*/
+type DownloadStatsDaily struct {
+	DownloadStats
+}
+
+/*
+BeforeSave aligns CreatedAt correctly
+*/
+func (s *DownloadStatsDaily) BeforeCreate() error {
+	s.CreatedAt = TimeStripTime(time.Now())
+	return nil
+}
+
+/*
+DownloadStatsDetail represents daily downloads of single file by single user with single installer. Installer,
+python version and operating system are parsed from user agent (see ParseStatsInstaller).
+*/
+type DownloadStatsDetail struct {
+	ID                   uint                `gorm:"primary_key" json:"-"`
+	PackageVersionFile   *PackageVersionFile `gorm:"ForeignKey:PackageVersionFileID" json:"file,omitempty"`
+	PackageVersionFileID uint                `gorm:"index" json:"-"`
+	PackageVersionID     uint                `gorm:"index" json:"-"`
+	UserID               uint                `gorm:"index" json:"-"`
+	Installer            string              `gorm:"type:varchar(32)" json:"installer"`
+	Python               string              `gorm:"type:varchar(16)" json:"python"`
+	System               string              `gorm:"type:varchar(32)" json:"system"`
+	Downloads            int                 `json:"downloads"`
+	CreatedAt            time.Time           `gorm:"index" json:"created_at"`
+}
+
 /*
 DownloadStatsWeekly represents download stats aggregated by week
 */
@@ -125,14 +179,17 @@ type License struct {
 Package model.
 */
 type Package struct {
-	ID          uint             `gorm:"primary_key" json:"id"`
-	Name        string           `json:"name"`
-	Versions    []PackageVersion `gorm:"ForeignKey:PackageID" json:"versions,omitempty"`
-	Maintainers []User           `gorm:"many2many:package_maintainers;" json:"maintainers,omitempty"`
-	CreatedAt   time.Time        `json:"created_at"`
-	UpdatedAt   time.Time        `json:"updated_at"`
-	Author      *User            `gorm:"ForeignKey:AuthorID" json:"author,omitempty"`
-	AuthorID    uint             `json:"-"`
+	ID           uint             `gorm:"primary_key" json:"id"`
+	Index        *Index           `gorm:"ForeignKey:IndexID" json:"index,omitempty"`
+	IndexID      uint             `gorm:"index" json:"index_id"`
+	Name         string           `json:"name"`
+	UploadPolicy string           `gorm:"type:varchar(32)" json:"upload_policy"`
+	Versions     []PackageVersion `gorm:"ForeignKey:PackageID" json:"versions,omitempty"`
+	Maintainers  []User           `gorm:"many2many:package_maintainers;" json:"maintainers,omitempty"`
+	CreatedAt    time.Time        `json:"created_at"`
+	UpdatedAt    time.Time        `json:"updated_at"`
+	Author       *User            `gorm:"ForeignKey:AuthorID" json:"author,omitempty"`
+	AuthorID     uint             `json:"-"`
 }
 
 /*
@@ -151,26 +208,107 @@ func (p *Package) BeforeSave() error {
 	return nil
 }
 
+/*
+Index is named namespace of packages with its own simple index and upload url. Packages of base indexes are visible
+through index (in order of bases, recursively).
+*/
+type Index struct {
+	ID           uint        `gorm:"primary_key" json:"id"`
+	Name         string      `gorm:"type:varchar(100);unique_index" json:"name"`
+	Description  string      `gorm:"type:varchar(255)" json:"description"`
+	Public       bool        `json:"public"`
+	OpenUpload   bool        `json:"open_upload"`
+	UploadPolicy string      `gorm:"type:varchar(32)" json:"upload_policy"`
+	Bases        []IndexBase `gorm:"ForeignKey:IndexID" json:"bases,omitempty"`
+	ACL          []IndexACL  `gorm:"ForeignKey:IndexID" json:"acl,omitempty"`
+	CreatedAt    time.Time   `json:"created_at"`
+	UpdatedAt    time.Time   `json:"updated_at"`
+}
+
+/*
+TableName returns name of table, "index" is reserved word in mysql
+*/
+func (i Index) TableName() string {
+	return "package_index"
+}
+
+/*
+BeforeCreate sets CreatedAt
+*/
+func (i *Index) BeforeCreate() error {
+	i.CreatedAt = gorm.NowFunc()
+	return nil
+}
+
+/*
+BeforeSave sets UpdatedAt
+*/
+func (i *Index) BeforeSave() error {
+	i.UpdatedAt = gorm.NowFunc()
+	return nil
+}
+
+/*
+IndexBase is base index of index, position defines order in which base indexes are searched
+*/
+type IndexBase struct {
+	ID       uint   `gorm:"primary_key" json:"-"`
+	IndexID  uint   `gorm:"index" json:"-"`
+	Base     *Index `gorm:"ForeignKey:BaseID" json:"-"`
+	BaseID   uint   `json:"id"`
+	BaseName string `gorm:"-" json:"name"`
+	Position int    `json:"position"`
+}
+
+func (i IndexBase) TableName() string {
+	return "package_index_base"
+}
+
+/*
+IndexACL grants user access to index. Public index is readable by all users, index with open upload accepts uploads
+from all users (package permissions still apply).
+*/
+type IndexACL struct {
+	ID        uint  `gorm:"primary_key" json:"-"`
+	IndexID   uint  `gorm:"index" json:"-"`
+	User      *User `gorm:"ForeignKey:UserID" json:"user,omitempty"`
+	UserID    uint  `json:"user_id"`
+	CanRead   bool  `json:"can_read"`
+	CanUpload bool  `json:"can_upload"`
+}
+
+func (i IndexACL) TableName() string {
+	return "package_index_acl"
+}
+
 /*
 PackageVersion model that holds information about given package version
 */
 type PackageVersion struct {
-	ID           uint                 `gorm:"primary_key" json:"id"`
-	PackageID    uint                 `json:"-"`
-	CreatedAt    time.Time            `json:"created_at"`
-	UpdatedAt    time.Time            `json:"updated_at"`
-	Author       *User                `gorm:"ForeignKey:AuthorID" json:"author,omitempty"`
-	AuthorID     uint                 `json:"-"`
-	Comment      string               `json:"comment"`
-	Description  string               `json:"description"`
-	Summary      string               `json:"summary"`
-	HomePage     string               `json:"home_page"`
-	License      *License             `gorm:"ForeignKey:LicenseID" json:"license,omitempty"`
-	LicenseID    uint                 `json:"-"`
-	Version      string               `gorm:"index" json:"version"`
-	VersionOrder int                  `gorm:"index" json:"version_order"`
-	Files        []PackageVersionFile `gorm:"ForeignKey:PackageVersionID" json:"files,omitempty"`
-	Classifiers  []Classifier         `gorm:"many2many:package_version_classifiers;" json:"classifiers,omitempty"`
+	ID                     uint                 `gorm:"primary_key" json:"id"`
+	Package                *Package             `gorm:"ForeignKey:PackageID" json:"package,omitempty"`
+	PackageID              uint                 `json:"-"`
+	CreatedAt              time.Time            `json:"created_at"`
+	UpdatedAt              time.Time            `json:"updated_at"`
+	Author                 *User                `gorm:"ForeignKey:AuthorID" json:"author,omitempty"`
+	AuthorID               uint                 `json:"-"`
+	Comment                string               `json:"comment"`
+	Description            string               `json:"description"`
+	DescriptionContentType string               `gorm:"type:varchar(128)" json:"description_content_type"`
+	DescriptionHTML        string               `gorm:"type:text" json:"description_html"`
+	Summary                string               `json:"summary"`
+	Keywords               string               `gorm:"type:text" json:"keywords"`
+	HomePage               string               `json:"home_page"`
+	License                *License             `gorm:"ForeignKey:LicenseID" json:"license,omitempty"`
+	LicenseID              uint                 `json:"-"`
+	Version                string               `gorm:"index" json:"version"`
+	VersionOrder           int                  `gorm:"index" json:"version_order"`
+	Yanked                 bool                 `json:"yanked"`
+	YankedReason           string               `gorm:"type:varchar(256)" json:"yanked_reason"`
+	RequiresPython         string               `gorm:"type:varchar(255)" json:"requires_python"`
+	Dependencies           []PackageDependency  `gorm:"ForeignKey:PackageVersionID" json:"dependencies,omitempty"`
+	Files                  []PackageVersionFile `gorm:"ForeignKey:PackageVersionID" json:"files,omitempty"`
+	Classifiers            []Classifier         `gorm:"many2many:package_version_classifiers;" json:"classifiers,omitempty"`
 }
 
 /*
@@ -193,14 +331,22 @@ func (p *PackageVersion) BeforeSave() error {
 PackageVersionFile model
 */
 type PackageVersionFile struct {
-	ID               uint      `gorm:"primary_key" json:"id"`
-	PackageVersionID uint      `json:"-"`
-	Filename         string    `json:"filename"`
-	RelativePath     string    `json:"relative_path"`
-	MD5Digest        string    `gorm:"column:md5_digest" json:"md5_digest"`
-	Author           *User     `gorm:"ForeignKey:AuthorID" json:"author,omitempty"`
-	AuthorID         uint      `json:"-"`
-	CreatedAt        time.Time `json:"created_at"`
+	ID               uint                    `gorm:"primary_key" json:"id"`
+	PackageVersion   *PackageVersion         `gorm:"ForeignKey:PackageVersionID" json:"version,omitempty"`
+	PackageVersionID uint                    `json:"-"`
+	Filename         string                  `json:"filename"`
+	RelativePath     string                  `json:"relative_path"`
+	MD5Digest        string                  `gorm:"column:md5_digest" json:"md5_digest"`
+	PackageType      string                  `gorm:"type:varchar(16)" json:"packagetype"`
+	BuildTag         string                  `gorm:"type:varchar(64)" json:"build_tag"`
+	PythonTag        string                  `gorm:"type:varchar(128)" json:"python_tag"`
+	ABITag           string                  `gorm:"column:abi_tag;type:varchar(128)" json:"abi_tag"`
+	PlatformTag      string                  `gorm:"type:varchar(255)" json:"platform_tag"`
+	RequiresPython   string                  `gorm:"type:varchar(255)" json:"requires_python"`
+	Tags             []PackageVersionFileTag `gorm:"ForeignKey:PackageVersionFileID" json:"tags,omitempty"`
+	Author           *User                   `gorm:"ForeignKey:AuthorID" json:"author,omitempty"`
+	AuthorID         uint                    `json:"-"`
+	CreatedAt        time.Time               `json:"created_at"`
 
 	// this field is used to have pregenearated download url
 	DownloadURL string `gorm:"-" json:"download_url,omitempty"`
@@ -223,12 +369,95 @@ func (p PackageVersionFile) GenerateRelativePath() string {
 	return filepath.Join(hash[:2], hash[:4], hash[4:])
 }
 
+/*
+PackageDependency is single requirement (Requires-Dist) of package version
+*/
+type PackageDependency struct {
+	ID               uint            `gorm:"primary_key" json:"id"`
+	PackageVersion   *PackageVersion `gorm:"ForeignKey:PackageVersionID" json:"version,omitempty"`
+	PackageVersionID uint            `gorm:"index" json:"-"`
+	Name             string          `gorm:"type:varchar(255)" json:"name"`
+	CanonicalName    string          `gorm:"type:varchar(255);index" json:"canonical_name"`
+	Extras           string          `gorm:"type:varchar(255)" json:"extras"`
+	Specifier        string          `gorm:"type:varchar(255)" json:"specifier"`
+	URL              string          `gorm:"type:varchar(1024)" json:"url"`
+	Marker           string          `gorm:"type:varchar(1024)" json:"marker"`
+
+	// id of hosted package that satisfies dependency (filled by api)
+	PackageID uint `gorm:"-" json:"package_id,omitempty"`
+}
+
+/*
+PackageVersionFileTag is single compatibility tag of file. Compressed tag sets of wheels are expanded, so
+"foo-1.0-py2.py3-none-any.whl" has two tags (py2-none-any and py3-none-any).
+*/
+type PackageVersionFileTag struct {
+	ID                   uint      `gorm:"primary_key" json:"id"`
+	PackageVersionFileID uint      `gorm:"index" json:"-"`
+	PythonTag            string    `gorm:"type:varchar(64);index" json:"python_tag"`
+	ABITag               string    `gorm:"column:abi_tag;type:varchar(64);index" json:"abi_tag"`
+	Platform             *Platform `gorm:"ForeignKey:PlatformID" json:"platform,omitempty"`
+	PlatformID           uint      `gorm:"index" json:"platform_id"`
+}
+
+/*
+PackageFileHistory records uploads, replacements and deletions of package files. History is kept after files are
+deleted, so immutable indexes can refuse reuse of filenames.
+*/
+type PackageFileHistory struct {
+	ID                uint      `gorm:"primary_key" json:"id"`
+	IndexID           uint      `gorm:"index" json:"index_id"`
+	PackageID         uint      `gorm:"index" json:"package_id"`
+	Version           string    `gorm:"type:varchar(128)" json:"version"`
+	Filename          string    `gorm:"type:varchar(255);index" json:"filename"`
+	Action            string    `gorm:"type:varchar(16)" json:"action"`
+	MD5Digest         string    `gorm:"column:md5_digest" json:"md5_digest"`
+	PreviousMD5Digest string    `gorm:"column:previous_md5_digest" json:"previous_md5_digest,omitempty"`
+	UserID            uint      `json:"user_id"`
+	Username          string    `gorm:"type:varchar(128)" json:"username"`
+	CreatedAt         time.Time `json:"created_at"`
+}
+
+/*
+BeforeCreate sets CreatedAt
+*/
+func (p *PackageFileHistory) BeforeCreate() error {
+	p.CreatedAt = gorm.NowFunc()
+	return nil
+}
+
+/*
+PackagePromotion records promotion of package version from one index to another. PackageVersionID is version in
+target index (same as SourceVersionID when version was moved).
+*/
+type PackagePromotion struct {
+	ID               uint      `gorm:"primary_key" json:"id"`
+	PackageVersionID uint      `gorm:"index" json:"package_version_id"`
+	SourceVersionID  uint      `gorm:"index" json:"source_version_id"`
+	SourceIndex      *Index    `gorm:"ForeignKey:SourceIndexID" json:"source_index,omitempty"`
+	SourceIndexID    uint      `json:"source_index_id"`
+	TargetIndex      *Index    `gorm:"ForeignKey:TargetIndexID" json:"target_index,omitempty"`
+	TargetIndexID    uint      `json:"target_index_id"`
+	Move             bool      `json:"move"`
+	UserID           uint      `json:"user_id"`
+	Username         string    `gorm:"type:varchar(128)" json:"username"`
+	CreatedAt        time.Time `json:"created_at"`
+}
+
+/*
+BeforeCreate sets CreatedAt
+*/
+func (p *PackagePromotion) BeforeCreate() error {
+	p.CreatedAt = gorm.NowFunc()
+	return nil
+}
+
 /*
 Platform model tracks all platforms such as: Linux, Darwin even more esoteric.
 */
 type Platform struct {
 	ID          uint   `json:"id"`
-	Name        string `json:"name"`
+	Name        string `gorm:"type:varchar(255);index" json:"name"`
 	Description string `json:"description"`
 }
 
@@ -253,6 +482,10 @@ type User struct {
 	CanDownload bool `json:"can_download"`
 	CanUpdate   bool `json:"can_update"`
 
+	// oidc login linked to user (issuer and subject identify account at identity provider)
+	OIDCIssuer  string `gorm:"column:oidc_issuer;type:varchar(255);index:idx_user_oidc" json:"oidc_issuer"`
+	OIDCSubject string `gorm:"column:oidc_subject;type:varchar(255);index:idx_user_oidc" json:"oidc_subject"`
+
 	CreatedAt time.Time `json:"created_at"`
 	UpdatedAt time.Time `json:"updated_at"`
 }
@@ -272,3 +505,245 @@ func (u *User) BeforeSave() error {
 	u.UpdatedAt = gorm.NowFunc()
 	return nil
 }
+
+/*
+Session model
+
+Every login creates session. Access token carries session key (jti claim), so when session is revoked all access
+tokens issued for it stop working. Refresh token is stored only as sha256 hash.
+*/
+type Session struct {
+	ID           uint       `gorm:"primary_key" json:"id"`
+	User         *User      `gorm:"ForeignKey:UserID" json:"-"`
+	UserID       uint       `gorm:"index" json:"-"`
+	Key          string     `gorm:"column:session_key;type:varchar(64);unique_index" json:"-"`
+	RefreshToken string     `gorm:"type:varchar(64);index" json:"-"`
+	UserAgent    string     `gorm:"type:varchar(256)" json:"user_agent"`
+	RemoteAddr   string     `gorm:"type:varchar(64)" json:"remote_addr"`
+	CreatedAt    time.Time  `json:"created_at"`
+	LastUsedAt   time.Time  `json:"last_used_at"`
+	ExpiresAt    time.Time  `json:"expires_at"`
+	RevokedAt    *time.Time `json:"revoked_at,omitempty"`
+
+	// this field marks session of current request
+	Current bool `gorm:"-" json:"current"`
+}
+
+/*
+BeforeCreate sets CreatedAt
+*/
+func (s *Session) BeforeCreate() error {
+	s.CreatedAt = gorm.NowFunc()
+	s.LastUsedAt = s.CreatedAt
+	return nil
+}
+
+/*
+IsValid returns whether session is not revoked nor expired
+*/
+func (s Session) IsValid() bool {
+	return s.RevokedAt == nil && s.ExpiresAt.After(time.Now())
+}
+
+/*
+NotificationPreference model
+
+Stores which email notifications user wants to receive. User without stored preference receives all notifications.
+*/
+type NotificationPreference struct {
+	ID         uint  `gorm:"primary_key" json:"-"`
+	User       *User `gorm:"ForeignKey:UserID" json:"-"`
+	UserID     uint  `gorm:"unique_index" json:"-"`
+	NewVersion bool  `json:"new_version"`
+	Maintainer bool  `json:"maintainer"`
+	Password   bool  `json:"password"`
+}
+
+/*
+Wants returns whether notification of given kind should be sent
+*/
+func (n NotificationPreference) Wants(kind string) bool {
+	switch kind {
+	case NOTIFICATION_NEW_VERSION:
+		return n.NewVersion
+	case NOTIFICATION_MAINTAINER:
+		return n.Maintainer
+	case NOTIFICATION_PASSWORD:
+		return n.Password
+	}
+	return false
+}
+
+/*
+AuditLog model
+
+Every security relevant or repository changing action is recorded. Actor username is stored along with id, so log
+stays readable after user is deleted. Changes hold json object with before/after values of changed fields.
+*/
+type AuditLog struct {
+	ID            uint            `gorm:"primary_key" json:"id"`
+	CreatedAt     time.Time       `gorm:"index" json:"created_at"`
+	ActorID       uint            `gorm:"index" json:"actor_id"`
+	ActorUsername string          `gorm:"type:varchar(64)" json:"actor_username"`
+	Action        string          `gorm:"type:varchar(64);index" json:"action"`
+	TargetType    string          `gorm:"type:varchar(32);index" json:"target_type"`
+	TargetID      string          `gorm:"type:varchar(64);index" json:"target_id"`
+	Target        string          `gorm:"type:varchar(256)" json:"target"`
+	Changes       string          `gorm:"type:text" json:"-"`
+	Source        string          `gorm:"type:varchar(16);index" json:"source"`
+	IP            string          `gorm:"type:varchar(64)" json:"ip"`
+	UserAgent     string          `gorm:"type:varchar(256)" json:"user_agent"`
+	ChangesJSON   json.RawMessage `gorm:"-" json:"changes,omitempty"`
+}
+
+/*
+BeforeCreate sets CreatedAt
+*/
+func (a *AuditLog) BeforeCreate() error {
+	a.CreatedAt = gorm.NowFunc()
+	return nil
+}
+
+/*
+AfterFind exposes stored changes as raw json
+*/
+func (a *AuditLog) AfterFind() error {
+	if a.Changes != "" {
+		a.ChangesJSON = json.RawMessage(a.Changes)
+	}
+	return nil
+}
+
+/*
+Webhook model
+
+Webhook without package is global and receives events of all packages. Events are stored as comma separated list,
+blank list means all events. Secret is used to sign payloads and is never returned by api.
+*/
+type Webhook struct {
+	ID        uint      `gorm:"primary_key" json:"id"`
+	Name      string    `gorm:"type:varchar(64)" json:"name"`
+	URL       string    `gorm:"type:varchar(512)" json:"url"`
+	Secret    string    `gorm:"type:varchar(128)" json:"-"`
+	Events    string    `gorm:"type:varchar(512)" json:"-"`
+	Package   *Package  `gorm:"ForeignKey:PackageID" json:"package,omitempty"`
+	PackageID *uint     `gorm:"index" json:"package_id"`
+	IsActive  bool      `json:"is_active"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	// following fields are computed
+	EventList []string `gorm:"-" json:"events"`
+	HasSecret bool     `gorm:"-" json:"has_secret"`
+}
+
+/*
+BeforeCreate sets CreatedAt
+*/
+func (w *Webhook) BeforeCreate() error {
+	w.CreatedAt = gorm.NowFunc()
+	return nil
+}
+
+/*
+BeforeSave sets UpdatedAt and stores list of events
+*/
+func (w *Webhook) BeforeSave() error {
+	w.UpdatedAt = gorm.NowFunc()
+	w.Events = strings.Join(w.EventList, ",")
+	w.HasSecret = w.Secret != ""
+	return nil
+}
+
+/*
+AfterFind fills computed fields
+*/
+func (w *Webhook) AfterFind() error {
+	w.EventList = []string{}
+	for _, event := range strings.Split(w.Events, ",") {
+		if event = strings.TrimSpace(event); event != "" {
+			w.EventList = append(w.EventList, event)
+		}
+	}
+	w.HasSecret = w.Secret != ""
+	return nil
+}
+
+/*
+Subscribes returns whether webhook receives given event
+*/
+func (w *Webhook) Subscribes(event string) bool {
+	return event == WEBHOOK_EVENT_PING || len(w.EventList) == 0 || StringListContains(w.EventList, event)
+}
+
+/*
+WebhookDelivery model
+
+Every event creates delivery for every subscribed webhook. Delivery is retried with backoff until it succeeds or
+maximum attempts are reached. Redelivery creates new delivery with the same payload.
+*/
+type WebhookDelivery struct {
+	ID             uint       `gorm:"primary_key" json:"id"`
+	Webhook        *Webhook   `gorm:"ForeignKey:WebhookID" json:"-"`
+	WebhookID      uint       `gorm:"index" json:"webhook_id"`
+	Event          string     `gorm:"type:varchar(32)" json:"event"`
+	Payload        string     `gorm:"type:text" json:"-"`
+	Status         string     `gorm:"type:varchar(16);index" json:"status"`
+	Attempts       int        `json:"attempts"`
+	NextAttemptAt  time.Time  `gorm:"index" json:"next_attempt_at"`
+	ResponseStatus int        `json:"response_status"`
+	ResponseBody   string     `gorm:"type:text" json:"response_body"`
+	Error          string     `gorm:"type:varchar(512)" json:"error"`
+	Duration       int64      `json:"duration_ms"`
+	CreatedAt      time.Time  `json:"created_at"`
+	DeliveredAt    *time.Time `json:"delivered_at"`
+
+	// payload as raw json
+	PayloadJSON json.RawMessage `gorm:"-" json:"payload,omitempty"`
+}
+
+/*
+BeforeCreate sets CreatedAt
+*/
+func (w *WebhookDelivery) BeforeCreate() error {
+	w.CreatedAt = gorm.NowFunc()
+	return nil
+}
+
+/*
+AfterFind exposes stored payload as raw json
+*/
+func (w *WebhookDelivery) AfterFind() error {
+	if w.Payload != "" {
+		w.PayloadJSON = json.RawMessage(w.Payload)
+	}
+	return nil
+}
+
+/*
+TaskLock model
+
+Every scheduled task has single lock row. Instance that updates the row runs the task, so task runs only once even
+when multiple gopypi instances share database. ScheduledAt holds last scheduled activation that was claimed.
+*/
+type TaskLock struct {
+	Name        string    `gorm:"primary_key;type:varchar(64)" json:"name"`
+	Owner       string    `gorm:"type:varchar(128)" json:"owner"`
+	LockedUntil time.Time `json:"locked_until"`
+	ScheduledAt time.Time `json:"scheduled_at"`
+}
+
+/*
+TaskRun model holds history of task runs
+*/
+type TaskRun struct {
+	ID         uint       `gorm:"primary_key" json:"id"`
+	Task       string     `gorm:"type:varchar(64);index" json:"task"`
+	Trigger    string     `gorm:"type:varchar(16)" json:"trigger"`
+	Owner      string     `gorm:"type:varchar(128)" json:"owner"`
+	Status     string     `gorm:"type:varchar(16);index" json:"status"`
+	Error      string     `gorm:"type:varchar(512)" json:"error"`
+	Duration   int64      `json:"duration_ms"`
+	StartedAt  time.Time  `gorm:"index" json:"started_at"`
+	FinishedAt *time.Time `json:"finished_at"`
+}
@@ -0,0 +1,363 @@
+/*
+Email notifications
+
+Users are notified by email about events that concern them (new version of maintained package, maintainership
+change, password change). Notification is built with NewNotification and sent with Send method, which filters
+recipients by their preferences, renders template and queues email.
+
+	NewNotification(NOTIFICATION_MAINTAINER, "email/maintainer_added.tpl.html").
+		Actor(actor).
+		Package(pack).
+		Send(cfg, maintainer)
+
+Every email template defines "subject" and "body" templates, body is rendered inside email/base.tpl.html.
+
+Queued emails are sent by Notifier in background, queue never blocks request. When notifier is not started (e.g.
+command line) emails are sent synchronously.
+*/
+package core
+
+import (
+	"bytes"
+	"crypto/tls"
+	"fmt"
+	"html"
+	"mime"
+	"mime/quotedprintable"
+	"net"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/uber-go/zap"
+)
+
+/*
+Email is single rendered email
+*/
+type Email struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+/*
+NewNotification returns new notification of given kind rendered with given template
+*/
+func NewNotification(kind, template string) *Notification {
+	return &Notification{
+		kind:     kind,
+		template: template,
+	}
+}
+
+/*
+Notification builds email notification
+*/
+type Notification struct {
+	kind     string
+	template string
+	actor    User
+	pack     Package
+	version  PackageVersion
+}
+
+/*
+Actor sets user that caused notification
+*/
+func (n *Notification) Actor(user User) *Notification {
+	n.actor = user
+	return n
+}
+
+/*
+Package sets package of notification
+*/
+func (n *Notification) Package(pack Package) *Notification {
+	n.pack = pack
+	return n
+}
+
+/*
+Version sets package version of notification
+*/
+func (n *Notification) Version(version PackageVersion) *Notification {
+	n.version = version
+	return n
+}
+
+/*
+Send renders and queues email for all users that want notification. When notifications are disabled nothing is
+sent. Errors are logged and returned, callers usually ignore them.
+*/
+func (n *Notification) Send(cfg Config, users ...User) (err error) {
+	if !cfg.Notifications().Enabled() {
+		return
+	}
+
+	for _, user := range cfg.Manager().Notification().Recipients(users, n.kind) {
+		var email Email
+		if email, err = RenderEmail(cfg, n.template, n.data(cfg, user)); err != nil {
+			n.logError(cfg, err)
+			return
+		}
+
+		if err = cfg.Notifications().Notifier().Enqueue(cfg, email); err != nil {
+			n.logError(cfg, err)
+		}
+	}
+
+	return
+}
+
+/*
+data returns template data for given recipient
+*/
+func (n *Notification) data(cfg Config, user User) map[string]interface{} {
+	return map[string]interface{}{
+		"User":    user,
+		"Actor":   n.actor,
+		"Package": n.pack,
+		"Version": n.version,
+		"Host":    cfg.Core().Host(),
+	}
+}
+
+/*
+logError logs error of sending notification
+*/
+func (n *Notification) logError(cfg Config, err error) {
+	cfg.Logger().Error("cannot send notification",
+		zap.String("kind", n.kind),
+		zap.String("template", n.template),
+		zap.String("error", err.Error()),
+	)
+}
+
+/*
+NotifyMaintainers sends notification to package author and maintainers except the actor
+*/
+func NotifyMaintainers(cfg Config, notification *Notification, pack Package, actor User) (err error) {
+	target := Package{}
+	if err = cfg.DB().Preload("Author").Preload("Maintainers").First(&target, "id = ?", pack.ID).Error; err != nil {
+		return
+	}
+
+	users := []User{}
+	if target.Author != nil && target.Author.ID != actor.ID {
+		users = append(users, *target.Author)
+	}
+	for _, maintainer := range target.Maintainers {
+		if maintainer.ID != actor.ID && (target.Author == nil || maintainer.ID != target.Author.ID) {
+			users = append(users, maintainer)
+		}
+	}
+
+	return notification.Actor(actor).Package(pack).Send(cfg, users...)
+}
+
+/*
+SendTestEmail sends test email to given address synchronously, preferences are not checked.
+*/
+func SendTestEmail(cfg Config, to string) (err error) {
+	if !cfg.Notifications().Enabled() {
+		return ErrNotificationsDisabled
+	}
+
+	var email Email
+	user := User{Username: to, Email: to}
+	if email, err = RenderEmail(cfg, "email/test.tpl.html", NewNotification("", "").data(cfg, user)); err != nil {
+		return
+	}
+
+	return cfg.Notifications().Notifier().Send(cfg, email)
+}
+
+/*
+RenderEmail renders subject and body of email template, recipient is taken from data "User"
+*/
+func RenderEmail(cfg Config, template string, data map[string]interface{}) (email Email, err error) {
+	if user, ok := data["User"].(User); ok {
+		email.To = user.Email
+	}
+
+	if email.Subject, err = cfg.RenderTemplateFiles(data, "email_subject", "email/subject.tpl.html", template); err != nil {
+		return
+	}
+	// subject is plain text
+	email.Subject = strings.TrimSpace(html.UnescapeString(email.Subject))
+
+	if email.Body, err = cfg.RenderTemplateFiles(data, "email", "email/base.tpl.html", template); err != nil {
+		return
+	}
+
+	return
+}
+
+/*
+NewNotifier returns notifier with queue of given size
+*/
+func NewNotifier(queueSize int) *Notifier {
+	if queueSize <= 0 {
+		queueSize = NOTIFICATIONS_QUEUE_SIZE
+	}
+	return &Notifier{
+		queue: make(chan Email, queueSize),
+	}
+}
+
+/*
+Notifier sends emails over smtp
+*/
+type Notifier struct {
+	queue   chan Email
+	started int32
+	once    sync.Once
+}
+
+/*
+Start runs worker that sends queued emails in background
+*/
+func (n *Notifier) Start(cfg Config) {
+	n.once.Do(func() {
+		atomic.StoreInt32(&n.started, 1)
+		go func() {
+			for email := range n.queue {
+				if err := n.Send(cfg, email); err != nil {
+					cfg.Logger().Error("cannot send email",
+						zap.String("to", email.To),
+						zap.String("subject", email.Subject),
+						zap.String("error", err.Error()),
+					)
+				}
+			}
+		}()
+	})
+}
+
+/*
+Enqueue queues email when notifier is started, otherwise email is sent immediately. Enqueue never blocks, when
+queue is full email is dropped and ErrNotificationQueueFull is returned.
+*/
+func (n *Notifier) Enqueue(cfg Config, email Email) error {
+	if atomic.LoadInt32(&n.started) == 0 {
+		return n.Send(cfg, email)
+	}
+
+	select {
+	case n.queue <- email:
+		return nil
+	default:
+		return ErrNotificationQueueFull
+	}
+}
+
+/*
+Send sends email over smtp server from configuration
+*/
+func (n *Notifier) Send(cfg Config, email Email) (err error) {
+	nc := cfg.Notifications()
+	sc := nc.SMTP()
+
+	addr := net.JoinHostPort(sc.Host(), strconv.Itoa(sc.Port()))
+	tlsConfig := &tls.Config{
+		ServerName:         sc.Host(),
+		InsecureSkipVerify: sc.InsecureSkipVerify(),
+	}
+
+	var conn net.Conn
+	dialer := &net.Dialer{Timeout: SMTP_TIMEOUT}
+	if sc.TLS() == SMTP_TLS_IMPLICIT {
+		conn, err = tls.DialWithDialer(dialer, "tcp", addr, tlsConfig)
+	} else {
+		conn, err = dialer.Dial("tcp", addr)
+	}
+	if err != nil {
+		return
+	}
+	conn.SetDeadline(time.Now().Add(SMTP_TIMEOUT))
+
+	var client *smtp.Client
+	if client, err = smtp.NewClient(conn, sc.Host()); err != nil {
+		conn.Close()
+		return
+	}
+	defer client.Close()
+
+	if sc.TLS() == SMTP_TLS_STARTTLS {
+		if err = client.StartTLS(tlsConfig); err != nil {
+			return
+		}
+	}
+
+	if sc.Username() != "" {
+		if err = client.Auth(smtp.PlainAuth("", sc.Username(), sc.Password(), sc.Host())); err != nil {
+			return
+		}
+	}
+
+	if err = client.Mail(nc.From()); err != nil {
+		return
+	}
+	if err = client.Rcpt(email.To); err != nil {
+		return
+	}
+
+	var message []byte
+	if message, err = n.message(nc.From(), email); err != nil {
+		return
+	}
+
+	w, err := client.Data()
+	if err != nil {
+		return
+	}
+	if _, err = w.Write(message); err != nil {
+		return
+	}
+	if err = w.Close(); err != nil {
+		return
+	}
+
+	return client.Quit()
+}
+
+/*
+message returns email message with headers, body is html encoded as quoted printable
+*/
+func (n *Notifier) message(from string, email Email) (result []byte, err error) {
+	var buf bytes.Buffer
+
+	fmt.Fprintf(&buf, "From: %s\r\n", from)
+	fmt.Fprintf(&buf, "To: %s\r\n", email.To)
+	fmt.Fprintf(&buf, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", email.Subject))
+	fmt.Fprintf(&buf, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	fmt.Fprintf(&buf, "Message-ID: <%x@%s>\r\n", GenerateSalt(16), n.domain(from))
+	buf.WriteString("MIME-Version: 1.0\r\n")
+	buf.WriteString("Content-Type: text/html; charset=\"utf-8\"\r\n")
+	buf.WriteString("Content-Transfer-Encoding: quoted-printable\r\n")
+	buf.WriteString("\r\n")
+
+	qp := quotedprintable.NewWriter(&buf)
+	if _, err = qp.Write([]byte(email.Body)); err != nil {
+		return
+	}
+	if err = qp.Close(); err != nil {
+		return
+	}
+
+	return buf.Bytes(), nil
+}
+
+/*
+domain returns domain part of email address
+*/
+func (n *Notifier) domain(address string) string {
+	if index := strings.LastIndex(address, "@"); index != -1 {
+		return strings.Trim(address[index+1:], "> ")
+	}
+	return "localhost"
+}
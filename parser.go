@@ -23,9 +23,9 @@ func GetPostAction(r *http.Request) (result int, err error) {
 }
 
 /*
-GetPackage parses request form and returns package
+GetPackage parses request form and returns package from given index
 */
-func GetPostedPackage(cfg Config, r *http.Request) (pack Package, err error) {
+func GetPostedPackage(cfg Config, index Index, r *http.Request) (pack Package, err error) {
 
 	form := r.Form
 	name := strings.TrimSpace(form.Get("name"))
@@ -43,8 +43,9 @@ func GetPostedPackage(cfg Config, r *http.Request) (pack Package, err error) {
 		user User
 	)
 
-	if cfg.DB().Preload("Author").First(&pack, "name = ?", name).RecordNotFound() {
+	if cfg.DB().Preload("Author").First(&pack, "name = ? AND index_id = ?", name, index.ID).RecordNotFound() {
 		pack.Name = name
+		pack.IndexID = index.ID
 		// get user from context
 		if user, err = ContextGetTokenUser(r.Context()); err != nil {
 			return
@@ -56,6 +57,50 @@ func GetPostedPackage(cfg Config, r *http.Request) (pack Package, err error) {
 	return
 }
 
+/*
+GetPostedDistribution parses filename of uploaded file and checks that it matches posted name and version
+*/
+func GetPostedDistribution(r *http.Request) (dist DistributionFilename, err error) {
+	if r.MultipartForm == nil || len(r.MultipartForm.File["content"]) == 0 {
+		err = ErrPostPackageMissingFile
+		return
+	}
+
+	if dist, err = ParseDistributionFilename(r.MultipartForm.File["content"][0].Filename); err != nil {
+		return
+	}
+
+	err = dist.Matches(strings.TrimSpace(r.Form.Get("name")), strings.TrimSpace(r.Form.Get("version")))
+	return
+}
+
+/*
+GetPostedRequiresPython returns validated requires_python field of upload form
+*/
+func GetPostedRequiresPython(r *http.Request) (result string, err error) {
+	result = strings.TrimSpace(r.Form.Get("requires_python"))
+	err = ValidateRequiresPython(result)
+	return
+}
+
+/*
+GetPostedRequirements returns parsed requires_dist fields of upload form
+*/
+func GetPostedRequirements(r *http.Request) (result []Requirement, err error) {
+	for _, value := range r.Form["requires_dist"] {
+		if strings.TrimSpace(value) == "" {
+			continue
+		}
+
+		var requirement Requirement
+		if requirement, err = ParseRequirement(value); err != nil {
+			return
+		}
+		result = append(result, requirement)
+	}
+	return
+}
+
 /*
 Return package version
 */
@@ -77,8 +122,12 @@ func GetPostedPackageVersion(cfg Config, pack Package, r *http.Request) (pv Pack
 		pv.Comment = strings.TrimSpace(r.Form.Get("comment"))
 		pv.Description = strings.TrimSpace(r.Form.Get("description"))
 		pv.Summary = strings.TrimSpace(r.Form.Get("summary"))
+		pv.Keywords = strings.TrimSpace(r.Form.Get("keywords"))
 		pv.Version = strings.TrimSpace(r.Form.Get("version"))
 		pv.HomePage = strings.TrimSpace(r.Form.Get("home_page"))
+		pv.RequiresPython = strings.TrimSpace(r.Form.Get("requires_python"))
+		pv.DescriptionContentType = strings.TrimSpace(r.Form.Get("description_content_type"))
+		pv.DescriptionHTML = RenderDescription(pv.DescriptionContentType, pv.Description)
 
 		// assign package
 		pv.PackageID = pack.ID
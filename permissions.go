@@ -0,0 +1,45 @@
+/*
+Permission functions are passed to auth middlewares (BasicAuthLoginRequired, TokenAuthLoginRequired). Every function
+returns error when user doesn't have given permission.
+*/
+package core
+
+/*
+PermissionActive allows only active users
+*/
+func PermissionActive(user User) (err error) {
+	if !user.IsActive {
+		return ErrUserInactive
+	}
+	return
+}
+
+/*
+PermissionAdmin allows only admins
+*/
+func PermissionAdmin(user User) (err error) {
+	if !user.IsAdmin {
+		return ErrUserNotAdmin
+	}
+	return
+}
+
+/*
+PermissionList allows users that can list packages
+*/
+func PermissionList(user User) (err error) {
+	if !user.CanList {
+		return ErrUserCannotRetrievePackages
+	}
+	return
+}
+
+/*
+PermissionDownload allows users that can download packages
+*/
+func PermissionDownload(user User) (err error) {
+	if !user.CanDownload {
+		return ErrUserCannotDownloadPackages
+	}
+	return
+}
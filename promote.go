@@ -0,0 +1,245 @@
+/*
+Promotion of releases between indexes
+
+Version tested in one index (e.g. "staging") can be promoted to another index (e.g. "stable") with the exact same
+files, nothing is uploaded again. Copy leaves version in source index and creates new version with hard links (or
+copies when filesystem doesn't support links) of files in target index, move reassigns version to package in target
+index. Every promotion is recorded with user and time, promoted files are recorded in file history of target index (and
+moved files also in history of source index), so their filenames cannot be reused there.
+
+	promotion, err := PromoteVersion(cfg, pack, version, target, &user, false)
+
+Package is created in target index when it doesn't exist yet (with author and maintainers of source package).
+*/
+package core
+
+import (
+	"io"
+	"os"
+	"os/user"
+	"path/filepath"
+
+	"github.com/jinzhu/gorm"
+)
+
+/*
+PromoteVersion promotes version of package to target index. When user is given, permissions are checked (user must be
+able to upload to target index and create or modify package there), command line promotes without checks.
+*/
+func PromoteVersion(cfg Config, pack Package, version PackageVersion, target Index, actor *User, move bool) (promotion PackagePromotion, err error) {
+	if pack.IndexID == target.ID {
+		err = ErrPromoteSameIndex
+		return
+	}
+
+	if actor != nil && !cfg.Manager().Index().CanUpload(target, *actor) {
+		err = ErrIndexUploadForbidden
+		return
+	}
+
+	tx := cfg.DB().Begin()
+
+	// files created on disk, removed when promotion fails
+	created := []string{}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+			for _, filename := range created {
+				os.Remove(filename)
+			}
+		}
+	}()
+
+	var targetPack Package
+	if targetPack, err = promoteTargetPackage(cfg, tx, pack, target, actor); err != nil {
+		return
+	}
+
+	count := 0
+	if err = tx.Model(PackageVersion{}).Where("package_id = ? AND version = ?", targetPack.ID, version.Version).Count(&count).Error; err != nil {
+		return
+	}
+	if count > 0 {
+		err = ErrPromoteVersionExists
+		return
+	}
+
+	// files of promoted version, recorded in file history of target index so their filenames cannot be reused
+	files := []PackageVersionFile{}
+
+	promoted := version
+	if move {
+		if err = tx.Model(&promoted).UpdateColumn("package_id", targetPack.ID).Error; err != nil {
+			return
+		}
+		if err = tx.Find(&files, "package_version_id = ?", version.ID).Error; err != nil {
+			return
+		}
+
+		// moved files leave source index
+		for _, vfile := range files {
+			if err = cfg.Manager().PackageVersionFile(tx).RecordHistory(FILE_HISTORY_MOVE, pack, version, vfile, "", actor); err != nil {
+				return
+			}
+		}
+	} else {
+		if err = tx.Preload("Classifiers").Preload("Files").Preload("Dependencies").First(&version, "id = ?", version.ID).Error; err != nil {
+			return
+		}
+
+		promoted = version
+		promoted.ID = 0
+		promoted.Package = nil
+		promoted.PackageID = targetPack.ID
+		promoted.Author = nil
+		promoted.License = nil
+		promoted.Files = nil
+		promoted.Dependencies = nil
+		promoted.VersionOrder = 0
+
+		if err = tx.Create(&promoted).Error; err != nil {
+			return
+		}
+
+		for _, dependency := range version.Dependencies {
+			dependency.ID = 0
+			dependency.PackageVersionID = promoted.ID
+			if err = tx.Create(&dependency).Error; err != nil {
+				return
+			}
+		}
+
+		for _, vfile := range version.Files {
+			pvf := PackageVersionFile{
+				PackageVersionID: promoted.ID,
+				Filename:         vfile.Filename,
+				MD5Digest:        vfile.MD5Digest,
+				AuthorID:         vfile.AuthorID,
+				PackageType:      vfile.PackageType,
+				BuildTag:         vfile.BuildTag,
+				PythonTag:        vfile.PythonTag,
+				ABITag:           vfile.ABITag,
+				PlatformTag:      vfile.PlatformTag,
+				RequiresPython:   vfile.RequiresPython,
+			}
+			pvf.RelativePath = pvf.GenerateRelativePath()
+
+			source := cfg.Manager().PackageVersionFile().GetAbsoluteFilename(&vfile)
+			destination := cfg.Manager().PackageVersionFile().GetAbsoluteFilename(&pvf)
+
+			if err = linkOrCopyFile(source, destination); err != nil {
+				cfg.Metrics().Registry().StorageErrors.Inc("write")
+				return
+			}
+			created = append(created, destination)
+
+			if err = tx.Create(&pvf).Error; err != nil {
+				return
+			}
+			if err = cfg.Manager().PackageVersionFile(tx).UpdateTags(&pvf); err != nil {
+				return
+			}
+			files = append(files, pvf)
+		}
+	}
+
+	for _, vfile := range files {
+		if err = cfg.Manager().PackageVersionFile(tx).RecordHistory(FILE_HISTORY_PROMOTE, targetPack, promoted, vfile, "", actor); err != nil {
+			return
+		}
+	}
+
+	promotion = PackagePromotion{
+		PackageVersionID: promoted.ID,
+		SourceVersionID:  version.ID,
+		SourceIndexID:    pack.IndexID,
+		TargetIndexID:    target.ID,
+		Move:             move,
+	}
+	if actor != nil {
+		promotion.UserID = actor.ID
+		promotion.Username = actor.Username
+	} else if current, errUser := user.Current(); errUser == nil {
+		promotion.Username = current.Username
+	}
+
+	if err = tx.Create(&promotion).Error; err != nil {
+		return
+	}
+
+	if err = tx.Commit().Error; err != nil {
+		return
+	}
+
+	// update versions order in both packages
+	cfg.Manager().Package().UpdateVersionOrder(targetPack)
+	if move {
+		cfg.Manager().Package().UpdateVersionOrder(pack)
+	}
+
+	return
+}
+
+/*
+promoteTargetPackage returns package with same name in target index, package is created when it doesn't exist
+*/
+func promoteTargetPackage(cfg Config, db *gorm.DB, pack Package, target Index, actor *User) (result Package, err error) {
+	if db.Preload("Author").First(&result, "name = ? AND index_id = ?", pack.Name, target.ID).RecordNotFound() {
+		if actor != nil && !actor.IsAdmin && !actor.CanCreate {
+			err = ErrUserCannotCreatePackage
+			return
+		}
+
+		if err = db.Preload("Maintainers").First(&pack, "id = ?", pack.ID).Error; err != nil {
+			return
+		}
+
+		result = Package{
+			Name:        pack.Name,
+			IndexID:     target.ID,
+			AuthorID:    pack.AuthorID,
+			Maintainers: pack.Maintainers,
+		}
+		err = db.Create(&result).Error
+		return
+	}
+
+	if actor != nil && !cfg.Manager().Package(db).CanModify(&result, actor) {
+		err = ErrUserCannotModifyPackage
+	}
+
+	return
+}
+
+/*
+linkOrCopyFile creates hard link of file, file is copied when link cannot be created (e.g. different filesystems)
+*/
+func linkOrCopyFile(source, destination string) (err error) {
+	if err = os.MkdirAll(filepath.Dir(destination), 0777); err != nil {
+		return
+	}
+
+	if os.Link(source, destination) == nil {
+		return
+	}
+
+	var in, out *os.File
+	if in, err = os.Open(source); err != nil {
+		return
+	}
+	defer in.Close()
+
+	if out, err = os.Create(destination); err != nil {
+		return
+	}
+
+	if _, err = io.Copy(out, in); err != nil {
+		out.Close()
+		return
+	}
+	if err = out.Sync(); err != nil {
+		out.Close()
+		return
+	}
+	return out.Close()
+}
@@ -0,0 +1,108 @@
+package core
+
+import (
+	"database/sql/driver"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+/*
+testPromoteHandler answers queries of promotion of version 1.0 (id 5) of package foo (id 10, index 1) with single file
+to existing package foo (id 20) in index 2
+*/
+func testPromoteHandler(query string, args []driver.Value) testDBResult {
+	switch {
+	case strings.HasPrefix(query, "INSERT"):
+		return testDBResult{Columns: []string{"id"}, Rows: [][]driver.Value{{int64(30)}}, RowsAffected: 1}
+	case strings.HasPrefix(query, "UPDATE"):
+		return testDBResult{RowsAffected: 1}
+	case strings.Contains(query, "count(*)"):
+		return countResult(0)
+	case strings.Contains(query, `FROM "package" `) && strings.Contains(query, "name = "):
+		return testDBResult{
+			Columns: []string{"id", "name", "index_id"},
+			Rows:    [][]driver.Value{{int64(20), "foo", int64(2)}},
+		}
+	case strings.Contains(query, `FROM "package_version_file" `):
+		return testDBResult{
+			Columns: []string{"id", "package_version_id", "filename", "md5_digest", "relative_path", "package_type"},
+			Rows:    [][]driver.Value{{int64(7), int64(5), "foo-1.0.tar.gz", "digest", "ab/abcd/ef", "sdist"}},
+		}
+	case strings.Contains(query, `FROM "package_version" `):
+		return testDBResult{
+			Columns: []string{"id", "package_id", "version"},
+			Rows:    [][]driver.Value{{int64(5), int64(10), "1.0"}},
+		}
+	}
+	return testDBResult{}
+}
+
+/*
+testFileHistory returns file history entries (action, index id and filename) created in fake database
+*/
+func testFileHistory(fake *testDB) (result []string) {
+	for i, query := range fake.queries {
+		if !strings.HasPrefix(query, `INSERT INTO "package_file_history"`) {
+			continue
+		}
+		entry := []string{}
+		for _, arg := range fake.args[i] {
+			switch value := arg.(type) {
+			case string:
+				if value == "foo-1.0.tar.gz" || value == FILE_HISTORY_PROMOTE || value == FILE_HISTORY_MOVE {
+					entry = append(entry, value)
+				}
+			case int64:
+				if value == 1 || value == 2 {
+					entry = append(entry, map[int64]string{1: "index 1", 2: "index 2"}[value])
+				}
+			}
+		}
+		result = append(result, strings.Join(entry, " "))
+	}
+	return
+}
+
+func TestPromoteVersionFileHistory(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gopypi")
+	if err != nil {
+		t.Fatalf("cannot create directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	source := filepath.Join(dir, "ab", "abcd", "ef", "foo-1.0.tar.gz")
+	if err = os.MkdirAll(filepath.Dir(source), 0777); err != nil {
+		t.Fatalf("cannot create directory: %v", err)
+	}
+	if err = ioutil.WriteFile(source, []byte("foo"), 0666); err != nil {
+		t.Fatalf("cannot write file: %v", err)
+	}
+
+	tc := []struct {
+		name    string
+		move    bool
+		history []string
+	}{
+		{"copy", false, []string{"index 2 foo-1.0.tar.gz promote"}},
+		{"move", true, []string{"index 1 foo-1.0.tar.gz move", "index 2 foo-1.0.tar.gz promote"}},
+	}
+
+	for _, tt := range tc {
+		t.Run(tt.name, func(st *testing.T) {
+			cfg, fake := newTestConfig(st, testPromoteHandler)
+			cfg.packagesDir = dir
+
+			pack := Package{ID: 10, Name: "foo", IndexID: 1}
+			if _, err := PromoteVersion(cfg, pack, PackageVersion{ID: 5, PackageID: 10, Version: "1.0"}, Index{ID: 2}, nil, tt.move); err != nil {
+				st.Fatalf("PromoteVersion returned error: %v", err)
+			}
+
+			if history := testFileHistory(fake); strings.Join(history, ",") != strings.Join(tt.history, ",") {
+				st.Errorf("PromoteVersion recorded history %q, expected %q", history, tt.history)
+			}
+		})
+	}
+}
@@ -0,0 +1,276 @@
+/*
+Request tracking
+
+Every request gets request id (incoming X-Request-ID header is used when it's valid), which is returned in response
+header, added to all request logs and to json error responses. Access log in Apache combined format can be written
+to separate file:
+
+	[logging]
+	level = "info"
+	format = "json"
+	output = "stdout"
+	access_log = "/var/log/gopypi/access.log"
+*/
+package core
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/phonkee/go-response"
+	"github.com/uber-go/zap"
+)
+
+/*
+RequestInfo is information about request shared by middlewares. It's stored in context as pointer, so
+authentication middlewares can set username that is logged after request is finished.
+*/
+type RequestInfo struct {
+	ID       string
+	Username string
+
+	// ip address of client (resolved by TrustedProxies)
+	RemoteIP string
+}
+
+/*
+ContextGetRequestInfo returns request info from context, nil when request was not handled by RequestIDMiddleware
+*/
+func ContextGetRequestInfo(ctx context.Context) *RequestInfo {
+	info, _ := ctx.Value(CONTEXT_REQUEST_INFO).(*RequestInfo)
+	return info
+}
+
+/*
+ContextGetRequestID returns request id from context
+*/
+func ContextGetRequestID(ctx context.Context) string {
+	if info := ContextGetRequestInfo(ctx); info != nil {
+		return info.ID
+	}
+	return ""
+}
+
+/*
+ParseTrustedProxies parses ip addresses and cidr ranges of trusted reverse proxies
+*/
+func ParseTrustedProxies(values []string) (result TrustedProxies, err error) {
+	for _, value := range values {
+		value = strings.TrimSpace(value)
+		if !strings.Contains(value, "/") {
+			ip := net.ParseIP(value)
+			if ip == nil {
+				return nil, fmt.Errorf("%v: %q", ErrInvalidTrustedProxy, value)
+			}
+			if ip.To4() != nil {
+				value += "/32"
+			} else {
+				value += "/128"
+			}
+		}
+
+		var network *net.IPNet
+		if _, network, err = net.ParseCIDR(value); err != nil {
+			return nil, fmt.Errorf("%v: %q", ErrInvalidTrustedProxy, value)
+		}
+		result = append(result, network)
+	}
+	return
+}
+
+/*
+TrustedProxies are networks of reverse proxies in front of gopypi
+*/
+type TrustedProxies []*net.IPNet
+
+/*
+Contains returns whether ip address belongs to trusted proxy
+*/
+func (t TrustedProxies) Contains(ip string) bool {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return false
+	}
+	for _, network := range t {
+		if network.Contains(parsed) {
+			return true
+		}
+	}
+	return false
+}
+
+/*
+ClientIP returns ip address of client. X-Forwarded-For and X-Real-IP headers are used only when request comes from
+trusted proxy, X-Forwarded-For is walked from right (closest proxy) and first address that isn't trusted proxy is
+client (addresses on the left can be forged by client).
+*/
+func (t TrustedProxies) ClientIP(r *http.Request) string {
+	ip, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		ip = r.RemoteAddr
+	}
+
+	if !t.Contains(ip) {
+		return ip
+	}
+
+	if forwarded := r.Header.Get("X-Forwarded-For"); forwarded != "" {
+		addresses := strings.Split(forwarded, ",")
+		for i := len(addresses) - 1; i >= 0; i-- {
+			address := strings.TrimSpace(addresses[i])
+			if net.ParseIP(address) == nil {
+				break
+			}
+			if ip = address; !t.Contains(address) {
+				break
+			}
+		}
+		return ip
+	}
+
+	if realIP := strings.TrimSpace(r.Header.Get("X-Real-IP")); net.ParseIP(realIP) != nil {
+		return realIP
+	}
+
+	return ip
+}
+
+/*
+RequestLogger returns logger with request id field
+*/
+func RequestLogger(cfg Config, r *http.Request) zap.Logger {
+	return cfg.Logger().With(zap.String("request_id", ContextGetRequestID(r.Context())))
+}
+
+/*
+NewRequestID returns random request id
+*/
+func NewRequestID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+/*
+validRequestID returns whether incoming request id is safe to be logged and returned
+*/
+func validRequestID(id string) bool {
+	if id == "" || len(id) > REQUEST_ID_MAX_LENGTH {
+		return false
+	}
+	for _, c := range id {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || strings.ContainsRune("-_.:", c)) {
+			return false
+		}
+	}
+	return true
+}
+
+/*
+responseRecorder records status and size of response. Json error responses are buffered, so request id can be added
+to them.
+*/
+type responseRecorder struct {
+	http.ResponseWriter
+
+	requestID string
+	status    int
+	size      int
+	buffer    *bytes.Buffer
+}
+
+func (r *responseRecorder) WriteHeader(status int) {
+	if r.status != 0 {
+		return
+	}
+	r.status = status
+
+	// status is now recorded, header set by go-response is not needed
+	r.Header().Del(response.STATUS_HEADER)
+
+	if status >= http.StatusBadRequest && strings.HasPrefix(r.Header().Get("Content-Type"), "application/json") {
+		r.buffer = &bytes.Buffer{}
+		return
+	}
+
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *responseRecorder) Write(b []byte) (int, error) {
+	if r.status == 0 {
+		r.WriteHeader(http.StatusOK)
+	}
+	if r.buffer != nil {
+		return r.buffer.Write(b)
+	}
+	n, err := r.ResponseWriter.Write(b)
+	r.size += n
+	return n, err
+}
+
+/*
+finish writes buffered error response with request id
+*/
+func (r *responseRecorder) finish() {
+	if r.buffer == nil {
+		return
+	}
+
+	body := r.buffer.Bytes()
+	r.buffer = nil
+
+	data := map[string]interface{}{}
+	if json.Unmarshal(body, &data) == nil {
+		data["request_id"] = r.requestID
+		if updated, err := json.Marshal(data); err == nil {
+			body = updated
+		}
+	}
+
+	r.ResponseWriter.WriteHeader(r.status)
+	n, _ := r.ResponseWriter.Write(body)
+	r.size += n
+}
+
+/*
+writeAccessLog writes request to access log in Apache combined format
+*/
+func writeAccessLog(w io.Writer, r *http.Request, username string, status, size int, start time.Time) {
+	host := RemoteIP(r)
+
+	dash := func(value string) string {
+		if value == "" {
+			return "-"
+		}
+		return value
+	}
+
+	bytesSent := "-"
+	if size > 0 {
+		bytesSent = fmt.Sprint(size)
+	}
+
+	quote := strings.NewReplacer(`\`, `\\`, `"`, `\"`)
+
+	fmt.Fprintf(w, "%v - %v [%v] \"%v %v %v\" %v %v \"%v\" \"%v\"\n",
+		host,
+		dash(username),
+		start.Format("02/Jan/2006:15:04:05 -0700"),
+		r.Method,
+		quote.Replace(r.RequestURI),
+		r.Proto,
+		status,
+		bytesSent,
+		quote.Replace(dash(r.Referer())),
+		quote.Replace(dash(r.UserAgent())),
+	)
+}
@@ -0,0 +1,97 @@
+package core
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestRequestIDMiddleware(t *testing.T) {
+	generated := regexp.MustCompile("^[0-9a-f]{32}$")
+
+	tc := []struct {
+		name   string
+		path   string
+		header string
+		same   bool
+	}{
+		{"generated", "/healthz/", "", false},
+		{"from client", "/healthz/", "abc-123_proxy.1:2", true},
+		{"invalid characters", "/healthz/", "abc 123\"", false},
+		{"too long", "/healthz/", strings.Repeat("a", REQUEST_ID_MAX_LENGTH+1), false},
+		{"error response", "/api/info/", "abc-123", true},
+		{"generated on error response", "/api/info/", "", false},
+	}
+
+	for _, tt := range tc {
+		t.Run(tt.name, func(st *testing.T) {
+			handler, _, _ := newTestServer(st, nil)
+
+			r := httptest.NewRequest("GET", tt.path, nil)
+			r.Header.Set(REQUEST_ID_HEADER, tt.header)
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, r)
+
+			id := w.Header().Get(REQUEST_ID_HEADER)
+			if tt.same && id != tt.header {
+				st.Errorf("request id is %q, expected %q", id, tt.header)
+			}
+			if !tt.same && !generated.MatchString(id) {
+				st.Errorf("request id %q was not generated", id)
+			}
+
+			// json error responses carry request id
+			if w.Code >= http.StatusBadRequest {
+				body := map[string]interface{}{}
+				if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+					st.Fatalf("cannot parse response %v: %v", w.Body.String(), err)
+				}
+				if body["request_id"] != id {
+					st.Errorf("error response has request id %v, expected %v", body["request_id"], id)
+				}
+			}
+		})
+	}
+}
+
+func TestAccessLog(t *testing.T) {
+	user := User{ID: 1, Username: "alice", IsActive: true}
+
+	tc := []struct {
+		name     string
+		path     string
+		token    bool
+		expected string
+	}{
+		{"anonymous", "/healthz/?check=1", false, `192.0.2.1 - - [* "GET /healthz/?check=1 HTTP/1.1" 200 * "-" "pip/23.0"`},
+		{"authenticated", "/api/info/", true, `192.0.2.1 - alice [* "GET /api/info/ HTTP/1.1" 200 * "-" "pip/23.0"`},
+		{"unauthorized", "/api/info/", false, `192.0.2.1 - - [* "GET /api/info/ HTTP/1.1" 401 * "-" "pip/23.0"`},
+		{"not found", "/unknown/", false, `192.0.2.1 - - [* "GET /unknown/ HTTP/1.1" 404 * "-" "pip/23.0"`},
+	}
+
+	for _, tt := range tc {
+		t.Run(tt.name, func(st *testing.T) {
+			handler, cfg, _ := newTestServer(st, testUsersHandler(user))
+
+			access := &bytes.Buffer{}
+			cfg.lc.accessLog = access
+
+			r := httptest.NewRequest("GET", tt.path, nil)
+			if tt.token {
+				r = testTokenRequest(st, cfg, "GET", tt.path, user)
+			}
+			r.Header.Set("User-Agent", "pip/23.0")
+			handler.ServeHTTP(httptest.NewRecorder(), r)
+
+			// time and size are not compared
+			pattern := "^" + strings.Replace(regexp.QuoteMeta(tt.expected), `\*`, ".+", -1) + "\n$"
+			if !regexp.MustCompile(pattern).MatchString(access.String()) {
+				st.Errorf("access log is %q, expected %q", access.String(), tt.expected)
+			}
+		})
+	}
+}
@@ -1,13 +1,10 @@
 package core
 
 import (
-	"errors"
-
 	"github.com/justinas/alice"
 
 	"net/http"
 	"github.com/phonkee/go-classy"
-	"github.com/phonkee/go-xmlrpc"
 	"github.com/elazarl/go-bindata-assetfs"
 )
 
@@ -19,26 +16,16 @@ func InitRouter(config Config) (chain alice.Chain, err error) {
 	router := config.Router()
 
 	// create base middlewares chain
-	chain = alice.New(CommonMiddleware(config, router))
+	chain = alice.New(RequestIDMiddleware(config), CommonMiddleware(config, router))
 
 	// enable debug for all classy views (for now)
 	classy.Debug()
 
 	// prepare middleware for logged user with list permission
-	listAuth := BasicAuthLoginRequired(config, DoNotBypass, func(user User) (err error) {
-		if !user.CanList {
-			return ErrUserCannotRetrievePackages
-		}
-		return
-	})
+	listAuth := BasicAuthLoginRequired(config, DoNotBypass, PermissionList)
 
 	// prepare middleware for logged user with download permission
-	downloadAuth := BasicAuthLoginRequired(config, DoNotBypass, func(user User) (err error) {
-		if !user.CanDownload {
-			return ErrUserCannotDownloadPackages
-		}
-		return
-	})
+	downloadAuth := BasicAuthLoginRequired(config, DoNotBypass, PermissionDownload)
 
 	// packages routes
 	classy.Path("/packages").Register(
@@ -53,6 +40,39 @@ func InitRouter(config Config) (chain alice.Chain, err error) {
 		classy.New(&PackageDetailView{Config: config}),
 	)
 
+	// public pages for browsing packages
+	classy.Path("/project").Use(listAuth).Register(
+		router,
+		classy.New(&ProjectListView{Config: config}),
+		classy.New(&ProjectDetailView{Config: config}),
+		classy.New(&ProjectHistoryView{Config: config}).Path("/{slug}/history"),
+		classy.New(&ProjectFilesView{Config: config}).Path("/{slug}/files"),
+		classy.New(&ProjectDependenciesView{Config: config}).Path("/{slug}/dependencies"),
+	)
+
+	// every index has its own simple and upload url, index name can contain slash ("team-a/dev")
+	indexPath := "/index/{index:" + INDEX_NAME_PATTERN + "}"
+	classy.Name("index_{name}").Path(indexPath+"/simple").Use(listAuth).Register(
+		router,
+		classy.New(&PackageListView{Config: config}),
+		classy.New(&PackageDetailView{Config: config}),
+	)
+	classy.Name("index_{name}").Path(indexPath+"/project").Use(listAuth).Register(
+		router,
+		classy.New(&ProjectListView{Config: config}),
+		classy.New(&ProjectDetailView{Config: config}),
+		classy.New(&ProjectHistoryView{Config: config}).Path("/{slug}/history"),
+		classy.New(&ProjectFilesView{Config: config}).Path("/{slug}/files"),
+		classy.New(&ProjectDependenciesView{Config: config}).Path("/{slug}/dependencies"),
+	)
+	classy.Register(
+		router,
+		classy.New(&PostPackageView{Config: config}).
+			Path(indexPath).
+			Name("index_upload").
+			Use(PostEndpointCheckMiddleware(config)),
+	)
+
 	// Register homepage and post package view
 	classy.Register(
 		router,
@@ -68,53 +88,77 @@ func InitRouter(config Config) (chain alice.Chain, err error) {
 
 		// post endpoint has special middleware
 		classy.New(&PostPackageView{Config: config}).Use(PostEndpointCheckMiddleware(config)),
+
+		// prometheus metrics, authenticated by metrics token
+		classy.New(&MetricsView{Config: config}).Path("/metrics").Name("metrics"),
+
+		// orchestration probes, not authenticated
+		classy.New(&HealthView{Config: config}).Path("/healthz").Name("healthz"),
+		classy.New(&ReadyView{Config: config}).Path("/readyz").Name("readyz"),
+		classy.New(&VersionView{Config: config}).Path("/version").Name("version"),
 	)
 
+	// prepare token auth for all active users
+	userAuth := TokenAuthLoginRequired(config, PermissionActive)
+
 	// prepare token auth for admin
-	adminAuth := TokenAuthLoginRequired(config, func(user User) (err error) {
-		if !user.IsActive {
-			return errors.New("user inactive")
-		}
-		if !user.IsAdmin {
-			return errors.New("only user with admin access allowed")
-		}
-		return
-	})
+	adminAuth := TokenAuthLoginRequired(config, PermissionActive, PermissionAdmin)
 
 	// login api route (not secured by token auth)
 	classy.Name("api:{name}").Path("/api/login").Register(
 		router,
 
 		classy.New(&LoginAPIView{Config: config}),
+		classy.New(&LoginRefreshAPIView{Config: config}).Path("/refresh"),
 	)
 
-	// api endpoints secured by token auth
-	classy.Name("api:{name}").Path("/api").Use(adminAuth).Register(
+	// OpenID Connect login routes (not secured by token auth)
+	oidcProvider := NewOIDCProvider(config.Auth().OIDC())
+	classy.Name("api:{name}").Path("/api/oidc").Register(
+		router,
+
+		classy.New(&OIDCLoginAPIView{Config: config, Provider: oidcProvider}).Path("/login"),
+		classy.New(&OIDCCallbackAPIView{Config: config, Provider: oidcProvider}).Path("/callback"),
+	)
+
+	// api endpoints available to all active users, views limit data to packages user maintains (unless admin)
+	classy.Name("api:{name}").Path("/api").Use(userAuth).Register(
 		router,
 
-		classy.New(&FeatureAPIViewSet{Config: config}).Path("/feature"),
 		classy.New(&InfoAPIView{Config: config}).Path("/info"),
-		classy.New(&LicenseAPIViewSet{Config: config}).Path("/license"),
 
 		// me views - all about current logged user (by token)
 		classy.Group(
 			"/me",
 			classy.New(&MeAPIView{Config: config}),
 			classy.New(&MeChangePasswordAPIView{Config: config}).Path("/password"),
+			classy.New(&MeNotificationAPIView{Config: config}).Path("/notifications"),
 			classy.New(&MyPackageAPIView{Config: config}).
 				Path("/package"),
+			classy.New(&MyUploadAPIView{Config: config}).
+				Path("/upload"),
+			classy.New(&MySessionAPIViewSet{Config: config}).
+				Path("/session"),
+			classy.New(&MeLogoutAPIView{Config: config}).Path("/logout"),
+			classy.New(&MeLogoutAllAPIView{Config: config}).Path("/logout/all"),
 		),
 
 		// package views
-		classy.Group(
-			"/package",
-			classy.New(&PackageAPIViewSet{Config: config}),
-			classy.New(&PackageMaintainerAPIViewSet{Config: config}).
-				Path("/{package_pk:[0-9]+}/maintainer/"),
-		),
-
-		// platform views
-		classy.New(&PlatformAPIViewSet{Config: config}).Path("/platform"),
+		classy.New(&PackageAPIViewSet{Config: config}).Path("/package"),
+
+		// index views, users see readable indexes, only admins can change them
+		classy.New(&IndexAPIViewSet{Config: config}).Path("/index"),
+		classy.New(&PackageVersionAPIView{Config: config}).Path("/package/{package_pk:[0-9]+}/version"),
+		classy.New(&PackageVersionYankAPIView{Config: config}).
+			Path("/package/{package_pk:[0-9]+}/version/{pk:[0-9]+}/yank"),
+		classy.New(&PackageVersionPromoteAPIView{Config: config}).
+			Path("/package/{package_pk:[0-9]+}/version/{pk:[0-9]+}/promote"),
+		classy.New(&PackageFileHistoryAPIView{Config: config}).Path("/package/{package_pk:[0-9]+}/history"),
+		classy.New(&PackageDependenciesAPIView{Config: config}).Path("/package/{package_pk:[0-9]+}/dependencies"),
+		classy.New(&PackageDependentsAPIView{Config: config}).Path("/package/{package_pk:[0-9]+}/dependents"),
+
+		// search packages of readable index
+		classy.New(&SearchAPIView{Config: config}).Path("/search"),
 
 		// stat classy views
 		classy.Group(
@@ -127,18 +171,44 @@ func InitRouter(config Config) (chain alice.Chain, err error) {
 				classy.New(&StatsDownloadPackageVersionAPIView{Config: config}).
 					Path("/{package_pk:[0-9]+}/version"),
 			),
+			classy.New(&StatsDownloadBreakdownAPIView{Config: config}).Path("/download/breakdown"),
 		),
+	)
+
+	// api endpoints secured by token auth available only to admins
+	classy.Name("api:{name}").Path("/api").Use(adminAuth).Register(
+		router,
+
+		classy.New(&AuditLogAPIViewSet{Config: config}).Path("/audit"),
+		classy.New(&AuditLogExportAPIView{Config: config}).Path("/audit/export"),
+
+		classy.New(&FeatureAPIViewSet{Config: config}).Path("/feature"),
+		classy.New(&IndexACLAPIViewSet{Config: config}).Path("/index/{index_pk:[0-9]+}/acl"),
+		classy.New(&LicenseAPIViewSet{Config: config}).Path("/license"),
+		classy.New(&LockoutAPIView{Config: config}).Path("/lockout"),
+
+		classy.New(&PackageMaintainerAPIViewSet{Config: config}).
+			Path("/package/{package_pk:[0-9]+}/maintainer/"),
+
+		// platform views
+		classy.New(&PlatformAPIViewSet{Config: config}).Path("/platform"),
 
 		classy.New(&UserAPIViewSet{Config: config}).Path("/user"),
-	)
 
-	// register rpc service
-	xmlrpcHandler := xmlrpc.NewHandler()
-	if e := xmlrpcHandler.AddService(&SearchService{Config: config}, ""); e != nil {
-		println("error:", e.Error())
-	}
+		// webhook views
+		classy.New(&WebhookAPIViewSet{Config: config}).Path("/webhook"),
+		classy.New(&WebhookDeliveryAPIViewSet{Config: config}).Path("/webhook/{webhook_pk:[0-9]+}/delivery"),
+		classy.New(&WebhookRedeliverAPIView{Config: config}).
+			Path("/webhook/{webhook_pk:[0-9]+}/delivery/{pk:[0-9]+}/redeliver"),
+
+		// background task views
+		classy.New(&TaskAPIView{Config: config}).Path("/task"),
+		classy.New(&TaskRunAPIViewSet{Config: config}).Path("/task/run"),
+		classy.New(&TaskRunTriggerAPIView{Config: config}).Path("/task/{name:[a-z_]+}/run"),
+	)
 
-	router.Handle("/RPC2", alice.New(listAuth).Then(xmlrpcHandler)).Methods("POST")
+	// register rpc service, service is created for every request so it searches as user of request
+	router.Handle("/RPC2", alice.New(listAuth).Then(NewSearchHandler(config))).Methods("POST")
 
 	/*
 		admin static handler with fallback
@@ -0,0 +1,171 @@
+package core
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+/*
+newTestServer returns handler with all routes and middlewares of server backed by fake database
+*/
+func newTestServer(t *testing.T, handler func(query string, args []driver.Value) testDBResult) (http.Handler, *config, *testDB) {
+	cfg, fake := newTestConfig(t, handler)
+
+	chain, err := InitRouter(cfg)
+	if err != nil {
+		t.Fatalf("InitRouter returned error: %v", err)
+	}
+
+	return chain.Then(cfg.Router()), cfg, fake
+}
+
+/*
+testUsersHandler answers sessions (session key is id of user) and users by id from given users
+*/
+func testUsersHandler(users ...User) func(query string, args []driver.Value) testDBResult {
+	return func(query string, args []driver.Value) testDBResult {
+		switch {
+		case strings.Contains(query, `FROM "session"`):
+			now := time.Now()
+			return testDBResult{
+				Columns: []string{"id", "user_id", "session_key", "created_at", "last_used_at", "expires_at"},
+				Rows: [][]driver.Value{
+					{int64(1), args[0], args[0], now, now, now.Add(time.Hour)},
+				},
+			}
+		case strings.Contains(query, `FROM "user"`) && strings.Contains(query, "id = $1"):
+			for _, user := range users {
+				if fmt.Sprint(user.ID) == fmt.Sprint(args[0]) {
+					return testDBResult{
+						Columns: []string{"id", "username", "password", "is_active", "is_admin", "can_list", "can_download"},
+						Rows: [][]driver.Value{
+							{int64(user.ID), user.Username, user.Password, user.IsActive, user.IsAdmin, user.CanList, user.CanDownload},
+						},
+					}
+				}
+			}
+		}
+		return testDBResult{}
+	}
+}
+
+/*
+testTokenRequest returns request authenticated by access token of given user
+*/
+func testTokenRequest(t *testing.T, cfg Config, method, target string, user User) *http.Request {
+	token, err := CreateToken(Session{Key: fmt.Sprint(user.ID), UserID: user.ID}, cfg.Core().SecretKey(), 60)
+	if err != nil {
+		t.Fatalf("CreateToken returned error: %v", err)
+	}
+
+	r := httptest.NewRequest(method, target, nil)
+	r.Header.Set(TOKEN_HEADER_NAME, "Bearer "+token)
+	return r
+}
+
+/*
+testBasicRequest returns request authenticated by basic auth of given user, credentials are verified from credential
+cache (user has to be answered by database with the same password hash)
+*/
+func testBasicRequest(cfg Config, method, target string, user User) *http.Request {
+	cfg.Auth().CredentialCache().Set(user.Username, "password", user)
+
+	r := httptest.NewRequest(method, target, nil)
+	r.SetBasicAuth(user.Username, "password")
+	return r
+}
+
+func TestRouterAPIPermissions(t *testing.T) {
+	admin := User{ID: 1, Username: "admin", IsActive: true, IsAdmin: true}
+	user := User{ID: 2, Username: "user", IsActive: true}
+	inactive := User{ID: 3, Username: "inactive", IsAdmin: true}
+
+	tc := []struct {
+		path    string
+		user    User
+		allowed bool
+	}{
+		{"/api/info/", admin, true},
+		{"/api/info/", user, true},
+		{"/api/info/", inactive, false},
+		{"/api/me/", user, true},
+		{"/api/package/", user, true},
+		{"/api/package/", inactive, false},
+		{"/api/feature/", admin, true},
+		{"/api/feature/", user, false},
+		{"/api/license/", admin, true},
+		{"/api/license/", user, false},
+		{"/api/platform/", admin, true},
+		{"/api/platform/", user, false},
+		{"/api/user/", admin, true},
+		{"/api/user/", user, false},
+		{"/api/user/", inactive, false},
+		{"/api/package/1/maintainer/", admin, true},
+		{"/api/package/1/maintainer/", user, false},
+	}
+
+	for _, tt := range tc {
+		t.Run(tt.path+" "+tt.user.Username, func(st *testing.T) {
+			handler, cfg, _ := newTestServer(st, testUsersHandler(admin, user, inactive))
+
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, testTokenRequest(st, cfg, "GET", tt.path, tt.user))
+
+			if tt.allowed && (w.Code == http.StatusUnauthorized || w.Code == http.StatusForbidden) {
+				st.Errorf("%v should be allowed to %v, got status %v", tt.user.Username, tt.path, w.Code)
+			}
+			if !tt.allowed && w.Code != http.StatusForbidden {
+				st.Errorf("%v should be forbidden to %v, got status %v", tt.user.Username, tt.path, w.Code)
+			}
+		})
+	}
+
+	t.Run("without token", func(st *testing.T) {
+		handler, _, _ := newTestServer(st, testUsersHandler(admin))
+
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest("GET", "/api/info/", nil))
+
+		if w.Code != http.StatusUnauthorized {
+			st.Errorf("request without token returned status %v, expected %v", w.Code, http.StatusUnauthorized)
+		}
+	})
+}
+
+func TestRouterAPIPackagesScope(t *testing.T) {
+	tc := []struct {
+		user   User
+		scoped bool
+	}{
+		{User{ID: 1, Username: "admin", IsActive: true, IsAdmin: true}, false},
+		{User{ID: 2, Username: "user", IsActive: true}, true},
+	}
+
+	for _, tt := range tc {
+		t.Run(tt.user.Username, func(st *testing.T) {
+			handler, cfg, fake := newTestServer(st, testUsersHandler(tt.user))
+
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, testTokenRequest(st, cfg, "GET", "/api/package/", tt.user))
+
+			if w.Code != http.StatusOK {
+				st.Fatalf("package list returned status %v: %v", w.Code, w.Body.String())
+			}
+
+			queries := fake.Queries(`FROM "package"`)
+			if len(queries) == 0 {
+				st.Fatalf("packages were not queried")
+			}
+			for _, query := range queries {
+				if scoped := strings.Contains(query, "author_id = "); scoped != tt.scoped {
+					st.Errorf("query %q of %v scoped to author is %v, expected %v", query, tt.user.Username, scoped, tt.scoped)
+				}
+			}
+		})
+	}
+}
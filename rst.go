@@ -0,0 +1,531 @@
+/*
+reStructuredText renderer
+
+Renderer of reStructuredText subset used in package descriptions: section titles (levels are assigned in order of
+appearance of adornment styles), paragraphs, literal blocks ("::"), bullet and enumerated lists, block quotes,
+transitions, doctest blocks, tables (rendered preformatted) and directives code-block, image and admonitions (note,
+warning, ...). Other directives and comments are skipped. Inline: literals, emphasis, strong emphasis, hyperlink
+references, interpreted text and standalone urls.
+*/
+package core
+
+import (
+	"html"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var (
+	rstBulletRegexp     = regexp.MustCompile(`^([-*+•])( +)(.*)$`)
+	rstEnumeratedRegexp = regexp.MustCompile(`^(?:([0-9]+|#)[.)]|\(([0-9]+|#)\))( +)(.*)$`)
+	rstDirectiveRegexp  = regexp.MustCompile(`^\.\.[ ]+([A-Za-z0-9_:+.-]+)::[ ]*(.*)$`)
+	rstTargetRegexp     = regexp.MustCompile(`^\.\.[ ]+_(?:` + "`" + `([^` + "`" + `]+)` + "`" + `|([^:]+)):[ ]*(.*)$`)
+	rstOptionRegexp     = regexp.MustCompile(`^:([A-Za-z0-9_-]+):[ ]*(.*)$`)
+	rstSimpleTable      = regexp.MustCompile(`^=+( +=+)+ *$`)
+	rstEmbeddedURI      = regexp.MustCompile(`^(?s)(.*?)\s*<([^<>]+)>$`)
+	rstRoleRegexp       = regexp.MustCompile(`^:([A-Za-z0-9_.-]+):` + "`")
+	rstSuffixRoleRegexp = regexp.MustCompile(`^:([A-Za-z0-9_.-]+):`)
+
+	// admonition directives rendered as quoted blocks with title
+	rstAdmonitions = map[string]string{
+		"attention": "Attention", "caution": "Caution", "danger": "Danger", "error": "Error", "hint": "Hint",
+		"important": "Important", "note": "Note", "tip": "Tip", "warning": "Warning", "seealso": "See also",
+		"admonition": "",
+	}
+)
+
+// characters used for section adornments
+const rstAdornmentCharacters = "!\"#$%&'()*+,-./:;<=>?@[\\]^_`{|}~"
+
+/*
+rst renders single document
+*/
+type rst struct {
+	targets map[string]string
+	styles  []string
+}
+
+/*
+renderRST renders reStructuredText to html
+*/
+func renderRST(text string) string {
+	r := &rst{targets: map[string]string{}}
+	lines := strings.Split(text, "\n")
+	r.collectTargets(lines)
+	return r.blocks(lines)
+}
+
+/*
+collectTargets stores hyperlink targets (".. _name: url") for references
+*/
+func (r *rst) collectTargets(lines []string) {
+	for _, line := range lines {
+		if match := rstTargetRegexp.FindStringSubmatch(strings.TrimSpace(line)); match != nil && match[3] != "" {
+			name := match[1]
+			if name == "" {
+				name = match[2]
+			}
+			r.targets[normalizeReferenceLabel(name)] = strings.TrimSpace(match[3])
+		}
+	}
+}
+
+/*
+isAdornment returns whether line is section adornment or transition (single repeated punctuation character)
+*/
+func isAdornment(line string) bool {
+	line = strings.TrimRight(line, " ")
+	if len(line) < 2 || strings.IndexByte(rstAdornmentCharacters, line[0]) < 0 {
+		return false
+	}
+	return strings.Count(line, line[:1]) == len(line)
+}
+
+/*
+headingLevel returns level of section title with adornment style
+*/
+func (r *rst) headingLevel(style string) string {
+	for index, existing := range r.styles {
+		if existing == style {
+			return strconv.Itoa(index + 1)
+		}
+	}
+	r.styles = append(r.styles, style)
+	if len(r.styles) > 6 {
+		return "6"
+	}
+	return strconv.Itoa(len(r.styles))
+}
+
+/*
+indentedBlock returns lines starting at i that are blank or indented at least by indent, and index of next line
+*/
+func indentedBlock(lines []string, i int, indent int) ([]string, int) {
+	start := i
+	for i < len(lines) && (isBlank(lines[i]) || indentation(lines[i]) >= indent) {
+		i++
+	}
+	return lines[start:i], i
+}
+
+/*
+blocks renders block level elements
+*/
+func (r *rst) blocks(lines []string) string {
+	var b strings.Builder
+
+	for i := 0; i < len(lines); {
+		line := lines[i]
+		trimmed := strings.TrimSpace(line)
+
+		switch {
+		case isBlank(line):
+			i++
+
+		// section title with overline
+		case isAdornment(line) && i+2 < len(lines) && !isBlank(lines[i+1]) && strings.TrimRight(lines[i+2], " ") == strings.TrimRight(line, " "):
+			level := r.headingLevel("over" + line[:1])
+			b.WriteString("<h" + level + ">" + r.inline(strings.TrimSpace(lines[i+1])) + "</h" + level + ">\n")
+			i += 3
+
+		// section title with underline
+		case indentation(line) == 0 && i+1 < len(lines) && isAdornment(lines[i+1]) &&
+			len([]rune(strings.TrimRight(lines[i+1], " "))) >= len([]rune(trimmed)) && !isAdornment(line):
+			level := r.headingLevel("under" + lines[i+1][:1])
+			b.WriteString("<h" + level + ">" + r.inline(trimmed) + "</h" + level + ">\n")
+			i += 2
+
+		case isAdornment(line) && len(trimmed) >= 4 && (i+1 >= len(lines) || isBlank(lines[i+1])):
+			b.WriteString("<hr>\n")
+			i++
+
+		case strings.HasPrefix(line, ".. ") || trimmed == "..":
+			i = r.directive(&b, lines, i)
+
+		case indentation(line) > 0:
+			quoted, next := indentedBlock(lines, i, 1)
+			b.WriteString("<blockquote>\n" + r.blocks(dedent(quoted)) + "</blockquote>\n")
+			i = next
+
+		case strings.HasPrefix(line, ">>>"):
+			start := i
+			for i < len(lines) && !isBlank(lines[i]) {
+				i++
+			}
+			b.WriteString("<pre><code>" + html.EscapeString(strings.Join(lines[start:i], "\n")) + "</code></pre>\n")
+
+		case strings.HasPrefix(line, "+-") || rstSimpleTable.MatchString(line):
+			start := i
+			for i < len(lines) && !isBlank(lines[i]) {
+				i++
+			}
+			b.WriteString("<pre>" + html.EscapeString(strings.Join(lines[start:i], "\n")) + "</pre>\n")
+
+		case rstBulletRegexp.MatchString(line):
+			i = r.list(&b, lines, i, false)
+
+		case rstEnumeratedRegexp.MatchString(line):
+			i = r.list(&b, lines, i, true)
+
+		default:
+			i = r.paragraph(&b, lines, i)
+		}
+	}
+
+	return b.String()
+}
+
+/*
+paragraph renders paragraph starting at line i, paragraph ending with "::" is followed by literal block
+*/
+func (r *rst) paragraph(b *strings.Builder, lines []string, i int) int {
+	collected := []string{}
+	for ; i < len(lines) && !isBlank(lines[i]) && indentation(lines[i]) == 0; i++ {
+		collected = append(collected, strings.TrimSpace(lines[i]))
+	}
+
+	text := strings.Join(collected, "\n")
+	literal := strings.HasSuffix(text, "::")
+	if literal {
+		switch {
+		case text == "::":
+			text = ""
+		case strings.HasSuffix(text, " ::"):
+			text = strings.TrimSuffix(text, " ::")
+		default:
+			text = strings.TrimSuffix(text, ":")
+		}
+	}
+
+	if text != "" {
+		b.WriteString("<p>" + r.inline(text) + "</p>\n")
+	}
+
+	if literal {
+		next := i
+		for next < len(lines) && isBlank(lines[next]) {
+			next++
+		}
+		if next < len(lines) && indentation(lines[next]) > 0 {
+			block, end := indentedBlock(lines, next, 1)
+			b.WriteString("<pre><code>" + html.EscapeString(strings.Join(dedent(block), "\n")) + "</code></pre>\n")
+			return end
+		}
+	}
+	return i
+}
+
+/*
+list renders bullet or enumerated list starting at line i
+*/
+func (r *rst) list(b *strings.Builder, lines []string, i int, enumerated bool) int {
+	items := [][]string{}
+	start := 0
+
+	for i < len(lines) {
+		var width int
+		var content string
+
+		if enumerated {
+			match := rstEnumeratedRegexp.FindStringSubmatch(lines[i])
+			if match == nil {
+				break
+			}
+			if len(items) == 0 {
+				number := match[1] + match[2]
+				start, _ = strconv.Atoi(number)
+			}
+			width = len(lines[i]) - len(match[4])
+			content = match[4]
+		} else {
+			match := rstBulletRegexp.FindStringSubmatch(lines[i])
+			if match == nil {
+				break
+			}
+			width = len(lines[i]) - len(match[3])
+			content = match[3]
+		}
+
+		block, next := indentedBlock(lines, i+1, width)
+		item := []string{content}
+		for _, line := range block {
+			if len(line) >= width {
+				line = line[width:]
+			} else {
+				line = ""
+			}
+			item = append(item, line)
+		}
+		items = append(items, item)
+		i = next
+
+		// blank lines between items
+		for next < len(lines) && isBlank(lines[next]) {
+			next++
+		}
+		if next < len(lines) && (enumerated && rstEnumeratedRegexp.MatchString(lines[next]) || !enumerated && rstBulletRegexp.MatchString(lines[next])) {
+			i = next
+		}
+	}
+
+	if enumerated {
+		if start > 1 {
+			b.WriteString(`<ol start="` + strconv.Itoa(start) + `">` + "\n")
+		} else {
+			b.WriteString("<ol>\n")
+		}
+	} else {
+		b.WriteString("<ul>\n")
+	}
+
+	for _, item := range items {
+		rendered := r.blocks(item)
+
+		// single paragraph items are not wrapped
+		if strings.Count(rendered, "<p>") == 1 && strings.HasPrefix(rendered, "<p>") {
+			rendered = strings.Replace(strings.Replace(rendered, "<p>", "", 1), "</p>", "", 1)
+		}
+		b.WriteString("<li>" + strings.TrimSuffix(rendered, "\n") + "</li>\n")
+	}
+
+	if enumerated {
+		b.WriteString("</ol>\n")
+	} else {
+		b.WriteString("</ul>\n")
+	}
+	return i
+}
+
+/*
+directive renders directive or skips comment starting at line i
+*/
+func (r *rst) directive(b *strings.Builder, lines []string, i int) int {
+	match := rstDirectiveRegexp.FindStringSubmatch(strings.TrimSpace(lines[i]))
+	body, next := indentedBlock(lines, i+1, 1)
+	body = dedent(body)
+
+	if match == nil {
+		// comments and hyperlink targets are not rendered
+		return next
+	}
+
+	name, argument := strings.ToLower(match[1]), strings.TrimSpace(match[2])
+
+	// options are at the beginning of body
+	options := map[string]string{}
+	for len(body) > 0 {
+		option := rstOptionRegexp.FindStringSubmatch(body[0])
+		if option == nil {
+			break
+		}
+		options[option[1]] = option[2]
+		body = body[1:]
+	}
+
+	switch name {
+	case "code", "code-block", "sourcecode":
+		for len(body) > 0 && isBlank(body[0]) {
+			body = body[1:]
+		}
+		language := markdownInfoRegexp.ReplaceAllString(argument, "")
+		if language != "" {
+			b.WriteString(`<pre><code class="language-` + language + `">`)
+		} else {
+			b.WriteString("<pre><code>")
+		}
+		b.WriteString(html.EscapeString(strings.Join(body, "\n")) + "</code></pre>\n")
+
+	case "image", "figure":
+		image := renderImage(argument, options["alt"], "")
+		if target, ok := options["target"]; ok {
+			image = renderLink(target, image, "")
+		}
+		b.WriteString("<p>" + image + "</p>\n")
+		if name == "figure" && len(body) > 0 {
+			b.WriteString(r.blocks(body))
+		}
+
+	default:
+		title, ok := rstAdmonitions[name]
+		if !ok {
+			return next
+		}
+		if name == "admonition" {
+			title, argument = argument, ""
+		}
+
+		b.WriteString("<blockquote>\n")
+		if title != "" {
+			b.WriteString("<p><strong>" + html.EscapeString(title) + "</strong></p>\n")
+		}
+		if argument != "" {
+			body = append([]string{argument, ""}, body...)
+		}
+		b.WriteString(r.blocks(body) + "</blockquote>\n")
+	}
+
+	return next
+}
+
+/*
+inline renders inline markup of text
+*/
+func (r *rst) inline(text string) string {
+	var b strings.Builder
+
+	for i := 0; i < len(text); {
+		c := text[i]
+		startOfWord := i == 0 || strings.IndexByte(" \n'\"([{<-/:", text[i-1]) >= 0
+
+		switch {
+		case c == '\\' && i+1 < len(text):
+			escapeByte(&b, text[i+1])
+			i += 2
+
+		case startOfWord && strings.HasPrefix(text[i:], "``"):
+			if end := r.findEnd(text, i+2, "``"); end > 0 {
+				b.WriteString("<code>" + html.EscapeString(strings.Replace(text[i+2:end], "\n", " ", -1)) + "</code>")
+				i = end + 2
+			} else {
+				b.WriteString("``")
+				i += 2
+			}
+
+		case startOfWord && strings.HasPrefix(text[i:], "**"):
+			if end := r.findEnd(text, i+2, "**"); end > 0 {
+				b.WriteString("<strong>" + html.EscapeString(text[i+2:end]) + "</strong>")
+				i = end + 2
+			} else {
+				b.WriteString("**")
+				i += 2
+			}
+
+		case startOfWord && c == '*':
+			if end := r.findEnd(text, i+1, "*"); end > 0 {
+				b.WriteString("<em>" + html.EscapeString(text[i+1:end]) + "</em>")
+				i = end + 1
+			} else {
+				b.WriteString("*")
+				i++
+			}
+
+		case startOfWord && c == ':' && rstRoleRegexp.MatchString(text[i:]):
+			role := rstRoleRegexp.FindStringSubmatch(text[i:])
+			i = r.interpreted(&b, text, i+len(role[0])-1, role[1])
+
+		case startOfWord && c == '`':
+			i = r.interpreted(&b, text, i, "")
+
+		case startOfWord && bareURLLength(text[i:]) > 0 && !strings.HasPrefix(strings.ToLower(text[i:]), "www."):
+			length := bareURLLength(text[i:])
+			b.WriteString(renderBareURL(text[i : i+length]))
+			i += length
+
+		case startOfWord && isAlnumByte(c):
+			// simple reference "name_"
+			end := i
+			for end < len(text) && (isAlnumByte(text[end]) || strings.IndexByte("-.", text[end]) >= 0) {
+				end++
+			}
+			word := text[i:end]
+			if end < len(text) && text[end] == '_' && (end+1 >= len(text) || !isAlnumByte(text[end+1]) && text[end+1] != '_') {
+				if target, ok := r.targets[normalizeReferenceLabel(word)]; ok {
+					b.WriteString(renderLink(target, html.EscapeString(word), ""))
+					i = end + 1
+					continue
+				}
+			}
+			b.WriteString(html.EscapeString(word))
+			i = end
+
+		default:
+			escapeByte(&b, c)
+			i++
+		}
+	}
+
+	return b.String()
+}
+
+/*
+findEnd returns index of end string of inline markup, end must follow non whitespace character
+*/
+func (r *rst) findEnd(text string, start int, end string) int {
+	if start >= len(text) || text[start] == ' ' || text[start] == '\n' {
+		return -1
+	}
+	for j := start + 1; j <= len(text)-len(end); j++ {
+		if text[j-1] == '\\' {
+			continue
+		}
+		if strings.HasPrefix(text[j:], end) && text[j-1] != ' ' && text[j-1] != '\n' {
+			after := j + len(end)
+			if after >= len(text) || !isAlnumByte(text[after]) {
+				return j
+			}
+		}
+	}
+	return -1
+}
+
+/*
+interpreted renders interpreted text or hyperlink reference starting at backtick on index i
+*/
+func (r *rst) interpreted(b *strings.Builder, text string, i int, role string) int {
+	end := r.findEnd(text, i+1, "`")
+	if end < 0 {
+		b.WriteString(html.EscapeString(text[i : i+1]))
+		return i + 1
+	}
+	content := text[i+1 : end]
+	next := end + 1
+
+	// hyperlink reference `text <url>`_ or `name`_
+	if role == "" && next < len(text) && text[next] == '_' {
+		next++
+		if next < len(text) && text[next] == '_' {
+			next++
+		}
+
+		if match := rstEmbeddedURI.FindStringSubmatch(content); match != nil {
+			label := match[1]
+			if label == "" {
+				label = match[2]
+			}
+			r.targets[normalizeReferenceLabel(label)] = match[2]
+			b.WriteString(renderLink(match[2], html.EscapeString(label), ""))
+			return next
+		}
+
+		if target, ok := r.targets[normalizeReferenceLabel(content)]; ok {
+			b.WriteString(renderLink(target, html.EscapeString(content), ""))
+		} else {
+			b.WriteString(html.EscapeString(content))
+		}
+		return next
+	}
+
+	// role can be written after interpreted text
+	if role == "" {
+		if match := rstSuffixRoleRegexp.FindStringSubmatch(text[next:]); match != nil {
+			role = match[1]
+			next += len(match[0])
+		}
+	}
+
+	switch role {
+	case "code", "literal", "file", "command", "samp", "kbd", "option", "envvar", "program":
+		b.WriteString("<code>" + html.EscapeString(content) + "</code>")
+	case "strong":
+		b.WriteString("<strong>" + html.EscapeString(content) + "</strong>")
+	case "emphasis":
+		b.WriteString("<em>" + html.EscapeString(content) + "</em>")
+	case "":
+		b.WriteString("<cite>" + html.EscapeString(content) + "</cite>")
+	default:
+		// sphinx roles (:class:, :func:, :ref:, ...) are rendered as code
+		b.WriteString("<code>" + html.EscapeString(content) + "</code>")
+	}
+	return next
+}
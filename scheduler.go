@@ -0,0 +1,248 @@
+/*
+Scheduler
+
+Scheduler runs registered tasks in background according to cron schedules from configuration:
+
+	[scheduler]
+	enabled = true
+	lock_ttl = 3600
+
+	[scheduler.tasks]
+	cleanup_download_stats = "@daily"
+	storage_gc = "0 3 * * 0"
+	metadata_backfill = ""
+
+Task with blank schedule is not run automatically, but it can be still run from admin api or command line. Before
+task runs, scheduler acquires lock in database, so when multiple gopypi instances share database, every scheduled
+activation runs only once. Every run is stored in task run history.
+*/
+package core
+
+import (
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/uber-go/zap"
+)
+
+/*
+ScheduledTask is task registered in scheduler
+*/
+type ScheduledTask struct {
+	Name        string
+	Description string
+	Spec        string
+	Schedule    Schedule
+	Task        Task
+}
+
+/*
+NewScheduler returns scheduler, lock ttl is maximum expected duration of single task run
+*/
+func NewScheduler(lockTTL time.Duration) *Scheduler {
+	hostname, _ := os.Hostname()
+	return &Scheduler{
+		lockTTL: lockTTL,
+		owner:   fmt.Sprintf("%v:%v", hostname, os.Getpid()),
+		running: map[string]bool{},
+	}
+}
+
+/*
+Scheduler runs registered tasks
+*/
+type Scheduler struct {
+	tasks   []*ScheduledTask
+	lockTTL time.Duration
+	owner   string
+	once    sync.Once
+	mutex   sync.Mutex
+	running map[string]bool
+}
+
+/*
+Register adds task to scheduler, blank spec means that task is run only manually
+*/
+func (s *Scheduler) Register(name, description, spec string, task Task) (err error) {
+	scheduled := &ScheduledTask{
+		Name:        name,
+		Description: description,
+		Spec:        spec,
+		Task:        task,
+	}
+
+	if spec != "" {
+		if scheduled.Schedule, err = ParseSchedule(spec); err != nil {
+			return fmt.Errorf("task %v: %v", name, err)
+		}
+	}
+
+	s.tasks = append(s.tasks, scheduled)
+	return
+}
+
+/*
+Tasks returns all registered tasks
+*/
+func (s *Scheduler) Tasks() []*ScheduledTask {
+	return s.tasks
+}
+
+/*
+Get returns registered task by name
+*/
+func (s *Scheduler) Get(name string) (*ScheduledTask, error) {
+	for _, task := range s.tasks {
+		if task.Name == name {
+			return task, nil
+		}
+	}
+	return nil, ErrTaskNotFound
+}
+
+/*
+Start runs scheduler in background
+*/
+func (s *Scheduler) Start(cfg Config) {
+	s.once.Do(func() {
+		go func() {
+			next := map[string]time.Time{}
+			for _, task := range s.tasks {
+				if task.Schedule != nil {
+					next[task.Name] = task.Schedule.Next(time.Now())
+				}
+			}
+
+			ticker := time.NewTicker(SCHEDULER_TICK)
+			defer ticker.Stop()
+
+			for now := range ticker.C {
+				for _, task := range s.tasks {
+					if task.Schedule == nil || now.Before(next[task.Name]) {
+						continue
+					}
+
+					slot := next[task.Name]
+					next[task.Name] = task.Schedule.Next(now)
+
+					go func(task *ScheduledTask, slot time.Time) {
+						if _, err := s.Run(cfg, task.Name, TASK_TRIGGER_SCHEDULE, &slot); err != nil && err != ErrTaskLocked {
+							cfg.Logger().Error("scheduled task failed",
+								zap.String("task", task.Name),
+								zap.String("error", err.Error()),
+							)
+						}
+					}(task, slot)
+				}
+			}
+		}()
+	})
+}
+
+/*
+Run runs task synchronously and returns its run record. When task is already running (in this or other instance)
+ErrTaskLocked is returned.
+*/
+func (s *Scheduler) Run(cfg Config, name, trigger string, slot *time.Time) (run *TaskRun, err error) {
+	var task *ScheduledTask
+	if task, run, err = s.Begin(cfg, name, trigger, slot); err != nil {
+		return
+	}
+
+	err = s.Finish(cfg, task, run)
+	return
+}
+
+/*
+Begin locks task and stores run record. Task itself is run by Finish, so callers can run it in background.
+*/
+func (s *Scheduler) Begin(cfg Config, name, trigger string, slot *time.Time) (task *ScheduledTask, run *TaskRun, err error) {
+	if task, err = s.Get(name); err != nil {
+		return
+	}
+
+	// same task never runs twice in single instance
+	s.mutex.Lock()
+	if s.running[name] {
+		s.mutex.Unlock()
+		return nil, nil, ErrTaskLocked
+	}
+	s.running[name] = true
+	s.mutex.Unlock()
+
+	if !cfg.Manager().Task().Acquire(name, s.owner, slot, s.lockTTL) {
+		s.done(name)
+		return nil, nil, ErrTaskLocked
+	}
+
+	run = &TaskRun{
+		Task:      name,
+		Trigger:   trigger,
+		Owner:     s.owner,
+		Status:    TASK_RUN_RUNNING,
+		StartedAt: time.Now(),
+	}
+
+	if err = cfg.DB().Create(run).Error; err != nil {
+		cfg.Manager().Task().Release(name, s.owner)
+		s.done(name)
+		return nil, nil, err
+	}
+
+	return
+}
+
+/*
+Finish runs task started by Begin, stores result and releases lock. Panic in task is recovered and stored as error.
+*/
+func (s *Scheduler) Finish(cfg Config, task *ScheduledTask, run *TaskRun) (err error) {
+	defer s.done(task.Name)
+	defer cfg.Manager().Task().Release(task.Name, s.owner)
+
+	err = s.execute(cfg, task)
+
+	finished := time.Now()
+	run.FinishedAt = &finished
+	run.Duration = int64(finished.Sub(run.StartedAt) / time.Millisecond)
+	run.Status = TASK_RUN_SUCCESS
+	if err != nil {
+		run.Status = TASK_RUN_FAILED
+		run.Error = StringTruncate(err.Error(), 512)
+	}
+
+	cfg.Metrics().Registry().TaskRuns.Inc(task.Name, run.Status)
+	cfg.Metrics().Registry().TaskDuration.Observe(finished.Sub(run.StartedAt).Seconds(), task.Name)
+
+	if errSave := cfg.DB().Save(run).Error; errSave != nil {
+		cfg.Logger().Error("cannot store task run",
+			zap.String("task", task.Name),
+			zap.String("error", errSave.Error()),
+		)
+	}
+
+	return
+}
+
+/*
+execute runs task and converts panic to error
+*/
+func (s *Scheduler) execute(cfg Config, task *ScheduledTask) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("task panicked: %v", r)
+		}
+	}()
+
+	return task.Task.Run(cfg)
+}
+
+/*
+done marks task as not running in this instance
+*/
+func (s *Scheduler) done(name string) {
+	s.mutex.Lock()
+	delete(s.running, name)
+	s.mutex.Unlock()
+}
@@ -0,0 +1,219 @@
+/*
+Search
+
+Search looks for packages by words of query in name, summary, keywords, classifiers and description of their latest
+release. Results are ordered by relevance: matches in name rank above matches in summary and keywords, which rank
+above classifiers and description, and package named exactly as query is always first.
+
+On postgres full text search is used, words are stemmed ("testing" finds "test") and word of query matches also
+beginning of word in document ("djan" finds "django"). Other databases use portable fallback, where every word of
+query must be contained in some of fields and relevance is sum of weights of fields that contain it.
+*/
+package core
+
+import (
+	"fmt"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/jinzhu/gorm"
+	"github.com/phonkee/go-paginator"
+)
+
+// weights of fields in portable search
+const (
+	searchWeightExactName   = 100
+	searchWeightName        = 8
+	searchWeightSummary     = 4
+	searchWeightKeywords    = 4
+	searchWeightClassifiers = 2
+	searchWeightDescription = 1
+)
+
+const (
+	// classifiers of package version joined to single text
+	searchClassifiersPostgres = "(SELECT string_agg(classifier.name, ' ') FROM classifier " +
+		"JOIN package_version_classifiers ON package_version_classifiers.classifier_id = classifier.id " +
+		"WHERE package_version_classifiers.package_version_id = package_version.id)"
+
+	// weighted document of package version for postgres full text search, %[1]v is text search configuration
+	searchDocumentPostgres = "(setweight(to_tsvector('%[1]v', translate(package.name, '-_.', '   ')), 'A') || " +
+		"setweight(to_tsvector('%[1]v', coalesce(package_version.summary, '') || ' ' || coalesce(package_version.keywords, '')), 'B') || " +
+		"setweight(to_tsvector('%[1]v', coalesce(" + searchClassifiersPostgres + ", '')), 'C') || " +
+		"setweight(to_tsvector('%[1]v', coalesce(package_version.description, '')), 'D'))"
+
+	// condition whether package version has classifier matching pattern
+	searchClassifierCondition = "EXISTS (SELECT 1 FROM classifier " +
+		"JOIN package_version_classifiers ON package_version_classifiers.classifier_id = classifier.id " +
+		"WHERE package_version_classifiers.package_version_id = package_version.id AND LOWER(classifier.name) LIKE ?)"
+
+	// canonical name of package (PEP 503) in sql
+	searchCanonicalName = "LOWER(REPLACE(REPLACE(package.name, '_', '-'), '.', '-'))"
+
+	// columns of search hit
+	searchColumns = "package.id AS package_id, package.name, package.index_id, package_index.name AS index_name, " +
+		"package_version.version, package_version.summary, package_version.created_at"
+)
+
+/*
+SearchHit is package found by search with its latest release
+*/
+type SearchHit struct {
+	PackageID uint      `json:"package_id"`
+	Name      string    `json:"name"`
+	IndexID   uint      `json:"index_id"`
+	IndexName string    `json:"index"`
+	Version   string    `json:"version"`
+	Summary   string    `json:"summary"`
+	CreatedAt time.Time `json:"created_at"`
+	Score     float64   `json:"score"`
+}
+
+/*
+SearchTerms returns lowercase words of search query (letters and digits), duplicate words are removed and only first
+SEARCH_MAX_TERMS words are used
+*/
+func SearchTerms(query string) (terms []string) {
+	seen := map[string]bool{}
+	for _, term := range strings.FieldsFunc(strings.ToLower(query), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}) {
+		if seen[term] {
+			continue
+		}
+		seen[term] = true
+		if terms = append(terms, term); len(terms) == SEARCH_MAX_TERMS {
+			break
+		}
+	}
+	return
+}
+
+/*
+PaginateSearchHits returns page of search hits and sets count of paginator
+*/
+func PaginateSearchHits(hits []SearchHit, p paginator.Paginator) []SearchHit {
+	p.Count(len(hits))
+
+	limit, offset := p.GetLimitOffset()
+	if offset > len(hits) {
+		offset = len(hits)
+	}
+	if limit = offset + limit; limit > len(hits) {
+		limit = len(hits)
+	}
+
+	return hits[offset:limit]
+}
+
+/*
+SearchManager searches packages of indexes
+*/
+type SearchManager struct {
+	DB *gorm.DB
+}
+
+/*
+Search returns packages of indexes (in lookup order as returned by IndexManager.ResolveIDs) that match query, most
+relevant first. When package with same name exists in multiple indexes, only package from first index is returned.
+*/
+func (s *SearchManager) Search(query string, ids []uint) (hits []SearchHit, err error) {
+	terms := SearchTerms(query)
+	if len(terms) == 0 {
+		err = ErrSearchQueryEmpty
+		return
+	}
+
+	queryset := s.latest(ids)
+	name := CanonicalPackageName(strings.TrimSpace(query))
+
+	switch s.DB.NewScope(nil).Dialect().GetName() {
+	case "postgres":
+		tsquery := strings.Join(terms, ":* & ") + ":*"
+		document := fmt.Sprintf(searchDocumentPostgres, SEARCH_TEXT_CONFIG)
+		queryset = queryset.
+			Select(fmt.Sprintf("%v, ts_rank(%v, to_tsquery('%v', ?)) + CASE WHEN %v = ? THEN 1 ELSE 0 END AS score",
+				searchColumns, document, SEARCH_TEXT_CONFIG, searchCanonicalName), tsquery, name).
+			Where(fmt.Sprintf("%v @@ to_tsquery('%v', ?)", document, SEARCH_TEXT_CONFIG), tsquery)
+	default:
+		score := []string{fmt.Sprintf("CASE WHEN %v = ? THEN %v ELSE 0 END", searchCanonicalName, searchWeightExactName)}
+		args := []interface{}{name}
+		for _, term := range terms {
+			like := "%" + term + "%"
+			queryset = queryset.Where("LOWER(package.name) LIKE ? OR LOWER(package_version.summary) LIKE ? OR "+
+				"LOWER(package_version.keywords) LIKE ? OR LOWER(package_version.description) LIKE ? OR "+
+				searchClassifierCondition, like, like, like, like, like)
+			score = append(score,
+				fmt.Sprintf("CASE WHEN LOWER(package.name) LIKE ? THEN %v ELSE 0 END", searchWeightName),
+				fmt.Sprintf("CASE WHEN LOWER(package_version.summary) LIKE ? THEN %v ELSE 0 END", searchWeightSummary),
+				fmt.Sprintf("CASE WHEN LOWER(package_version.keywords) LIKE ? THEN %v ELSE 0 END", searchWeightKeywords),
+				fmt.Sprintf("CASE WHEN %v THEN %v ELSE 0 END", searchClassifierCondition, searchWeightClassifiers),
+				fmt.Sprintf("CASE WHEN LOWER(package_version.description) LIKE ? THEN %v ELSE 0 END", searchWeightDescription),
+			)
+			args = append(args, like, like, like, like, like)
+		}
+		queryset = queryset.Select(searchColumns+", "+strings.Join(score, " + ")+" AS score", args...)
+	}
+
+	if err = queryset.Order("score DESC, package.name ASC").Scan(&hits).Error; err != nil {
+		return
+	}
+
+	hits = s.unshadowed(hits, ids)
+	return
+}
+
+/*
+List returns all packages of indexes with their latest releases ordered by name, packages shadowed by package with
+same name in earlier index are skipped.
+*/
+func (s *SearchManager) List(ids []uint) (hits []SearchHit, err error) {
+	if err = s.latest(ids).Select(searchColumns).Order("package.name ASC").Scan(&hits).Error; err != nil {
+		return
+	}
+
+	hits = s.unshadowed(hits, ids)
+	return
+}
+
+/*
+latest returns queryset of latest releases of packages in indexes
+*/
+func (s *SearchManager) latest(ids []uint) *gorm.DB {
+	if len(ids) == 0 {
+		ids = append(ids, 0)
+	}
+
+	queryset := s.DB.Table("package").
+		Joins("JOIN package_index ON package_index.id = package.index_id").
+		Joins("JOIN package_version ON package_version.package_id = package.id").
+		Where("package.index_id IN (?)", ids)
+	return FFLatestPackageVersions()(queryset)
+}
+
+/*
+unshadowed removes hits of packages that exist in earlier index (order of hits is kept)
+*/
+func (s *SearchManager) unshadowed(hits []SearchHit, ids []uint) []SearchHit {
+	position := map[uint]int{}
+	for i, id := range ids {
+		position[id] = i
+	}
+
+	first := map[string]int{}
+	for _, hit := range hits {
+		if current, ok := first[hit.Name]; !ok || position[hit.IndexID] < current {
+			first[hit.Name] = position[hit.IndexID]
+		}
+	}
+
+	result := make([]SearchHit, 0, len(hits))
+	for _, hit := range hits {
+		if position[hit.IndexID] == first[hit.Name] {
+			result = append(result, hit)
+			first[hit.Name] = -1
+		}
+	}
+	return result
+}
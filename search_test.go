@@ -0,0 +1,215 @@
+package core
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestSearchTerms(t *testing.T) {
+	tc := []struct {
+		query string
+		terms string
+	}{
+		{"", ""},
+		{"   ", ""},
+		{"django", "django"},
+		{"Django REST framework", "django,rest,framework"},
+		{"django-rest_framework.auth", "django,rest,framework,auth"},
+		{"django django DJANGO", "django"},
+		{"'; DROP TABLE package; --", "drop,table,package"},
+		{"100% %_ test*", "100,test"},
+		{"žluťoučký kůň", "žluťoučký,kůň"},
+		{"a b c d e f g h i j k l", "a,b,c,d,e,f,g,h,i,j"},
+		{"a a b b c c d d e e f f g g h h i i j j k", "a,b,c,d,e,f,g,h,i,j"},
+	}
+
+	for _, tt := range tc {
+		t.Run(tt.query, func(st *testing.T) {
+			if result := strings.Join(SearchTerms(tt.query), ","); result != tt.terms {
+				st.Errorf("SearchTerms(%q) returned %q, expected %q", tt.query, result, tt.terms)
+			}
+		})
+	}
+}
+
+func TestSearchManagerUnshadowed(t *testing.T) {
+	tc := []struct {
+		name   string
+		hits   []SearchHit
+		ids    []uint
+		result []SearchHit
+	}{
+		{
+			"empty",
+			[]SearchHit{},
+			[]uint{1, 2},
+			[]SearchHit{},
+		},
+		{
+			"distinct names",
+			[]SearchHit{{Name: "a", IndexID: 2}, {Name: "b", IndexID: 1}},
+			[]uint{1, 2},
+			[]SearchHit{{Name: "a", IndexID: 2}, {Name: "b", IndexID: 1}},
+		},
+		{
+			"shadowed by earlier index",
+			[]SearchHit{{Name: "a", IndexID: 2, Version: "2.0"}, {Name: "b", IndexID: 2}, {Name: "a", IndexID: 1, Version: "1.0"}},
+			[]uint{1, 2},
+			[]SearchHit{{Name: "b", IndexID: 2}, {Name: "a", IndexID: 1, Version: "1.0"}},
+		},
+		{
+			"lookup order instead of ids",
+			[]SearchHit{{Name: "a", IndexID: 1, Version: "1.0"}, {Name: "a", IndexID: 3, Version: "3.0"}, {Name: "a", IndexID: 2, Version: "2.0"}},
+			[]uint{3, 1, 2},
+			[]SearchHit{{Name: "a", IndexID: 3, Version: "3.0"}},
+		},
+		{
+			"same index",
+			[]SearchHit{{Name: "a", IndexID: 1, Version: "1.0"}, {Name: "a", IndexID: 1, Version: "2.0"}},
+			[]uint{1},
+			[]SearchHit{{Name: "a", IndexID: 1, Version: "1.0"}},
+		},
+	}
+
+	manager := &SearchManager{}
+	for _, tt := range tc {
+		t.Run(tt.name, func(st *testing.T) {
+			if result := manager.unshadowed(tt.hits, tt.ids); fmt.Sprint(result) != fmt.Sprint(tt.result) {
+				st.Errorf("unshadowed returned %v, expected %v", result, tt.result)
+			}
+		})
+	}
+}
+
+func TestPaginateSearchHits(t *testing.T) {
+	hits := make([]SearchHit, 45)
+	for i := range hits {
+		hits[i].PackageID = uint(i)
+	}
+
+	tc := []struct {
+		name   string
+		values url.Values
+		first  uint
+		length int
+	}{
+		{"default", url.Values{}, 0, 20},
+		{"second page", url.Values{"page": {"2"}}, 20, 20},
+		{"last page", url.Values{"page": {"3"}}, 40, 5},
+		{"after last page", url.Values{"page": {"10"}}, 0, 0},
+		{"limit", url.Values{"limit": {"30"}, "page": {"2"}}, 30, 15},
+	}
+
+	for _, tt := range tc {
+		t.Run(tt.name, func(st *testing.T) {
+			p := CommonPaginator(tt.values)
+			result := PaginateSearchHits(hits, p)
+
+			if len(result) != tt.length {
+				st.Fatalf("PaginateSearchHits returned %v hits, expected %v", len(result), tt.length)
+			}
+			if tt.length > 0 && result[0].PackageID != tt.first {
+				st.Errorf("PaginateSearchHits returned page starting at %v, expected %v", result[0].PackageID, tt.first)
+			}
+			if p.GetCount() != len(hits) {
+				st.Errorf("PaginateSearchHits set count %v", p.GetCount())
+			}
+		})
+	}
+
+	if result := PaginateSearchHits(nil, CommonPaginator(url.Values{})); len(result) != 0 {
+		t.Errorf("PaginateSearchHits without hits returned %v", result)
+	}
+}
+
+/*
+testSearchHandler answers index queries from graph and every search with single package of default index
+*/
+func testSearchHandler(graph testIndexes) func(query string, args []driver.Value) testDBResult {
+	return func(query string, args []driver.Value) testDBResult {
+		if strings.Contains(query, "ts_rank") {
+			return testDBResult{
+				Columns: []string{"package_id", "name", "index_id", "index_name", "version", "summary", "score"},
+				Rows:    [][]driver.Value{{int64(1), "secret", int64(1), DEFAULT_INDEX, "1.0", "private package", 1.0}},
+			}
+		}
+		return graph.handler(query, args)
+	}
+}
+
+func TestSearchServiceDefaultIndexACL(t *testing.T) {
+	graph := testIndexes{
+		indexes: []Index{{ID: 1, Name: DEFAULT_INDEX}},
+		acl:     []IndexACL{{IndexID: 1, UserID: 10, CanRead: true}},
+	}
+
+	tc := []struct {
+		name   string
+		public bool
+		user   User
+		found  int
+	}{
+		{"private without acl", false, User{ID: 2, CanList: true}, 0},
+		{"private with acl", false, User{ID: 10, CanList: true}, 1},
+		{"private admin", false, User{ID: 1, IsAdmin: true}, 1},
+		{"anonymous", false, User{}, 0},
+		{"public", true, User{ID: 2, CanList: true}, 1},
+	}
+
+	for _, tt := range tc {
+		t.Run(tt.name, func(st *testing.T) {
+			graph.indexes[0].Public = tt.public
+			cfg, fake := newTestConfig(st, testSearchHandler(graph))
+
+			result, err := (&SearchService{Config: cfg, User: tt.user}).search("secret")
+			if err != nil {
+				st.Fatalf("search returned error: %v", err)
+			}
+			if len(result) != tt.found {
+				st.Errorf("search returned %v results, expected %v", len(result), tt.found)
+			}
+			if tt.found == 0 && len(fake.Queries("ts_rank")) > 0 {
+				st.Errorf("search queried packages of index user cannot read")
+			}
+
+			// xml rpc handler searches as user of request
+			body := `<?xml version="1.0"?><methodCall><methodName>search</methodName>` +
+				`<params><param><value><string>secret</string></value></param></params></methodCall>`
+			r := httptest.NewRequest("POST", "/RPC2", strings.NewReader(body))
+			r = r.WithContext(ContextSetTokenUser(r.Context(), tt.user))
+			w := httptest.NewRecorder()
+			NewSearchHandler(cfg).ServeHTTP(w, r)
+
+			if found := strings.Contains(w.Body.String(), "private package"); found != (tt.found > 0) {
+				st.Errorf("xml rpc search returned %v", w.Body.String())
+			}
+		})
+	}
+}
+
+func TestSearchFrontendsACL(t *testing.T) {
+	graph := testIndexes{
+		indexes: []Index{{ID: 1, Name: DEFAULT_INDEX}},
+		acl:     []IndexACL{{IndexID: 1, UserID: 10, CanRead: true}},
+	}
+
+	for _, user := range []User{{ID: 2, CanList: true}, {ID: 10, CanList: true}, {ID: 1, IsAdmin: true}, {}} {
+		cfg, _ := newTestConfig(t, testSearchHandler(graph))
+
+		result, _ := (&SearchService{Config: cfg, User: user}).search("secret")
+
+		r := httptest.NewRequest("GET", "/api/search?q=secret", nil)
+		r = r.WithContext(ContextSetTokenUser(r.Context(), user))
+		w := httptest.NewRecorder()
+		(&SearchAPIView{Config: cfg}).GET(w, r).Write(w, r)
+
+		if (len(result) > 0) != (w.Code == http.StatusOK) {
+			t.Errorf("search of user %v found %v packages over xml rpc, api responded %v", user.ID, len(result), w.Code)
+		}
+	}
+}
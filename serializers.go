@@ -2,6 +2,7 @@ package core
 
 import (
 	"errors"
+	"fmt"
 	"strings"
 
 	"github.com/asaskevich/govalidator"
@@ -170,6 +171,10 @@ type UserUpdateSerializer struct {
 	CanCreate   bool   `json:"can_create"`
 	CanUpdate   bool   `json:"can_update"`
 
+	// admin links oidc login to account explicitly
+	OIDCIssuer  string `json:"oidc_issuer"`
+	OIDCSubject string `json:"oidc_subject"`
+
 	// mark that user is changing password
 	passwordChange bool
 }
@@ -207,6 +212,18 @@ func (u *UserUpdateSerializer) Validate(cfg Config) (result ValidationResult) {
 		}
 	}
 
+	// validate oidc link
+	u.OIDCIssuer = strings.TrimSpace(u.OIDCIssuer)
+	u.OIDCSubject = strings.TrimSpace(u.OIDCSubject)
+	if (u.OIDCIssuer == "") != (u.OIDCSubject == "") {
+		result.AddFieldError("oidc_subject", ErrOIDCLinkIncomplete)
+	} else if u.OIDCSubject != "" {
+		user := User{}
+		if !cfg.DB().First(&user, "oidc_issuer = ? AND oidc_subject = ? AND id != ?", u.OIDCIssuer, u.OIDCSubject, u.ID).RecordNotFound() {
+			result.AddFieldError("oidc_subject", ErrOIDCAlreadyLinked)
+		}
+	}
+
 	// if any password has been given perform validation
 	if u.Password != "" || u.Password2 != "" {
 		// mark that serializer changes password
@@ -243,6 +260,15 @@ func (u *UserUpdateSerializer) UpdateUser(cfg Config, user *User) {
 	user.CanDownload = u.CanDownload
 	user.CanCreate = u.CanCreate
 	user.CanUpdate = u.CanUpdate
+	user.OIDCIssuer = u.OIDCIssuer
+	user.OIDCSubject = u.OIDCSubject
+}
+
+/*
+RevokesSessions returns whether update should sign out all user sessions (password change or deactivation)
+*/
+func (u *UserUpdateSerializer) RevokesSessions() bool {
+	return u.passwordChange || !u.IsActive
 }
 
 /*
@@ -316,3 +342,170 @@ func (u *UserChangePasswordSerializer) ChangePassword(config Config, user *User)
 	config.Manager().User().SetPassword(user, u.Password)
 	return
 }
+
+/*
+WebhookSerializer creates and updates webhooks
+*/
+type WebhookSerializer struct {
+	Name      string   `json:"name"`
+	URL       string   `json:"url"`
+	Secret    *string  `json:"secret"`
+	Events    []string `json:"events"`
+	PackageID *uint    `json:"package_id"`
+	IsActive  bool     `json:"is_active"`
+}
+
+/*
+Validate validates webhook data, blank list of events means all events
+*/
+func (w *WebhookSerializer) Validate(cfg Config) (result ValidationResult) {
+	result = NewValidationResult()
+
+	w.Name = strings.TrimSpace(w.Name)
+	w.URL = strings.TrimSpace(w.URL)
+
+	if !govalidator.IsURL(w.URL) || !(strings.HasPrefix(w.URL, "http://") || strings.HasPrefix(w.URL, "https://")) {
+		result.AddFieldError("url", ErrWebhookInvalidURL)
+	}
+
+	events := []string{}
+	for _, event := range w.Events {
+		if event = strings.TrimSpace(event); event == "" {
+			continue
+		}
+		if !StringListContains(AVAILABLE_WEBHOOK_EVENTS, event) {
+			result.AddFieldError("events", fmt.Errorf("%v: %v", ErrWebhookUnknownEvent, event))
+			continue
+		}
+		events = append(events, event)
+	}
+	w.Events = events
+
+	// zero package id means global webhook
+	if w.PackageID != nil && *w.PackageID == 0 {
+		w.PackageID = nil
+	}
+
+	if w.PackageID != nil {
+		pack := Package{}
+		if cfg.DB().First(&pack, "id = ?", *w.PackageID).RecordNotFound() {
+			result.AddFieldError("package_id", ErrPackageNotFound)
+		}
+	}
+
+	return
+}
+
+/*
+UpdateWebhook updates webhook with serializer data, secret is changed only when given
+*/
+func (w *WebhookSerializer) UpdateWebhook(webhook *Webhook) {
+	webhook.Name = w.Name
+	webhook.URL = w.URL
+	webhook.EventList = w.Events
+	webhook.PackageID = w.PackageID
+	webhook.IsActive = w.IsActive
+
+	if w.Secret != nil {
+		webhook.Secret = strings.TrimSpace(*w.Secret)
+	}
+}
+
+/*
+IndexSerializer creates and updates indexes, bases are names of inherited indexes in lookup order
+*/
+type IndexSerializer struct {
+	Name         string   `json:"name"`
+	Description  string   `json:"description"`
+	Public       bool     `json:"public"`
+	OpenUpload   bool     `json:"open_upload"`
+	UploadPolicy string   `json:"upload_policy"`
+	Bases        []string `json:"bases"`
+}
+
+/*
+Validate validates index data, ID is id of updated index (0 for new index)
+*/
+func (i *IndexSerializer) Validate(cfg Config, ID uint) (result ValidationResult) {
+	result = NewValidationResult()
+
+	i.Description = strings.TrimSpace(i.Description)
+
+	if ValidateIndexName("name", &i.Name, result) {
+		count := 0
+		cfg.DB().Model(Index{}).Where("id != ? AND name = ?", ID, i.Name).Count(&count)
+		if count > 0 {
+			result.AddFieldError("name", ErrIndexAlreadyExists)
+		}
+	}
+
+	ValidateUploadPolicy("upload_policy", &i.UploadPolicy, result)
+
+	bases := []string{}
+	for _, name := range i.Bases {
+		if name = strings.TrimSpace(name); name == "" || StringListContains(bases, name) {
+			continue
+		}
+		if name == i.Name {
+			result.AddFieldError("bases", ErrIndexCycle)
+			continue
+		}
+		if _, err := cfg.Manager().Index().GetByName(name); err != nil {
+			result.AddFieldError("bases", fmt.Errorf("%v: %v", err, name))
+			continue
+		}
+		bases = append(bases, name)
+	}
+	i.Bases = bases
+
+	return
+}
+
+/*
+UpdateIndex updates index with serializer data, bases are set by IndexManager.SetBases
+*/
+func (i *IndexSerializer) UpdateIndex(index *Index) {
+	index.Name = i.Name
+	index.Description = i.Description
+	index.Public = i.Public
+	index.OpenUpload = i.OpenUpload
+	index.UploadPolicy = i.UploadPolicy
+}
+
+/*
+IndexACLSerializer grants user access to index
+*/
+type IndexACLSerializer struct {
+	UserID    uint `json:"user_id"`
+	CanRead   bool `json:"can_read"`
+	CanUpload bool `json:"can_upload"`
+}
+
+/*
+Validate validates that user exists
+*/
+func (i *IndexACLSerializer) Validate(cfg Config) (result ValidationResult) {
+	result = NewValidationResult()
+
+	if cfg.DB().First(&User{}, "id = ?", i.UserID).RecordNotFound() {
+		result.AddFieldError("user_id", ErrUserNotFound)
+	}
+
+	return
+}
+
+/*
+PackageUpdateSerializer updates package settings, blank upload policy means policy of index
+*/
+type PackageUpdateSerializer struct {
+	UploadPolicy string `json:"upload_policy"`
+}
+
+/*
+Validate validates upload policy
+*/
+func (p *PackageUpdateSerializer) Validate(cfg Config) (result ValidationResult) {
+	result = NewValidationResult()
+	ValidateUploadPolicy("upload_policy", &p.UploadPolicy, result)
+	return
+}
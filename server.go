@@ -1,7 +1,11 @@
 package core
 
 import (
+	"context"
 	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
 
 	"github.com/gorilla/mux"
 	"github.com/justinas/alice"
@@ -64,13 +68,55 @@ func (s *server) Router() *mux.Router {
 }
 
 /*
-ListenAndServe starts http server and listens to requests
+ListenAndServe starts http server and listens to requests. On interrupt server finishes running requests and flushes
+buffered download stats.
 */
 func (s *server) ListenAndServe() (err error) {
 	s.Config().Logger().Info("Attempting to listen on: 0.0.0.0:9900")
 
 	final := s.chain.Then(s.Router())
 
-	err = http.ListenAndServe("0.0.0.0:9900", final)
+	// start sending webhook deliveries in background
+	s.Config().Webhooks().Dispatcher().Start(s.Config())
+
+	// send email notifications in background
+	s.Config().Notifications().Notifier().Start(s.Config())
+
+	// run scheduled tasks
+	if s.Config().Scheduler().Enabled() {
+		s.Config().Scheduler().Scheduler().Start(s.Config())
+	}
+
+	// write download stats in background
+	collector := s.Config().DownloadStats().Collector()
+	collector.Start(s.Config())
+	defer collector.Stop(s.Config())
+
+	srv := &http.Server{
+		Addr:    "0.0.0.0:9900",
+		Handler: final,
+	}
+
+	// closed when running requests are finished
+	shutdown := make(chan struct{})
+
+	go func() {
+		defer close(shutdown)
+
+		signals := make(chan os.Signal, 1)
+		signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+		<-signals
+
+		s.Config().Logger().Info("Shutting down server")
+
+		ctx, cancel := context.WithTimeout(context.Background(), SHUTDOWN_TIMEOUT)
+		defer cancel()
+		srv.Shutdown(ctx)
+	}()
+
+	if err = srv.ListenAndServe(); err == http.ErrServerClosed {
+		<-shutdown
+		err = nil
+	}
 	return
 }
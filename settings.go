@@ -1,6 +1,9 @@
 package core
 
-import "regexp"
+import (
+	"regexp"
+	"time"
+)
 
 const (
 	VERSION = "0.5.3"
@@ -21,22 +24,388 @@ const (
                                                      v ` + VERSION
 )
 
+var (
+	// COMMIT is git commit of build, set by linker (-X github.com/phonkee/gopypi.COMMIT=...)
+	COMMIT = ""
+)
+
 var (
 	AVAILABLE_DB_DRIVERS = []string{"postgres", "mysql"}
 	DEFAULT_DB_DRIVER    = "postgres"
 )
 
+// authentication backends
+const (
+	AUTH_BACKEND_LOCAL = "local"
+	AUTH_BACKEND_LDAP  = "ldap"
+)
+
+var (
+	AVAILABLE_AUTH_BACKENDS = []string{AUTH_BACKEND_LOCAL, AUTH_BACKEND_LDAP}
+)
+
+// user permissions that can be granted by external authentication backends
+const (
+	USER_PERMISSION_ADMIN    = "admin"
+	USER_PERMISSION_LIST     = "list"
+	USER_PERMISSION_DOWNLOAD = "download"
+	USER_PERMISSION_CREATE   = "create"
+	USER_PERMISSION_UPDATE   = "update"
+)
+
+var (
+	AVAILABLE_USER_PERMISSIONS = []string{
+		USER_PERMISSION_ADMIN,
+		USER_PERMISSION_LIST,
+		USER_PERMISSION_DOWNLOAD,
+		USER_PERMISSION_CREATE,
+		USER_PERMISSION_UPDATE,
+	}
+)
+
 // token related constants
 const (
 	TOKEN_HEADER_NAME = "Authorization"
 	TOKEN_ISSUER      = "gopypi"
-	TOKEN_EXPIRATION  = 24 * 3600
+	TOKEN_EXPIRATION  = 15 * 60
+
+	// refresh token (session) expiration
+	TOKEN_REFRESH_EXPIRATION = 30 * 24 * 3600
+	TOKEN_REFRESH_BYTES      = 32
+	SESSION_KEY_BYTES        = 16
+)
+
+// login throttling defaults (durations in seconds)
+const (
+	THROTTLE_MAX_FAILURES     = 5
+	THROTTLE_IP_MAX_FAILURES  = 20
+	THROTTLE_LOCKOUT          = 60
+	THROTTLE_MAX_LOCKOUT      = 3600
+	THROTTLE_WINDOW           = 15 * 60
+	THROTTLE_CREDENTIAL_CACHE = 60
+)
+
+// OpenID Connect constants
+const (
+	OIDC_COOKIE_NAME  = "gopypi_oidc"
+	OIDC_STATE_BYTES  = 16
+	OIDC_HTTP_TIMEOUT = 10 * time.Second
+	OIDC_STATE_MAXAGE = 600
+)
+
+// audit log sources
+const (
+	AUDIT_SOURCE_HTTP = "http"
+	AUDIT_SOURCE_CLI  = "cli"
+
+	// number of entries fetched from database at once during export
+	AUDIT_EXPORT_BATCH = 500
+)
+
+// audit log actions
+const (
+	AUDIT_ACTION_LOGIN        = "auth.login"
+	AUDIT_ACTION_LOGIN_FAILED = "auth.login_failed"
+	AUDIT_ACTION_UNLOCK       = "auth.unlock"
+
+	AUDIT_ACTION_SESSION_REVOKE     = "session.revoke"
+	AUDIT_ACTION_SESSION_REVOKE_ALL = "session.revoke_all"
+	AUDIT_ACTION_SESSION_CLEANUP    = "session.cleanup"
+
+	AUDIT_ACTION_USER_CREATE   = "user.create"
+	AUDIT_ACTION_USER_UPDATE   = "user.update"
+	AUDIT_ACTION_USER_PASSWORD = "user.password_change"
+	AUDIT_ACTION_USER_IMPORT   = "user.import"
+
+	AUDIT_ACTION_FEATURE_UPDATE = "feature.update"
+	AUDIT_ACTION_LICENSE_UPDATE = "license.update"
+
+	AUDIT_ACTION_WEBHOOK_CREATE    = "webhook.create"
+	AUDIT_ACTION_WEBHOOK_UPDATE    = "webhook.update"
+	AUDIT_ACTION_WEBHOOK_DELETE    = "webhook.delete"
+	AUDIT_ACTION_WEBHOOK_REDELIVER = "webhook.redeliver"
+
+	AUDIT_ACTION_INDEX_CREATE = "index.create"
+	AUDIT_ACTION_INDEX_UPDATE = "index.update"
+	AUDIT_ACTION_INDEX_DELETE = "index.delete"
+
+	AUDIT_ACTION_PACKAGE_CREATE         = "package.create"
+	AUDIT_ACTION_VERSION_CREATE         = "package.version_create"
+	AUDIT_ACTION_VERSION_YANK           = "package.version_yank"
+	AUDIT_ACTION_VERSION_UNYANK         = "package.version_unyank"
+	AUDIT_ACTION_VERSION_DELETE         = "package.version_delete"
+	AUDIT_ACTION_VERSION_PROMOTE        = "package.version_promote"
+	AUDIT_ACTION_FILE_UPLOAD            = "package.file_upload"
+	AUDIT_ACTION_FILE_REPLACE           = "package.file_replace"
+	AUDIT_ACTION_PACKAGE_UPDATE         = "package.update"
+	AUDIT_ACTION_MAINTAINER_ADD         = "package.maintainer_add"
+	AUDIT_ACTION_MAINTAINER_REMOVE      = "package.maintainer_remove"
+	AUDIT_ACTION_DOWNLOAD_STATS_CLEANUP = "stats.cleanup"
+	AUDIT_ACTION_MIGRATE                = "system.migrate"
+	AUDIT_ACTION_TASK_RUN               = "system.task_run"
+)
+
+// audit log target types
+const (
+	AUDIT_TARGET_USER    = "user"
+	AUDIT_TARGET_SESSION = "session"
+	AUDIT_TARGET_FEATURE = "feature"
+	AUDIT_TARGET_LICENSE = "license"
+	AUDIT_TARGET_PACKAGE = "package"
+	AUDIT_TARGET_VERSION = "package_version"
+	AUDIT_TARGET_FILE    = "package_version_file"
+	AUDIT_TARGET_LOCKOUT = "lockout"
+	AUDIT_TARGET_SYSTEM  = "system"
+	AUDIT_TARGET_WEBHOOK = "webhook"
+	AUDIT_TARGET_TASK    = "task"
+	AUDIT_TARGET_INDEX   = "index"
+)
+
+// Index constants
+const (
+	// index of packages uploaded to / and listed in /simple (all packages created before indexes were added)
+	DEFAULT_INDEX = "default"
+
+	// regular expression of index name ("stable", "team-a/dev"), used also in routes
+	INDEX_NAME_PATTERN = `[a-z0-9][a-z0-9._-]*(?:/[a-z0-9][a-z0-9._-]*)?`
+
+	INDEX_NAME_MAX_LENGTH = 100
+)
+
+var (
+	indexNameRegexp = regexp.MustCompile("^" + INDEX_NAME_PATTERN + "$")
+)
+
+// Upload policies decide whether uploaded file can be replaced by upload with the same filename
+const (
+	// uploaded files cannot be replaced, filenames cannot be reused even after files were deleted (as on PyPI)
+	UPLOAD_POLICY_IMMUTABLE = "immutable"
+
+	// files of dev versions (e.g. 1.0.dev3) can be replaced, other versions are immutable
+	UPLOAD_POLICY_OVERWRITE_DEV = "overwrite_dev"
+
+	// files can be always replaced
+	UPLOAD_POLICY_OVERWRITE = "overwrite"
+)
+
+var (
+	AVAILABLE_UPLOAD_POLICIES = []string{
+		UPLOAD_POLICY_IMMUTABLE,
+		UPLOAD_POLICY_OVERWRITE_DEV,
+		UPLOAD_POLICY_OVERWRITE,
+	}
+
+	// PEP 440 dev release segment ("1.0.dev1", "1.0a1.dev", "1.0-dev2+local")
+	devVersionRegexp = regexp.MustCompile(`(?i)[0-9a-z][-_.]?dev[-_.]?[0-9]*(\+[a-z0-9._]*)?$`)
+)
+
+// package types of distribution files (as in "filetype" field of upload form)
+const (
+	PACKAGE_TYPE_SDIST   = "sdist"
+	PACKAGE_TYPE_WHEEL   = "bdist_wheel"
+	PACKAGE_TYPE_EGG     = "bdist_egg"
+	PACKAGE_TYPE_WININST = "bdist_wininst"
+	PACKAGE_TYPE_MSI     = "bdist_msi"
+)
+
+// description content types (PEP 566) and formats they are rendered by
+const (
+	DESCRIPTION_CONTENT_TYPE_MARKDOWN = "text/markdown"
+	DESCRIPTION_CONTENT_TYPE_RST      = "text/x-rst"
+	DESCRIPTION_CONTENT_TYPE_PLAIN    = "text/plain"
+
+	DESCRIPTION_FORMAT_MARKDOWN = "markdown"
+	DESCRIPTION_FORMAT_RST      = "rst"
+	DESCRIPTION_FORMAT_PLAIN    = "plain"
+)
+
+// metadata files (METADATA, PKG-INFO) bigger than this are not read
+const METADATA_MAX_SIZE = 1 << 20
+
+// search settings, postgres full text search uses SEARCH_TEXT_CONFIG configuration (stemming, stop words)
+const (
+	SEARCH_MAX_TERMS   = 10
+	SEARCH_TEXT_CONFIG = "english"
+)
+
+// file history actions
+const (
+	FILE_HISTORY_UPLOAD  = "upload"
+	FILE_HISTORY_REPLACE = "replace"
+	FILE_HISTORY_DELETE  = "delete"
+	FILE_HISTORY_PROMOTE = "promote"
+	FILE_HISTORY_MOVE    = "move"
+)
+
+// webhook events
+const (
+	WEBHOOK_EVENT_PING              = "ping"
+	WEBHOOK_EVENT_FILE_UPLOAD       = "file.upload"
+	WEBHOOK_EVENT_VERSION_CREATE    = "version.create"
+	WEBHOOK_EVENT_VERSION_YANK      = "version.yank"
+	WEBHOOK_EVENT_VERSION_UNYANK    = "version.unyank"
+	WEBHOOK_EVENT_VERSION_DELETE    = "version.delete"
+	WEBHOOK_EVENT_MAINTAINER_ADD    = "maintainer.add"
+	WEBHOOK_EVENT_MAINTAINER_REMOVE = "maintainer.remove"
+)
+
+var (
+	// events that webhook can subscribe to (ping is always delivered)
+	AVAILABLE_WEBHOOK_EVENTS = []string{
+		WEBHOOK_EVENT_FILE_UPLOAD,
+		WEBHOOK_EVENT_VERSION_CREATE,
+		WEBHOOK_EVENT_VERSION_YANK,
+		WEBHOOK_EVENT_VERSION_UNYANK,
+		WEBHOOK_EVENT_VERSION_DELETE,
+		WEBHOOK_EVENT_MAINTAINER_ADD,
+		WEBHOOK_EVENT_MAINTAINER_REMOVE,
+	}
+)
+
+// webhook delivery constants (durations in seconds)
+const (
+	WEBHOOK_DELIVERY_PENDING = "pending"
+	WEBHOOK_DELIVERY_SUCCESS = "success"
+	WEBHOOK_DELIVERY_FAILED  = "failed"
+
+	WEBHOOK_HEADER_EVENT     = "X-Gopypi-Event"
+	WEBHOOK_HEADER_DELIVERY  = "X-Gopypi-Delivery"
+	WEBHOOK_HEADER_SIGNATURE = "X-Gopypi-Signature"
+
+	WEBHOOK_TIMEOUT         = 10
+	WEBHOOK_MAX_ATTEMPTS    = 8
+	WEBHOOK_RETRY_DELAY     = 30
+	WEBHOOK_MAX_RETRY_DELAY = 3600
+	WEBHOOK_POLL_INTERVAL   = 10
+
+	// number of deliveries processed in single dispatcher run
+	WEBHOOK_DISPATCH_BATCH = 50
+
+	// maximum length of stored response body
+	WEBHOOK_RESPONSE_BODY_LENGTH = 1024
+)
+
+// notification kinds, users can turn every kind on or off
+const (
+	NOTIFICATION_NEW_VERSION = "new_version"
+	NOTIFICATION_MAINTAINER  = "maintainer"
+	NOTIFICATION_PASSWORD    = "password"
+)
+
+// smtp tls modes
+const (
+	SMTP_TLS_NONE     = "none"
+	SMTP_TLS_STARTTLS = "starttls"
+	SMTP_TLS_IMPLICIT = "tls"
+)
+
+var (
+	AVAILABLE_SMTP_TLS_MODES = []string{SMTP_TLS_NONE, SMTP_TLS_STARTTLS, SMTP_TLS_IMPLICIT}
+)
+
+// notification defaults
+const (
+	NOTIFICATIONS_QUEUE_SIZE = 100
+	SMTP_PORT                = 25
+	SMTP_TIMEOUT             = 30 * time.Second
+)
+
+// scheduled tasks
+const (
+	TASK_CLEANUP_DOWNLOAD_STATS = "cleanup_download_stats"
+	TASK_CLEANUP_SESSIONS       = "cleanup_sessions"
+	TASK_STORAGE_GC             = "storage_gc"
+	TASK_METADATA_BACKFILL      = "metadata_backfill"
+)
+
+// task run statuses and triggers
+const (
+	TASK_RUN_RUNNING = "running"
+	TASK_RUN_SUCCESS = "success"
+	TASK_RUN_FAILED  = "failed"
+
+	TASK_TRIGGER_SCHEDULE = "schedule"
+	TASK_TRIGGER_MANUAL   = "manual"
+	TASK_TRIGGER_CLI      = "cli"
+)
+
+// scheduler defaults
+const (
+	// lock is held by instance running task, task that runs longer can be started by another instance (seconds)
+	SCHEDULER_LOCK_TTL = 3600
+
+	// how often scheduler checks for due tasks
+	SCHEDULER_TICK = 15 * time.Second
+
+	// files younger than this are never removed by storage gc, upload can be in progress
+	STORAGE_GC_GRACE = time.Hour
+)
+
+// download stats breakdown dimensions
+const (
+	STATS_BREAKDOWN_VERSION   = "version"
+	STATS_BREAKDOWN_FILE      = "file"
+	STATS_BREAKDOWN_USER      = "user"
+	STATS_BREAKDOWN_INSTALLER = "installer"
+	STATS_BREAKDOWN_PYTHON    = "python"
+	STATS_BREAKDOWN_SYSTEM    = "system"
+
+	// value used when installer cannot be recognized
+	STATS_UNKNOWN = "unknown"
+
+	// name of user in breakdown of downloads without authentication
+	STATS_ANONYMOUS = "anonymous"
+)
+
+var (
+	AVAILABLE_STATS_BREAKDOWNS = []string{
+		STATS_BREAKDOWN_VERSION, STATS_BREAKDOWN_FILE, STATS_BREAKDOWN_USER,
+		STATS_BREAKDOWN_INSTALLER, STATS_BREAKDOWN_PYTHON, STATS_BREAKDOWN_SYSTEM,
+	}
+)
+
+// download stats collector defaults
+const (
+	// how many days daily stats and detailed stats (per file, user and installer) are kept
+	DOWNLOAD_STATS_ARCHIVE_DAILY   = 30
+	DOWNLOAD_STATS_ARCHIVE_DETAILS = 90
+
+	// how often buffered downloads are written to database (seconds)
+	DOWNLOAD_STATS_FLUSH_INTERVAL = 10
+
+	// number of buffered (version, day) pairs that triggers flush before interval
+	DOWNLOAD_STATS_MAX_PENDING = 1000
+
+	// number of rows inserted by single statement
+	DOWNLOAD_STATS_BATCH = 500
+)
+
+// how long server waits for running requests on shutdown
+const (
+	SHUTDOWN_TIMEOUT = 30 * time.Second
 )
 
 // Context constants
 const (
 	CONTEXT_TOKEN_USER = iota + 1000
 	CONTEXT_ROUTE_NAME
+	CONTEXT_TOKEN_SESSION
+	CONTEXT_REQUEST_INFO
+)
+
+// Logging constants
+const (
+	LOG_FORMAT_TEXT = "text"
+	LOG_FORMAT_JSON = "json"
+
+	LOG_OUTPUT_STDOUT = "stdout"
+	LOG_OUTPUT_STDERR = "stderr"
+
+	// header with request id, incoming value is used when it's valid
+	REQUEST_ID_HEADER = "X-Request-ID"
+
+	// maximum length of incoming request id
+	REQUEST_ID_MAX_LENGTH = 128
 )
 
 // confgen constants
@@ -62,6 +431,41 @@ const (
 	PASSWORD_SALT_BYTES = 32
 	PASSWORD_HASH_BYTES = 128
 	PASSWORD_ITERATIONS = 16384
+
+	// argon2id parameters (memory in KiB)
+	PASSWORD_ARGON2_TIME       = 3
+	PASSWORD_ARGON2_MEMORY     = 64 * 1024
+	PASSWORD_ARGON2_THREADS    = 2
+	PASSWORD_ARGON2_SALT_BYTES = 16
+	PASSWORD_ARGON2_HASH_BYTES = 32
+
+	PASSWORD_BCRYPT_COST       = 12
+	PASSWORD_PBKDF2_ITERATIONS = 390000
+
+	// maximum parameters of verified hashes, hash with higher cost is rejected so crafted (or imported) hash cannot
+	// exhaust memory or cpu
+	PASSWORD_MAX_ITERATIONS        = 4 * PASSWORD_ITERATIONS
+	PASSWORD_ARGON2_MAX_TIME       = 4 * PASSWORD_ARGON2_TIME
+	PASSWORD_ARGON2_MAX_MEMORY     = 4 * PASSWORD_ARGON2_MEMORY
+	PASSWORD_ARGON2_MAX_THREADS    = 4 * PASSWORD_ARGON2_THREADS
+	PASSWORD_ARGON2_MAX_HASH_BYTES = 4 * PASSWORD_ARGON2_HASH_BYTES
+	PASSWORD_BCRYPT_MAX_COST       = PASSWORD_BCRYPT_COST + 2
+	PASSWORD_PBKDF2_MAX_ITERATIONS = 4 * PASSWORD_PBKDF2_ITERATIONS
+
+	PASSWORD_HASHER_ARGON2ID      = "argon2id"
+	PASSWORD_HASHER_SCRYPT        = "scrypt"
+	PASSWORD_HASHER_BCRYPT        = "bcrypt"
+	PASSWORD_HASHER_PBKDF2_SHA256 = "pbkdf2_sha256"
+)
+
+var (
+	// hashers that can be used to hash new passwords
+	AVAILABLE_PASSWORD_HASHERS = []string{
+		PASSWORD_HASHER_ARGON2ID,
+		PASSWORD_HASHER_SCRYPT,
+		PASSWORD_HASHER_BCRYPT,
+		PASSWORD_HASHER_PBKDF2_SHA256,
+	}
 )
 
 var (
@@ -1,6 +1,10 @@
 package core
 
-import "time"
+import (
+	"encoding/json"
+	"strings"
+	"time"
+)
 
 type StatsAggregation int
 
@@ -8,6 +12,7 @@ const (
 	STATS_DOWNLOAD_WEEKLY StatsAggregation = iota + 1
 	STATS_DOWNLOAD_MONTHLY
 	STATS_DOWNLOAD_ALL
+	STATS_DOWNLOAD_DAILY
 )
 
 type StatsDownloadItem struct {
@@ -16,3 +21,63 @@ type StatsDownloadItem struct {
 	Downloads        int             `json:"downloads"`
 	CreatedAt        time.Time       `json:"created_at"`
 }
+
+/*
+StatsBreakdownItem is download count for single value of breakdown dimension (e.g. installer "pip")
+*/
+type StatsBreakdownItem struct {
+	ID        uint   `json:"id,omitempty"`
+	Name      string `json:"name"`
+	Downloads int    `json:"downloads"`
+}
+
+/*
+StatsInstaller is information about downloading client parsed from user agent
+*/
+type StatsInstaller struct {
+	Installer string
+	Python    string
+	System    string
+}
+
+/*
+ParseStatsInstaller parses user agent of pip (and compatible installers) that sends json with environment
+information after installer name:
+
+	pip/23.0 {"installer":{"name":"pip","version":"23.0"},"python":"3.11.2","system":{"name":"Linux"},...}
+
+For other clients product name from user agent is used as installer. Python version is shortened to major.minor.
+*/
+func ParseStatsInstaller(userAgent string) (result StatsInstaller) {
+	result.Installer = STATS_UNKNOWN
+
+	if index := strings.Index(userAgent, "{"); index != -1 {
+		data := struct {
+			Installer struct {
+				Name string `json:"name"`
+			} `json:"installer"`
+			Python string `json:"python"`
+			System struct {
+				Name string `json:"name"`
+			} `json:"system"`
+		}{}
+
+		if json.Unmarshal([]byte(userAgent[index:]), &data) == nil {
+			if data.Installer.Name != "" {
+				result.Installer = data.Installer.Name
+			}
+			if parts := strings.SplitN(data.Python, ".", 3); len(parts) >= 2 {
+				result.Python = parts[0] + "." + parts[1]
+			}
+			result.System = data.System.Name
+		}
+	} else if product := strings.SplitN(strings.TrimSpace(userAgent), "/", 2)[0]; product != "" {
+		result.Installer = strings.Fields(product)[0]
+	}
+
+	result.Installer = StringTruncate(strings.ToLower(result.Installer), 32)
+	result.Python = StringTruncate(result.Python, 16)
+	result.System = StringTruncate(result.System, 32)
+
+	return
+}
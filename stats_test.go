@@ -0,0 +1,35 @@
+package core
+
+import "testing"
+
+func TestParseStatsInstaller(t *testing.T) {
+	tc := []struct {
+		userAgent string
+		expected  StatsInstaller
+	}{
+		{
+			`pip/23.0 {"installer":{"name":"pip","version":"23.0"},"python":"3.11.2","system":{"name":"Linux"}}`,
+			StatsInstaller{"pip", "3.11", "Linux"},
+		},
+		{
+			`pip/9.0.1 {"installer":{"name":"pip","version":"9.0.1"},"python":"2.7","system":{"name":"Darwin"}}`,
+			StatsInstaller{"pip", "2.7", "Darwin"},
+		},
+		{
+			`uv/0.1.0 {"installer":{"name":"uv"},"python":"3"}`,
+			StatsInstaller{"uv", "", ""},
+		},
+		{`pip/23.0 {broken`, StatsInstaller{STATS_UNKNOWN, "", ""}},
+		{`Poetry/1.8.2 (Darwin)`, StatsInstaller{"poetry", "", ""}},
+		{`curl/8.0`, StatsInstaller{"curl", "", ""}},
+		{``, StatsInstaller{STATS_UNKNOWN, "", ""}},
+	}
+
+	for _, tt := range tc {
+		t.Run(tt.userAgent, func(st *testing.T) {
+			if result := ParseStatsInstaller(tt.userAgent); result != tt.expected {
+				st.Errorf("ParseStatsInstaller returned %+v, expected %+v", result, tt.expected)
+			}
+		})
+	}
+}
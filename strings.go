@@ -33,4 +33,15 @@ func StringParseBool(value string) (result *bool) {
 		return &tmp
 	}
 	return
-}
\ No newline at end of file
+}
+
+/*
+StringTruncate truncates string to given maximum length (in runes)
+ */
+func StringTruncate(value string, length int) string {
+	runes := []rune(value)
+	if len(runes) > length {
+		return string(runes[:length])
+	}
+	return value
+}
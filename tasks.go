@@ -3,6 +3,21 @@ tasks is set of background tasks
 */
 package core
 
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"time"
+
+	"github.com/uber-go/zap"
+)
+
+var (
+	// package files are stored as xx/xxxx/<hash>/<filename> (see PackageVersionFile.GenerateRelativePath)
+	storageFilePattern = regexp.MustCompile(`^[0-9a-f]{2}/[0-9a-f]{4}/[0-9a-f]+/[^/]+$`)
+)
+
 /*
 Task interface for background running tasks
  */
@@ -11,3 +26,399 @@ type Task interface {
 	// Run executes task
 	Run(config Config) error
 }
+
+/*
+RegisterTasks registers all built-in tasks to scheduler, schedules are read by given function
+*/
+func RegisterTasks(scheduler *Scheduler, schedule func(name, def string) string) (err error) {
+	tasks := []struct {
+		name        string
+		description string
+		def         string
+		task        Task
+	}{
+		{TASK_CLEANUP_DOWNLOAD_STATS, "Deletes old daily, weekly, monthly and detailed download stats", "@daily", DownloadStatsCleanupTask{}},
+		{TASK_CLEANUP_SESSIONS, "Deletes expired and revoked login sessions", "@daily", SessionCleanupTask{}},
+		{TASK_STORAGE_GC, "Removes package files that are not referenced from database", "@weekly", StorageGCTask{}},
+		{TASK_METADATA_BACKFILL, "Fills missing metadata of packages and files", "", MetadataBackfillTask{}},
+	}
+
+	for _, task := range tasks {
+		if err = scheduler.Register(task.name, task.description, schedule(task.name, task.def), task.task); err != nil {
+			return
+		}
+	}
+
+	return
+}
+
+/*
+DownloadStatsCleanupTask deletes daily, weekly, monthly and detailed download stats older than configured archive
+*/
+type DownloadStatsCleanupTask struct{}
+
+func (d DownloadStatsCleanupTask) Run(cfg Config) (err error) {
+	var deleted map[string]int64
+	if deleted, err = cfg.Manager().DownloadStats().Cleanup(); err != nil {
+		return
+	}
+
+	cfg.Logger().Info("download stats cleaned up",
+		zap.Int64("daily", deleted["daily"]),
+		zap.Int64("weekly", deleted["weekly"]),
+		zap.Int64("monthly", deleted["monthly"]),
+		zap.Int64("details", deleted["details"]),
+	)
+	return
+}
+
+/*
+SessionCleanupTask deletes expired and revoked sessions
+*/
+type SessionCleanupTask struct{}
+
+func (s SessionCleanupTask) Run(cfg Config) (err error) {
+	var deleted int64
+	if deleted, err = cfg.Manager().Session().Cleanup(); err != nil {
+		return
+	}
+
+	cfg.Logger().Info("sessions cleaned up", zap.Int64("deleted", deleted))
+	return
+}
+
+/*
+StorageGCTask removes files in packages directory that are not referenced by any package version file (e.g. left
+after failed upload or deleted version). Only files that follow package storage layout and are older than
+STORAGE_GC_GRACE are removed, empty directories are removed as well.
+*/
+type StorageGCTask struct{}
+
+func (s StorageGCTask) Run(cfg Config) (err error) {
+	files := []PackageVersionFile{}
+	if err = cfg.DB().Select("relative_path, filename").Find(&files).Error; err != nil {
+		return
+	}
+
+	manager := cfg.Manager().PackageVersionFile()
+	referenced := map[string]bool{}
+	for _, file := range files {
+		referenced[filepath.Clean(manager.GetRelativeFilename(&file))] = true
+	}
+
+	root := cfg.Packages().Directory()
+	threshold := time.Now().Add(-STORAGE_GC_GRACE)
+	removed := 0
+	dirs := []string{}
+
+	err = filepath.Walk(root, func(path string, info os.FileInfo, errWalk error) error {
+		if errWalk != nil {
+			return errWalk
+		}
+
+		relative, errRel := filepath.Rel(root, path)
+		if errRel != nil {
+			return errRel
+		}
+
+		if info.IsDir() {
+			if path != root {
+				dirs = append(dirs, path)
+			}
+			return nil
+		}
+
+		if referenced[relative] || !storageFilePattern.MatchString(filepath.ToSlash(relative)) || info.ModTime().After(threshold) {
+			return nil
+		}
+
+		if errRemove := os.Remove(path); errRemove != nil {
+			cfg.Metrics().Registry().StorageErrors.Inc("remove")
+			return errRemove
+		}
+
+		cfg.Logger().Info("storage gc removed file", zap.String("file", relative))
+		removed++
+		return nil
+	})
+
+	// packages directory is created with first upload
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return
+	}
+
+	// remove empty directories, deepest first
+	for i := len(dirs) - 1; i >= 0; i-- {
+		if entries, errRead := ioutil.ReadDir(dirs[i]); errRead == nil && len(entries) == 0 {
+			os.Remove(dirs[i])
+		}
+	}
+
+	cfg.Logger().Info("storage gc finished", zap.Int("removed", removed))
+	return
+}
+
+/*
+MetadataBackfillTask fills metadata that is missing for packages uploaded by older versions of gopypi: md5 digest of
+files is computed from stored file, tags are parsed from filenames (and files linked to platforms), requires python,
+dependencies, descriptions and keywords are read from metadata, descriptions are rendered and order of versions is
+recomputed.
+*/
+type MetadataBackfillTask struct{}
+
+func (m MetadataBackfillTask) Run(cfg Config) (err error) {
+	files := []PackageVersionFile{}
+	if err = cfg.DB().Where("md5_digest = ? OR md5_digest IS NULL", "").Find(&files).Error; err != nil {
+		return
+	}
+
+	manager := cfg.Manager().PackageVersionFile()
+	updated := 0
+	for _, file := range files {
+		content, errRead := ioutil.ReadFile(manager.GetAbsoluteFilename(&file))
+		if errRead != nil {
+			cfg.Logger().Error("metadata backfill cannot read file",
+				zap.String("file", file.Filename),
+				zap.String("error", errRead.Error()),
+			)
+			continue
+		}
+
+		if err = cfg.DB().Model(&file).UpdateColumn("md5_digest", MD5(string(content))).Error; err != nil {
+			return
+		}
+		updated++
+	}
+
+	// parse tags of files uploaded before filenames were validated
+	untagged := []PackageVersionFile{}
+	if err = cfg.DB().Where("package_type = ? OR package_type IS NULL", "").Find(&untagged).Error; err != nil {
+		return
+	}
+
+	for _, file := range untagged {
+		dist, errParse := ParseDistributionFilename(file.Filename)
+		if errParse != nil {
+			cfg.Logger().Error("metadata backfill cannot parse filename",
+				zap.String("file", file.Filename),
+				zap.String("error", errParse.Error()),
+			)
+			continue
+		}
+
+		if err = cfg.DB().Model(&file).UpdateColumns(map[string]interface{}{
+			"package_type": dist.PackageType,
+			"build_tag":    dist.BuildTag,
+			"python_tag":   dist.PythonTag,
+			"abi_tag":      dist.ABITag,
+			"platform_tag": dist.PlatformTag,
+		}).Error; err != nil {
+			return
+		}
+		updated++
+	}
+
+	// link files to platforms and tags
+	unlinked := []PackageVersionFile{}
+	if err = cfg.DB().Where("package_type NOT IN (?) AND id NOT IN (SELECT package_version_file_id FROM package_version_file_tag)", []string{"", PACKAGE_TYPE_SDIST}).Find(&unlinked).Error; err != nil {
+		return
+	}
+
+	for i := range unlinked {
+		if err = manager.UpdateTags(&unlinked[i]); err != nil {
+			return
+		}
+		updated++
+	}
+
+	// read requires python from metadata of files
+	if err = m.backfillRequiresPython(cfg, &updated); err != nil {
+		return
+	}
+
+	// read dependencies from metadata of files
+	if err = m.backfillDependencies(cfg, &updated); err != nil {
+		return
+	}
+
+	// render descriptions
+	if err = m.backfillDescriptions(cfg, &updated); err != nil {
+		return
+	}
+
+	// read keywords (used by search) from metadata of files
+	if err = m.backfillKeywords(cfg, &updated); err != nil {
+		return
+	}
+
+	packages := []Package{}
+	if err = cfg.DB().Find(&packages).Error; err != nil {
+		return
+	}
+
+	for _, pack := range packages {
+		if err = cfg.Manager().Package().UpdateVersionOrder(pack); err != nil {
+			return
+		}
+	}
+
+	cfg.Logger().Info("metadata backfill finished",
+		zap.Int("files", updated),
+		zap.Int("packages", len(packages)),
+	)
+	return
+}
+
+/*
+backfillRequiresPython reads Requires-Python from metadata of files that don't have it, releases without it get value
+of their file.
+*/
+func (m MetadataBackfillTask) backfillRequiresPython(cfg Config, updated *int) (err error) {
+	files := []PackageVersionFile{}
+	if err = cfg.DB().Where("(requires_python = ? OR requires_python IS NULL) AND package_type <> ?", "", "").Find(&files).Error; err != nil {
+		return
+	}
+
+	manager := cfg.Manager().PackageVersionFile()
+	for _, file := range files {
+		dist, errParse := ParseDistributionFilename(file.Filename)
+		if errParse != nil {
+			continue
+		}
+
+		meta, errMeta := ReadDistributionMetadata(manager.GetAbsoluteFilename(&file), dist)
+		if errMeta != nil {
+			continue
+		}
+
+		value := meta.Get("Requires-Python")
+		if value == "" || ValidateRequiresPython(value) != nil {
+			continue
+		}
+
+		if err = cfg.DB().Model(&file).UpdateColumn("requires_python", value).Error; err != nil {
+			return
+		}
+		if err = cfg.DB().Model(PackageVersion{}).
+			Where("id = ? AND (requires_python = ? OR requires_python IS NULL)", file.PackageVersionID, "").
+			UpdateColumn("requires_python", value).Error; err != nil {
+			return
+		}
+		*updated++
+	}
+
+	return
+}
+
+/*
+backfillDependencies reads Requires-Dist from metadata of first readable file of versions without dependencies
+*/
+func (m MetadataBackfillTask) backfillDependencies(cfg Config, updated *int) (err error) {
+	versions := []PackageVersion{}
+	if err = cfg.DB().Preload("Files").Where("id NOT IN (SELECT package_version_id FROM package_dependency)").Find(&versions).Error; err != nil {
+		return
+	}
+
+	manager := cfg.Manager().PackageVersionFile()
+	for _, version := range versions {
+		for _, file := range version.Files {
+			dist, errParse := ParseDistributionFilename(file.Filename)
+			if errParse != nil {
+				continue
+			}
+
+			meta, errMeta := ReadDistributionMetadata(manager.GetAbsoluteFilename(&file), dist)
+			if errMeta != nil {
+				continue
+			}
+
+			if err = cfg.Manager().PackageVersion().SetMissingDependencies(version, ParseRequirements(meta.GetAll("Requires-Dist"))); err != nil {
+				return
+			}
+			*updated++
+			break
+		}
+	}
+
+	return
+}
+
+/*
+backfillDescriptions renders descriptions of versions, versions without description get it from metadata of first
+readable file
+*/
+func (m MetadataBackfillTask) backfillDescriptions(cfg Config, updated *int) (err error) {
+	versions := []PackageVersion{}
+	if err = cfg.DB().Preload("Files").Where("description_html = ? OR description_html IS NULL", "").Find(&versions).Error; err != nil {
+		return
+	}
+
+	manager := cfg.Manager().PackageVersionFile()
+	for _, version := range versions {
+		if version.Description != "" {
+			if err = cfg.DB().Model(&version).UpdateColumn("description_html", RenderDescription(version.DescriptionContentType, version.Description)).Error; err != nil {
+				return
+			}
+			*updated++
+			continue
+		}
+
+		for _, file := range version.Files {
+			dist, errParse := ParseDistributionFilename(file.Filename)
+			if errParse != nil {
+				continue
+			}
+
+			meta, errMeta := ReadDistributionMetadata(manager.GetAbsoluteFilename(&file), dist)
+			if errMeta != nil {
+				continue
+			}
+
+			if err = cfg.Manager().PackageVersion().SetDescription(&version, meta); err != nil {
+				return
+			}
+			*updated++
+			break
+		}
+	}
+
+	return
+}
+
+/*
+backfillKeywords reads Keywords from metadata of first readable file of versions without keywords
+*/
+func (m MetadataBackfillTask) backfillKeywords(cfg Config, updated *int) (err error) {
+	versions := []PackageVersion{}
+	if err = cfg.DB().Preload("Files").Where("keywords = ? OR keywords IS NULL", "").Find(&versions).Error; err != nil {
+		return
+	}
+
+	manager := cfg.Manager().PackageVersionFile()
+	for _, version := range versions {
+		for _, file := range version.Files {
+			dist, errParse := ParseDistributionFilename(file.Filename)
+			if errParse != nil {
+				continue
+			}
+
+			meta, errMeta := ReadDistributionMetadata(manager.GetAbsoluteFilename(&file), dist)
+			if errMeta != nil {
+				continue
+			}
+
+			if meta.Get("Keywords") != "" {
+				if err = cfg.Manager().PackageVersion().SetKeywords(&version, meta.Get("Keywords")); err != nil {
+					return
+				}
+				*updated++
+			}
+			break
+		}
+	}
+
+	return
+}
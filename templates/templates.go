@@ -1,7 +1,21 @@
 // Code generated by go-bindata.
 // sources:
+// email/base.tpl.html
+// email/maintainer_added.tpl.html
+// email/maintainer_removed.tpl.html
+// email/new_version.tpl.html
+// email/password_changed.tpl.html
+// email/subject.tpl.html
+// email/test.tpl.html
 // index.tpl.html
+// package_detail.tpl.html
 // package_list.tpl.html
+// project_detail.tpl.html
+// public/base.tpl.html
+// public/dependencies.tpl.html
+// public/files.tpl.html
+// public/history.tpl.html
+// public/list.tpl.html
 // DO NOT EDIT!
 
 package templates
@@ -46,6 +60,171 @@ func (fi bindataFileInfo) Sys() interface{} {
 	return nil
 }
 
+var _emailBaseTplHtml = []byte(`<!DOCTYPE html>
+<html lang="en">
+<head>
+    <meta charset="utf-8">
+    <title>{{template "subject" .}}</title>
+</head>
+<body style="font-family: Helvetica, Arial, sans-serif; font-size: 14px; color: #333;">
+    <p>Hello {{if .User.FirstName}}{{.User.FirstName}}{{else}}{{.User.Username}}{{end}},</p>
+    {{template "body" .}}
+    <p style="color: #777; font-size: 12px;">
+        This email was sent by gopypi server at <a href="{{.Host}}">{{.Host}}</a>.
+        You can change which notifications you receive in your profile.
+    </p>
+</body>
+</html>
+`)
+
+func emailBaseTplHtmlBytes() ([]byte, error) {
+	return _emailBaseTplHtml, nil
+}
+
+func emailBaseTplHtml() (*asset, error) {
+	bytes, err := emailBaseTplHtmlBytes()
+	if err != nil {
+		return nil, err
+	}
+
+	info := bindataFileInfo{name: "email/base.tpl.html", size: 552, mode: os.FileMode(420), modTime: time.Unix(1792411868, 0)}
+	a := &asset{bytes: bytes, info: info}
+	return a, nil
+}
+
+var _emailMaintainer_addedTplHtml = []byte(`{{define "subject"}}[gopypi] You are now maintainer of {{.Package.Name}}{{end}}
+{{define "body"}}
+    <p>
+        User <strong>{{.Actor.Username}}</strong> added you as maintainer of package <strong>{{.Package.Name}}</strong>.
+    </p>
+{{end}}
+`)
+
+func emailMaintainer_addedTplHtmlBytes() ([]byte, error) {
+	return _emailMaintainer_addedTplHtml, nil
+}
+
+func emailMaintainer_addedTplHtml() (*asset, error) {
+	bytes, err := emailMaintainer_addedTplHtmlBytes()
+	if err != nil {
+		return nil, err
+	}
+
+	info := bindataFileInfo{name: "email/maintainer_added.tpl.html", size: 244, mode: os.FileMode(420), modTime: time.Unix(1792411868, 0)}
+	a := &asset{bytes: bytes, info: info}
+	return a, nil
+}
+
+var _emailMaintainer_removedTplHtml = []byte(`{{define "subject"}}[gopypi] You are no longer maintainer of {{.Package.Name}}{{end}}
+{{define "body"}}
+    <p>
+        User <strong>{{.Actor.Username}}</strong> removed you from maintainers of package <strong>{{.Package.Name}}</strong>.
+    </p>
+{{end}}
+`)
+
+func emailMaintainer_removedTplHtmlBytes() ([]byte, error) {
+	return _emailMaintainer_removedTplHtml, nil
+}
+
+func emailMaintainer_removedTplHtml() (*asset, error) {
+	bytes, err := emailMaintainer_removedTplHtmlBytes()
+	if err != nil {
+		return nil, err
+	}
+
+	info := bindataFileInfo{name: "email/maintainer_removed.tpl.html", size: 255, mode: os.FileMode(420), modTime: time.Unix(1792411868, 0)}
+	a := &asset{bytes: bytes, info: info}
+	return a, nil
+}
+
+var _emailNew_versionTplHtml = []byte(`{{define "subject"}}[gopypi] New version {{.Version.Version}} of {{.Package.Name}}{{end}}
+{{define "body"}}
+    <p>
+        User <strong>{{.Actor.Username}}</strong> uploaded new version <strong>{{.Version.Version}}</strong>
+        of package <strong>{{.Package.Name}}</strong> that you maintain.
+    </p>
+    {{if .Version.Summary}}<p>{{.Version.Summary}}</p>{{end}}
+{{end}}
+`)
+
+func emailNew_versionTplHtmlBytes() ([]byte, error) {
+	return _emailNew_versionTplHtml, nil
+}
+
+func emailNew_versionTplHtml() (*asset, error) {
+	bytes, err := emailNew_versionTplHtmlBytes()
+	if err != nil {
+		return nil, err
+	}
+
+	info := bindataFileInfo{name: "email/new_version.tpl.html", size: 377, mode: os.FileMode(420), modTime: time.Unix(1792411868, 0)}
+	a := &asset{bytes: bytes, info: info}
+	return a, nil
+}
+
+var _emailPassword_changedTplHtml = []byte(`{{define "subject"}}[gopypi] Your password was changed{{end}}
+{{define "body"}}
+    <p>
+        Password of your account <strong>{{.User.Username}}</strong> was changed{{if .Actor.Username}} by <strong>{{.Actor.Username}}</strong>{{end}}.
+    </p>
+    <p>If you did not request this change, please contact administrator immediately.</p>
+{{end}}
+`)
+
+func emailPassword_changedTplHtmlBytes() ([]byte, error) {
+	return _emailPassword_changedTplHtml, nil
+}
+
+func emailPassword_changedTplHtml() (*asset, error) {
+	bytes, err := emailPassword_changedTplHtmlBytes()
+	if err != nil {
+		return nil, err
+	}
+
+	info := bindataFileInfo{name: "email/password_changed.tpl.html", size: 345, mode: os.FileMode(420), modTime: time.Unix(1792411868, 0)}
+	a := &asset{bytes: bytes, info: info}
+	return a, nil
+}
+
+var _emailSubjectTplHtml = []byte(`{{template "subject" .}}`)
+
+func emailSubjectTplHtmlBytes() ([]byte, error) {
+	return _emailSubjectTplHtml, nil
+}
+
+func emailSubjectTplHtml() (*asset, error) {
+	bytes, err := emailSubjectTplHtmlBytes()
+	if err != nil {
+		return nil, err
+	}
+
+	info := bindataFileInfo{name: "email/subject.tpl.html", size: 24, mode: os.FileMode(420), modTime: time.Unix(1792411868, 0)}
+	a := &asset{bytes: bytes, info: info}
+	return a, nil
+}
+
+var _emailTestTplHtml = []byte(`{{define "subject"}}[gopypi] Test email{{end}}
+{{define "body"}}
+    <p>This is test email, your notification settings work.</p>
+{{end}}
+`)
+
+func emailTestTplHtmlBytes() ([]byte, error) {
+	return _emailTestTplHtml, nil
+}
+
+func emailTestTplHtml() (*asset, error) {
+	bytes, err := emailTestTplHtmlBytes()
+	if err != nil {
+		return nil, err
+	}
+
+	info := bindataFileInfo{name: "email/test.tpl.html", size: 137, mode: os.FileMode(420), modTime: time.Unix(1792411868, 0)}
+	a := &asset{bytes: bytes, info: info}
+	return a, nil
+}
+
 var _indexTplHtml = []byte(`<!DOCTYPE html>
 <html lang="en">
 <head>
@@ -140,6 +319,34 @@ func indexTplHtml() (*asset, error) {
 	return a, nil
 }
 
+var _package_detailTplHtml = []byte(`<html>
+    <head>
+        <title>Links for {{.Name}}</title>
+    </head>
+    <body>
+        <h1>Links for {{.Name}}</h1>
+        {{range .Links}}
+            <a href="{{.URL}}#md5={{.MD5Digest}}"{{with .RequiresPython}} data-requires-python="{{.}}"{{end}}{{if .Yanked}} data-yanked="{{.YankedReason}}"{{end}}>{{.Filename}}</a><br>
+        {{end}}
+    </body>
+</html>
+`)
+
+func package_detailTplHtmlBytes() ([]byte, error) {
+	return _package_detailTplHtml, nil
+}
+
+func package_detailTplHtml() (*asset, error) {
+	bytes, err := package_detailTplHtmlBytes()
+	if err != nil {
+		return nil, err
+	}
+
+	info := bindataFileInfo{name: "package_detail.tpl.html", size: 367, mode: os.FileMode(420), modTime: time.Unix(1792413781, 0)}
+	a := &asset{bytes: bytes, info: info}
+	return a, nil
+}
+
 var _package_listTplHtml = []byte(`<html>
     <head>
         <title>Gopypi simple Index</title>
@@ -147,7 +354,7 @@ var _package_listTplHtml = []byte(`<html>
     <body>
         <h1>Simple Index</h1>
         {{range .Packages}}
-            <a href="{{reverse "package_detail" "slug" .Name}}">{{.Name}}</a><br>
+            <a href="{{reverse $.DetailRoute "index" $.Index "slug" .Name}}">{{.Name}}</a><br>
         {{end}}
     </body>
 </html>`)
@@ -162,7 +369,370 @@ func package_listTplHtml() (*asset, error) {
 		return nil, err
 	}
 
-	info := bindataFileInfo{name: "package_list.tpl.html", size: 259, mode: os.FileMode(420), modTime: time.Unix(1476313556, 0)}
+	info := bindataFileInfo{name: "package_list.tpl.html", size: 272, mode: os.FileMode(420), modTime: time.Unix(1476313556, 0)}
+	a := &asset{bytes: bytes, info: info}
+	return a, nil
+}
+
+var _project_detailTplHtml = []byte(`{{define "title"}}{{.Package.Name}} {{.Version.Version}}{{end}}
+{{define "content"}}
+    {{template "project_header" .}}
+    <div class="row">
+        <div class="col-md-9 description">
+            {{if .Version.DescriptionHTML}}{{.Description}}{{else}}<p class="text-muted">The author of this package has not provided a project description.</p>{{end}}
+        </div>
+        <div class="col-md-3">
+            <h4>Meta</h4>
+            <dl>
+                <dt>Index</dt>
+                <dd>{{with .Package.Index}}{{.Name}}{{end}}</dd>
+                <dt>Released</dt>
+                <dd>{{.Version.CreatedAt.Format "2006-01-02"}}</dd>
+                {{with .Version.HomePage}}
+                    <dt>Home page</dt>
+                    <dd><a href="{{.}}" rel="nofollow">{{.}}</a></dd>
+                {{end}}
+                {{with .Version.RequiresPython}}
+                    <dt>Requires Python</dt>
+                    <dd><code>{{.}}</code></dd>
+                {{end}}
+                {{with .Version.License}}{{if .Name}}
+                    <dt>License</dt>
+                    <dd>{{.Name}}{{with .Code}} ({{.}}){{end}}</dd>
+                {{end}}{{end}}
+            </dl>
+
+            {{with .Package.Maintainers}}
+                <h4>Maintainers</h4>
+                <ul class="list-unstyled">
+                    {{range .}}
+                        <li>{{.Username}}{{if or .FirstName .LastName}} <span class="text-muted">({{.FirstName}}{{if and .FirstName .LastName}} {{end}}{{.LastName}})</span>{{end}}</li>
+                    {{end}}
+                </ul>
+            {{end}}
+
+            {{with .Version.Classifiers}}
+                <h4>Classifiers</h4>
+                <ul class="list-unstyled small">
+                    {{range .}}<li>{{.Name}}</li>{{end}}
+                </ul>
+            {{end}}
+        </div>
+    </div>
+{{end}}
+`)
+
+func project_detailTplHtmlBytes() ([]byte, error) {
+	return _project_detailTplHtml, nil
+}
+
+func project_detailTplHtml() (*asset, error) {
+	bytes, err := project_detailTplHtmlBytes()
+	if err != nil {
+		return nil, err
+	}
+
+	info := bindataFileInfo{name: "project_detail.tpl.html", size: 1862, mode: os.FileMode(420), modTime: time.Unix(1792417397, 0)}
+	a := &asset{bytes: bytes, info: info}
+	return a, nil
+}
+
+var _publicBaseTplHtml = []byte(`<!DOCTYPE html>
+<html lang="en">
+<head>
+    <meta charset="utf-8">
+    <meta http-equiv="X-UA-Compatible" content="IE=edge">
+    <meta name="viewport" content="width=device-width, initial-scale=1">
+
+    <title>{{template "title" .}} - Gopypi</title>
+
+    <link href="https://maxcdn.bootstrapcdn.com/bootstrap/3.3.7/css/bootstrap.min.css" rel="stylesheet"
+          integrity="sha384-BVYiiSIFeK1dGmJRAkycuHAHRg32OmUcww7on3RYdg4Va+PmSTsz/K68vbdEjh4u" crossorigin="anonymous">
+
+    <style type="text/css">
+        body {
+            padding-bottom: 20px;
+        }
+        .description img {
+            max-width: 100%;
+        }
+        .digest {
+            font-family: monospace;
+            font-size: 12px;
+            word-break: break-all;
+        }
+    </style>
+</head>
+
+<body>
+<nav class="navbar navbar-default navbar-static-top">
+    <div class="container">
+        <div class="navbar-header">
+            <a class="navbar-brand" href="{{.Reverse "project_list"}}">Gopypi <small>{{.Index.Name}}</small></a>
+        </div>
+        <form class="navbar-form navbar-right" method="get" action="{{.Reverse "project_list"}}">
+            <div class="form-group">
+                <input type="text" class="form-control" name="q" placeholder="Search packages">
+            </div>
+            <button type="submit" class="btn btn-default">Search</button>
+        </form>
+    </div>
+</nav>
+<div class="container">
+    {{template "content" .}}
+</div>
+</body>
+</html>
+{{define "project_header"}}
+    <div class="page-header">
+        <h1>{{.Package.Name}} <small>{{.Version.Version}}</small></h1>
+        {{with .Version.Summary}}<p class="lead">{{.}}</p>{{end}}
+        <p><code>pip install --index-url {{.IndexURL}} {{.Package.Name}}=={{.Version.Version}}</code></p>
+        {{if .Version.Yanked}}
+            <div class="alert alert-warning">
+                This release is yanked{{with .Version.YankedReason}}: {{.}}{{end}}
+            </div>
+        {{end}}
+        {{if ne .Version.Version (index .Versions 0).Version}}
+            <div class="alert alert-info">
+                This is not the latest release,
+                latest is <a href="{{.ProjectURL "detail" .Package.Name}}">{{(index .Versions 0).Version}}</a>.
+            </div>
+        {{end}}
+    </div>
+    <ul class="nav nav-tabs">
+        <li{{if eq .Tab "detail"}} class="active"{{end}}><a href="{{.ProjectURL "detail" .Package.Name}}?version={{.Version.Version}}">Description</a></li>
+        <li{{if eq .Tab "history"}} class="active"{{end}}><a href="{{.ProjectURL "history" .Package.Name}}?version={{.Version.Version}}">Release history</a></li>
+        <li{{if eq .Tab "files"}} class="active"{{end}}><a href="{{.ProjectURL "files" .Package.Name}}?version={{.Version.Version}}">Files</a></li>
+        <li{{if eq .Tab "dependencies"}} class="active"{{end}}><a href="{{.ProjectURL "dependencies" .Package.Name}}?version={{.Version.Version}}">Dependencies</a></li>
+    </ul>
+    <br>
+{{end}}
+`)
+
+func publicBaseTplHtmlBytes() ([]byte, error) {
+	return _publicBaseTplHtml, nil
+}
+
+func publicBaseTplHtml() (*asset, error) {
+	bytes, err := publicBaseTplHtmlBytes()
+	if err != nil {
+		return nil, err
+	}
+
+	info := bindataFileInfo{name: "public/base.tpl.html", size: 2965, mode: os.FileMode(420), modTime: time.Unix(1792417329, 0)}
+	a := &asset{bytes: bytes, info: info}
+	return a, nil
+}
+
+var _publicDependenciesTplHtml = []byte(`{{define "title"}}{{.Package.Name}} {{.Version.Version}} dependencies{{end}}
+{{define "content"}}
+    {{template "project_header" .}}
+    <h4>Dependencies</h4>
+    {{if .Dependencies}}
+        <table class="table table-striped">
+            <thead>
+            <tr>
+                <th>Package</th>
+                <th>Version</th>
+                <th>Extras</th>
+                <th>Environment</th>
+            </tr>
+            </thead>
+            <tbody>
+            {{range .Dependencies}}
+                <tr>
+                    <td>{{if .Hosted}}<a href="{{$.ProjectURL "detail" .Hosted}}">{{.Name}}</a>{{else}}{{.Name}}{{end}}</td>
+                    <td>{{if .URL}}<code>@ {{.URL}}</code>{{else}}<code>{{.Specifier}}</code>{{end}}</td>
+                    <td>{{.Extras}}</td>
+                    <td>{{with .Marker}}<code>{{.}}</code>{{end}}</td>
+                </tr>
+            {{end}}
+            </tbody>
+        </table>
+    {{else}}
+        <p class="text-muted">This release has no dependencies.</p>
+    {{end}}
+
+    <h4>Required by</h4>
+    {{if .Dependents}}
+        <table class="table table-striped">
+            <thead>
+            <tr>
+                <th>Package</th>
+                <th>Requires</th>
+                <th>Environment</th>
+            </tr>
+            </thead>
+            <tbody>
+            {{range .Dependents}}
+                <tr>
+                    <td><a href="{{$.ProjectURL "detail" .Package}}?version={{.Version}}">{{.Package}} {{.Version}}</a></td>
+                    <td><code>{{.Specifier}}</code></td>
+                    <td>{{with .Marker}}<code>{{.}}</code>{{end}}</td>
+                </tr>
+            {{end}}
+            </tbody>
+        </table>
+    {{else}}
+        <p class="text-muted">No package in this index depends on {{.Package.Name}}.</p>
+    {{end}}
+{{end}}
+`)
+
+func publicDependenciesTplHtmlBytes() ([]byte, error) {
+	return _publicDependenciesTplHtml, nil
+}
+
+func publicDependenciesTplHtml() (*asset, error) {
+	bytes, err := publicDependenciesTplHtmlBytes()
+	if err != nil {
+		return nil, err
+	}
+
+	info := bindataFileInfo{name: "public/dependencies.tpl.html", size: 1836, mode: os.FileMode(420), modTime: time.Unix(1792417348, 0)}
+	a := &asset{bytes: bytes, info: info}
+	return a, nil
+}
+
+var _publicFilesTplHtml = []byte(`{{define "title"}}{{.Package.Name}} {{.Version.Version}} files{{end}}
+{{define "content"}}
+    {{template "project_header" .}}
+    {{if .Version.Files}}
+        <table class="table table-striped">
+            <thead>
+            <tr>
+                <th>Filename</th>
+                <th>Type</th>
+                <th>Python</th>
+                <th>Uploaded</th>
+                <th>MD5</th>
+            </tr>
+            </thead>
+            <tbody>
+            {{range .Version.Files}}
+                <tr>
+                    <td><a href="{{.DownloadURL}}">{{.Filename}}</a></td>
+                    <td>{{.PackageType}}</td>
+                    <td>{{.PythonTag}}{{with .RequiresPython}} <code>{{.}}</code>{{end}}</td>
+                    <td>{{.CreatedAt.Format "2006-01-02 15:04"}}</td>
+                    <td class="digest">{{.MD5Digest}}</td>
+                </tr>
+            {{end}}
+            </tbody>
+        </table>
+    {{else}}
+        <p class="text-muted">This release has no files.</p>
+    {{end}}
+{{end}}
+`)
+
+func publicFilesTplHtmlBytes() ([]byte, error) {
+	return _publicFilesTplHtml, nil
+}
+
+func publicFilesTplHtml() (*asset, error) {
+	bytes, err := publicFilesTplHtmlBytes()
+	if err != nil {
+		return nil, err
+	}
+
+	info := bindataFileInfo{name: "public/files.tpl.html", size: 1027, mode: os.FileMode(420), modTime: time.Unix(1792417348, 0)}
+	a := &asset{bytes: bytes, info: info}
+	return a, nil
+}
+
+var _publicHistoryTplHtml = []byte(`{{define "title"}}{{.Package.Name}} release history{{end}}
+{{define "content"}}
+    {{template "project_header" .}}
+    <table class="table table-striped">
+        <thead>
+        <tr>
+            <th>Version</th>
+            <th>Released</th>
+            <th>Requires Python</th>
+            <th></th>
+        </tr>
+        </thead>
+        <tbody>
+        {{range .Versions}}
+            <tr{{if eq .Version $.Version.Version}} class="info"{{end}}>
+                <td><a href="{{$.ProjectURL "detail" $.Package.Name}}?version={{.Version}}">{{.Version}}</a></td>
+                <td>{{.CreatedAt.Format "2006-01-02 15:04"}}</td>
+                <td>{{with .RequiresPython}}<code>{{.}}</code>{{end}}</td>
+                <td>{{if .Yanked}}<span class="label label-warning" title="{{.YankedReason}}">yanked</span>{{end}}</td>
+            </tr>
+        {{end}}
+        </tbody>
+    </table>
+{{end}}
+`)
+
+func publicHistoryTplHtmlBytes() ([]byte, error) {
+	return _publicHistoryTplHtml, nil
+}
+
+func publicHistoryTplHtml() (*asset, error) {
+	bytes, err := publicHistoryTplHtmlBytes()
+	if err != nil {
+		return nil, err
+	}
+
+	info := bindataFileInfo{name: "public/history.tpl.html", size: 898, mode: os.FileMode(420), modTime: time.Unix(1792417348, 0)}
+	a := &asset{bytes: bytes, info: info}
+	return a, nil
+}
+
+var _publicListTplHtml = []byte(`{{define "title"}}{{if .Query}}Search "{{.Query}}"{{else}}Packages{{end}}{{end}}
+{{define "content"}}
+    <div class="page-header">
+        <h1>{{if .Query}}Search results <small>{{.Query}}</small>{{else}}Packages{{end}}</h1>
+        <p><code>pip install --index-url {{.IndexURL}} &lt;package&gt;</code></p>
+    </div>
+    <form class="form-inline" method="get" action="{{.Reverse "project_list"}}">
+        <div class="form-group">
+            <input type="text" class="form-control" name="q" value="{{.Query}}" placeholder="Search packages">
+        </div>
+        <button type="submit" class="btn btn-primary">Search</button>
+        <span class="text-muted">&nbsp;{{.Count}} package(s)</span>
+    </form>
+    <br>
+    {{if .Items}}
+        <div class="list-group">
+            {{range .Items}}
+                <a class="list-group-item" href="{{$.ProjectURL "detail" .Name}}">
+                    <h4 class="list-group-item-heading">
+                        {{.Name}} <small>{{.Version}}</small>
+                        <small class="pull-right">{{.CreatedAt.Format "2006-01-02"}}</small>
+                    </h4>
+                    <p class="list-group-item-text">{{.Summary}}</p>
+                </a>
+            {{end}}
+        </div>
+        {{if gt .NumPages 1}}
+            <ul class="pager">
+                {{if .PrevURL}}<li class="previous"><a href="{{.PrevURL}}">&larr; Previous</a></li>{{end}}
+                <li>Page {{.Page}} of {{.NumPages}}</li>
+                {{if .NextURL}}<li class="next"><a href="{{.NextURL}}">Next &rarr;</a></li>{{end}}
+            </ul>
+        {{end}}
+    {{else}}
+        <p class="text-muted">No packages found.</p>
+    {{end}}
+{{end}}
+`)
+
+func publicListTplHtmlBytes() ([]byte, error) {
+	return _publicListTplHtml, nil
+}
+
+func publicListTplHtml() (*asset, error) {
+	bytes, err := publicListTplHtmlBytes()
+	if err != nil {
+		return nil, err
+	}
+
+	info := bindataFileInfo{name: "public/list.tpl.html", size: 1688, mode: os.FileMode(420), modTime: time.Unix(1792417348, 0)}
 	a := &asset{bytes: bytes, info: info}
 	return a, nil
 }
@@ -219,8 +789,22 @@ func AssetNames() []string {
 
 // _bindata is a table, holding each asset generator, mapped to its name.
 var _bindata = map[string]func() (*asset, error){
+	"email/base.tpl.html": emailBaseTplHtml,
+	"email/maintainer_added.tpl.html": emailMaintainer_addedTplHtml,
+	"email/maintainer_removed.tpl.html": emailMaintainer_removedTplHtml,
+	"email/new_version.tpl.html": emailNew_versionTplHtml,
+	"email/password_changed.tpl.html": emailPassword_changedTplHtml,
+	"email/subject.tpl.html": emailSubjectTplHtml,
+	"email/test.tpl.html": emailTestTplHtml,
 	"index.tpl.html": indexTplHtml,
+	"package_detail.tpl.html": package_detailTplHtml,
 	"package_list.tpl.html": package_listTplHtml,
+	"project_detail.tpl.html": project_detailTplHtml,
+	"public/base.tpl.html": publicBaseTplHtml,
+	"public/dependencies.tpl.html": publicDependenciesTplHtml,
+	"public/files.tpl.html": publicFilesTplHtml,
+	"public/history.tpl.html": publicHistoryTplHtml,
+	"public/list.tpl.html": publicListTplHtml,
 }
 
 // AssetDir returns the file names below a certain
@@ -263,8 +847,26 @@ type bintree struct {
 	Children map[string]*bintree
 }
 var _bintree = &bintree{nil, map[string]*bintree{
+	"email": &bintree{nil, map[string]*bintree{
+		"base.tpl.html": &bintree{emailBaseTplHtml, map[string]*bintree{}},
+		"maintainer_added.tpl.html": &bintree{emailMaintainer_addedTplHtml, map[string]*bintree{}},
+		"maintainer_removed.tpl.html": &bintree{emailMaintainer_removedTplHtml, map[string]*bintree{}},
+		"new_version.tpl.html": &bintree{emailNew_versionTplHtml, map[string]*bintree{}},
+		"password_changed.tpl.html": &bintree{emailPassword_changedTplHtml, map[string]*bintree{}},
+		"subject.tpl.html": &bintree{emailSubjectTplHtml, map[string]*bintree{}},
+		"test.tpl.html": &bintree{emailTestTplHtml, map[string]*bintree{}},
+	}},
 	"index.tpl.html": &bintree{indexTplHtml, map[string]*bintree{}},
+	"package_detail.tpl.html": &bintree{package_detailTplHtml, map[string]*bintree{}},
 	"package_list.tpl.html": &bintree{package_listTplHtml, map[string]*bintree{}},
+	"project_detail.tpl.html": &bintree{project_detailTplHtml, map[string]*bintree{}},
+	"public": &bintree{nil, map[string]*bintree{
+		"base.tpl.html": &bintree{publicBaseTplHtml, map[string]*bintree{}},
+		"dependencies.tpl.html": &bintree{publicDependenciesTplHtml, map[string]*bintree{}},
+		"files.tpl.html": &bintree{publicFilesTplHtml, map[string]*bintree{}},
+		"history.tpl.html": &bintree{publicHistoryTplHtml, map[string]*bintree{}},
+		"list.tpl.html": &bintree{publicListTplHtml, map[string]*bintree{}},
+	}},
 }}
 
 // RestoreAsset restores an asset under the given directory
@@ -0,0 +1,211 @@
+package core
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/jinzhu/gorm"
+	toml "github.com/pelletier/go-toml"
+)
+
+/*
+testDBResult is response of test database to single statement
+*/
+type testDBResult struct {
+	Columns      []string
+	Rows         [][]driver.Value
+	RowsAffected int64
+	LastInsertID int64
+	Err          error
+}
+
+/*
+testDB is fake database that records executed statements and answers them with handler, so managers can be tested
+without database server
+*/
+type testDB struct {
+	handler func(query string, args []driver.Value) testDBResult
+
+	mutex   sync.Mutex
+	queries []string
+	args    [][]driver.Value
+}
+
+var (
+	testDBOnce      sync.Once
+	testDBSetupOnce sync.Once
+	testDBMutex     sync.Mutex
+	testDBRegistry  = map[string]*testDB{}
+)
+
+/*
+newTestDB returns gorm database of given dialect backed by fake database
+*/
+func newTestDB(t *testing.T, dialect string, handler func(query string, args []driver.Value) testDBResult) (*gorm.DB, *testDB) {
+	testDBOnce.Do(func() {
+		sql.Register("gopypi_test", testDriver{})
+	})
+
+	fake := &testDB{handler: handler}
+
+	testDBMutex.Lock()
+	dsn := fmt.Sprintf("test%v", len(testDBRegistry))
+	testDBRegistry[dsn] = fake
+	testDBMutex.Unlock()
+
+	db, err := gorm.Open(dialect, "gopypi_test", dsn)
+	if err != nil {
+		t.Fatalf("cannot open test database: %v", err)
+	}
+	db.SingularTable(true)
+
+	// callbacks are global, remove them only once
+	testDBSetupOnce.Do(func() {
+		setupDB(db)
+	})
+
+	return db, fake
+}
+
+/*
+testConfig is configuration used by newTestConfig, logs are discarded
+*/
+const testConfig = `
+[core]
+secret_key = "test secret"
+host = "http://localhost"
+
+[logging]
+level = "error"
+output = "/dev/null"
+`
+
+/*
+newTestConfig returns config with postgres database backed by fake database
+*/
+func newTestConfig(t *testing.T, handler func(query string, args []driver.Value) testDBResult) (*config, *testDB) {
+	db, fake := newTestDB(t, "postgres", handler)
+
+	tree, err := toml.Load(testConfig)
+	if err != nil {
+		t.Fatalf("cannot parse test config: %v", err)
+	}
+
+	cfg, err := newConfig(tree, db)
+	if err != nil {
+		t.Fatalf("cannot create test config: %v", err)
+	}
+	return cfg.(*config), fake
+}
+
+/*
+Queries returns executed statements that contain given text
+*/
+func (f *testDB) Queries(contains string) (result []string) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	for _, query := range f.queries {
+		if strings.Contains(query, contains) {
+			result = append(result, query)
+		}
+	}
+	return
+}
+
+/*
+run records statement and returns its result
+*/
+func (f *testDB) run(query string, args []driver.Value) testDBResult {
+	f.mutex.Lock()
+	f.queries = append(f.queries, query)
+	f.args = append(f.args, args)
+	f.mutex.Unlock()
+
+	if f.handler == nil {
+		return testDBResult{}
+	}
+	return f.handler(query, args)
+}
+
+type testDriver struct{}
+
+func (testDriver) Open(dsn string) (driver.Conn, error) {
+	testDBMutex.Lock()
+	defer testDBMutex.Unlock()
+
+	fake, ok := testDBRegistry[dsn]
+	if !ok {
+		return nil, fmt.Errorf("unknown test database %q", dsn)
+	}
+	return &testConn{db: fake}, nil
+}
+
+type testConn struct {
+	db *testDB
+}
+
+func (c *testConn) Prepare(query string) (driver.Stmt, error) {
+	return &testStmt{conn: c, query: query}, nil
+}
+
+func (c *testConn) Close() error              { return nil }
+func (c *testConn) Begin() (driver.Tx, error) { return testTx{}, nil }
+
+type testTx struct{}
+
+func (testTx) Commit() error   { return nil }
+func (testTx) Rollback() error { return nil }
+
+type testStmt struct {
+	conn  *testConn
+	query string
+}
+
+func (s *testStmt) Close() error  { return nil }
+func (s *testStmt) NumInput() int { return -1 }
+
+func (s *testStmt) Exec(args []driver.Value) (driver.Result, error) {
+	result := s.conn.db.run(s.query, args)
+	if result.Err != nil {
+		return nil, result.Err
+	}
+	return testExecResult{result}, nil
+}
+
+func (s *testStmt) Query(args []driver.Value) (driver.Rows, error) {
+	result := s.conn.db.run(s.query, args)
+	if result.Err != nil {
+		return nil, result.Err
+	}
+	return &testRows{result: result}, nil
+}
+
+type testExecResult struct {
+	result testDBResult
+}
+
+func (r testExecResult) LastInsertId() (int64, error) { return r.result.LastInsertID, nil }
+func (r testExecResult) RowsAffected() (int64, error) { return r.result.RowsAffected, nil }
+
+type testRows struct {
+	result testDBResult
+	index  int
+}
+
+func (r *testRows) Columns() []string { return r.result.Columns }
+func (r *testRows) Close() error      { return nil }
+
+func (r *testRows) Next(dest []driver.Value) error {
+	if r.index >= len(r.result.Rows) {
+		return io.EOF
+	}
+	copy(dest, r.result.Rows[r.index])
+	r.index++
+	return nil
+}
@@ -0,0 +1,402 @@
+/*
+Login throttling
+
+Failed logins are counted per username and per client ip address. When number of failures reaches configured limit,
+username (or ip address) is locked out. Lockout duration doubles with every further failure up to maximum lockout.
+Counters are reset after successful login (username counter) or when there was no failure in given window.
+
+Successfully verified credentials are cached for short time, so package installers that issue hundreds of requests
+with basic auth don't pay for password hash verification on every request.
+
+State is held in memory of gopypi process.
+*/
+package core
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"fmt"
+	"net"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+/*
+ThrottleError is returned when login is throttled, it carries time when user can try again
+*/
+type ThrottleError struct {
+	Until time.Time
+}
+
+/*
+Error returns error message
+*/
+func (t ThrottleError) Error() string {
+	return ErrLoginThrottled.Error()
+}
+
+/*
+RetryAfter returns number of seconds after which login can be retried
+*/
+func (t ThrottleError) RetryAfter() int {
+	seconds := int(time.Until(t.Until).Seconds()) + 1
+	if seconds < 1 {
+		seconds = 1
+	}
+	return seconds
+}
+
+/*
+NewLoginThrottle returns new login throttle. When maxFailures or ipMaxFailures is zero, given counter is disabled.
+*/
+func NewLoginThrottle(maxFailures, ipMaxFailures int, lockout, maxLockout, window time.Duration) *LoginThrottle {
+	return &LoginThrottle{
+		maxFailures:   maxFailures,
+		ipMaxFailures: ipMaxFailures,
+		lockout:       lockout,
+		maxLockout:    maxLockout,
+		window:        window,
+		entries:       map[string]*throttleEntry{},
+	}
+}
+
+/*
+LoginThrottle counts failed logins per username and ip address
+*/
+type LoginThrottle struct {
+	maxFailures   int
+	ipMaxFailures int
+	lockout       time.Duration
+	maxLockout    time.Duration
+	window        time.Duration
+
+	mutex     sync.Mutex
+	entries   map[string]*throttleEntry
+	lastPrune time.Time
+}
+
+/*
+throttleEntry holds failures for single username or ip address
+*/
+type throttleEntry struct {
+	failures    int
+	lastFailure time.Time
+	lockedUntil time.Time
+}
+
+/*
+LockedLogin is locked username or ip address as seen by admin
+*/
+type LockedLogin struct {
+	Username    string    `json:"username,omitempty"`
+	IP          string    `json:"ip,omitempty"`
+	Failures    int       `json:"failures"`
+	LastFailure time.Time `json:"last_failure"`
+	LockedUntil time.Time `json:"locked_until"`
+}
+
+/*
+Check returns ThrottleError when username or ip address is locked out
+*/
+func (l *LoginThrottle) Check(username, ip string) (err error) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	now := time.Now()
+	until := time.Time{}
+
+	for _, key := range l.keys(username, ip) {
+		if entry, ok := l.entries[key]; ok && entry.lockedUntil.After(now) && entry.lockedUntil.After(until) {
+			until = entry.lockedUntil
+		}
+	}
+
+	if !until.IsZero() {
+		return ThrottleError{Until: until}
+	}
+
+	return
+}
+
+/*
+Failure records failed login for username and ip address
+*/
+func (l *LoginThrottle) Failure(username, ip string) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	now := time.Now()
+	l.prune(now)
+
+	if username != "" && l.maxFailures > 0 {
+		l.failure("user:"+username, l.maxFailures, now)
+	}
+	if ip != "" && l.ipMaxFailures > 0 {
+		l.failure("ip:"+ip, l.ipMaxFailures, now)
+	}
+}
+
+/*
+Success resets failures for username. Ip address counter is not reset, so attacker with single valid account cannot
+reset it.
+*/
+func (l *LoginThrottle) Success(username string) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	delete(l.entries, "user:"+username)
+}
+
+/*
+Unlock removes lockout of username or ip address
+*/
+func (l *LoginThrottle) Unlock(username, ip string) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	for _, key := range l.keys(username, ip) {
+		delete(l.entries, key)
+	}
+}
+
+/*
+Locked returns list of currently locked usernames and ip addresses
+*/
+func (l *LoginThrottle) Locked() (result []LockedLogin) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	now := time.Now()
+	result = []LockedLogin{}
+
+	for key, entry := range l.entries {
+		if !entry.lockedUntil.After(now) {
+			continue
+		}
+		item := LockedLogin{
+			Failures:    entry.failures,
+			LastFailure: entry.lastFailure,
+			LockedUntil: entry.lockedUntil,
+		}
+		if key[:3] == "ip:" {
+			item.IP = key[3:]
+		} else {
+			item.Username = key[5:]
+		}
+		result = append(result, item)
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].LockedUntil.After(result[j].LockedUntil)
+	})
+
+	return
+}
+
+/*
+failure increments failures for given key and locks it out when limit is reached
+*/
+func (l *LoginThrottle) failure(key string, limit int, now time.Time) {
+	entry, ok := l.entries[key]
+	if !ok || (now.Sub(entry.lastFailure) > l.window && !entry.lockedUntil.After(now)) {
+		entry = &throttleEntry{}
+		l.entries[key] = entry
+	}
+
+	entry.failures++
+	entry.lastFailure = now
+
+	if entry.failures < limit {
+		return
+	}
+
+	// double lockout with every failure over limit
+	lockout := l.lockout
+	for i := limit; i < entry.failures && lockout < l.maxLockout; i++ {
+		lockout *= 2
+	}
+	if lockout > l.maxLockout {
+		lockout = l.maxLockout
+	}
+
+	entry.lockedUntil = now.Add(lockout)
+}
+
+/*
+prune removes stale entries, so memory doesn't grow when attacker uses many usernames or addresses
+*/
+func (l *LoginThrottle) prune(now time.Time) {
+	if now.Sub(l.lastPrune) < l.window {
+		return
+	}
+	l.lastPrune = now
+
+	for key, entry := range l.entries {
+		if now.Sub(entry.lastFailure) > l.window && !entry.lockedUntil.After(now) {
+			delete(l.entries, key)
+		}
+	}
+}
+
+/*
+keys returns throttle keys for username and ip address
+*/
+func (l *LoginThrottle) keys(username, ip string) (result []string) {
+	result = []string{}
+	if username != "" {
+		result = append(result, "user:"+username)
+	}
+	if ip != "" {
+		result = append(result, "ip:"+ip)
+	}
+	return
+}
+
+/*
+NewCredentialCache returns cache of verified credentials, zero ttl disables cache.
+*/
+func NewCredentialCache(secret string, ttl time.Duration) *CredentialCache {
+	return &CredentialCache{
+		secret:  secret,
+		ttl:     ttl,
+		entries: map[string]credentialEntry{},
+	}
+}
+
+/*
+CredentialCache caches successfully verified credentials. Credentials are stored as hmac, so plain passwords are
+never kept in memory.
+*/
+type CredentialCache struct {
+	secret string
+	ttl    time.Duration
+
+	mutex     sync.Mutex
+	entries   map[string]credentialEntry
+	lastPrune time.Time
+}
+
+/*
+credentialEntry is single cached credential
+*/
+type credentialEntry struct {
+	userID   uint
+	password string
+	expires  time.Time
+}
+
+/*
+Get returns cached user id for given credentials
+*/
+func (c *CredentialCache) Get(username, password string) (userID uint, passwordHash string, ok bool) {
+	if c.ttl <= 0 {
+		return
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	var entry credentialEntry
+	if entry, ok = c.entries[c.key(username, password)]; !ok {
+		return
+	}
+
+	if !entry.expires.After(time.Now()) {
+		delete(c.entries, c.key(username, password))
+		return 0, "", false
+	}
+
+	return entry.userID, entry.password, true
+}
+
+/*
+Set stores verified credentials along with current password hash of user, cache entry is not used when password
+changes.
+*/
+func (c *CredentialCache) Set(username, password string, user User) {
+	if c.ttl <= 0 {
+		return
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	now := time.Now()
+
+	// remove expired entries
+	if now.Sub(c.lastPrune) > c.ttl {
+		c.lastPrune = now
+		for key, entry := range c.entries {
+			if !entry.expires.After(now) {
+				delete(c.entries, key)
+			}
+		}
+	}
+
+	c.entries[c.key(username, password)] = credentialEntry{
+		userID:   user.ID,
+		password: user.Password,
+		expires:  now.Add(c.ttl),
+	}
+}
+
+/*
+key returns cache key for credentials
+*/
+func (c *CredentialCache) key(username, password string) string {
+	mac := hmac.New(sha256.New, []byte(c.secret))
+	mac.Write([]byte(username))
+	mac.Write([]byte{0})
+	mac.Write([]byte(password))
+	return fmt.Sprintf("%x", mac.Sum(nil))
+}
+
+/*
+AuthenticateRequest authenticates username and password from request against configured backends. Login throttling
+is applied and verified credentials are cached.
+*/
+func AuthenticateRequest(cfg Config, r *http.Request, username, password string) (user User, err error) {
+	throttle := cfg.Auth().Throttle()
+	cache := cfg.Auth().CredentialCache()
+	ip := RemoteIP(r)
+
+	if err = throttle.Check(username, ip); err != nil {
+		return
+	}
+
+	// try cache first, password hash must be the same as in the time of verification
+	if userID, passwordHash, ok := cache.Get(username, password); ok {
+		if cfg.DB().First(&user, "id = ?", userID).Error == nil && user.Password == passwordHash {
+			return
+		}
+		user = User{}
+	}
+
+	if user, err = cfg.Auth().Backend().Authenticate(username, password); err != nil {
+		throttle.Failure(username, ip)
+		return
+	}
+
+	throttle.Success(username)
+	cache.Set(username, password, user)
+
+	return
+}
+
+/*
+RemoteIP returns ip address of client without port, as resolved by RequestIDMiddleware (X-Forwarded-For and X-Real-IP
+are used only from trusted proxies)
+*/
+func RemoteIP(r *http.Request) string {
+	if info := ContextGetRequestInfo(r.Context()); info != nil && info.RemoteIP != "" {
+		return info.RemoteIP
+	}
+
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
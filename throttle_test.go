@@ -0,0 +1,196 @@
+package core
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestLoginThrottleLockout(t *testing.T) {
+	throttle := NewLoginThrottle(3, 0, time.Minute, 4*time.Minute, time.Hour)
+
+	for i := 0; i < 2; i++ {
+		throttle.Failure("user", "10.0.0.1")
+		if err := throttle.Check("user", "10.0.0.1"); err != nil {
+			t.Fatalf("Check after %v failures returned error: %v", i+1, err)
+		}
+	}
+
+	throttle.Failure("user", "10.0.0.1")
+	err := throttle.Check("user", "10.0.0.1")
+	te, ok := err.(ThrottleError)
+	if !ok {
+		t.Fatalf("Check after limit returned %v, expected ThrottleError", err)
+	}
+	if lockout := time.Until(te.Until); lockout < 59*time.Second || lockout > time.Minute {
+		t.Errorf("first lockout is %v, expected minute", lockout)
+	}
+	if retry := te.RetryAfter(); retry < 59 || retry > 61 {
+		t.Errorf("RetryAfter returned %v", retry)
+	}
+
+	// every further failure doubles lockout up to maximum
+	for _, expected := range []time.Duration{2 * time.Minute, 4 * time.Minute, 4 * time.Minute} {
+		throttle.Failure("user", "10.0.0.1")
+		te = throttle.Check("user", "").(ThrottleError)
+		if lockout := time.Until(te.Until); lockout < expected-time.Second || lockout > expected {
+			t.Errorf("lockout is %v, expected %v", lockout, expected)
+		}
+	}
+
+	// other username and disabled ip counter are not affected
+	if err = throttle.Check("other", "10.0.0.1"); err != nil {
+		t.Errorf("Check of other username returned error: %v", err)
+	}
+}
+
+func TestLoginThrottleIP(t *testing.T) {
+	throttle := NewLoginThrottle(0, 2, time.Minute, time.Hour, time.Hour)
+
+	throttle.Failure("first", "10.0.0.1")
+	throttle.Failure("second", "10.0.0.1")
+
+	if err := throttle.Check("third", "10.0.0.1"); err == nil {
+		t.Errorf("Check of locked ip address should return error")
+	}
+	if err := throttle.Check("first", "10.0.0.2"); err != nil {
+		t.Errorf("Check with disabled username counter returned error: %v", err)
+	}
+
+	// successful login doesn't reset ip address counter
+	throttle.Success("first")
+	if err := throttle.Check("", "10.0.0.1"); err == nil {
+		t.Errorf("Success should not unlock ip address")
+	}
+
+	locked := throttle.Locked()
+	if len(locked) != 1 || locked[0].IP != "10.0.0.1" || locked[0].Failures != 2 {
+		t.Errorf("Locked returned %+v", locked)
+	}
+
+	throttle.Unlock("", "10.0.0.1")
+	if err := throttle.Check("", "10.0.0.1"); err != nil {
+		t.Errorf("Check after Unlock returned error: %v", err)
+	}
+	if locked = throttle.Locked(); len(locked) != 0 {
+		t.Errorf("Locked after Unlock returned %+v", locked)
+	}
+}
+
+func TestLoginThrottleSuccess(t *testing.T) {
+	throttle := NewLoginThrottle(2, 0, time.Minute, time.Hour, time.Hour)
+
+	throttle.Failure("user", "")
+	throttle.Success("user")
+	throttle.Failure("user", "")
+
+	if err := throttle.Check("user", ""); err != nil {
+		t.Errorf("Success should reset username counter, Check returned %v", err)
+	}
+}
+
+func TestCredentialCache(t *testing.T) {
+	cache := NewCredentialCache("secret", time.Minute)
+	user := User{ID: 1, Password: "hash"}
+
+	if _, _, ok := cache.Get("user", "password"); ok {
+		t.Fatalf("empty cache returned credentials")
+	}
+
+	cache.Set("user", "password", user)
+	if id, hash, ok := cache.Get("user", "password"); !ok || id != user.ID || hash != user.Password {
+		t.Errorf("Get returned %v, %q, %v", id, hash, ok)
+	}
+	if _, _, ok := cache.Get("user", "wrong"); ok {
+		t.Errorf("Get with wrong password returned credentials")
+	}
+	if _, _, ok := cache.Get("userp", "assword"); ok {
+		t.Errorf("Get with shifted username and password returned credentials")
+	}
+
+	disabled := NewCredentialCache("secret", 0)
+	disabled.Set("user", "password", user)
+	if _, _, ok := disabled.Get("user", "password"); ok {
+		t.Errorf("disabled cache returned credentials")
+	}
+}
+
+func TestParseTrustedProxies(t *testing.T) {
+	proxies, err := ParseTrustedProxies([]string{"127.0.0.1", " 10.0.0.0/8 ", "::1", "fd00::/8"})
+	if err != nil {
+		t.Fatalf("ParseTrustedProxies returned error: %v", err)
+	}
+
+	for ip, expected := range map[string]bool{
+		"127.0.0.1": true,
+		"127.0.0.2": false,
+		"10.1.2.3":  true,
+		"11.0.0.1":  false,
+		"::1":       true,
+		"fd00::1":   true,
+		"fe80::1":   false,
+		"invalid":   false,
+	} {
+		if result := proxies.Contains(ip); result != expected {
+			t.Errorf("Contains(%q) returned %v", ip, result)
+		}
+	}
+
+	for _, value := range []string{"", "localhost", "10.0.0.0/33", "10.0.0"} {
+		if _, err := ParseTrustedProxies([]string{value}); err == nil {
+			t.Errorf("ParseTrustedProxies(%q) should return error", value)
+		}
+	}
+}
+
+func TestTrustedProxiesClientIP(t *testing.T) {
+	proxies, _ := ParseTrustedProxies([]string{"10.0.0.0/8"})
+
+	tc := []struct {
+		remote    string
+		forwarded string
+		real      string
+		out       string
+	}{
+		{"192.0.2.1:1234", "", "", "192.0.2.1"},
+		{"192.0.2.1:1234", "198.51.100.1", "198.51.100.2", "192.0.2.1"},
+		{"10.0.0.1:1234", "", "", "10.0.0.1"},
+		{"10.0.0.1:1234", "198.51.100.1", "", "198.51.100.1"},
+		{"10.0.0.1:1234", "", "198.51.100.2", "198.51.100.2"},
+		{"10.0.0.1:1234", "198.51.100.1", "198.51.100.2", "198.51.100.1"},
+		{"10.0.0.1:1234", "203.0.113.9, 198.51.100.1, 10.0.0.2", "", "198.51.100.1"},
+		{"10.0.0.1:1234", "10.0.0.3, 10.0.0.2", "", "10.0.0.3"},
+		{"10.0.0.1:1234", "garbage, 198.51.100.1", "", "198.51.100.1"},
+		{"10.0.0.1:1234", "198.51.100.1, garbage", "", "10.0.0.1"},
+		{"10.0.0.1:1234", "", "garbage", "10.0.0.1"},
+		{"[::1]:1234", "198.51.100.1", "", "::1"},
+	}
+
+	for _, tt := range tc {
+		t.Run(tt.remote+" "+tt.forwarded+" "+tt.real, func(st *testing.T) {
+			r := &http.Request{RemoteAddr: tt.remote, Header: http.Header{}}
+			if tt.forwarded != "" {
+				r.Header.Set("X-Forwarded-For", tt.forwarded)
+			}
+			if tt.real != "" {
+				r.Header.Set("X-Real-IP", tt.real)
+			}
+			if result := proxies.ClientIP(r); result != tt.out {
+				st.Errorf("ClientIP returned %q, expected %q", result, tt.out)
+			}
+		})
+	}
+}
+
+func TestRemoteIP(t *testing.T) {
+	r := &http.Request{RemoteAddr: "10.0.0.1:1234", Header: http.Header{}}
+	if result := RemoteIP(r); result != "10.0.0.1" {
+		t.Errorf("RemoteIP without request info returned %q", result)
+	}
+
+	r = r.WithContext(context.WithValue(context.Background(), CONTEXT_REQUEST_INFO, &RequestInfo{RemoteIP: "198.51.100.1"}))
+	if result := RemoteIP(r); result != "198.51.100.1" {
+		t.Errorf("RemoteIP with request info returned %q", result)
+	}
+}
@@ -32,3 +32,13 @@ TimeAlignWeek aligns date to first day of year and strips time and timezone
 func TimeAlignYear(t time.Time) time.Time {
 	return TimeAlignMonth(t).AddDate(0, -int(t.Month())+1, 0)
 }
+
+/*
+TimeParse parses time in RFC3339 format or date only (2006-01-02) in UTC
+*/
+func TimeParse(value string) (result time.Time, err error) {
+	if result, err = time.Parse(time.RFC3339, value); err == nil {
+		return
+	}
+	return time.Parse("2006-01-02", value)
+}
@@ -9,7 +9,6 @@ import (
 
 	"time"
 
-	"github.com/jinzhu/gorm"
 	"github.com/dgrijalva/jwt-go"
 )
 
@@ -22,14 +21,14 @@ type TokenClaims struct {
 }
 
 /*
-CreateToken creates auth token
+CreateToken creates access token for given session. Session key is stored in jti claim.
 */
-func CreateToken(db *gorm.DB, user User, secret string, expiration int) (result string, err error) {
+func CreateToken(session Session, secret string, expiration int) (result string, err error) {
 
 	claims := TokenClaims{
-		UserID: user.ID,
+		UserID: session.UserID,
 		StandardClaims: jwt.StandardClaims{
-			// what to do with this
+			Id:        session.Key,
 			ExpiresAt: time.Now().Unix() + int64(expiration),
 			Issuer:    TOKEN_ISSUER,
 		},
@@ -41,6 +40,54 @@ func CreateToken(db *gorm.DB, user User, secret string, expiration int) (result
 	return
 }
 
+/*
+TokenResponse is returned from login and refresh endpoints
+*/
+type TokenResponse struct {
+	Token        string `json:"token"`
+	RefreshToken string `json:"refresh_token"`
+	ExpiresIn    int    `json:"expires_in"`
+}
+
+/*
+CreateSessionToken creates new session for user (login) and returns access and refresh tokens
+*/
+func CreateSessionToken(cfg Config, user User, r *http.Request) (result TokenResponse, err error) {
+	var session Session
+
+	if session, result.RefreshToken, err = cfg.Manager().Session().Create(user, r.UserAgent(), RemoteIP(r)); err != nil {
+		return
+	}
+
+	result.ExpiresIn = TOKEN_EXPIRATION
+	result.Token, err = CreateToken(session, cfg.Core().SecretKey(), TOKEN_EXPIRATION)
+	return
+}
+
+/*
+RefreshSessionToken rotates refresh token and returns new access token for the same session
+*/
+func RefreshSessionToken(cfg Config, refresh string) (result TokenResponse, err error) {
+	var session Session
+
+	if session, result.RefreshToken, err = cfg.Manager().Session().Refresh(refresh); err != nil {
+		return
+	}
+
+	// user must be still active
+	user := User{}
+	if err = cfg.DB().First(&user, "id = ?", session.UserID).Error; err != nil {
+		return
+	}
+	if err = PermissionActive(user); err != nil {
+		return
+	}
+
+	result.ExpiresIn = TOKEN_EXPIRATION
+	result.Token, err = CreateToken(session, cfg.Core().SecretKey(), TOKEN_EXPIRATION)
+	return
+}
+
 /*
 ParseToken parses token and returns claims
 */
@@ -115,8 +162,32 @@ func ContextGetTokenUser(ctx context.Context) (user User, err error) {
 }
 
 /*
-Return token claims from request context
+Return token claims from request context, username is stored to request info for access log
 */
 func ContextSetTokenUser(ctx context.Context, user User) (result context.Context) {
+	if info := ContextGetRequestInfo(ctx); info != nil {
+		info.Username = user.Username
+	}
 	return context.WithValue(ctx, CONTEXT_TOKEN_USER, user)
 }
+
+/*
+Return session of token from request context
+*/
+func ContextGetTokenSession(ctx context.Context) (session Session, err error) {
+	result := ctx.Value(CONTEXT_TOKEN_SESSION)
+
+	var ok bool
+	if session, ok = result.(Session); !ok {
+		err = ErrTokenInvalid
+	}
+
+	return
+}
+
+/*
+Set session of token to request context
+*/
+func ContextSetTokenSession(ctx context.Context, session Session) (result context.Context) {
+	return context.WithValue(ctx, CONTEXT_TOKEN_SESSION, session)
+}
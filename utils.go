@@ -10,6 +10,7 @@ import (
 	"io/ioutil"
 	"net/http"
 	"path"
+	"sort"
 	"strings"
 )
 
@@ -135,3 +136,30 @@ NormalizePackageName normalizes package name by PEP 503
 func NormalizePackageName(name string) string {
 	return replacer.Replace(name)
 }
+
+/*
+IsDevVersion returns whether version is PEP 440 dev release (e.g. "1.0.dev3")
+*/
+func IsDevVersion(version string) bool {
+	return devVersionRegexp.MatchString(strings.TrimSpace(version))
+}
+
+/*
+SortPackagesByIndex sorts packages by name and then by position of their index in lookup order (as returned by
+IndexManager.ResolveIDs)
+*/
+func SortPackagesByIndex(packages []Package, ids []uint) []Package {
+	position := map[uint]int{}
+	for i, id := range ids {
+		position[id] = i
+	}
+
+	sort.SliceStable(packages, func(i, j int) bool {
+		if packages[i].Name != packages[j].Name {
+			return packages[i].Name < packages[j].Name
+		}
+		return position[packages[i].IndexID] < position[packages[j].IndexID]
+	})
+
+	return packages
+}
@@ -164,3 +164,30 @@ func ValidateEmail(field string, value *string, vr ValidationResult, required ..
 
 	return govalidator.IsEmail(*value)
 }
+
+/*
+ValidateIndexName validates index name. Last part of name cannot be "simple", it would clash with simple urls.
+*/
+func ValidateIndexName(field string, value *string, vr ValidationResult) bool {
+	*value = strings.TrimSpace(*value)
+
+	parts := strings.Split(*value, "/")
+	if !indexNameRegexp.MatchString(*value) || len(*value) > INDEX_NAME_MAX_LENGTH || parts[len(parts)-1] == "simple" {
+		vr.AddFieldError(field, ErrIndexInvalidName)
+		return false
+	}
+	return true
+}
+
+/*
+ValidateUploadPolicy validates upload policy, blank value is allowed (policy is inherited)
+*/
+func ValidateUploadPolicy(field string, value *string, vr ValidationResult) bool {
+	*value = strings.TrimSpace(*value)
+
+	if *value != "" && !StringListContains(AVAILABLE_UPLOAD_POLICIES, *value) {
+		vr.AddFieldError(field, ErrUploadPolicyUnknown)
+		return false
+	}
+	return true
+}
@@ -3,6 +3,9 @@ package core
 import (
 	"net/http"
 
+	"bytes"
+	"crypto/subtle"
+	"fmt"
 	"io"
 	"os"
 	"path/filepath"
@@ -10,11 +13,16 @@ import (
 	"strings"
 
 	"io/ioutil"
+	"runtime"
 	"strconv"
+	"sync/atomic"
+	"time"
 
 	"github.com/gorilla/mux"
-	"github.com/phonkee/go-response"
+	"github.com/jinzhu/gorm"
 	"github.com/phonkee/go-classy"
+	"github.com/phonkee/go-response"
+	"github.com/uber-go/zap"
 	"gopkg.in/h2non/filetype.v0"
 )
 
@@ -25,7 +33,6 @@ Be careful that template view needs Config and TemplateName.
 Example how ::
 
 	classy.New(TemplateView{Config: cfg, TemplateName: "homepage.tpl.html"}).Register(router, "homepage")
-
 */
 type TemplateView struct {
 	classy.GenericView
@@ -120,36 +127,60 @@ ActionFileUpload Handles file upload which is called with
 func (p *PostPackageView) ActionFileUpload(r *http.Request) response.Response {
 
 	var (
-		err  error
-		pack Package
+		err   error
+		index Index
+		pack  Package
+		user  User
 	)
 
+	start := time.Now()
+	metrics := p.Config.Metrics().Registry()
+
+	// get user from context
+	if user, err = ContextGetTokenUser(r.Context()); err != nil {
+		return response.Error(err)
+	}
+
+	// get index from url, uploads to / go to default index
+	name := mux.Vars(r)["index"]
+	if name == "" {
+		name = DEFAULT_INDEX
+	}
+	if index, err = p.Config.Manager().Index().GetByName(name); err != nil {
+		return response.NotFound().Error(err)
+	}
+
+	if !p.Config.Manager().Index().CanUpload(index, user) {
+		return response.New(http.StatusForbidden).Error(ErrIndexUploadForbidden)
+	}
+
 	// get package by request
-	if pack, err = GetPostedPackage(p.Config, r); err != nil {
+	if pack, err = GetPostedPackage(p.Config, index, r); err != nil {
 		return response.New(http.StatusBadRequest).Error(err)
 	}
 
-	var (
-		user User
-	)
+	// check filename before anything is stored
+	var dist DistributionFilename
+	if dist, err = GetPostedDistribution(r); err != nil {
+		return response.New(http.StatusBadRequest).Error(err)
+	}
 
-	// get user from context
-	if user, err = ContextGetTokenUser(r.Context()); err != nil {
-		return response.Error(err)
+	var requiresPython string
+	if requiresPython, err = GetPostedRequiresPython(r); err != nil {
+		return response.New(http.StatusBadRequest).Error(err)
+	}
+
+	var requirements []Requirement
+	if requirements, err = GetPostedRequirements(r); err != nil {
+		return response.New(http.StatusBadRequest).Error(err)
 	}
 
 	// if package is newly created, check permissions
 	if p.Config.DB().NewRecord(pack) {
-
 		// check if user can create new package
 		if !user.CanCreate {
 			return response.New(http.StatusForbidden)
 		}
-
-		// try to save package to database
-		if err = p.Config.DB().Create(&pack).Error; err != nil {
-			return response.Error(err)
-		}
 	} else {
 		// check if user is maintainer or author
 		if !(pack.AuthorID == user.ID || (p.Config.Manager().Package().IsMaintainer(&pack, &user) && user.CanUpdate)) {
@@ -157,6 +188,30 @@ func (p *PostPackageView) ActionFileUpload(r *http.Request) response.Response {
 		}
 	}
 
+	// check upload policy before package or version is stored (and events are fired), file with the same name can
+	// be replaced only when upload policy allows it
+	policy := p.Config.Manager().Package().UploadPolicy(pack)
+	existing := PackageVersion{Version: strings.TrimSpace(r.Form.Get("version"))}
+	exists := false
+	if !p.Config.DB().NewRecord(pack) && p.Config.DB().First(&existing, "package_id = ? AND version = ?", pack.ID, existing.Version).Error == nil {
+		exists = p.Config.Manager().PackageVersionFile().Exists(existing, dist.Filename)
+	}
+	if err = p.Config.Manager().PackageVersionFile().CheckUpload(policy, pack, existing, dist.Filename, exists); err != nil {
+		return response.New(http.StatusConflict).Error(err)
+	}
+
+	if p.Config.DB().NewRecord(pack) {
+		// try to save package to database
+		if err = p.Config.DB().Create(&pack).Error; err != nil {
+			return response.Error(err)
+		}
+
+		NewAuditEntry(AUDIT_ACTION_PACKAGE_CREATE).
+			Request(r).
+			Target(AUDIT_TARGET_PACKAGE, pack.ID, pack.Name).
+			Save(p.Config)
+	}
+
 	var (
 		pv PackageVersion
 	)
@@ -177,6 +232,20 @@ func (p *PostPackageView) ActionFileUpload(r *http.Request) response.Response {
 		if err := p.Config.Manager().Package().UpdateVersionOrder(pack); err != nil {
 			return response.Error(err)
 		}
+
+		NewAuditEntry(AUDIT_ACTION_VERSION_CREATE).
+			Request(r).
+			Target(AUDIT_TARGET_VERSION, pv.ID, pack.Name+" "+pv.Version).
+			Save(p.Config)
+
+		NewWebhookEvent(WEBHOOK_EVENT_VERSION_CREATE).
+			Actor(user).
+			Package(pack).
+			Version(pv).
+			Fire(p.Config)
+
+		// let other maintainers know about new version
+		NotifyMaintainers(p.Config, NewNotification(NOTIFICATION_NEW_VERSION, "email/new_version.tpl.html").Version(pv), pack, user)
 	}
 
 	var (
@@ -189,18 +258,60 @@ func (p *PostPackageView) ActionFileUpload(r *http.Request) response.Response {
 		return response.Error(err)
 	}
 
-	// file already exists in database
-	if !p.Config.DB().NewRecord(pvf) {
-		return response.New(http.StatusForbidden)
+	// check again, concurrent upload could store file with the same name in the meantime
+	exists = !p.Config.DB().NewRecord(pvf)
+	if err = p.Config.Manager().PackageVersionFile().CheckUpload(policy, pack, pv, pvf.Filename, exists); err != nil {
+		os.Remove(f)
+		return response.New(http.StatusConflict).Error(err)
+	}
+
+	// replacing file is stored to new path, replaced file is removed when database is updated
+	replaced := pvf
+	if exists {
+		var content []byte
+		if content, err = ioutil.ReadFile(f); err != nil {
+			return response.Error(err)
+		}
+		pvf.MD5Digest = MD5(string(content))
+		pvf.RelativePath = pvf.GenerateRelativePath()
+		pvf.AuthorID = user.ID
 	}
 
 	// assign author to file
 	pvf.Author = &user
+	dist.Apply(&pvf)
+
+	// metadata stored in file takes precedence over form fields
+	if meta, errMeta := ReadDistributionMetadata(f, dist); errMeta != nil {
+		RequestLogger(p.Config, r).Debug("cannot read distribution metadata",
+			zap.String("file", pvf.Filename),
+			zap.String("error", errMeta.Error()),
+		)
+	} else {
+		if value := meta.Get("Requires-Python"); value != "" && ValidateRequiresPython(value) == nil {
+			requiresPython = value
+		}
+		if values := meta.GetAll("Requires-Dist"); len(values) > 0 {
+			requirements = ParseRequirements(values)
+		}
+
+		// release uploaded without description gets it from metadata
+		if pv.Description == "" {
+			if err = p.Config.Manager().PackageVersion().SetDescription(&pv, meta); err != nil {
+				return response.Error(err)
+			}
+		}
+		if err = p.Config.Manager().PackageVersion().SetKeywords(&pv, meta.Get("Keywords")); err != nil {
+			return response.Error(err)
+		}
+	}
+	pvf.RequiresPython = requiresPython
 
 	abspath := filepath.Join(p.Config.Packages().Directory(), pvf.RelativePath)
 	fullfilename := filepath.Join(abspath, pvf.Filename)
 
 	if err = os.MkdirAll(abspath, 0777); err != nil {
+		metrics.StorageErrors.Inc("write")
 		return response.Error(err)
 	}
 
@@ -219,30 +330,154 @@ func (p *PostPackageView) ActionFileUpload(r *http.Request) response.Response {
 	defer sourcefile.Close()
 
 	if targetfile, err = os.Create(fullfilename); err != nil {
+		metrics.StorageErrors.Inc("write")
 		return response.Error(err)
 	}
 
 	// cleanup
 	defer targetfile.Close()
 
+	var size int64
+
 	// copy from file to target
-	if _, err = io.Copy(targetfile, sourcefile); err != nil {
+	if size, err = io.Copy(targetfile, sourcefile); err != nil {
+		metrics.StorageErrors.Inc("write")
 		return response.Error(err)
 	}
 
 	// flush contents
 	if err = targetfile.Sync(); err != nil {
+		metrics.StorageErrors.Inc("write")
+		return response.Error(err)
+	}
+
+	if exists {
+		if err = p.Config.DB().Model(&pvf).UpdateColumns(map[string]interface{}{
+			"md5_digest":      pvf.MD5Digest,
+			"relative_path":   pvf.RelativePath,
+			"author_id":       pvf.AuthorID,
+			"package_type":    pvf.PackageType,
+			"build_tag":       pvf.BuildTag,
+			"python_tag":      pvf.PythonTag,
+			"abi_tag":         pvf.ABITag,
+			"platform_tag":    pvf.PlatformTag,
+			"requires_python": pvf.RequiresPython,
+		}).Error; err != nil {
+			return response.Error(err)
+		}
+
+		if err = os.Remove(p.Config.Manager().PackageVersionFile().GetAbsoluteFilename(&replaced)); err != nil && !os.IsNotExist(err) {
+			metrics.StorageErrors.Inc("remove")
+		}
+
+		p.Config.Manager().PackageVersionFile().RecordHistory(FILE_HISTORY_REPLACE, pack, pv, pvf, replaced.MD5Digest, &user)
+
+		NewAuditEntry(AUDIT_ACTION_FILE_REPLACE).
+			Request(r).
+			Target(AUDIT_TARGET_FILE, pvf.ID, pvf.Filename).
+			Change("md5_digest", replaced.MD5Digest, pvf.MD5Digest).
+			Change("upload_policy", nil, policy).
+			Save(p.Config)
+	} else {
+		// create PackageVersionFile
+		if err = p.Config.DB().Create(&pvf).Error; err != nil {
+			return response.Error(err)
+		}
+
+		p.Config.Manager().PackageVersionFile().RecordHistory(FILE_HISTORY_UPLOAD, pack, pv, pvf, "", &user)
+
+		NewAuditEntry(AUDIT_ACTION_FILE_UPLOAD).
+			Request(r).
+			Target(AUDIT_TARGET_FILE, pvf.ID, pvf.Filename).
+			Change("md5_digest", nil, pvf.MD5Digest).
+			Save(p.Config)
+	}
+
+	// link file to its platforms and tags
+	if err = p.Config.Manager().PackageVersionFile().UpdateTags(&pvf); err != nil {
 		return response.Error(err)
 	}
 
-	// create PackageVersionFile
-	if err = p.Config.DB().Create(&pvf).Error; err != nil {
+	// release without dependencies gets them from its first file
+	if err = p.Config.Manager().PackageVersion().SetMissingDependencies(pv, requirements); err != nil {
 		return response.Error(err)
 	}
 
+	// release without requires python gets it from its first file
+	if pv.RequiresPython == "" && pvf.RequiresPython != "" {
+		if err = p.Config.DB().Model(&pv).UpdateColumn("requires_python", pvf.RequiresPython).Error; err != nil {
+			return response.Error(err)
+		}
+	}
+
+	NewWebhookEvent(WEBHOOK_EVENT_FILE_UPLOAD).
+		Actor(user).
+		Package(pack).
+		Version(pv).
+		File(p.Config, pvf).
+		Fire(p.Config)
+
+	metrics.UploadBytes.Add(float64(size))
+	metrics.UploadDuration.Observe(time.Now().Sub(start).Seconds())
+
 	return response.OK()
 }
 
+/*
+GetRequestIndex returns index given in url (default index when url has no index) when user can read it
+*/
+func GetRequestIndex(cfg Config, r *http.Request) (index Index, err error) {
+	name := mux.Vars(r)["index"]
+	if name == "" {
+		name = DEFAULT_INDEX
+	}
+
+	if index, err = cfg.Manager().Index().GetByName(name); err != nil {
+		return
+	}
+
+	// unreadable index is reported as not found
+	user, _ := ContextGetTokenUser(r.Context())
+	if !cfg.Manager().Index().CanRead(index, user) {
+		err = ErrIndexNotFound
+	}
+
+	return
+}
+
+/*
+requestBaseURL returns scheme and host of request (as seen by client behind proxy)
+*/
+func requestBaseURL(r *http.Request) string {
+	scheme := "http"
+	if r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https" {
+		scheme = "https"
+	}
+	return scheme + "://" + r.Host
+}
+
+/*
+simpleRouteName returns name of simple route for index (index routes have "index_" prefix)
+*/
+func simpleRouteName(r *http.Request, name string) string {
+	if mux.Vars(r)["index"] != "" {
+		return "index_" + name
+	}
+	return name
+}
+
+/*
+simpleLink is single file link on simple package detail page
+*/
+type simpleLink struct {
+	Filename       string
+	URL            string
+	MD5Digest      string
+	RequiresPython string
+	Yanked         bool
+	YankedReason   string
+}
+
 /*
 PackageListView returns list of packages
 */
@@ -254,28 +489,49 @@ type PackageListView struct {
 }
 
 /*
-List (http GET) returns list of all packages
+List (http GET) returns list of all packages of index and its bases. When package with same name exists in multiple
+indexes, package from first index in lookup order is listed.
 
 if `format` url query is set to json, json response will be returned
 */
 func (p *PackageListView) List(rw http.ResponseWriter, r *http.Request) response.Response {
 	var (
-		err  error
-		list []Package
+		err   error
+		index Index
+		ids   []uint
+		all   []Package
 	)
 
+	if index, err = GetRequestIndex(p.Config, r); err != nil {
+		return response.NotFound().Error(err)
+	}
+
+	if ids, err = p.Config.Manager().Index().ResolveIDs(index); err != nil {
+		return response.Error(err)
+	}
+
 	// list all packages
-	if err = p.Config.DB().Order("name").Find(&list).Error; err != nil {
+	if err = p.Config.DB().Where("index_id IN (?)", ids).Order("name").Find(&all).Error; err != nil {
 		return response.Error(err)
 	}
 
+	list := make([]Package, 0, len(all))
+	for _, pack := range SortPackagesByIndex(all, ids) {
+		if len(list) > 0 && list[len(list)-1].Name == pack.Name {
+			continue
+		}
+		list = append(list, pack)
+	}
+
 	// handle ?format=json
 	if r.URL.Query().Get("format") == "json" {
 		return response.OK().SliceResult(list)
 	}
 
 	data := map[string]interface{}{
-		"Packages": list,
+		"Index":       index.Name,
+		"DetailRoute": simpleRouteName(r, "package_detail"),
+		"Packages":    list,
 	}
 
 	var rendered string
@@ -298,19 +554,116 @@ type PackageDetailView struct {
 }
 
 /*
-Retrieve is GET method
+Retrieve is GET method, it returns simple page with links to files of package found in index and its bases.
+
+if `format` url query is set to json, package from first index in lookup order is returned
 */
 func (p *PackageDetailView) Retrieve(w http.ResponseWriter, r *http.Request) response.Response {
+	var (
+		err      error
+		index    Index
+		ids      []uint
+		packages []Package
+	)
+
 	slug := mux.Vars(r)["slug"]
 
-	pack := &Package{}
+	if index, err = GetRequestIndex(p.Config, r); err != nil {
+		return response.NotFound().Error(err)
+	}
+
+	if ids, err = p.Config.Manager().Index().ResolveIDs(index); err != nil {
+		return response.Error(err)
+	}
+
+	if err = ApplyFilterFuncs(p.Config.DB(), FFPackageName(slug), FFPackagesInIndexes(ids...)).
+		Preload("Versions", func(db *gorm.DB) *gorm.DB { return db.Order("version_order ASC") }).
+		Preload("Versions.License").
+		Preload("Versions.Files").
+		Find(&packages).Error; err != nil {
+		return response.Error(err)
+	}
 
-	if p.Config.DB().Where("name = ?", slug).Preload("Versions").Preload("Versions.License").First(pack).RecordNotFound() {
+	if len(packages) == 0 {
 		return response.New(http.StatusNotFound)
 	}
 
-	return response.OK().Result(pack)
+	packages = SortPackagesByIndex(packages, ids)
+
+	// handle ?format=json
+	if r.URL.Query().Get("format") == "json" {
+		pack := packages[0]
+		for i := range pack.Versions {
+			pack.Versions[i].Files = nil
+		}
+		return response.OK().Result(pack)
+	}
+
+	// files with same name from later indexes are shadowed
+	links := []simpleLink{}
+	seen := map[string]bool{}
+	for _, pack := range packages {
+		for _, version := range pack.Versions {
+			for _, file := range version.Files {
+				if seen[file.Filename] {
+					continue
+				}
+				seen[file.Filename] = true
+
+				// files uploaded without requires python inherit it from release
+				requiresPython := file.RequiresPython
+				if requiresPython == "" {
+					requiresPython = version.RequiresPython
+				}
+
+				vfile := file
+				links = append(links, simpleLink{
+					Filename:       file.Filename,
+					URL:            p.Config.Manager().PackageVersionFile().GetDownloadURL(&vfile),
+					MD5Digest:      file.MD5Digest,
+					RequiresPython: requiresPython,
+					Yanked:         version.Yanked,
+					YankedReason:   version.YankedReason,
+				})
+			}
+		}
+	}
+
+	data := map[string]interface{}{
+		"Name":  packages[0].Name,
+		"Links": links,
+	}
+
+	var rendered string
 
+	if rendered, err = p.Config.RenderTemplate(data, "detail", "package_detail.tpl.html"); err != nil {
+		return response.Error(err)
+	}
+
+	return response.OK().HTML(rendered)
+}
+
+/*
+ProjectDetailView is public page of package with rendered description
+*/
+type ProjectDetailView struct {
+	classy.SlugDetailView
+
+	// config instance
+	Config Config
+}
+
+/*
+Retrieve renders page of package found in index and its bases, latest version is shown unless "version" query
+parameter is given. Page is rendered with layout of public pages (see ProjectListView).
+*/
+func (p *ProjectDetailView) Retrieve(w http.ResponseWriter, r *http.Request) response.Response {
+	page, err := getProjectPage(p.Config, r, "detail")
+	if err != nil {
+		return projectErrorResponse(err)
+	}
+
+	return renderProjectPage(p.Config, page, "project_detail.tpl.html")
 }
 
 /*
@@ -338,7 +691,7 @@ func (p *PackageDownloadView) Routes() (result map[string]classy.Mapping) {
 Download returns content of requested file.
 
 Aside of that, when download_stats feature is enabled, stats will be recorded to database.
- */
+*/
 func (p *PackageDownloadView) Download(w http.ResponseWriter, r *http.Request) response.Response {
 
 	filename := mux.Vars(r)["filename"]
@@ -358,6 +711,17 @@ func (p *PackageDownloadView) Download(w http.ResponseWriter, r *http.Request) r
 		return response.NotFound()
 	}
 
+	var user *User
+	if tokenUser, err := ContextGetTokenUser(r.Context()); err == nil {
+		user = &tokenUser
+	}
+
+	// file is downloadable only through index user can read
+	pack, err := p.Config.Manager().PackageVersionFile().GetPackage(&pvf)
+	if err != nil || user == nil || !p.Config.Manager().Index().CanReadPackage(pack, *user) {
+		return response.NotFound()
+	}
+
 	// return file
 	absfilename := p.Config.Manager().PackageVersionFile().GetAbsoluteFilename(&pvf)
 
@@ -365,6 +729,7 @@ func (p *PackageDownloadView) Download(w http.ResponseWriter, r *http.Request) r
 	result := response.OK()
 
 	if typ, err := filetype.MatchFile(absfilename); err != nil {
+		p.Config.Metrics().Registry().StorageErrors.Inc("read")
 		return response.Error(err)
 	} else {
 		result.Header("Content-Type", typ.MIME.Value)
@@ -374,14 +739,169 @@ func (p *PackageDownloadView) Download(w http.ResponseWriter, r *http.Request) r
 
 	// read file contents
 	if body, err := ioutil.ReadFile(absfilename); err != nil {
+		p.Config.Metrics().Registry().StorageErrors.Inc("read")
 		return response.Error(err)
 	} else {
 		result.Header("Content-Length", strconv.Itoa(len(body)))
 		result.Body(body)
 	}
 
-	// Add download
-	p.Config.Manager().DownloadStats().AddDownloadFile(&pvf)
+	// Add download, it's written to database in background
+	p.Config.DownloadStats().Collector().Add(&pvf, user, r.UserAgent())
+	p.Config.Metrics().Registry().Downloads.Inc(pack.Name)
 
 	return result
 }
+
+/*
+MetricsView serves metrics in Prometheus text format
+*/
+type MetricsView struct {
+	classy.GenericView
+
+	// config instance
+	Config Config
+}
+
+/*
+GET writes all metrics, when metrics token is configured it's required in Authorization header
+*/
+func (m *MetricsView) GET(w http.ResponseWriter, r *http.Request) response.Response {
+	if !m.Config.Metrics().Enabled() {
+		return response.NotFound()
+	}
+
+	if token := m.Config.Metrics().Token(); token != "" {
+		given := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if subtle.ConstantTimeCompare([]byte(given), []byte(token)) != 1 {
+			m.Config.Metrics().Registry().AuthFailures.Inc("metrics", "invalid_token")
+			return response.New(http.StatusUnauthorized).Error(ErrMetricsInvalidToken)
+		}
+	}
+
+	buf := &bytes.Buffer{}
+	if err := m.Config.Metrics().Registry().Write(buf, m.Config); err != nil {
+		return response.Error(err)
+	}
+
+	return response.OK().
+		Header("Content-Type", "text/plain; version=0.0.4; charset=utf-8").
+		Body(buf.Bytes())
+}
+
+/*
+HealthView reports that process is alive, it doesn't check any dependencies
+*/
+type HealthView struct {
+	classy.GenericView
+
+	// config instance
+	Config Config
+}
+
+/*
+GET returns ok status
+*/
+func (h *HealthView) GET(w http.ResponseWriter, r *http.Request) response.Response {
+	return response.OK().Result(map[string]string{"status": "ok"})
+}
+
+/*
+ReadyView reports whether server is able to serve requests: database is reachable, migrations are applied and
+packages directory is writable
+*/
+type ReadyView struct {
+	classy.GenericView
+
+	// config instance
+	Config Config
+
+	// migrations are checked until they are applied once
+	migrated int32
+}
+
+/*
+GET returns result of every check, when any check fails status is 503
+*/
+func (v *ReadyView) GET(w http.ResponseWriter, r *http.Request) response.Response {
+	checks := map[string]string{}
+	ready := true
+
+	check := func(name string, err error) {
+		checks[name] = "ok"
+		if err != nil {
+			checks[name] = err.Error()
+			ready = false
+		}
+	}
+
+	err := v.Config.DB().DB().Ping()
+	check("database", err)
+
+	if err == nil {
+		check("migrations", v.checkMigrations())
+	}
+
+	check("storage", v.checkStorage())
+
+	if !ready {
+		return response.New(http.StatusServiceUnavailable).Result(checks)
+	}
+
+	return response.OK().Result(checks)
+}
+
+/*
+checkMigrations returns error with list of missing tables, columns and indexes
+*/
+func (v *ReadyView) checkMigrations() error {
+	if atomic.LoadInt32(&v.migrated) == 1 {
+		return nil
+	}
+
+	if pending := PendingMigrations(v.Config.DB()); len(pending) > 0 {
+		return fmt.Errorf("%v: %v", ErrMigrationsPending, strings.Join(pending, ", "))
+	}
+
+	atomic.StoreInt32(&v.migrated, 1)
+	return nil
+}
+
+/*
+checkStorage writes and removes temporary file in packages directory
+*/
+func (v *ReadyView) checkStorage() (err error) {
+	directory := v.Config.Packages().Directory()
+	if err = os.MkdirAll(directory, 0777); err != nil {
+		return
+	}
+
+	var f *os.File
+	if f, err = ioutil.TempFile(directory, ".readyz"); err != nil {
+		return
+	}
+
+	f.Close()
+	return os.Remove(f.Name())
+}
+
+/*
+VersionView returns version of gopypi
+*/
+type VersionView struct {
+	classy.GenericView
+
+	// config instance
+	Config Config
+}
+
+/*
+GET returns gopypi version, git commit of build and go version
+*/
+func (v *VersionView) GET(w http.ResponseWriter, r *http.Request) response.Response {
+	return response.OK().Result(map[string]string{
+		"version": VERSION,
+		"commit":  COMMIT,
+		"go":      runtime.Version(),
+	})
+}
@@ -2,19 +2,100 @@ package core
 
 import (
 	"net/http"
+	"os"
 
 	"strconv"
 
 	"fmt"
 
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
 	"github.com/gorilla/mux"
 	"github.com/jinzhu/gorm"
 	"github.com/phonkee/go-response"
 	"github.com/phonkee/go-classy"
 	"github.com/fukata/golang-stats-api-handler"
 	"github.com/phonkee/go-metadata"
+	"github.com/uber-go/zap"
 )
 
+/*
+AuditLogAPIViewSet provides read only rest endpoints for audit log
+*/
+type AuditLogAPIViewSet struct {
+	classy.ViewSet
+
+	// config instance
+	Config Config
+}
+
+/*
+List returns paginated audit log, newest first. Supports filtering by actor, action, target_type, target_id, source,
+since and until url query values.
+*/
+func (a *AuditLogAPIViewSet) List(w http.ResponseWriter, r *http.Request) response.Response {
+
+	// don't forget to parse form
+	r.ParseForm()
+	paginator := CommonPaginator(r.Form)
+
+	entries := []AuditLog{}
+
+	// apply filter from url
+	filtered := NewAuditLogListFilter(r).Apply(a.Config.DB())
+
+	queryset := LimitQueryset(filtered, paginator).Order("created_at DESC, id DESC").Find(&entries)
+	if err := queryset.Error; err != nil {
+		return response.Error(err)
+	}
+
+	// set count
+	CountQueryset(filtered.Model(AuditLog{}), paginator)
+
+	return response.OK().SliceResult(entries).Data("paginator", paginator)
+}
+
+/*
+Retrieve returns single audit log entry
+*/
+func (a *AuditLogAPIViewSet) Retrieve(w http.ResponseWriter, r *http.Request) response.Response {
+	entry := AuditLog{}
+	if err := a.Config.DB().First(&entry, "id = ?", Atoui(mux.Vars(r)["pk"])).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return response.NotFound()
+		}
+		return response.Error(err)
+	}
+
+	return response.OK().Result(entry)
+}
+
+/*
+AuditLogExportAPIView exports audit log as json lines
+*/
+type AuditLogExportAPIView struct {
+	classy.GenericView
+
+	// config instance
+	Config Config
+}
+
+/*
+GET streams audit log entries (oldest first) as json lines, accepts same filters as audit log list
+*/
+func (a *AuditLogExportAPIView) GET(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.Header().Set("Content-Disposition", "attachment; filename=\"gopypi-audit.jsonl\"")
+	w.WriteHeader(http.StatusOK)
+
+	if err := ExportAuditLog(a.Config, w, NewAuditLogListFilter(r)); err != nil {
+		a.Config.Logger().Error("audit log export failed", zap.String("error", err.Error()))
+	}
+}
+
 /*
 FeatureAPIViewSet provides rest endpoints for features
 */
@@ -79,12 +160,20 @@ func (f *FeatureAPIViewSet) Update(w http.ResponseWriter, r *http.Request) respo
 		return response.Error(err)
 	}
 
+	before := feature
+
 	// update value
 	feature.Value = serializer.Value
 	if err := f.Config.DB().Save(&feature).Error; err != nil {
 		return response.Error(err)
 	}
 
+	NewAuditEntry(AUDIT_ACTION_FEATURE_UPDATE).
+		Request(r).
+		Target(AUDIT_TARGET_FEATURE, feature.ID, feature.ID).
+		Diff(before, feature).
+		Save(f.Config)
+
 	return response.OK()
 }
 
@@ -111,11 +200,17 @@ func (i *InfoAPIView) GET(w http.ResponseWriter, r *http.Request) response.Respo
 		}
 	}
 
-	return response.New().Result(map[string]interface{}{
+	result := map[string]interface{}{
 		"version":  VERSION,
 		"features": features,
-		"system":   stats_api.GetStats(),
-	})
+	}
+
+	// system information is available only to admins
+	if user, err := ContextGetTokenUser(r.Context()); err == nil && user.IsAdmin {
+		result["system"] = stats_api.GetStats()
+	}
+
+	return response.New().Result(result)
 }
 
 /*
@@ -206,6 +301,8 @@ func (l *LicenseAPIViewSet) Update(w http.ResponseWriter, r *http.Request) respo
 		return response.BadRequest().Error(vr)
 	}
 
+	before := license
+
 	// update license with data from serializer
 	serializer.UpdateLicense(&license)
 
@@ -213,6 +310,12 @@ func (l *LicenseAPIViewSet) Update(w http.ResponseWriter, r *http.Request) respo
 		return response.Error(err)
 	}
 
+	NewAuditEntry(AUDIT_ACTION_LICENSE_UPDATE).
+		Request(r).
+		Target(AUDIT_TARGET_LICENSE, license.ID, license.Code).
+		Diff(before, license).
+		Save(l.Config)
+
 	return response.OK().Result(license)
 
 }
@@ -226,6 +329,15 @@ type LoginAPIView struct {
 	Config Config
 }
 
+/*
+GET returns available login methods, so admin can display single sign on button
+*/
+func (l *LoginAPIView) GET(w http.ResponseWriter, r *http.Request) response.Response {
+	return response.OK().Result(map[string]interface{}{
+		"oidc": l.Config.Auth().OIDC().Enabled(),
+	})
+}
+
 /*
 POST checks for username and password in JSON format and returns appropriate json response.
 If succeeds, Authorization header is added with correct token
@@ -243,31 +355,235 @@ func (l *LoginAPIView) POST(w http.ResponseWriter, r *http.Request) response.Res
 		return response.New(http.StatusBadRequest).Error(err)
 	}
 
-	user := User{}
-
-	db := l.Config.DB()
+	var (
+		err   error
+		token TokenResponse
+		user  User
+	)
 
-	// find user by username
-	if db.Where("username = ?", ser.Username).First(&user).RecordNotFound() {
+	// authenticate user against configured backends (throttled)
+	if user, err = AuthenticateRequest(l.Config, r, ser.Username, ser.Password); err != nil {
+		if te, ok := err.(ThrottleError); ok {
+			l.Config.Metrics().Registry().AuthFailures.Inc("login", "throttled")
+			return response.New(http.StatusTooManyRequests).Header("Retry-After", strconv.Itoa(te.RetryAfter())).Error(te)
+		}
+		l.Config.Metrics().Registry().AuthFailures.Inc("login", "invalid_credentials")
+		AuditLoginFailure(l.Config, r, ser.Username)
 		return response.NotFound().Error("user with given username and password not found")
 	}
 
-	// verify password
-	if !l.Config.Manager().User().VerifyPassword(user, ser.Password) {
-		return response.NotFound().Error("user with given username and password not found")
+	// create session and token
+	if token, err = CreateSessionToken(l.Config, user, r); err != nil {
+		return response.New(http.StatusInternalServerError).Error(err)
+	}
+
+	NewAuditEntry(AUDIT_ACTION_LOGIN).
+		Request(r).
+		Actor(user).
+		Target(AUDIT_TARGET_USER, user.ID, user.Username).
+		Change("method", nil, "password").
+		Save(l.Config)
+
+	return response.New().Header("Authorization", fmt.Sprintf("Bearer %s", token.Token)).Result(token)
+}
+
+/*
+LoginRefreshAPIView exchanges refresh token for new access token
+*/
+type LoginRefreshAPIView struct {
+	classy.GenericView
+
+	Config Config
+}
+
+/*
+POST accepts refresh token in JSON format and returns new access token and new refresh token. Every refresh token
+can be used only once.
+*/
+func (l *LoginRefreshAPIView) POST(w http.ResponseWriter, r *http.Request) response.Response {
+	ser := struct {
+		RefreshToken string `json:"refresh_token"`
+	}{}
+
+	if err := Bind(r, &ser); err != nil {
+		return response.New(http.StatusBadRequest).Error(err)
+	}
+
+	token, err := RefreshSessionToken(l.Config, ser.RefreshToken)
+	if err != nil {
+		return response.New(http.StatusUnauthorized).Error(err)
+	}
+
+	return response.New().Header("Authorization", fmt.Sprintf("Bearer %s", token.Token)).Result(token)
+}
+
+/*
+OIDCLoginAPIView starts OpenID Connect authorization code flow
+*/
+type OIDCLoginAPIView struct {
+	classy.GenericView
+
+	Config   Config
+	Provider *OIDCProvider
+}
+
+/*
+GET stores signed state and nonce in cookie and redirects user to provider
+*/
+func (o *OIDCLoginAPIView) GET(w http.ResponseWriter, r *http.Request) response.Response {
+	if !o.Config.Auth().OIDC().Enabled() {
+		return response.NotFound().Error(ErrOIDCDisabled)
 	}
 
+	state, nonce := oidcRandom(), oidcRandom()
+
 	var (
-		err   error
-		token string
+		err      error
+		location string
 	)
 
-	// create token
-	if token, err = CreateToken(db, user, l.Config.Core().SecretKey(), TOKEN_EXPIRATION); err != nil {
-		return response.New(http.StatusInternalServerError).Error(err)
+	if location, err = o.Provider.AuthCodeURL(state, nonce); err != nil {
+		return response.Error(err)
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     OIDC_COOKIE_NAME,
+		Value:    OIDCStateCookie(o.Config.Core().SecretKey(), state, nonce),
+		Path:     "/api/oidc/",
+		MaxAge:   OIDC_STATE_MAXAGE,
+		HttpOnly: true,
+		Secure:   OIDCSecureCookie(o.Config.Auth().OIDC()),
+	})
+
+	return response.New(http.StatusFound).Header("Location", location)
+}
+
+/*
+OIDCCallbackAPIView finishes OpenID Connect authorization code flow
+*/
+type OIDCCallbackAPIView struct {
+	classy.GenericView
+
+	Config   Config
+	Provider *OIDCProvider
+}
+
+/*
+GET exchanges code for id token, provisions user and redirects to admin with gopypi token.
+Token is passed in url fragment so it's never sent to server (and logged).
+*/
+func (o *OIDCCallbackAPIView) GET(w http.ResponseWriter, r *http.Request) response.Response {
+	if !o.Config.Auth().OIDC().Enabled() {
+		return response.NotFound().Error(ErrOIDCDisabled)
+	}
+
+	// remove state cookie, it's single use
+	http.SetCookie(w, &http.Cookie{Name: OIDC_COOKIE_NAME, Path: "/api/oidc/", MaxAge: -1})
+
+	query := r.URL.Query()
+
+	if e := query.Get("error"); e != "" {
+		return o.redirect("error", e)
+	}
+
+	var (
+		err    error
+		cookie *http.Cookie
+		nonce  string
+	)
+
+	if cookie, err = r.Cookie(OIDC_COOKIE_NAME); err != nil {
+		return o.redirect("error", ErrOIDCInvalidState.Error())
+	}
+
+	if nonce, err = OIDCVerifyStateCookie(o.Config.Core().SecretKey(), cookie.Value, query.Get("state")); err != nil {
+		return o.redirect("error", err.Error())
+	}
+
+	var idToken string
+	if idToken, err = o.Provider.Exchange(query.Get("code")); err != nil {
+		return o.redirect("error", err.Error())
+	}
+
+	var claims jwt.MapClaims
+	if claims, err = o.Provider.Verify(idToken, nonce); err != nil {
+		return o.redirect("error", err.Error())
+	}
+
+	var user User
+	if user, err = OIDCAuthenticate(o.Config, claims); err != nil {
+		return o.redirect("error", err.Error())
+	}
+
+	var token TokenResponse
+	if token, err = CreateSessionToken(o.Config, user, r); err != nil {
+		return response.Error(err)
+	}
+
+	NewAuditEntry(AUDIT_ACTION_LOGIN).
+		Request(r).
+		Actor(user).
+		Target(AUDIT_TARGET_USER, user.ID, user.Username).
+		Change("method", nil, "oidc").
+		Save(o.Config)
+
+	return o.redirect("token", token.Token, "refresh_token", token.RefreshToken)
+}
+
+/*
+redirect redirects to admin login page with given key value pairs in url fragment
+*/
+func (o *OIDCCallbackAPIView) redirect(pairs ...string) response.Response {
+	if len(pairs) > 0 && pairs[0] == "error" {
+		o.Config.Metrics().Registry().AuthFailures.Inc("oidc", "rejected")
+	}
+
+	values := url.Values{}
+	for i := 0; i+1 < len(pairs); i += 2 {
+		values.Set(pairs[i], pairs[i+1])
+	}
+	return response.New(http.StatusFound).Header("Location", "/admin/login#"+values.Encode())
+}
+
+/*
+LockoutAPIView gives admins information about usernames and ip addresses locked out after failed logins
+*/
+type LockoutAPIView struct {
+	classy.GenericView
+
+	Config Config
+}
+
+/*
+GET returns list of currently locked usernames and ip addresses
+*/
+func (l *LockoutAPIView) GET(w http.ResponseWriter, r *http.Request) response.Response {
+	return response.OK().SliceResult(l.Config.Auth().Throttle().Locked())
+}
+
+/*
+POST unlocks given username and/or ip address
+*/
+func (l *LockoutAPIView) POST(w http.ResponseWriter, r *http.Request) response.Response {
+	ser := struct {
+		Username string `json:"username"`
+		IP       string `json:"ip"`
+	}{}
+
+	if err := Bind(r, &ser); err != nil {
+		return response.New(http.StatusBadRequest).Error(err)
 	}
 
-	return response.New().Header("Authorization", fmt.Sprintf("Bearer %s", token))
+	username, ip := strings.TrimSpace(ser.Username), strings.TrimSpace(ser.IP)
+
+	l.Config.Auth().Throttle().Unlock(username, ip)
+
+	NewAuditEntry(AUDIT_ACTION_UNLOCK).
+		Request(r).
+		Target(AUDIT_TARGET_LOCKOUT, ip, username).
+		Save(l.Config)
+
+	return response.OK()
 }
 
 /*
@@ -330,6 +646,8 @@ func (m *MeAPIView) POST(w http.ResponseWriter, r *http.Request) response.Respon
 		return response.New(http.StatusBadRequest).Error(vr)
 	}
 
+	before := user
+
 	user.FirstName = s.FirstName
 	user.LastName = s.LastName
 	user.Email = s.Email
@@ -339,6 +657,12 @@ func (m *MeAPIView) POST(w http.ResponseWriter, r *http.Request) response.Respon
 		return response.Error(err)
 	}
 
+	NewAuditEntry(AUDIT_ACTION_USER_UPDATE).
+		Request(r).
+		Target(AUDIT_TARGET_USER, user.ID, user.Username).
+		Diff(before, user).
+		Save(m.Config)
+
 	return response.Result(user)
 }
 
@@ -387,284 +711,294 @@ func (m *MeChangePasswordAPIView ) POST(w http.ResponseWriter, r *http.Request)
 		return response.Error(err)
 	}
 
-	return response.OK()
-}
+	// sign out all other sessions, current session stays
+	session, _ := ContextGetTokenSession(r.Context())
+	if err = m.Config.Manager().Session().RevokeAll(user, session.ID); err != nil {
+		return response.Error(err)
+	}
 
+	NewAuditEntry(AUDIT_ACTION_USER_PASSWORD).
+		Request(r).
+		Target(AUDIT_TARGET_USER, user.ID, user.Username).
+		Save(m.Config)
 
+	NewNotification(NOTIFICATION_PASSWORD, "email/password_changed.tpl.html").
+		Actor(user).
+		Send(m.Config, user)
+
+	return response.OK()
+}
 
 /*
-MyPackageAPIView gives information about packages for currently logged in user
+MeLogoutAPIView signs out current session
 */
-type MyPackageAPIView struct {
-	classy.ListView
+type MeLogoutAPIView struct {
+	classy.GenericView
 
 	// store config
 	Config Config
 }
 
 /*
-List returns list of packages for given logged user. User must be either author or maintainer of packege to be returned
-in list.
- */
-func (m *MyPackageAPIView) List(w http.ResponseWriter, r *http.Request) response.Response {
-
-	var (
-		err  error
-		user User
-	)
-
-	// Get user from context.
-	if user, err = ContextGetTokenUser(r.Context()); err != nil {
+POST revokes session of current token
+*/
+func (m *MeLogoutAPIView) POST(w http.ResponseWriter, r *http.Request) response.Response {
+	session, err := ContextGetTokenSession(r.Context())
+	if err != nil {
 		return response.Error(err)
 	}
 
-	packages := []Package{}
-
-	if err = m.Config.Manager().Package().List(&packages, FFPackagesFor(user), FFPreload("Author", "Maintainers", "Versions", "Versions.Author")).Error; err != nil {
-		if err != gorm.ErrRecordNotFound {
-			return response.Error(err)
-		}
-		err = nil
+	if err = m.Config.Manager().Session().Revoke(session); err != nil {
+		return response.Error(err)
 	}
 
-	return response.OK().SliceResult(packages)
+	NewAuditEntry(AUDIT_ACTION_SESSION_REVOKE).
+		Request(r).
+		Target(AUDIT_TARGET_SESSION, session.ID, session.UserAgent).
+		Save(m.Config)
+
+	return response.OK()
 }
 
 /*
-PackageAPIViewSet provides following methods
-
-List - list packages
-Retrieve - retrieve single package
+MeLogoutAllAPIView signs out all sessions of current user
 */
-type PackageAPIViewSet struct {
-	classy.ViewSet
+type MeLogoutAllAPIView struct {
+	classy.GenericView
 
 	// store config
 	Config Config
 }
 
 /*
-List retrieves list of packages
+POST revokes all sessions of current user including current one
 */
-func (p *PackageAPIViewSet) List(w http.ResponseWriter, r *http.Request) response.Response {
-
-	db := p.Config.DB()
-
-	// don't forget to parse form
-	r.ParseForm()
-
-	paginator := CommonPaginator(r.Form)
-
-	limit, offset := paginator.GetLimitOffset()
-
-	packages := []Package{}
-	queryset := db.
-		Limit(limit).
-		Offset(offset).
-		Preload("Versions").
-		Preload("Versions.Files").
-		Preload("Versions.Files.Author").
-		Preload("Author").
-		Preload("Maintainers").
-		Find(&packages)
-
-	// find all packages
-	if err := queryset.Error; err != nil {
+func (m *MeLogoutAllAPIView) POST(w http.ResponseWriter, r *http.Request) response.Response {
+	user, err := ContextGetTokenUser(r.Context())
+	if err != nil {
 		return response.Error(err)
 	}
 
-	// add DownloadURL to all files
-	for i, pack := range packages {
-		for j, version := range pack.Versions {
-			for k, vfile := range version.Files {
-				packages[i].Versions[j].Files[k].DownloadURL = p.Config.Manager().PackageVersionFile().GetDownloadURL(&vfile)
-			}
-		}
+	if err = m.Config.Manager().Session().RevokeAll(user); err != nil {
+		return response.Error(err)
 	}
 
-	// write packages as response
-	return response.SliceResult(packages).Data("paginator", paginator)
+	NewAuditEntry(AUDIT_ACTION_SESSION_REVOKE_ALL).
+		Request(r).
+		Target(AUDIT_TARGET_USER, user.ID, user.Username).
+		Save(m.Config)
+
+	return response.OK()
 }
 
 /*
-Retrieve returns single package
+MeNotificationAPIView provides api to read and update email notification preferences of current user
 */
-func (p *PackageAPIViewSet) Retrieve(w http.ResponseWriter, r *http.Request) response.Response {
-	pack := Package{
-		ID: Atoui(mux.Vars(r)["pk"]),
-	}
-
-	// find single package
-	preload := FFPreload("Author", "Versions", "Versions.Files", "Versions.Files.Author", "Versions.Author", "Maintainers")
-	if p.Config.Manager().Package().Get(&pack, preload).RecordNotFound() {
-		return response.New(http.StatusNotFound)
-	}
-
-	// add DownloadURL to all files
-	for i, version := range pack.Versions {
-		for j, vfile := range version.Files {
-			pack.Versions[i].Files[j].DownloadURL = p.Config.Manager().PackageVersionFile().GetDownloadURL(&vfile)
-		}
-	}
-
-	// write packages as response
-	return response.Result(pack)
-}
-
-/*
-StatsAPIView returns some statistic information for admin dashboard.
- */
-type StatsAPIView struct {
+type MeNotificationAPIView struct {
 	classy.GenericView
 
+	// store config
 	Config Config
 }
 
 /*
-Stats result
+GET returns notification preferences of current user
 */
-type Stats struct {
-	Packages    int `json:"packages"`
-	ActiveUsers int `json:"active_users"`
-	Licenses    int `json:"licenses"`
-	Downloads   int `json:"downloads"`
+func (m *MeNotificationAPIView) GET(w http.ResponseWriter, r *http.Request) response.Response {
+	user, err := ContextGetTokenUser(r.Context())
+	if err != nil {
+		return response.Error(err)
+	}
+
+	var preference NotificationPreference
+	if preference, err = m.Config.Manager().Notification().Preference(user); err != nil {
+		return response.Error(err)
+	}
+
+	return response.Result(preference)
 }
 
 /*
-GET returns summary statistics about gopypi
- */
-func (s *StatsAPIView) GET(w http.ResponseWriter, r *http.Request) response.Response {
-	db := s.Config.DB()
-	// create stats
-	stats := Stats{}
-
-	// add packages count
-	db.Model(Package{}).Count(&(stats.Packages))
+POST updates notification preferences of current user
+*/
+func (m *MeNotificationAPIView) POST(w http.ResponseWriter, r *http.Request) response.Response {
+	user, err := ContextGetTokenUser(r.Context())
+	if err != nil {
+		return response.Error(err)
+	}
 
-	// add count of all active users
-	db.Model(User{}).Where("is_active = ?", true).Count(&(stats.ActiveUsers))
+	preference := NotificationPreference{}
+	if err = Bind(r, &preference); err != nil {
+		return response.New(http.StatusBadRequest).Error(err)
+	}
 
-	// add count of opensource licenses in system
-	db.Model(License{}).Count(&(stats.Licenses))
+	if err = m.Config.Manager().Notification().SetPreference(user, &preference); err != nil {
+		return response.Error(err)
+	}
 
-	// return count of all downloaded files
-	s.Config.Manager().DownloadStats().GetCount(&(stats.Downloads))
-	return response.New().Result(stats)
+	return response.Result(preference)
 }
 
 /*
-StatsDownloadAllAPIView provides stats of package downloads
+MySessionAPIViewSet lists and revokes sessions of currently logged in user
 */
-type StatsDownloadAllAPIView struct {
-	classy.ListView
+type MySessionAPIViewSet struct {
+	classy.ViewSet
 
-	// config instance
+	// store config
 	Config Config
 }
 
 /*
-List returns download stats about all packages (sums)
+List returns all valid sessions of current user, current session is marked
 */
-func (s *StatsDownloadAllAPIView) List(w http.ResponseWriter, r *http.Request) response.Response {
+func (m *MySessionAPIViewSet) List(w http.ResponseWriter, r *http.Request) response.Response {
+	user, err := ContextGetTokenUser(r.Context())
+	if err != nil {
+		return response.Error(err)
+	}
 
-	var err error
+	current, _ := ContextGetTokenSession(r.Context())
 
-	stats := map[string][]StatsDownloadItem{}
+	sessions := []Session{}
+	if err = m.Config.Manager().Session().List(user, &sessions).Error; err != nil {
+		return response.Error(err)
+	}
 
-	// get all stats
-	if err = s.Config.Manager().DownloadStats().GetAllStats(stats); err != nil {
+	for i := range sessions {
+		sessions[i].Current = sessions[i].ID == current.ID
+	}
+
+	return response.OK().SliceResult(sessions)
+}
+
+/*
+Delete revokes single session of current user
+*/
+func (m *MySessionAPIViewSet) Delete(w http.ResponseWriter, r *http.Request) response.Response {
+	user, err := ContextGetTokenUser(r.Context())
+	if err != nil {
 		return response.Error(err)
 	}
 
-	return response.OK().Result(stats)
+	session := Session{}
+	if m.Config.DB().First(&session, "id = ? AND user_id = ?", Atoui(mux.Vars(r)["pk"]), user.ID).RecordNotFound() {
+		return response.NotFound()
+	}
+
+	if err = m.Config.Manager().Session().Revoke(session); err != nil {
+		return response.Error(err)
+	}
+
+	NewAuditEntry(AUDIT_ACTION_SESSION_REVOKE).
+		Request(r).
+		Target(AUDIT_TARGET_SESSION, session.ID, session.UserAgent).
+		Save(m.Config)
+
+	return response.OK()
 }
 
+
+
 /*
-StatsDownloadPackageAPIView provides stats of package downloads
+MyPackageAPIView gives information about packages for currently logged in user
 */
-type StatsDownloadPackageAPIView struct {
-	classy.DetailView
+type MyPackageAPIView struct {
+	classy.ListView
 
-	// config instance
+	// store config
 	Config Config
 }
 
 /*
-List returns download stats about all packages (sum)
-*/
-func (s *StatsDownloadPackageAPIView) Retrieve(w http.ResponseWriter, r *http.Request) response.Response {
+List returns list of packages for given logged user. User must be either author or maintainer of packege to be returned
+in list.
+ */
+func (m *MyPackageAPIView) List(w http.ResponseWriter, r *http.Request) response.Response {
 
 	var (
 		err  error
-		pack Package
+		user User
 	)
 
-	// get package from url var
-	if err = s.Config.Manager().Package().Get(&pack, FFID(Atoui(mux.Vars(r)["pk"]))).Error; err != nil {
-		if err == gorm.ErrRecordNotFound {
-			return response.NotFound()
-		} else {
-			return response.Error(err)
-		}
+	// Get user from context.
+	if user, err = ContextGetTokenUser(r.Context()); err != nil {
+		return response.Error(err)
 	}
 
-	// get all stats
-	stats := map[string][]StatsDownloadItem{}
-	if err = s.Config.Manager().DownloadStats().GetAllStats(stats, FFDownloadStatsPackage(&pack)); err != nil {
-		return response.Error(err)
+	packages := []Package{}
+
+	if err = m.Config.Manager().Package().List(&packages, FFPackagesFor(user), FFPreload("Author", "Maintainers", "Versions", "Versions.Author")).Error; err != nil {
+		if err != gorm.ErrRecordNotFound {
+			return response.Error(err)
+		}
+		err = nil
 	}
 
-	return response.OK().Result(stats)
+	return response.OK().SliceResult(packages)
 }
 
 /*
-StatsDownloadPackageVersionAPIView provides stats of package version downloads
+MyUploadAPIView gives information about files uploaded by currently logged in user
 */
-type StatsDownloadPackageVersionAPIView struct {
-	classy.DetailView
+type MyUploadAPIView struct {
+	classy.ListView
 
-	// config instance
+	// store config
 	Config Config
 }
 
 /*
-List returns download stats about all package versions (sum)
+List returns paginated list of files uploaded by logged user, newest first.
 */
-func (s *StatsDownloadPackageVersionAPIView) Retrieve(w http.ResponseWriter, r *http.Request) response.Response {
+func (m *MyUploadAPIView) List(w http.ResponseWriter, r *http.Request) response.Response {
 
 	var (
-		err error
+		err  error
+		user User
 	)
 
-	vars := mux.Vars(r)
-
-	pv := PackageVersion{
-		ID:        Atoui(vars["pk"]),
-		PackageID: Atoui(vars["package_pk"]),
+	// Get user from context.
+	if user, err = ContextGetTokenUser(r.Context()); err != nil {
+		return response.Error(err)
 	}
 
-	// get package from url var
-	if err = s.Config.Manager().PackageVersion().Get(&pv, FFWhere(pv)).Error; err != nil {
-		if err == gorm.ErrRecordNotFound {
-			return response.NotFound()
-		} else {
-			return response.Error(err)
-		}
-	}
+	// don't forget to parse form
+	r.ParseForm()
 
-	// get all stats
-	stats := map[string][]StatsDownloadItem{}
-	if err = s.Config.Manager().DownloadStats().GetAllStats(stats, FFDownloadStatsPackageVersion(&pv)); err != nil {
+	paginator := CommonPaginator(r.Form)
+
+	limit, offset := paginator.GetLimitOffset()
+
+	files := []PackageVersionFile{}
+	queryset := m.Config.DB().
+		Where("author_id = ?", user.ID).
+		Order("created_at DESC").
+		Limit(limit).
+		Offset(offset).
+		Preload("PackageVersion").
+		Preload("PackageVersion.Package").
+		Find(&files)
+
+	if err = queryset.Error; err != nil {
 		return response.Error(err)
 	}
 
-	return response.OK().Result(stats)
+	// add DownloadURL to all files
+	for i, vfile := range files {
+		files[i].DownloadURL = m.Config.Manager().PackageVersionFile().GetDownloadURL(&vfile)
+	}
+
+	return response.SliceResult(files).Data("paginator", paginator)
 }
 
 /*
-UserAPIViewSet handles basic crud on users
+PackageAPIViewSet provides following methods
+
+List - list packages
+Retrieve - retrieve single package
 */
-type UserAPIViewSet struct {
+type PackageAPIViewSet struct {
 	classy.ViewSet
 
 	// store config
@@ -672,134 +1006,1698 @@ type UserAPIViewSet struct {
 }
 
 /*
-List of users
+List retrieves list of packages. Non admin users see only packages they author or maintain.
 */
-func (u *UserAPIViewSet) List(w http.ResponseWriter, r *http.Request) response.Response {
-	db := u.Config.DB()
+func (p *PackageAPIViewSet) List(w http.ResponseWriter, r *http.Request) response.Response {
+
+	user, err := ContextGetTokenUser(r.Context())
+	if err != nil {
+		return response.Error(err)
+	}
+
+	db := p.Config.DB()
 
 	// don't forget to parse form
 	r.ParseForm()
+
 	paginator := CommonPaginator(r.Form)
 
-	users := []User{}
+	limit, offset := paginator.GetLimitOffset()
 
-	// Limit queryset with applied filter
-	queryset := LimitQueryset(NewUserListFilter(r).Apply(db), paginator).Find(&users)
+	// filter by index name
+	if name := r.Form.Get("index"); name != "" {
+		index, err := p.Config.Manager().Index().GetByName(name)
+		if err != nil {
+			return response.NotFound().Error(err)
+		}
+		db = FFPackagesInIndexes(index.ID)(db)
+	}
 
-	// find all users
+	// filter by compatibility tags of files (build matrix coverage)
+	tag := CompatibilityTag{
+		PythonTag:   r.Form.Get("python_tag"),
+		ABITag:      r.Form.Get("abi_tag"),
+		PlatformTag: r.Form.Get("platform"),
+	}
+	if tag != (CompatibilityTag{}) {
+		versions := r.Form.Get("versions")
+		if versions != "" && versions != "latest" && versions != "any" {
+			return response.BadRequest().Error(ErrInvalidVersionsFilter)
+		}
+		lacking := r.Form.Get("lacking") == "true" || r.Form.Get("lacking") == "1"
+		db = FFPackagesWithFileTag(tag, versions != "any", lacking)(db)
+	}
+
+	packages := []Package{}
+	queryset := FFPackagesVisibleFor(user)(db).
+		Limit(limit).
+		Offset(offset).
+		Preload("Index").
+		Preload("Versions").
+		Preload("Versions.Files").
+		Preload("Versions.Files.Author").
+		Preload("Versions.Files.Tags").
+		Preload("Versions.Files.Tags.Platform").
+		Preload("Author").
+		Preload("Maintainers").
+		Find(&packages)
+
+	// find all packages
 	if err := queryset.Error; err != nil {
 		return response.Error(err)
 	}
 
-	// set count
-	CountQueryset(queryset, paginator)
+	// add DownloadURL to all files
+	for i, pack := range packages {
+		for j, version := range pack.Versions {
+			for k, vfile := range version.Files {
+				packages[i].Versions[j].Files[k].DownloadURL = p.Config.Manager().PackageVersionFile().GetDownloadURL(&vfile)
+			}
+		}
+	}
 
-	// write users as response
-	return response.OK().SliceResult(users).Data("paginator", paginator)
+	// write packages as response
+	return response.SliceResult(packages).Data("paginator", paginator)
 }
 
 /*
-Retrieve single user
+Retrieve returns single package. Non admin users can retrieve only packages they author or maintain.
 */
-func (u *UserAPIViewSet) Retrieve(w http.ResponseWriter, r *http.Request) {
-	db := u.Config.DB()
-	pk := mux.Vars(r)["pk"]
+func (p *PackageAPIViewSet) Retrieve(w http.ResponseWriter, r *http.Request) response.Response {
+	user, err := ContextGetTokenUser(r.Context())
+	if err != nil {
+		return response.Error(err)
+	}
 
-	// prepare blank user
-	user := User{}
+	pack := Package{
+		ID: Atoui(mux.Vars(r)["pk"]),
+	}
 
-	// fetch user from database
-	if db.Where("id = ?", pk).First(&user).RecordNotFound() {
-		response.New(http.StatusNotFound).Write(w, r)
-		return
+	// find single package
+	preload := FFPreload("Index", "Author", "Versions", "Versions.Files", "Versions.Files.Author", "Versions.Files.Tags",
+		"Versions.Files.Tags.Platform", "Versions.Author", "Maintainers")
+	if p.Config.Manager().Package().Get(&pack, preload, FFPackagesVisibleFor(user)).RecordNotFound() {
+		return response.New(http.StatusNotFound)
 	}
 
-	// write users as response
-	response.New().Result(user).Write(w, r)
+	// add DownloadURL to all files
+	for i, version := range pack.Versions {
+		for j, vfile := range version.Files {
+			pack.Versions[i].Files[j].DownloadURL = p.Config.Manager().PackageVersionFile().GetDownloadURL(&vfile)
+		}
+	}
+
+	// write packages as response
+	return response.Result(pack)
 }
 
 /*
-Create is called when new user is created
+Update updates package settings (upload policy), only admins can update packages
 */
-func (u *UserAPIViewSet) Create(w http.ResponseWriter, r *http.Request) response.Response {
+func (p *PackageAPIViewSet) Update(w http.ResponseWriter, r *http.Request) response.Response {
+	user, err := ContextGetTokenUser(r.Context())
+	if err != nil {
+		return response.Error(err)
+	}
 
-	var (
-		err error
-	)
+	if !user.IsAdmin {
+		return response.New(http.StatusForbidden).Error(ErrUserNotAdmin)
+	}
+
+	pack := Package{}
+	if p.Config.DB().First(&pack, "id = ?", Atoui(mux.Vars(r)["pk"])).RecordNotFound() {
+		return response.NotFound()
+	}
+
+	serializer := PackageUpdateSerializer{}
+	if err = Bind(r, &serializer); err != nil {
+		return response.BadRequest().Error(err)
+	}
+
+	if vr := serializer.Validate(p.Config); !vr.IsValid() {
+		return response.BadRequest().Error(vr)
+	}
+
+	before := pack.UploadPolicy
+	pack.UploadPolicy = serializer.UploadPolicy
+	if err = p.Config.DB().Model(&pack).UpdateColumn("upload_policy", pack.UploadPolicy).Error; err != nil {
+		return response.Error(err)
+	}
+
+	NewAuditEntry(AUDIT_ACTION_PACKAGE_UPDATE).
+		Request(r).
+		Target(AUDIT_TARGET_PACKAGE, pack.ID, pack.Name).
+		Change("upload_policy", before, pack.UploadPolicy).
+		Save(p.Config)
+
+	return response.OK().Result(pack)
+}
+
+/*
+PackageFileHistoryAPIView lists uploads, replacements and deletions of files of package, newest first
+*/
+type PackageFileHistoryAPIView struct {
+	classy.ListView
+
+	// config instance
+	Config Config
+}
+
+/*
+List returns paginated file history of package visible to user
+*/
+func (p *PackageFileHistoryAPIView) List(w http.ResponseWriter, r *http.Request) response.Response {
+	user, err := ContextGetTokenUser(r.Context())
+	if err != nil {
+		return response.Error(err)
+	}
+
+	pack := Package{}
+	if err = p.Config.Manager().Package().Get(&pack, FFID(Atoui(mux.Vars(r)["package_pk"])), FFPackagesVisibleFor(user)).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return response.NotFound()
+		}
+		return response.Error(err)
+	}
+
+	// don't forget to parse form
+	r.ParseForm()
+	paginator := CommonPaginator(r.Form)
+
+	filtered := p.Config.DB().Model(PackageFileHistory{}).Where("package_id = ?", pack.ID)
+	if filename := r.Form.Get("filename"); filename != "" {
+		filtered = filtered.Where("filename = ?", filename)
+	}
+
+	history := []PackageFileHistory{}
+	if err = LimitQueryset(filtered, paginator).Order("id DESC").Find(&history).Error; err != nil {
+		return response.Error(err)
+	}
+
+	// set count
+	CountQueryset(filtered, paginator)
+
+	return response.OK().SliceResult(history).Data("paginator", paginator)
+}
+
+/*
+PackageDependenciesAPIView returns dependencies of package versions
+*/
+type PackageDependenciesAPIView struct {
+	classy.GenericView
+
+	// config instance
+	Config Config
+}
+
+/*
+packageVersionDependencies is dependencies result of single version
+*/
+type packageVersionDependencies struct {
+	ID           uint                `json:"id"`
+	Version      string              `json:"version"`
+	Dependencies []PackageDependency `json:"dependencies"`
+}
+
+/*
+GET returns dependencies of all versions (or single version given by "version" query parameter). Dependencies hosted
+in gopypi have package_id set (package resolved through index of package).
+*/
+func (p *PackageDependenciesAPIView) GET(w http.ResponseWriter, r *http.Request) response.Response {
+	user, err := ContextGetTokenUser(r.Context())
+	if err != nil {
+		return response.Error(err)
+	}
+
+	pack := Package{}
+	if err = p.Config.Manager().Package().Get(&pack, FFID(Atoui(mux.Vars(r)["package_pk"])), FFPackagesVisibleFor(user), FFPreload("Index")).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return response.NotFound()
+		}
+		return response.Error(err)
+	}
+
+	queryset := p.Config.DB().Preload("Dependencies").Where("package_id = ?", pack.ID)
+	if version := r.URL.Query().Get("version"); version != "" {
+		queryset = queryset.Where("version = ?", version)
+	}
+
+	versions := []PackageVersion{}
+	if err = queryset.Order("version_order DESC, id DESC").Find(&versions).Error; err != nil {
+		return response.Error(err)
+	}
+
+	// hosted packages by canonical name, earlier indexes of resolution win
+	hosted := map[string]uint{}
+	if pack.Index != nil {
+		resolved, errResolve := p.Config.Manager().Index().Resolve(*pack.Index)
+		if errResolve != nil {
+			return response.Error(errResolve)
+		}
+
+		for _, index := range resolved {
+			packages := []Package{}
+			if err = p.Config.DB().Select("id, name").Where("index_id = ?", index.ID).Find(&packages).Error; err != nil {
+				return response.Error(err)
+			}
+			for _, item := range packages {
+				if _, ok := hosted[CanonicalPackageName(item.Name)]; !ok {
+					hosted[CanonicalPackageName(item.Name)] = item.ID
+				}
+			}
+		}
+	}
+
+	result := make([]packageVersionDependencies, 0, len(versions))
+	for _, version := range versions {
+		for i := range version.Dependencies {
+			version.Dependencies[i].PackageID = hosted[version.Dependencies[i].CanonicalName]
+		}
+		if version.Dependencies == nil {
+			version.Dependencies = []PackageDependency{}
+		}
+
+		result = append(result, packageVersionDependencies{
+			ID:           version.ID,
+			Version:      version.Version,
+			Dependencies: version.Dependencies,
+		})
+	}
+
+	return response.OK().SliceResult(result)
+}
+
+/*
+PackageDependentsAPIView returns package versions that depend on package
+*/
+type PackageDependentsAPIView struct {
+	classy.ListView
+
+	// config instance
+	Config Config
+}
+
+/*
+packageDependent is single version of package that depends on package
+*/
+type packageDependent struct {
+	PackageID uint   `json:"package_id"`
+	Package   string `json:"package"`
+	IndexName string `json:"index"`
+	VersionID uint   `json:"version_id"`
+	Version   string `json:"version"`
+	Extras    string `json:"extras"`
+	Specifier string `json:"specifier"`
+	URL       string `json:"url"`
+	Marker    string `json:"marker"`
+}
+
+/*
+List returns paginated dependents of package from indexes readable by user. By default only latest versions of
+dependents are returned, "versions=any" returns all versions.
+*/
+func (p *PackageDependentsAPIView) List(w http.ResponseWriter, r *http.Request) response.Response {
+	user, err := ContextGetTokenUser(r.Context())
+	if err != nil {
+		return response.Error(err)
+	}
+
+	pack := Package{}
+	if err = p.Config.Manager().Package().Get(&pack, FFID(Atoui(mux.Vars(r)["package_pk"])), FFPackagesVisibleFor(user)).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return response.NotFound()
+		}
+		return response.Error(err)
+	}
+
+	// don't forget to parse form
+	r.ParseForm()
+	paginator := CommonPaginator(r.Form)
+
+	versions := r.Form.Get("versions")
+	if versions != "" && versions != "latest" && versions != "any" {
+		return response.BadRequest().Error(ErrInvalidVersionsFilter)
+	}
+
+	indexes := []Index{}
+	if err = p.Config.Manager().Index().Readable(&indexes, user); err != nil {
+		return response.Error(err)
+	}
+	ids := make([]uint, 0, len(indexes))
+	for _, index := range indexes {
+		ids = append(ids, index.ID)
+	}
+
+	filtered := p.Config.DB().Table("package_dependency").
+		Joins("JOIN package_version ON package_version.id = package_dependency.package_version_id").
+		Joins("JOIN package ON package.id = package_version.package_id").
+		Joins("JOIN package_index ON package_index.id = package.index_id").
+		Where("package_dependency.canonical_name = ?", CanonicalPackageName(pack.Name))
+	if len(ids) == 0 {
+		ids = append(ids, 0)
+	}
+	filtered = filtered.Where("package.id <> ? AND package.index_id IN (?)", pack.ID, ids)
+	if versions != "any" {
+		filtered = FFLatestPackageVersions()(filtered)
+	}
+
+	dependents := []packageDependent{}
+	queryset := LimitQueryset(filtered, paginator).
+		Select("package.id AS package_id, package.name AS package, package_index.name AS index_name, " +
+			"package_version.id AS version_id, package_version.version, package_dependency.extras, " +
+			"package_dependency.specifier, package_dependency.url, package_dependency.marker").
+		Order("package.name ASC, package_version.version_order DESC, package_version.id DESC")
+	if err = queryset.Scan(&dependents).Error; err != nil {
+		return response.Error(err)
+	}
+
+	// set count
+	CountQueryset(filtered, paginator)
+
+	return response.OK().SliceResult(dependents).Data("paginator", paginator)
+}
+
+/*
+SearchAPIView searches packages of index
+*/
+type SearchAPIView struct {
+	classy.GenericView
+
+	// config instance
+	Config Config
+}
+
+/*
+GET returns paginated packages of index (and its bases) that match query ("q"), most relevant first. Index is given
+by "index" url query value (default index when not given), unreadable index is reported as not found.
+*/
+func (s *SearchAPIView) GET(w http.ResponseWriter, r *http.Request) response.Response {
+	var (
+		err   error
+		index Index
+		ids   []uint
+		hits  []SearchHit
+	)
+
+	// don't forget to parse form
+	r.ParseForm()
+	paginator := CommonPaginator(r.Form)
+
+	name := r.Form.Get("index")
+	if name == "" {
+		name = DEFAULT_INDEX
+	}
+
+	user, _ := ContextGetTokenUser(r.Context())
+	if index, err = s.Config.Manager().Index().GetByName(name); err != nil || !s.Config.Manager().Index().CanRead(index, user) {
+		return response.NotFound().Error(ErrIndexNotFound)
+	}
+
+	if ids, err = s.Config.Manager().Index().ResolveIDs(index); err != nil {
+		return response.Error(err)
+	}
+
+	if hits, err = s.Config.Manager().Search().Search(r.Form.Get("q"), ids); err != nil {
+		if err == ErrSearchQueryEmpty {
+			return response.BadRequest().Error(err)
+		}
+		return response.Error(err)
+	}
+
+	return response.OK().SliceResult(PaginateSearchHits(hits, paginator)).Data("paginator", paginator)
+}
+
+/*
+StatsAPIView returns some statistic information for admin dashboard.
+ */
+type StatsAPIView struct {
+	classy.GenericView
+
+	Config Config
+}
+
+/*
+Stats result
+*/
+type Stats struct {
+	Packages    int `json:"packages"`
+	ActiveUsers int `json:"active_users"`
+	Licenses    int `json:"licenses"`
+	Downloads   int `json:"downloads"`
+}
+
+/*
+GET returns summary statistics about gopypi. Packages and downloads are counted only for packages visible to user.
+ */
+func (s *StatsAPIView) GET(w http.ResponseWriter, r *http.Request) response.Response {
+	user, err := ContextGetTokenUser(r.Context())
+	if err != nil {
+		return response.Error(err)
+	}
+
+	db := s.Config.DB()
+	// create stats
+	stats := Stats{}
+
+	// add packages count
+	FFPackagesVisibleFor(user)(db.Model(Package{})).Count(&(stats.Packages))
+
+	// add count of all active users
+	db.Model(User{}).Where("is_active = ?", true).Count(&(stats.ActiveUsers))
+
+	// add count of opensource licenses in system
+	db.Model(License{}).Count(&(stats.Licenses))
+
+	// return count of all downloaded files
+	s.Config.Manager().DownloadStats().GetCount(&(stats.Downloads), FFDownloadStatsUser(user))
+	return response.New().Result(stats)
+}
+
+/*
+StatsDownloadAllAPIView provides stats of package downloads
+*/
+type StatsDownloadAllAPIView struct {
+	classy.ListView
+
+	// config instance
+	Config Config
+}
+
+/*
+List returns download stats about all packages (sums)
+*/
+func (s *StatsDownloadAllAPIView) List(w http.ResponseWriter, r *http.Request) response.Response {
+
+	var (
+		err  error
+		user User
+	)
+
+	if user, err = ContextGetTokenUser(r.Context()); err != nil {
+		return response.Error(err)
+	}
+
+	stats := map[string][]StatsDownloadItem{}
+
+	// get all stats (only packages visible to user)
+	if err = s.Config.Manager().DownloadStats().GetAllStats(stats, FFDownloadStatsUser(user)); err != nil {
+		return response.Error(err)
+	}
+
+	return response.OK().Result(stats)
+}
+
+/*
+StatsDownloadPackageAPIView provides stats of package downloads
+*/
+type StatsDownloadPackageAPIView struct {
+	classy.DetailView
+
+	// config instance
+	Config Config
+}
+
+/*
+List returns download stats about all packages (sum)
+*/
+func (s *StatsDownloadPackageAPIView) Retrieve(w http.ResponseWriter, r *http.Request) response.Response {
+
+	var (
+		err  error
+		pack Package
+		user User
+	)
+
+	if user, err = ContextGetTokenUser(r.Context()); err != nil {
+		return response.Error(err)
+	}
+
+	// get package from url var
+	if err = s.Config.Manager().Package().Get(&pack, FFID(Atoui(mux.Vars(r)["pk"])), FFPackagesVisibleFor(user)).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return response.NotFound()
+		} else {
+			return response.Error(err)
+		}
+	}
+
+	// get all stats
+	stats := map[string][]StatsDownloadItem{}
+	if err = s.Config.Manager().DownloadStats().GetAllStats(stats, FFDownloadStatsPackage(&pack)); err != nil {
+		return response.Error(err)
+	}
+
+	return response.OK().Result(stats)
+}
+
+/*
+StatsDownloadPackageVersionAPIView provides stats of package version downloads
+*/
+type StatsDownloadPackageVersionAPIView struct {
+	classy.DetailView
+
+	// config instance
+	Config Config
+}
+
+/*
+List returns download stats about all package versions (sum)
+*/
+func (s *StatsDownloadPackageVersionAPIView) Retrieve(w http.ResponseWriter, r *http.Request) response.Response {
+
+	var (
+		err  error
+		user User
+	)
+
+	if user, err = ContextGetTokenUser(r.Context()); err != nil {
+		return response.Error(err)
+	}
+
+	vars := mux.Vars(r)
+
+	// check that package is visible to user
+	pack := Package{}
+	if err = s.Config.Manager().Package().Get(&pack, FFID(Atoui(vars["package_pk"])), FFPackagesVisibleFor(user)).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return response.NotFound()
+		} else {
+			return response.Error(err)
+		}
+	}
+
+	pv := PackageVersion{
+		ID:        Atoui(vars["pk"]),
+		PackageID: pack.ID,
+	}
+
+	// get package from url var
+	if err = s.Config.Manager().PackageVersion().Get(&pv, FFWhere(pv)).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return response.NotFound()
+		} else {
+			return response.Error(err)
+		}
+	}
+
+	// get all stats
+	stats := map[string][]StatsDownloadItem{}
+	if err = s.Config.Manager().DownloadStats().GetAllStats(stats, FFDownloadStatsPackageVersion(&pv)); err != nil {
+		return response.Error(err)
+	}
+
+	return response.OK().Result(stats)
+}
+
+/*
+UserAPIViewSet handles basic crud on users
+*/
+type UserAPIViewSet struct {
+	classy.ViewSet
+
+	// store config
+	Config Config
+}
+
+/*
+List of users
+*/
+func (u *UserAPIViewSet) List(w http.ResponseWriter, r *http.Request) response.Response {
+	db := u.Config.DB()
+
+	// don't forget to parse form
+	r.ParseForm()
+	paginator := CommonPaginator(r.Form)
+
+	users := []User{}
+
+	// Limit queryset with applied filter
+	queryset := LimitQueryset(NewUserListFilter(r).Apply(db), paginator).Find(&users)
+
+	// find all users
+	if err := queryset.Error; err != nil {
+		return response.Error(err)
+	}
+
+	// set count
+	CountQueryset(queryset, paginator)
+
+	// write users as response
+	return response.OK().SliceResult(users).Data("paginator", paginator)
+}
+
+/*
+Retrieve single user
+*/
+func (u *UserAPIViewSet) Retrieve(w http.ResponseWriter, r *http.Request) {
+	db := u.Config.DB()
+	pk := mux.Vars(r)["pk"]
+
+	// prepare blank user
+	user := User{}
+
+	// fetch user from database
+	if db.Where("id = ?", pk).First(&user).RecordNotFound() {
+		response.New(http.StatusNotFound).Write(w, r)
+		return
+	}
+
+	// write users as response
+	response.New().Result(user).Write(w, r)
+}
+
+/*
+Create is called when new user is created
+*/
+func (u *UserAPIViewSet) Create(w http.ResponseWriter, r *http.Request) response.Response {
+
+	var (
+		err error
+	)
 	s := &UserAddSerializer{}
 	if err = Bind(r, s); err != nil {
 		return response.New(http.StatusBadRequest).Error(err)
 	}
 
-	var (
-		vr ValidationResult
-	)
+	var (
+		vr ValidationResult
+	)
+
+	// validate serializer
+	if vr = s.Validate(u.Config); !vr.IsValid() {
+		return response.New(http.StatusBadRequest).Error(vr)
+	}
+
+	// get user from serializer
+	user := s.GetUser(u.Config)
+
+	// insert user to database
+	if err = u.Config.DB().Create(&user).Error; err != nil {
+		return response.Error(err)
+	}
+
+	NewAuditEntry(AUDIT_ACTION_USER_CREATE).
+		Request(r).
+		Target(AUDIT_TARGET_USER, user.ID, user.Username).
+		Diff(nil, user).
+		Save(u.Config)
+
+	return response.Result(user)
+}
+
+/*
+Update is called when POST is called to update user
+*/
+func (u *UserAPIViewSet) Update(w http.ResponseWriter, r *http.Request) response.Response {
+
+	var (
+		err error
+		pk  int
+	)
+	serializer := UserUpdateSerializer{}
+
+	// bind request to serializer
+	if err = Bind(r, &serializer); err != nil {
+		return response.BadRequest()
+	}
+
+	// convert primary key to int
+	if pk, err = strconv.Atoi(mux.Vars(r)["pk"]); err != nil {
+		return response.BadRequest()
+	}
+
+	// prepare user
+	user := User{}
+	if u.Config.DB().First(&user, "id = ?", pk).RecordNotFound() {
+		return response.NotFound()
+	}
+
+	// add primary key to serializer so Validate method can use it
+	serializer.ID = uint(pk)
+
+	if vr := serializer.Validate(u.Config); !vr.IsValid() {
+		return response.BadRequest().Error(vr)
+	}
+
+	before := user
+
+	// Update user
+	serializer.UpdateUser(u.Config, &user)
+
+	// save user
+	if err = u.Config.DB().Save(&user).Error; err != nil {
+		return response.Error(err)
+	}
+
+	entry := NewAuditEntry(AUDIT_ACTION_USER_UPDATE).
+		Request(r).
+		Target(AUDIT_TARGET_USER, user.ID, user.Username).
+		Diff(before, user)
+
+	// password hash is not part of diff
+	if before.Password != user.Password {
+		entry.Change("password", nil, "changed")
+
+		actor, _ := ContextGetTokenUser(r.Context())
+		NewNotification(NOTIFICATION_PASSWORD, "email/password_changed.tpl.html").
+			Actor(actor).
+			Send(u.Config, user)
+	}
+	entry.Save(u.Config)
+
+	// password change or deactivation signs out all user sessions
+	if serializer.RevokesSessions() {
+		if err = u.Config.Manager().Session().RevokeAll(user); err != nil {
+			return response.Error(err)
+		}
+	}
+
+	return response.OK().Result(user)
+}
+
+/*
+PackageMaintainerAPIViewSet provides rest endpoints for maintainers of given package
+*/
+type PackageMaintainerAPIViewSet struct {
+	classy.ViewSet
+
+	// config instance
+	Config Config
+}
+
+/*
+GetPackage returns package from request
+*/
+func (p *PackageMaintainerAPIViewSet) GetPackage(r *http.Request) (result Package, err error) {
+	result = Package{}
+	err = p.Config.DB().First(&result, "id = ?", mux.Vars(r)["package_pk"]).Error
+	return
+}
+
+/*
+List method lists all maintainers for given package
+*/
+func (p *PackageMaintainerAPIViewSet) List(w http.ResponseWriter, r *http.Request) response.Response {
+
+	var (
+		err  error
+		pack Package
+	)
+	if pack, err = p.GetPackage(r); err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return response.NotFound()
+		}
+	}
+
+	var (
+		maintainers []User
+	)
+
+	// list all maintainers
+	if err = p.Config.DB().Model(pack).Association("Maintainers").Find(&maintainers).Error; err != nil {
+		if err != gorm.ErrRecordNotFound {
+			return response.Error(err)
+		}
+
+		err = nil
+	}
+
+	return response.OK().SliceResult(maintainers)
+}
+
+/*
+Update association means that when it's not present, it's created,
+Update method doesn't handle request body.
+*/
+func (p *PackageMaintainerAPIViewSet) Update(w http.ResponseWriter, r *http.Request) response.Response {
+	var (
+		err  error
+		pack Package
+	)
+	if pack, err = p.GetPackage(r); err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return response.NotFound()
+		}
+	}
+
+	var user User
+
+	// try to find user by pk first
+	if p.Config.DB().First(&user, "id = ?", mux.Vars(r)["pk"]).RecordNotFound() {
+		return response.NotFound()
+	}
+
+	var maintainers []User
+
+	if err = p.Config.DB().Model(&pack).Association("Maintainers").Find(&maintainers).Error; err != nil {
+		if err != gorm.ErrRecordNotFound {
+			return response.Error(err)
+		}
+		err = nil
+	}
+
+	// iterate over maintainers to find whether maintainer is already there
+	for _, maintainer := range maintainers {
+		if maintainer.ID == user.ID {
+			return response.OK().Result(user)
+		}
+	}
+
+	// maintainer not found, create one
+	if err = p.Config.DB().Model(&pack).Association("Maintainers").Append(user).Error; err != nil {
+		return response.Error(err)
+	}
+
+	NewAuditEntry(AUDIT_ACTION_MAINTAINER_ADD).
+		Request(r).
+		Target(AUDIT_TARGET_PACKAGE, pack.ID, pack.Name).
+		Change("maintainer", nil, user.Username).
+		Save(p.Config)
+
+	actor, _ := ContextGetTokenUser(r.Context())
+	NewWebhookEvent(WEBHOOK_EVENT_MAINTAINER_ADD).
+		Actor(actor).
+		Package(pack).
+		Maintainer(user).
+		Fire(p.Config)
+
+	if actor.ID != user.ID {
+		NewNotification(NOTIFICATION_MAINTAINER, "email/maintainer_added.tpl.html").
+			Actor(actor).
+			Package(pack).
+			Send(p.Config, user)
+	}
+
+	return response.OK().Result(user)
+}
+
+/*
+Delete removes association of maintainer to given package
+*/
+func (p *PackageMaintainerAPIViewSet) Delete(w http.ResponseWriter, r *http.Request) response.Response {
+	var (
+		err  error
+		pack Package
+	)
+	if pack, err = p.GetPackage(r); err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return response.NotFound()
+		}
+	}
+
+	var user User
+
+	// try to find user by pk first
+	if err = p.Config.DB().First(&user, "id = ?", mux.Vars(r)["pk"]).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return response.NotFound()
+		}
+		return response.Error(err)
+	}
+
+	// try to delete association
+	if err = p.Config.DB().Model(&pack).Association("Maintainers").Delete(user).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return response.NotFound()
+		}
+		return response.Error(err)
+	}
+
+	NewAuditEntry(AUDIT_ACTION_MAINTAINER_REMOVE).
+		Request(r).
+		Target(AUDIT_TARGET_PACKAGE, pack.ID, pack.Name).
+		Change("maintainer", user.Username, nil).
+		Save(p.Config)
+
+	actor, _ := ContextGetTokenUser(r.Context())
+	NewWebhookEvent(WEBHOOK_EVENT_MAINTAINER_REMOVE).
+		Actor(actor).
+		Package(pack).
+		Maintainer(user).
+		Fire(p.Config)
+
+	if actor.ID != user.ID {
+		NewNotification(NOTIFICATION_MAINTAINER, "email/maintainer_removed.tpl.html").
+			Actor(actor).
+			Package(pack).
+			Send(p.Config, user)
+	}
+
+	return response.OK()
+}
+
+/*
+PackageVersionAPIView provides rest endpoints for single package version (retrieve, delete)
+*/
+type PackageVersionAPIView struct {
+	classy.DetailView
+
+	// config instance
+	Config Config
+}
+
+/*
+Retrieve returns single package version with files
+*/
+func (p *PackageVersionAPIView) Retrieve(w http.ResponseWriter, r *http.Request) response.Response {
+	_, version, resp := getRequestPackageVersion(p.Config, r, false)
+	if resp != nil {
+		return resp
+	}
+
+	for i, vfile := range version.Files {
+		version.Files[i].DownloadURL = p.Config.Manager().PackageVersionFile().GetDownloadURL(&vfile)
+	}
+
+	return response.OK().Result(version)
+}
+
+/*
+Delete deletes package version along with its files. Only admins, package author and maintainers with update
+permission can delete versions.
+*/
+func (p *PackageVersionAPIView) Delete(w http.ResponseWriter, r *http.Request) response.Response {
+	pack, version, resp := getRequestPackageVersion(p.Config, r, true)
+	if resp != nil {
+		return resp
+	}
+
+	if err := p.Config.Manager().PackageVersion().Delete(version); err != nil {
+		return response.Error(err)
+	}
+
+	// deleted filenames stay in history, immutable indexes don't allow their reuse
+	actor, _ := ContextGetTokenUser(r.Context())
+	for _, vfile := range version.Files {
+		p.Config.Manager().PackageVersionFile().RecordHistory(FILE_HISTORY_DELETE, pack, version, vfile, "", &actor)
+	}
+
+	// remove files from disk, database is source of truth so errors are only counted
+	for _, vfile := range version.Files {
+		if err := os.Remove(p.Config.Manager().PackageVersionFile().GetAbsoluteFilename(&vfile)); err != nil && !os.IsNotExist(err) {
+			p.Config.Metrics().Registry().StorageErrors.Inc("remove")
+		}
+	}
+
+	if err := p.Config.Manager().Package().UpdateVersionOrder(pack); err != nil {
+		return response.Error(err)
+	}
+
+	NewAuditEntry(AUDIT_ACTION_VERSION_DELETE).
+		Request(r).
+		Target(AUDIT_TARGET_VERSION, version.ID, pack.Name+" "+version.Version).
+		Change("files", len(version.Files), nil).
+		Save(p.Config)
+
+	NewWebhookEvent(WEBHOOK_EVENT_VERSION_DELETE).
+		Actor(actor).
+		Package(pack).
+		Version(version).
+		Fire(p.Config)
+
+	return response.OK()
+}
+
+/*
+PackageVersionYankAPIView yanks and unyanks package version. Yanked versions stay downloadable but installers should
+ignore them unless pinned exactly.
+*/
+type PackageVersionYankAPIView struct {
+	classy.GenericView
+
+	// config instance
+	Config Config
+}
+
+/*
+POST yanks package version with optional reason
+*/
+func (p *PackageVersionYankAPIView) POST(w http.ResponseWriter, r *http.Request) response.Response {
+	ser := struct {
+		Reason string `json:"reason"`
+	}{}
+
+	if err := Bind(r, &ser); err != nil {
+		return response.BadRequest().Error(err)
+	}
+
+	return p.yank(r, true, StringTruncate(strings.TrimSpace(ser.Reason), 256))
+}
+
+/*
+DELETE unyanks package version
+*/
+func (p *PackageVersionYankAPIView) DELETE(w http.ResponseWriter, r *http.Request) response.Response {
+	return p.yank(r, false, "")
+}
+
+/*
+yank sets yanked flag of package version
+*/
+func (p *PackageVersionYankAPIView) yank(r *http.Request, yanked bool, reason string) response.Response {
+	pack, version, resp := getRequestPackageVersion(p.Config, r, true)
+	if resp != nil {
+		return resp
+	}
+
+	before := version
+	version.Yanked = yanked
+	version.YankedReason = reason
+
+	if err := p.Config.DB().Model(&version).UpdateColumns(map[string]interface{}{
+		"yanked":        version.Yanked,
+		"yanked_reason": version.YankedReason,
+	}).Error; err != nil {
+		return response.Error(err)
+	}
+
+	action, event := AUDIT_ACTION_VERSION_YANK, WEBHOOK_EVENT_VERSION_YANK
+	if !yanked {
+		action, event = AUDIT_ACTION_VERSION_UNYANK, WEBHOOK_EVENT_VERSION_UNYANK
+	}
+
+	NewAuditEntry(action).
+		Request(r).
+		Target(AUDIT_TARGET_VERSION, version.ID, pack.Name+" "+version.Version).
+		Change("yanked", before.Yanked, version.Yanked).
+		Change("yanked_reason", before.YankedReason, version.YankedReason).
+		Save(p.Config)
+
+	actor, _ := ContextGetTokenUser(r.Context())
+	NewWebhookEvent(event).
+		Actor(actor).
+		Package(pack).
+		Version(version).
+		Fire(p.Config)
+
+	return response.OK().Result(version)
+}
+
+/*
+PackageVersionPromoteAPIView promotes package version to another index (copy or move with the same files) and lists
+promotions of version
+*/
+type PackageVersionPromoteAPIView struct {
+	classy.GenericView
+
+	// config instance
+	Config Config
+}
+
+/*
+GET returns promotions of package version (from and to it), newest first
+*/
+func (p *PackageVersionPromoteAPIView) GET(w http.ResponseWriter, r *http.Request) response.Response {
+	_, version, resp := getRequestPackageVersion(p.Config, r, false)
+	if resp != nil {
+		return resp
+	}
+
+	promotions := []PackagePromotion{}
+	if err := p.Config.DB().
+		Preload("SourceIndex").
+		Preload("TargetIndex").
+		Where("package_version_id = ? OR source_version_id = ?", version.ID, version.ID).
+		Order("id DESC").
+		Find(&promotions).Error; err != nil {
+		return response.Error(err)
+	}
+
+	return response.OK().SliceResult(promotions)
+}
+
+/*
+POST promotes package version to index given by name. Moving version requires permission to modify package.
+*/
+func (p *PackageVersionPromoteAPIView) POST(w http.ResponseWriter, r *http.Request) response.Response {
+	ser := struct {
+		Index string `json:"index"`
+		Move  bool   `json:"move"`
+	}{}
+
+	if err := Bind(r, &ser); err != nil {
+		return response.BadRequest().Error(err)
+	}
+
+	pack, version, resp := getRequestPackageVersion(p.Config, r, ser.Move)
+	if resp != nil {
+		return resp
+	}
+
+	user, err := ContextGetTokenUser(r.Context())
+	if err != nil {
+		return response.Error(err)
+	}
+
+	target, err := p.Config.Manager().Index().GetByName(strings.TrimSpace(ser.Index))
+	if err != nil || !p.Config.Manager().Index().CanRead(target, user) {
+		return response.NotFound().Error(ErrIndexNotFound)
+	}
+
+	source := Index{}
+	p.Config.DB().First(&source, "id = ?", pack.IndexID)
+
+	promotion, err := PromoteVersion(p.Config, pack, version, target, &user, ser.Move)
+	switch err {
+	case nil:
+	case ErrPromoteSameIndex, ErrPromoteVersionExists:
+		return response.BadRequest().Error(err)
+	case ErrIndexUploadForbidden, ErrUserCannotCreatePackage, ErrUserCannotModifyPackage:
+		return response.New(http.StatusForbidden).Error(err)
+	default:
+		return response.Error(err)
+	}
+
+	NewAuditEntry(AUDIT_ACTION_VERSION_PROMOTE).
+		Request(r).
+		Target(AUDIT_TARGET_VERSION, promotion.PackageVersionID, pack.Name+" "+version.Version).
+		Change("index", source.Name, target.Name).
+		Change("move", nil, ser.Move).
+		Change("files", nil, len(version.Files)).
+		Save(p.Config)
+
+	return response.OK().Result(promotion)
+}
+
+/*
+getRequestPackageVersion returns package and version from url (package_pk, pk) visible to request user. When modify
+is true, user must be able to modify package.
+*/
+func getRequestPackageVersion(cfg Config, r *http.Request, modify bool) (pack Package, version PackageVersion, resp response.Response) {
+	user, err := ContextGetTokenUser(r.Context())
+	if err != nil {
+		return pack, version, response.Error(err)
+	}
+
+	vars := mux.Vars(r)
+
+	if err = cfg.Manager().Package().Get(&pack, FFID(Atoui(vars["package_pk"])), FFPackagesVisibleFor(user)).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return pack, version, response.NotFound()
+		}
+		return pack, version, response.Error(err)
+	}
+
+	if modify && !cfg.Manager().Package().CanModify(&pack, &user) {
+		return pack, version, response.New(http.StatusForbidden).Error(ErrUserCannotModifyPackage)
+	}
+
+	if err = cfg.DB().Preload("Files").First(&version, "id = ? AND package_id = ?", Atoui(vars["pk"]), pack.ID).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return pack, version, response.NotFound()
+		}
+		return pack, version, response.Error(err)
+	}
+
+	return
+}
+
+/*
+PlatformAPIViewSet provides rest endpoints for platform (RU)
+*/
+type PlatformAPIViewSet struct {
+	classy.ViewSet
+
+	// configuration
+	Config Config
+}
+
+/*
+List returns all platform stored in database
+*/
+func (p *PlatformAPIViewSet) List(w http.ResponseWriter, r *http.Request) response.Response {
+
+	var (
+		err error
+	)
+
+	list := []Platform{}
+
+	if err = p.Config.Manager().Platform().List(&list).Error; err != nil {
+		if err != gorm.ErrRecordNotFound {
+			return response.Error(err)
+		}
+	}
+
+	return response.OK().SliceResult(list)
+}
+
+/*
+Retrieve returns single platform
+*/
+func (p *PlatformAPIViewSet) Retrieve(w http.ResponseWriter, r *http.Request) response.Response {
+
+	// prepare platform to find
+	platform := Platform{
+		ID: Atoui(mux.Vars(r)["pk"]),
+	}
+
+	// get platform
+	if err := p.Config.Manager().Platform().Get(&platform).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return response.NotFound()
+		} else {
+			return response.Error(err)
+		}
+	}
+
+	return response.OK().Result(platform)
+}
+
+/*
+WebhookAPIViewSet provides rest endpoints for webhooks (CRUD)
+*/
+type WebhookAPIViewSet struct {
+	classy.ViewSet
+
+	// config instance
+	Config Config
+}
+
+/*
+List returns all webhooks
+*/
+func (wv *WebhookAPIViewSet) List(w http.ResponseWriter, r *http.Request) response.Response {
+	webhooks := []Webhook{}
+	if err := wv.Config.DB().Preload("Package").Order("id ASC").Find(&webhooks).Error; err != nil {
+		return response.Error(err)
+	}
+
+	return response.OK().SliceResult(webhooks)
+}
+
+/*
+Create creates new webhook and sends ping event to it
+*/
+func (wv *WebhookAPIViewSet) Create(w http.ResponseWriter, r *http.Request) response.Response {
+	serializer := WebhookSerializer{}
+	if err := Bind(r, &serializer); err != nil {
+		return response.BadRequest().Error(err)
+	}
+
+	if vr := serializer.Validate(wv.Config); !vr.IsValid() {
+		return response.BadRequest().Error(vr)
+	}
+
+	webhook := Webhook{}
+	serializer.UpdateWebhook(&webhook)
+
+	if err := wv.Config.DB().Create(&webhook).Error; err != nil {
+		return response.Error(err)
+	}
+
+	NewAuditEntry(AUDIT_ACTION_WEBHOOK_CREATE).
+		Request(r).
+		Target(AUDIT_TARGET_WEBHOOK, webhook.ID, webhook.Name).
+		Diff(nil, webhook).
+		Save(wv.Config)
+
+	// let receiver know that webhook works
+	if webhook.IsActive {
+		actor, _ := ContextGetTokenUser(r.Context())
+		NewWebhookEvent(WEBHOOK_EVENT_PING).Actor(actor).FireTo(wv.Config, webhook)
+	}
+
+	return response.OK().Result(webhook)
+}
+
+/*
+Retrieve returns single webhook
+*/
+func (wv *WebhookAPIViewSet) Retrieve(w http.ResponseWriter, r *http.Request) response.Response {
+	webhook := Webhook{}
+	if wv.Config.DB().Preload("Package").First(&webhook, "id = ?", Atoui(mux.Vars(r)["pk"])).RecordNotFound() {
+		return response.NotFound()
+	}
+
+	return response.OK().Result(webhook)
+}
+
+/*
+Update updates webhook, secret is changed only when given
+*/
+func (wv *WebhookAPIViewSet) Update(w http.ResponseWriter, r *http.Request) response.Response {
+	webhook := Webhook{}
+	if wv.Config.DB().First(&webhook, "id = ?", Atoui(mux.Vars(r)["pk"])).RecordNotFound() {
+		return response.NotFound()
+	}
+
+	serializer := WebhookSerializer{}
+	if err := Bind(r, &serializer); err != nil {
+		return response.BadRequest().Error(err)
+	}
+
+	if vr := serializer.Validate(wv.Config); !vr.IsValid() {
+		return response.BadRequest().Error(vr)
+	}
+
+	before := webhook
+	serializer.UpdateWebhook(&webhook)
+
+	if err := wv.Config.DB().Save(&webhook).Error; err != nil {
+		return response.Error(err)
+	}
+
+	entry := NewAuditEntry(AUDIT_ACTION_WEBHOOK_UPDATE).
+		Request(r).
+		Target(AUDIT_TARGET_WEBHOOK, webhook.ID, webhook.Name).
+		Diff(before, webhook)
+
+	// secret is not part of diff
+	if before.Secret != webhook.Secret {
+		entry.Change("secret", nil, "changed")
+	}
+	entry.Save(wv.Config)
+
+	return response.OK().Result(webhook)
+}
+
+/*
+Delete deletes webhook along with its deliveries
+*/
+func (wv *WebhookAPIViewSet) Delete(w http.ResponseWriter, r *http.Request) response.Response {
+	webhook := Webhook{}
+	if wv.Config.DB().First(&webhook, "id = ?", Atoui(mux.Vars(r)["pk"])).RecordNotFound() {
+		return response.NotFound()
+	}
+
+	if err := wv.Config.Manager().Webhook().Delete(webhook); err != nil {
+		return response.Error(err)
+	}
+
+	NewAuditEntry(AUDIT_ACTION_WEBHOOK_DELETE).
+		Request(r).
+		Target(AUDIT_TARGET_WEBHOOK, webhook.ID, webhook.Name).
+		Save(wv.Config)
+
+	return response.OK()
+}
+
+/*
+WebhookDeliveryAPIViewSet lists deliveries of given webhook
+*/
+type WebhookDeliveryAPIViewSet struct {
+	classy.ViewSet
+
+	// config instance
+	Config Config
+}
+
+/*
+List returns paginated deliveries of webhook, newest first. Deliveries can be filtered by status.
+*/
+func (wd *WebhookDeliveryAPIViewSet) List(w http.ResponseWriter, r *http.Request) response.Response {
+
+	// don't forget to parse form
+	r.ParseForm()
+	paginator := CommonPaginator(r.Form)
+
+	filtered := wd.Config.DB().Model(WebhookDelivery{}).Where("webhook_id = ?", Atoui(mux.Vars(r)["webhook_pk"]))
+	if status := r.URL.Query().Get("status"); status != "" {
+		filtered = filtered.Where("status = ?", status)
+	}
+
+	deliveries := []WebhookDelivery{}
+	if err := LimitQueryset(filtered, paginator).Order("id DESC").Find(&deliveries).Error; err != nil {
+		return response.Error(err)
+	}
+
+	// set count
+	CountQueryset(filtered, paginator)
+
+	return response.OK().SliceResult(deliveries).Data("paginator", paginator)
+}
+
+/*
+Retrieve returns single delivery with payload and response
+*/
+func (wd *WebhookDeliveryAPIViewSet) Retrieve(w http.ResponseWriter, r *http.Request) response.Response {
+	vars := mux.Vars(r)
+
+	delivery := WebhookDelivery{}
+	if wd.Config.DB().First(&delivery, "id = ? AND webhook_id = ?", Atoui(vars["pk"]), Atoui(vars["webhook_pk"])).RecordNotFound() {
+		return response.NotFound()
+	}
+
+	return response.OK().Result(delivery)
+}
+
+/*
+WebhookRedeliverAPIView sends payload of delivery again
+*/
+type WebhookRedeliverAPIView struct {
+	classy.GenericView
+
+	// config instance
+	Config Config
+}
+
+/*
+POST creates new delivery with the same payload
+*/
+func (wr *WebhookRedeliverAPIView) POST(w http.ResponseWriter, r *http.Request) response.Response {
+	vars := mux.Vars(r)
+
+	delivery := WebhookDelivery{}
+	if wr.Config.DB().First(&delivery, "id = ? AND webhook_id = ?", Atoui(vars["pk"]), Atoui(vars["webhook_pk"])).RecordNotFound() {
+		return response.NotFound()
+	}
+
+	redelivery, err := wr.Config.Manager().Webhook().Redeliver(delivery)
+	if err != nil {
+		return response.Error(err)
+	}
+
+	wr.Config.Webhooks().Dispatcher().Notify()
+
+	NewAuditEntry(AUDIT_ACTION_WEBHOOK_REDELIVER).
+		Request(r).
+		Target(AUDIT_TARGET_WEBHOOK, delivery.WebhookID, delivery.Event).
+		Change("delivery", delivery.ID, redelivery.ID).
+		Save(wr.Config)
+
+	return response.OK().Result(redelivery)
+}
+
+/*
+TaskAPIView lists registered background tasks along with their schedules and last runs
+*/
+type TaskAPIView struct {
+	classy.GenericView
+
+	// config instance
+	Config Config
+}
+
+/*
+TaskInfo describes registered task
+*/
+type TaskInfo struct {
+	Name        string     `json:"name"`
+	Description string     `json:"description"`
+	Schedule    string     `json:"schedule"`
+	NextRunAt   *time.Time `json:"next_run_at"`
+	LastRun     *TaskRun   `json:"last_run"`
+}
+
+/*
+GET returns all registered tasks
+*/
+func (t *TaskAPIView) GET(w http.ResponseWriter, r *http.Request) response.Response {
+	result := []TaskInfo{}
+
+	for _, task := range t.Config.Scheduler().Scheduler().Tasks() {
+		info := TaskInfo{
+			Name:        task.Name,
+			Description: task.Description,
+			Schedule:    task.Spec,
+			LastRun:     t.Config.Manager().Task().LastRun(task.Name),
+		}
+		if task.Schedule != nil && t.Config.Scheduler().Enabled() {
+			next := task.Schedule.Next(time.Now())
+			info.NextRunAt = &next
+		}
+		result = append(result, info)
+	}
+
+	return response.OK().SliceResult(result)
+}
+
+/*
+TaskRunTriggerAPIView runs task immediately
+*/
+type TaskRunTriggerAPIView struct {
+	classy.GenericView
+
+	// config instance
+	Config Config
+}
+
+/*
+POST starts task in background and returns its run record
+*/
+func (t *TaskRunTriggerAPIView) POST(w http.ResponseWriter, r *http.Request) response.Response {
+	scheduler := t.Config.Scheduler().Scheduler()
+
+	task, run, err := scheduler.Begin(t.Config, mux.Vars(r)["name"], TASK_TRIGGER_MANUAL, nil)
+	if err != nil {
+		switch err {
+		case ErrTaskNotFound:
+			return response.NotFound()
+		case ErrTaskLocked:
+			return response.New(http.StatusConflict).Error(err)
+		}
+		return response.Error(err)
+	}
+
+	NewAuditEntry(AUDIT_ACTION_TASK_RUN).
+		Request(r).
+		Target(AUDIT_TARGET_TASK, run.ID, task.Name).
+		Save(t.Config)
+
+	go scheduler.Finish(t.Config, task, run)
+
+	return response.New(http.StatusAccepted).Result(run)
+}
+
+/*
+TaskRunAPIViewSet provides history of task runs
+*/
+type TaskRunAPIViewSet struct {
+	classy.ViewSet
+
+	// config instance
+	Config Config
+}
+
+/*
+List returns paginated task runs, newest first. Runs can be filtered by task and status.
+*/
+func (t *TaskRunAPIViewSet) List(w http.ResponseWriter, r *http.Request) response.Response {
+
+	// don't forget to parse form
+	r.ParseForm()
+	paginator := CommonPaginator(r.Form)
+
+	filtered := t.Config.DB().Model(TaskRun{})
+	if task := r.URL.Query().Get("task"); task != "" {
+		filtered = filtered.Where("task = ?", task)
+	}
+	if status := r.URL.Query().Get("status"); status != "" {
+		filtered = filtered.Where("status = ?", status)
+	}
+
+	runs := []TaskRun{}
+	if err := LimitQueryset(filtered, paginator).Order("id DESC").Find(&runs).Error; err != nil {
+		return response.Error(err)
+	}
+
+	// set count
+	CountQueryset(filtered, paginator)
+
+	return response.OK().SliceResult(runs).Data("paginator", paginator)
+}
 
-	// validate serializer
-	if vr = s.Validate(u.Config); !vr.IsValid() {
-		return response.New(http.StatusBadRequest).Error(vr)
+/*
+Retrieve returns single task run
+*/
+func (t *TaskRunAPIViewSet) Retrieve(w http.ResponseWriter, r *http.Request) response.Response {
+	run := TaskRun{}
+	if t.Config.DB().First(&run, "id = ?", Atoui(mux.Vars(r)["pk"])).RecordNotFound() {
+		return response.NotFound()
 	}
 
-	// get user from serializer
-	user := s.GetUser(u.Config)
+	return response.OK().Result(run)
+}
 
-	// insert user to database
-	if err = u.Config.DB().Create(&user).Error; err != nil {
-		return response.Error(err)
-	}
+/*
+StatsDownloadBreakdownAPIView provides detailed download stats grouped by version, file, user, installer, python
+or system
+*/
+type StatsDownloadBreakdownAPIView struct {
+	classy.GenericView
 
-	return response.Result(user)
+	// config instance
+	Config Config
 }
 
 /*
-Update is called when POST is called to update user
+GET returns download breakdown, query parameters:
+
+	by       dimension (version, file, user, installer, python, system), user is available only to admins
+	package  package id
+	version  package version id
+	since    time or date (2006-01-02) of first day
+	until    time or date of last day (exclusive)
+
+Only packages visible to user are counted.
 */
-func (u *UserAPIViewSet) Update(w http.ResponseWriter, r *http.Request) response.Response {
+func (s *StatsDownloadBreakdownAPIView) GET(w http.ResponseWriter, r *http.Request) response.Response {
 
 	var (
-		err error
-		pk  int
+		err  error
+		user User
 	)
-	serializer := UserUpdateSerializer{}
 
-	// bind request to serializer
-	if err = Bind(r, &serializer); err != nil {
-		return response.BadRequest()
+	if user, err = ContextGetTokenUser(r.Context()); err != nil {
+		return response.Error(err)
 	}
 
-	// convert primary key to int
-	if pk, err = strconv.Atoi(mux.Vars(r)["pk"]); err != nil {
-		return response.BadRequest()
+	query := r.URL.Query()
+
+	by := query.Get("by")
+	if by == "" {
+		by = STATS_BREAKDOWN_INSTALLER
+	}
+	if !StringListContains(AVAILABLE_STATS_BREAKDOWNS, by) {
+		return response.BadRequest().Error(ErrStatsUnknownBreakdown)
 	}
 
-	// prepare user
-	user := User{}
-	if u.Config.DB().First(&user, "id = ?", pk).RecordNotFound() {
-		return response.NotFound()
+	// downloads of other users are visible only to admins
+	if by == STATS_BREAKDOWN_USER && !user.IsAdmin {
+		return response.New(http.StatusForbidden).Error(ErrUserNotAdmin)
 	}
 
-	// add primary key to serializer so Validate method can use it
-	serializer.ID = uint(pk)
+	filter := []FilterFunc{FFDownloadStatsUser(user)}
 
-	if vr := serializer.Validate(u.Config); !vr.IsValid() {
-		return response.BadRequest().Error(vr)
+	if value := query.Get("package"); value != "" {
+		pack := Package{}
+		if err = s.Config.Manager().Package().Get(&pack, FFID(Atoui(value)), FFPackagesVisibleFor(user)).Error; err != nil {
+			if err == gorm.ErrRecordNotFound {
+				return response.NotFound()
+			}
+			return response.Error(err)
+		}
+		filter = append(filter, FFDownloadStatsPackage(&pack))
 	}
 
-	// Update user
-	serializer.UpdateUser(u.Config, &user)
+	if value := query.Get("version"); value != "" {
+		filter = append(filter, FFDownloadStatsPackageVersion(&PackageVersion{ID: Atoui(value)}))
+	}
 
-	// save user
-	if err = u.Config.DB().Save(&user).Error; err != nil {
+	for param, condition := range map[string]string{"since": "created_at >= ?", "until": "created_at < ?"} {
+		if value := query.Get(param); value != "" {
+			var t time.Time
+			if t, err = TimeParse(value); err != nil {
+				return response.BadRequest().Error(err)
+			}
+			filter = append(filter, FFWhere(condition, t))
+		}
+	}
+
+	result := []StatsBreakdownItem{}
+	if err = s.Config.Manager().DownloadStats().GetBreakdown(by, &result, filter...); err != nil {
 		return response.Error(err)
 	}
 
-	return response.OK().Result(user)
+	return response.OK().Result(result)
 }
 
 /*
-PackageMaintainerAPIViewSet provides rest endpoints for maintainers of given package
+IndexAPIViewSet provides rest endpoints for indexes. Users see indexes they can read, only admins can create, update
+and delete indexes.
 */
-type PackageMaintainerAPIViewSet struct {
+type IndexAPIViewSet struct {
 	classy.ViewSet
 
 	// config instance
@@ -807,174 +2705,293 @@ type PackageMaintainerAPIViewSet struct {
 }
 
 /*
-GetPackage returns package from request
+Before allows only safe methods to non admin users
 */
-func (p *PackageMaintainerAPIViewSet) GetPackage(r *http.Request) (result Package, err error) {
-	result = Package{}
-	err = p.Config.DB().First(&result, "id = ?", mux.Vars(r)["package_pk"]).Error
-	return
+func (iv *IndexAPIViewSet) Before(w http.ResponseWriter, r *http.Request) response.Response {
+	user, err := ContextGetTokenUser(r.Context())
+	if err != nil {
+		return response.Error(err)
+	}
+
+	if r.Method != http.MethodGet && r.Method != http.MethodOptions && !user.IsAdmin {
+		return response.New(http.StatusForbidden).Error(ErrUserNotAdmin)
+	}
+
+	return nil
 }
 
 /*
-List method lists all maintainers for given package
+List returns indexes readable by user
 */
-func (p *PackageMaintainerAPIViewSet) List(w http.ResponseWriter, r *http.Request) response.Response {
-
-	var (
-		err  error
-		pack Package
-	)
-	if pack, err = p.GetPackage(r); err != nil {
-		if err == gorm.ErrRecordNotFound {
-			return response.NotFound()
-		}
+func (iv *IndexAPIViewSet) List(w http.ResponseWriter, r *http.Request) response.Response {
+	user, err := ContextGetTokenUser(r.Context())
+	if err != nil {
+		return response.Error(err)
 	}
 
-	var (
-		maintainers []User
-	)
+	indexes := []Index{}
+	if err = iv.Config.Manager().Index().Readable(&indexes, user); err != nil {
+		return response.Error(err)
+	}
 
-	// list all maintainers
-	if err = p.Config.DB().Model(pack).Association("Maintainers").Find(&maintainers).Error; err != nil {
-		if err != gorm.ErrRecordNotFound {
+	for i := range indexes {
+		if indexes[i].Bases, err = iv.Config.Manager().Index().ListBases(indexes[i]); err != nil {
 			return response.Error(err)
 		}
-
-		err = nil
 	}
 
-	return response.OK().SliceResult(maintainers)
+	return response.OK().SliceResult(indexes)
 }
 
 /*
-Update association means that when it's not present, it's created,
-Update method doesn't handle request body.
+Create creates new index
 */
-func (p *PackageMaintainerAPIViewSet) Update(w http.ResponseWriter, r *http.Request) response.Response {
-	var (
-		err  error
-		pack Package
-	)
-	if pack, err = p.GetPackage(r); err != nil {
-		if err == gorm.ErrRecordNotFound {
-			return response.NotFound()
-		}
+func (iv *IndexAPIViewSet) Create(w http.ResponseWriter, r *http.Request) response.Response {
+	serializer := IndexSerializer{}
+	if err := Bind(r, &serializer); err != nil {
+		return response.BadRequest().Error(err)
 	}
 
-	var user User
+	if vr := serializer.Validate(iv.Config, 0); !vr.IsValid() {
+		return response.BadRequest().Error(vr)
+	}
 
-	// try to find user by pk first
-	if p.Config.DB().First(&user, "id = ?", mux.Vars(r)["pk"]).RecordNotFound() {
+	index := Index{}
+	serializer.UpdateIndex(&index)
+
+	if err := iv.Config.DB().Create(&index).Error; err != nil {
+		return response.Error(err)
+	}
+
+	if err := iv.Config.Manager().Index().SetBases(index, serializer.Bases); err != nil {
+		return response.BadRequest().Error(err)
+	}
+
+	NewAuditEntry(AUDIT_ACTION_INDEX_CREATE).
+		Request(r).
+		Target(AUDIT_TARGET_INDEX, index.ID, index.Name).
+		Diff(nil, index).
+		Change("bases", nil, serializer.Bases).
+		Save(iv.Config)
+
+	return iv.result(index)
+}
+
+/*
+Retrieve returns single index readable by user, access list is returned only to admins
+*/
+func (iv *IndexAPIViewSet) Retrieve(w http.ResponseWriter, r *http.Request) response.Response {
+	user, err := ContextGetTokenUser(r.Context())
+	if err != nil {
+		return response.Error(err)
+	}
+
+	index := Index{}
+	if iv.Config.DB().First(&index, "id = ?", Atoui(mux.Vars(r)["pk"])).RecordNotFound() {
 		return response.NotFound()
 	}
 
-	var maintainers []User
+	if !iv.Config.Manager().Index().CanRead(index, user) {
+		return response.NotFound()
+	}
 
-	if err = p.Config.DB().Model(&pack).Association("Maintainers").Find(&maintainers).Error; err != nil {
-		if err != gorm.ErrRecordNotFound {
+	if user.IsAdmin {
+		if err = iv.Config.DB().Preload("User").Where("index_id = ?", index.ID).Find(&index.ACL).Error; err != nil {
 			return response.Error(err)
 		}
-		err = nil
 	}
 
-	// iterate over maintainers to find whether maintainer is already there
-	for _, maintainer := range maintainers {
-		if maintainer.ID == user.ID {
-			return response.OK().Result(user)
+	return iv.result(index)
+}
+
+/*
+Update updates index and replaces its bases
+*/
+func (iv *IndexAPIViewSet) Update(w http.ResponseWriter, r *http.Request) response.Response {
+	index := Index{}
+	if iv.Config.DB().First(&index, "id = ?", Atoui(mux.Vars(r)["pk"])).RecordNotFound() {
+		return response.NotFound()
+	}
+
+	serializer := IndexSerializer{}
+	if err := Bind(r, &serializer); err != nil {
+		return response.BadRequest().Error(err)
+	}
+
+	if vr := serializer.Validate(iv.Config, index.ID); !vr.IsValid() {
+		return response.BadRequest().Error(vr)
+	}
+
+	// default index cannot be renamed, it's served at /simple
+	if index.Name == DEFAULT_INDEX && serializer.Name != DEFAULT_INDEX {
+		return response.BadRequest().Error(ErrIndexDefault)
+	}
+
+	before := index
+	serializer.UpdateIndex(&index)
+
+	beforeBases := []string{}
+	if bases, err := iv.Config.Manager().Index().ListBases(index); err == nil {
+		for _, base := range bases {
+			beforeBases = append(beforeBases, base.BaseName)
 		}
 	}
 
-	// maintainer not found, create one
-	if err = p.Config.DB().Model(&pack).Association("Maintainers").Append(user).Error; err != nil {
+	if err := iv.Config.Manager().Index().SetBases(index, serializer.Bases); err != nil {
+		return response.BadRequest().Error(err)
+	}
+
+	if err := iv.Config.DB().Save(&index).Error; err != nil {
 		return response.Error(err)
 	}
 
-	return response.OK().Result(user)
+	entry := NewAuditEntry(AUDIT_ACTION_INDEX_UPDATE).
+		Request(r).
+		Target(AUDIT_TARGET_INDEX, index.ID, index.Name).
+		Diff(before, index)
+
+	if strings.Join(beforeBases, ",") != strings.Join(serializer.Bases, ",") {
+		entry.Change("bases", beforeBases, serializer.Bases)
+	}
+	entry.Save(iv.Config)
+
+	return iv.result(index)
 }
 
 /*
-Delete removes association of maintainer to given package
+Delete deletes index, default index and indexes with packages cannot be deleted
 */
-func (p *PackageMaintainerAPIViewSet) Delete(w http.ResponseWriter, r *http.Request) response.Response {
-	var (
-		err  error
-		pack Package
-	)
-	if pack, err = p.GetPackage(r); err != nil {
-		if err == gorm.ErrRecordNotFound {
-			return response.NotFound()
-		}
+func (iv *IndexAPIViewSet) Delete(w http.ResponseWriter, r *http.Request) response.Response {
+	index := Index{}
+	if iv.Config.DB().First(&index, "id = ?", Atoui(mux.Vars(r)["pk"])).RecordNotFound() {
+		return response.NotFound()
 	}
 
-	var user User
-
-	// try to find user by pk first
-	if err = p.Config.DB().First(&user, "id = ?", mux.Vars(r)["pk"]).Error; err != nil {
-		if err == gorm.ErrRecordNotFound {
-			return response.NotFound()
+	if err := iv.Config.Manager().Index().Delete(index); err != nil {
+		if err == ErrIndexDefault || err == ErrIndexNotEmpty {
+			return response.BadRequest().Error(err)
 		}
 		return response.Error(err)
 	}
 
-	// try to delete association
-	if err = p.Config.DB().Model(&pack).Association("Maintainers").Delete(user).Error; err != nil {
-		if err == gorm.ErrRecordNotFound {
-			return response.NotFound()
-		}
-		return response.Error(err)
-	}
+	NewAuditEntry(AUDIT_ACTION_INDEX_DELETE).
+		Request(r).
+		Target(AUDIT_TARGET_INDEX, index.ID, index.Name).
+		Save(iv.Config)
 
 	return response.OK()
 }
 
 /*
-PlatformAPIViewSet provides rest endpoints for platform (RU)
+result returns response with index and its bases
 */
-type PlatformAPIViewSet struct {
+func (iv *IndexAPIViewSet) result(index Index) response.Response {
+	var err error
+	if index.Bases, err = iv.Config.Manager().Index().ListBases(index); err != nil {
+		return response.Error(err)
+	}
+	return response.OK().Result(index)
+}
+
+/*
+IndexACLAPIViewSet manages access list of index
+*/
+type IndexACLAPIViewSet struct {
 	classy.ViewSet
 
-	// configuration
+	// config instance
 	Config Config
 }
 
 /*
-List returns all platform stored in database
+getIndex returns index from url
 */
-func (p *PlatformAPIViewSet) List(w http.ResponseWriter, r *http.Request) response.Response {
+func (ia *IndexACLAPIViewSet) getIndex(r *http.Request) (index Index, err error) {
+	if ia.Config.DB().First(&index, "id = ?", Atoui(mux.Vars(r)["index_pk"])).RecordNotFound() {
+		err = ErrIndexNotFound
+	}
+	return
+}
 
-	var (
-		err error
-	)
+/*
+List returns access list of index
+*/
+func (ia *IndexACLAPIViewSet) List(w http.ResponseWriter, r *http.Request) response.Response {
+	index, err := ia.getIndex(r)
+	if err != nil {
+		return response.NotFound().Error(err)
+	}
 
-	list := []Platform{}
+	acl := []IndexACL{}
+	if err = ia.Config.DB().Preload("User").Where("index_id = ?", index.ID).Order("id ASC").Find(&acl).Error; err != nil {
+		return response.Error(err)
+	}
 
-	if err = p.Config.Manager().Platform().List(&list).Error; err != nil {
-		if err != gorm.ErrRecordNotFound {
-			return response.Error(err)
-		}
+	return response.OK().SliceResult(acl)
+}
+
+/*
+Create grants user access to index, existing access of user is replaced
+*/
+func (ia *IndexACLAPIViewSet) Create(w http.ResponseWriter, r *http.Request) response.Response {
+	index, err := ia.getIndex(r)
+	if err != nil {
+		return response.NotFound().Error(err)
 	}
 
-	return response.OK().SliceResult(list)
+	serializer := IndexACLSerializer{}
+	if err = Bind(r, &serializer); err != nil {
+		return response.BadRequest().Error(err)
+	}
+
+	if vr := serializer.Validate(ia.Config); !vr.IsValid() {
+		return response.BadRequest().Error(vr)
+	}
+
+	acl, _ := ia.Config.Manager().Index().GetACL(index, User{ID: serializer.UserID})
+	before := acl
+
+	acl.IndexID = index.ID
+	acl.UserID = serializer.UserID
+	acl.CanRead = serializer.CanRead
+	acl.CanUpload = serializer.CanUpload
+
+	if err = ia.Config.DB().Save(&acl).Error; err != nil {
+		return response.Error(err)
+	}
+
+	NewAuditEntry(AUDIT_ACTION_INDEX_UPDATE).
+		Request(r).
+		Target(AUDIT_TARGET_INDEX, index.ID, index.Name).
+		Change("acl", before, acl).
+		Save(ia.Config)
+
+	return response.OK().Result(acl)
 }
 
 /*
-Retrieve returns single platform
+Delete removes user (given by pk) from access list of index
 */
-func (p *PlatformAPIViewSet) Retrieve(w http.ResponseWriter, r *http.Request) response.Response {
+func (ia *IndexACLAPIViewSet) Delete(w http.ResponseWriter, r *http.Request) response.Response {
+	index, err := ia.getIndex(r)
+	if err != nil {
+		return response.NotFound().Error(err)
+	}
 
-	// prepare platform to find
-	platform := Platform{
-		ID: Atoui(mux.Vars(r)["pk"]),
+	acl, found := ia.Config.Manager().Index().GetACL(index, User{ID: Atoui(mux.Vars(r)["pk"])})
+	if !found {
+		return response.NotFound()
 	}
 
-	// get platform
-	if err := p.Config.Manager().Platform().Get(&platform).Error; err != nil {
-		if err == gorm.ErrRecordNotFound {
-			return response.NotFound()
-		} else {
-			return response.Error(err)
-		}
+	if err = ia.Config.DB().Delete(&acl).Error; err != nil {
+		return response.Error(err)
 	}
 
-	return response.OK().Result(platform)
+	NewAuditEntry(AUDIT_ACTION_INDEX_UPDATE).
+		Request(r).
+		Target(AUDIT_TARGET_INDEX, index.ID, index.Name).
+		Change("acl", acl, nil).
+		Save(ia.Config)
+
+	return response.OK()
 }
@@ -0,0 +1,388 @@
+/*
+Public pages
+
+Server rendered pages for browsing packages, available to everybody who can read index (packages of base indexes are
+included same as in /simple):
+
+	/project/                        list of packages, ?q= searches packages (see SearchManager)
+	/project/<name>/                 overview with description, license, classifiers and maintainers (ProjectDetailView)
+	/project/<name>/history/         release history
+	/project/<name>/files/           files of release with their hashes
+	/project/<name>/dependencies/    dependencies of release and packages that depend on it
+
+Pages of index are available under /index/<index>/project/, ?version= selects release (latest by default).
+*/
+package core
+
+import (
+	"html/template"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"github.com/jinzhu/gorm"
+	"github.com/phonkee/go-classy"
+	"github.com/phonkee/go-response"
+)
+
+/*
+projectPage is common data of public pages
+*/
+type projectPage struct {
+	Index    Index
+	IndexURL string
+	Package  Package
+	Versions []PackageVersion
+	Version  PackageVersion
+
+	// active tab of project pages
+	Tab string
+
+	router *mux.Router
+	ids    []uint
+	prefix string
+	pairs  []string
+}
+
+/*
+newProjectPage returns page for index of request, unreadable index is reported as not found
+*/
+func newProjectPage(cfg Config, r *http.Request) (page projectPage, err error) {
+	if page.Index, err = GetRequestIndex(cfg, r); err != nil {
+		return
+	}
+
+	if page.ids, err = cfg.Manager().Index().ResolveIDs(page.Index); err != nil {
+		return
+	}
+
+	page.router = cfg.Router()
+	if name := mux.Vars(r)["index"]; name != "" {
+		page.prefix, page.pairs = "index_", []string{"index", name}
+	}
+	page.IndexURL = requestBaseURL(r) + page.Reverse("package_list")
+
+	return
+}
+
+/*
+getProjectPage returns page of package from request with selected release (?version=, latest by default)
+*/
+func getProjectPage(cfg Config, r *http.Request, tab string) (page projectPage, err error) {
+	if page, err = newProjectPage(cfg, r); err != nil {
+		return
+	}
+	page.Tab = tab
+
+	packages := []Package{}
+	if err = ApplyFilterFuncs(cfg.DB(), FFPackageName(mux.Vars(r)["slug"]), FFPackagesInIndexes(page.ids...)).
+		Preload("Index").
+		Preload("Maintainers").
+		Preload("Versions", func(db *gorm.DB) *gorm.DB { return db.Order("version_order DESC, id DESC") }).
+		Find(&packages).Error; err != nil {
+		return
+	}
+
+	if len(packages) == 0 {
+		err = ErrPackageNotFound
+		return
+	}
+
+	page.Package = SortPackagesByIndex(packages, page.ids)[0]
+	page.Versions = page.Package.Versions
+	if len(page.Versions) == 0 {
+		err = ErrPackageNotFound
+		return
+	}
+
+	requested := r.URL.Query().Get("version")
+	found := false
+	for _, version := range page.Versions {
+		if requested == "" || version.Version == requested {
+			page.Version, found = version, true
+			break
+		}
+	}
+	if !found {
+		err = ErrPackageVersionNotFound
+		return
+	}
+
+	if err = cfg.DB().
+		Preload("License").
+		Preload("Classifiers").
+		Preload("Files", func(db *gorm.DB) *gorm.DB { return db.Order("filename") }).
+		Preload("Dependencies", func(db *gorm.DB) *gorm.DB { return db.Order("canonical_name") }).
+		First(&page.Version, page.Version.ID).Error; err != nil {
+		return
+	}
+
+	sort.Slice(page.Version.Classifiers, func(i, j int) bool {
+		return page.Version.Classifiers[i].Name < page.Version.Classifiers[j].Name
+	})
+	for i := range page.Version.Files {
+		page.Version.Files[i].DownloadURL = cfg.Manager().PackageVersionFile().GetDownloadURL(&page.Version.Files[i])
+	}
+
+	return
+}
+
+/*
+Reverse returns url of named route in index of page (index routes have "index_" prefix)
+*/
+func (p projectPage) Reverse(name string, pairs ...string) string {
+	url, err := p.router.Get(p.prefix + name).URL(append(append([]string{}, p.pairs...), pairs...)...)
+	if err != nil {
+		return ""
+	}
+	return url.String()
+}
+
+/*
+ProjectURL returns url of project page ("detail", "history", "files" or "dependencies") of package
+*/
+func (p projectPage) ProjectURL(page string, name string) string {
+	return p.Reverse("project_"+page, "slug", name)
+}
+
+/*
+Description returns rendered description of release, it's sanitized when rendered at upload
+*/
+func (p projectPage) Description() template.HTML {
+	return template.HTML(p.Version.DescriptionHTML)
+}
+
+/*
+renderProjectPage renders page template (relative to templates directory) with public base layout
+*/
+func renderProjectPage(cfg Config, data interface{}, filename string) response.Response {
+	rendered, err := cfg.RenderTemplateFiles(data, "public", "public/base.tpl.html", filename)
+	if err != nil {
+		return response.Error(err)
+	}
+
+	return response.OK().HTML(rendered)
+}
+
+/*
+projectErrorResponse returns response for error of public page, missing objects are reported as not found
+*/
+func projectErrorResponse(err error) response.Response {
+	switch err {
+	case ErrIndexNotFound, ErrPackageNotFound, ErrPackageVersionNotFound, gorm.ErrRecordNotFound:
+		return response.NotFound().Error(err)
+	}
+	return response.Error(err)
+}
+
+/*
+projectListPage is data of list page
+*/
+type projectListPage struct {
+	projectPage
+
+	Query    string
+	Items    []SearchHit
+	Count    int
+	PrevURL  string
+	NextURL  string
+	NumPages int
+	Page     int
+}
+
+/*
+ProjectListView is public list of packages with search
+*/
+type ProjectListView struct {
+	classy.ListView
+
+	// config instance
+	Config Config
+}
+
+/*
+List renders paginated list of packages of index and its bases with their latest releases. When package with same
+name exists in multiple indexes, package from first index in lookup order is listed. Query (?q=) searches packages,
+most relevant are listed first.
+*/
+func (p *ProjectListView) List(w http.ResponseWriter, r *http.Request) response.Response {
+	var (
+		err  error
+		hits []SearchHit
+		page projectListPage
+	)
+
+	if page.projectPage, err = newProjectPage(p.Config, r); err != nil {
+		return projectErrorResponse(err)
+	}
+
+	page.Query = strings.TrimSpace(r.URL.Query().Get("q"))
+
+	if page.Query == "" {
+		hits, err = p.Config.Manager().Search().List(page.ids)
+	} else if hits, err = p.Config.Manager().Search().Search(page.Query, page.ids); err == ErrSearchQueryEmpty {
+		err = nil
+	}
+	if err != nil {
+		return response.Error(err)
+	}
+
+	r.ParseForm()
+	paginator := CommonPaginator(r.Form)
+
+	page.Items = PaginateSearchHits(hits, paginator)
+	page.Count = len(hits)
+	page.Page, page.NumPages = paginator.GetPage(), paginator.GetNumPages()
+
+	pageURL := func(number int) string {
+		values := url.Values{}
+		if page.Query != "" {
+			values.Set("q", page.Query)
+		}
+		values.Set("page", strconv.Itoa(number))
+		return page.Reverse("project_list") + "?" + values.Encode()
+	}
+	if page.Page > 1 {
+		page.PrevURL = pageURL(page.Page - 1)
+	}
+	if page.Page < page.NumPages {
+		page.NextURL = pageURL(page.Page + 1)
+	}
+
+	return renderProjectPage(p.Config, page, "public/list.tpl.html")
+}
+
+/*
+ProjectHistoryView is public release history of package
+*/
+type ProjectHistoryView struct {
+	classy.GenericView
+
+	// config instance
+	Config Config
+}
+
+/*
+GET renders all releases of package, newest first
+*/
+func (p *ProjectHistoryView) GET(w http.ResponseWriter, r *http.Request) response.Response {
+	page, err := getProjectPage(p.Config, r, "history")
+	if err != nil {
+		return projectErrorResponse(err)
+	}
+
+	return renderProjectPage(p.Config, page, "public/history.tpl.html")
+}
+
+/*
+ProjectFilesView is public list of files of release
+*/
+type ProjectFilesView struct {
+	classy.GenericView
+
+	// config instance
+	Config Config
+}
+
+/*
+GET renders files of release with their hashes and download links
+*/
+func (p *ProjectFilesView) GET(w http.ResponseWriter, r *http.Request) response.Response {
+	page, err := getProjectPage(p.Config, r, "files")
+	if err != nil {
+		return projectErrorResponse(err)
+	}
+
+	return renderProjectPage(p.Config, page, "public/files.tpl.html")
+}
+
+/*
+projectDependency is dependency of release, Hosted is name of package in index (when it's hosted)
+*/
+type projectDependency struct {
+	PackageDependency
+
+	Hosted string
+}
+
+/*
+projectDependent is latest release of package in index that depends on package
+*/
+type projectDependent struct {
+	Package   string
+	Version   string
+	Specifier string
+	Marker    string
+}
+
+/*
+projectDependenciesPage is data of dependencies page
+*/
+type projectDependenciesPage struct {
+	projectPage
+
+	Dependencies []projectDependency
+	Dependents   []projectDependent
+}
+
+/*
+ProjectDependenciesView is public list of dependencies of release
+*/
+type ProjectDependenciesView struct {
+	classy.GenericView
+
+	// config instance
+	Config Config
+}
+
+/*
+GET renders dependencies of release (linked to packages hosted in index) and latest releases of packages in index
+that depend on package
+*/
+func (p *ProjectDependenciesView) GET(w http.ResponseWriter, r *http.Request) response.Response {
+	var (
+		err  error
+		page projectDependenciesPage
+	)
+
+	if page.projectPage, err = getProjectPage(p.Config, r, "dependencies"); err != nil {
+		return projectErrorResponse(err)
+	}
+
+	// hosted packages by canonical name, earlier indexes of resolution win
+	packages := []Package{}
+	if err = p.Config.DB().Select("name, index_id").Where("index_id IN (?)", page.ids).Find(&packages).Error; err != nil {
+		return response.Error(err)
+	}
+	hosted := map[string]string{}
+	for _, pack := range SortPackagesByIndex(packages, page.ids) {
+		if _, ok := hosted[CanonicalPackageName(pack.Name)]; !ok {
+			hosted[CanonicalPackageName(pack.Name)] = pack.Name
+		}
+	}
+
+	for _, dependency := range page.Version.Dependencies {
+		page.Dependencies = append(page.Dependencies, projectDependency{
+			PackageDependency: dependency,
+			Hosted:            hosted[dependency.CanonicalName],
+		})
+	}
+
+	queryset := p.Config.DB().Table("package_dependency").
+		Joins("JOIN package_version ON package_version.id = package_dependency.package_version_id").
+		Joins("JOIN package ON package.id = package_version.package_id").
+		Where("package_dependency.canonical_name = ?", CanonicalPackageName(page.Package.Name)).
+		Where("package.name <> ? AND package.index_id IN (?)", page.Package.Name, page.ids)
+	if err = FFLatestPackageVersions()(queryset).
+		Select("package.name AS package, package_version.version, package_dependency.specifier, " +
+			"package_dependency.marker").
+		Order("package.name").
+		Scan(&page.Dependents).Error; err != nil {
+		return response.Error(err)
+	}
+
+	return renderProjectPage(p.Config, page, "public/dependencies.tpl.html")
+}
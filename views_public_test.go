@@ -0,0 +1,139 @@
+package core
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+/*
+newTestProjectRepository returns default index with package "Foo_Bar" with two releases
+*/
+func newTestProjectRepository(public bool, users ...User) testRepository {
+	return testRepository{
+		testIndexes: testIndexes{indexes: []Index{{ID: 1, Name: DEFAULT_INDEX, Public: public}}},
+		users:       users,
+		packages: []Package{
+			{ID: 1, IndexID: 1, Name: "Foo_Bar", Versions: []PackageVersion{
+				{ID: 10, Version: "0.9", Summary: "Old <b>summary</b>", Files: []PackageVersionFile{
+					{ID: 100, Filename: "Foo_Bar-0.9.tar.gz", MD5Digest: "d41d8cd98f00b204e9800998ecf8427e"},
+				}},
+				{ID: 11, Version: "1.0", Summary: "Foo <b>bar</b>", DescriptionHTML: "<p>Rendered <em>description</em></p>",
+					RequiresPython: ">=3.8", Files: []PackageVersionFile{
+						{ID: 101, Filename: "Foo_Bar-1.0.tar.gz", MD5Digest: "0cc175b9c0f1b6a831c399e269772661"},
+						{ID: 102, Filename: "Foo_Bar-1.0-py3-none-any.whl", MD5Digest: "92eb5ffee6ae2fec3ad71c777531578f"},
+					}},
+			}},
+		},
+	}
+}
+
+func TestProjectViews(t *testing.T) {
+	user := User{ID: 1, Username: "user", Password: "hash", IsActive: true, CanList: true}
+
+	tc := []struct {
+		name     string
+		path     string
+		status   int
+		contains []string
+	}{
+		{"list", "/project/", http.StatusOK, []string{
+			`href="/project/Foo_Bar/"`, "Foo &lt;b&gt;bar&lt;/b&gt;", "1.0",
+		}},
+		{"detail", "/project/Foo_Bar/", http.StatusOK, []string{
+			"<title>Foo_Bar 1.0", "<p>Rendered <em>description</em></p>", "<code>&gt;=3.8</code>",
+		}},
+		{"detail by canonical name", "/project/foo-bar/", http.StatusOK, []string{
+			"<title>Foo_Bar 1.0", "<p>Rendered <em>description</em></p>",
+		}},
+		{"detail of release", "/project/foo.bar/?version=0.9", http.StatusOK, []string{
+			"<title>Foo_Bar 0.9", "not provided a project description",
+		}},
+		{"unknown release", "/project/foo-bar/?version=2.0", http.StatusNotFound, nil},
+		{"unknown package", "/project/unknown/", http.StatusNotFound, nil},
+		{"history", "/project/foo-bar/history/", http.StatusOK, []string{
+			`href="/project/Foo_Bar/?version=0.9"`, `href="/project/Foo_Bar/?version=1.0"`,
+		}},
+		{"files", "/project/FOO_BAR/files/", http.StatusOK, []string{
+			"Foo_Bar-1.0.tar.gz", "Foo_Bar-1.0-py3-none-any.whl", "0cc175b9c0f1b6a831c399e269772661",
+		}},
+		{"files of release", "/project/foo-bar/files/?version=0.9", http.StatusOK, []string{
+			"Foo_Bar-0.9.tar.gz", "d41d8cd98f00b204e9800998ecf8427e",
+		}},
+		{"dependencies", "/project/foo-bar/dependencies/", http.StatusOK, []string{"<title>Foo_Bar 1.0"}},
+	}
+
+	for _, tt := range tc {
+		t.Run(tt.name, func(st *testing.T) {
+			handler, cfg, _ := newTestServer(st, newTestProjectRepository(true, user).handler)
+
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, testBasicRequest(cfg, "GET", tt.path, user))
+
+			if w.Code != tt.status {
+				st.Fatalf("%v returned status %v, expected %v: %v", tt.path, w.Code, tt.status, w.Body.String())
+			}
+			for _, part := range tt.contains {
+				if !strings.Contains(w.Body.String(), part) {
+					st.Errorf("%v does not contain %q:\n%v", tt.path, part, w.Body.String())
+				}
+			}
+		})
+	}
+}
+
+func TestProjectViewsCanonicalName(t *testing.T) {
+	user := User{ID: 1, Username: "user", Password: "hash", IsActive: true, CanList: true}
+	handler, cfg, _ := newTestServer(t, newTestProjectRepository(true, user).handler)
+
+	render := func(t *testing.T, path string) string {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, testBasicRequest(cfg, "GET", path, user))
+		if w.Code != http.StatusOK {
+			t.Fatalf("%v returned status %v", path, w.Code)
+		}
+		return w.Body.String()
+	}
+
+	expected := render(t, "/project/Foo_Bar/")
+	for _, path := range []string{"/project/foo-bar/", "/project/FOO.BAR/", "/project/foo_bar/"} {
+		t.Run(path, func(st *testing.T) {
+			if render(st, path) != expected {
+				st.Errorf("%v renders different page than /project/Foo_Bar/", path)
+			}
+		})
+	}
+}
+
+func TestProjectViewsPrivateIndex(t *testing.T) {
+	tc := []struct {
+		name   string
+		user   User
+		status int
+	}{
+		{"without acl", User{ID: 2, Username: "other", Password: "hash", IsActive: true, CanList: true}, http.StatusNotFound},
+		{"with acl", User{ID: 10, Username: "reader", Password: "hash", IsActive: true, CanList: true}, http.StatusOK},
+		{"admin", User{ID: 1, Username: "admin", Password: "hash", IsActive: true, IsAdmin: true, CanList: true}, http.StatusOK},
+	}
+
+	for _, tt := range tc {
+		for _, path := range []string{"/project/", "/project/foo-bar/", "/project/foo-bar/files/"} {
+			t.Run(tt.name+" "+path, func(st *testing.T) {
+				repository := newTestProjectRepository(false, tt.user)
+				repository.acl = []IndexACL{{IndexID: 1, UserID: 10, CanRead: true}}
+				handler, cfg, _ := newTestServer(st, repository.handler)
+
+				w := httptest.NewRecorder()
+				handler.ServeHTTP(w, testBasicRequest(cfg, "GET", path, tt.user))
+
+				if w.Code != tt.status {
+					st.Errorf("%v returned status %v, expected %v", path, w.Code, tt.status)
+				}
+				if tt.status == http.StatusNotFound && strings.Contains(w.Body.String(), "Foo_Bar") {
+					st.Errorf("%v of unreadable index shows package", path)
+				}
+			})
+		}
+	}
+}
@@ -0,0 +1,232 @@
+package core
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+/*
+testSchemaHandler answers schema queries of migration check, given tables are missing
+*/
+func testSchemaHandler(missing ...string) func(query string, args []driver.Value) testDBResult {
+	return func(query string, args []driver.Value) testDBResult {
+		if strings.Contains(query, "INFORMATION_SCHEMA") || strings.Contains(query, "pg_indexes") {
+			for _, table := range missing {
+				if len(args) > 0 && args[0] == table {
+					return countResult(0)
+				}
+			}
+			return countResult(1)
+		}
+		return testDBResult{}
+	}
+}
+
+/*
+testRepository is set of users, indexes and packages (with versions and files) answering queries of views. Versions
+of package are in ascending order. Ids of packages, versions and files must not collide, since lookups by id and by
+parent id are not distinguished.
+*/
+type testRepository struct {
+	testIndexes
+
+	users    []User
+	packages []Package
+}
+
+/*
+handler answers queries of users, packages, versions and files, other queries are answered by index graph
+*/
+func (t testRepository) handler(query string, args []driver.Value) testDBResult {
+	hasArg := func(values ...interface{}) bool {
+		for _, arg := range args {
+			for _, value := range values {
+				if arg == value {
+					return true
+				}
+			}
+		}
+		return false
+	}
+	now := time.Now()
+
+	switch {
+	case strings.Contains(query, `FROM "session"`), strings.Contains(query, `FROM "user"`):
+		return testUsersHandler(t.users...)(query, args)
+	case strings.Contains(query, "JOIN package_index ON"):
+		// latest versions of packages listed by SearchManager
+		result := testDBResult{Columns: []string{"package_id", "name", "index_id", "index_name", "version", "summary", "created_at"}}
+		for _, pack := range t.packages {
+			if len(pack.Versions) > 0 && hasArg(int64(pack.IndexID)) {
+				latest := pack.Versions[len(pack.Versions)-1]
+				result.Rows = append(result.Rows, []driver.Value{int64(pack.ID), pack.Name, int64(pack.IndexID),
+					DEFAULT_INDEX, latest.Version, latest.Summary, now})
+			}
+		}
+		return result
+	case strings.Contains(query, `FROM "package"`):
+		result := testDBResult{Columns: []string{"id", "index_id", "name", "created_at"}}
+		for _, pack := range t.packages {
+			if strings.Contains(query, "package.name = ") && !hasArg(pack.Name, CanonicalPackageName(pack.Name)) {
+				continue
+			}
+			result.Rows = append(result.Rows, []driver.Value{int64(pack.ID), int64(pack.IndexID), pack.Name, now})
+		}
+		return result
+	case strings.Contains(query, `FROM "package_version"`):
+		result := testDBResult{Columns: []string{"id", "package_id", "version", "version_order", "summary",
+			"description_html", "requires_python", "yanked", "yanked_reason", "created_at"}}
+		for _, pack := range t.packages {
+			for order, version := range pack.Versions {
+				if hasArg(int64(version.ID), int64(pack.ID)) {
+					result.Rows = append(result.Rows, []driver.Value{int64(version.ID), int64(pack.ID), version.Version,
+						int64(order), version.Summary, version.DescriptionHTML, version.RequiresPython, version.Yanked,
+						version.YankedReason, now})
+				}
+			}
+		}
+		// versions are stored in ascending order
+		if strings.Contains(query, "version_order DESC") {
+			for i, j := 0, len(result.Rows)-1; i < j; i, j = i+1, j-1 {
+				result.Rows[i], result.Rows[j] = result.Rows[j], result.Rows[i]
+			}
+		}
+		return result
+	case strings.Contains(query, `FROM "package_version_file"`):
+		result := testDBResult{Columns: []string{"id", "package_version_id", "filename", "relative_path", "md5_digest",
+			"requires_python", "created_at"}}
+		for _, pack := range t.packages {
+			for _, version := range pack.Versions {
+				for _, file := range version.Files {
+					if hasArg(int64(version.ID)) {
+						result.Rows = append(result.Rows, []driver.Value{int64(file.ID), int64(version.ID), file.Filename,
+							file.RelativePath, file.MD5Digest, file.RequiresPython, now})
+					}
+				}
+			}
+		}
+		return result
+	}
+
+	return t.testIndexes.handler(query, args)
+}
+
+func TestProbeViews(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gopypi")
+	if err != nil {
+		t.Fatalf("cannot create temporary directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	// packages directory cannot be created under regular file
+	file := path.Join(dir, "file")
+	if err = ioutil.WriteFile(file, []byte{}, 0644); err != nil {
+		t.Fatalf("cannot create file: %v", err)
+	}
+
+	tc := []struct {
+		name     string
+		path     string
+		missing  []string
+		packages string
+		status   int
+		result   map[string]string
+	}{
+		{"healthz", "/healthz/", []string{"session"}, file, http.StatusOK, nil},
+		{"readyz", "/readyz/", nil, dir, http.StatusOK,
+			map[string]string{"database": "ok", "migrations": "ok", "storage": "ok"}},
+		{"readyz pending migrations", "/readyz/", []string{"session"}, dir, http.StatusServiceUnavailable,
+			map[string]string{"database": "ok", "migrations": ErrMigrationsPending.Error() + ": table session", "storage": "ok"}},
+		{"readyz storage", "/readyz/", nil, path.Join(file, "packages"), http.StatusServiceUnavailable,
+			map[string]string{"database": "ok", "migrations": "ok"}},
+		{"version", "/version/", nil, dir, http.StatusOK,
+			map[string]string{"version": VERSION, "commit": COMMIT, "go": runtime.Version()}},
+	}
+
+	for _, tt := range tc {
+		t.Run(tt.name, func(st *testing.T) {
+			handler, cfg, _ := newTestServer(st, testSchemaHandler(tt.missing...))
+			cfg.packagesDir = tt.packages
+
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, httptest.NewRequest("GET", tt.path, nil))
+
+			if w.Code != tt.status {
+				st.Fatalf("%v returned status %v, expected %v: %v", tt.path, w.Code, tt.status, w.Body.String())
+			}
+			if tt.result == nil {
+				return
+			}
+
+			body := struct {
+				Result map[string]string `json:"result"`
+			}{}
+			if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+				st.Fatalf("cannot parse response %v: %v", w.Body.String(), err)
+			}
+			for key, value := range tt.result {
+				if body.Result[key] != value {
+					st.Errorf("%v returned %v = %q, expected %q", tt.path, key, body.Result[key], value)
+				}
+			}
+			if tt.status == http.StatusServiceUnavailable && tt.result["storage"] == "" && body.Result["storage"] == "ok" {
+				st.Errorf("storage check should fail")
+			}
+		})
+	}
+}
+
+func TestPackageDetailViewRequiresPython(t *testing.T) {
+	user := User{ID: 1, Username: "user", Password: "hash", IsActive: true, CanList: true}
+	repository := testRepository{
+		testIndexes: testIndexes{indexes: []Index{{ID: 1, Name: DEFAULT_INDEX, Public: true}}},
+		users:       []User{user},
+		packages: []Package{
+			{ID: 1, IndexID: 1, Name: "Foo_Bar", Versions: []PackageVersion{
+				{ID: 10, Version: "0.9", Files: []PackageVersionFile{
+					{ID: 100, Filename: "Foo_Bar-0.9.tar.gz", MD5Digest: "a"},
+				}},
+				{ID: 11, Version: "1.0", RequiresPython: ">=3.6", Yanked: true, YankedReason: "broken", Files: []PackageVersionFile{
+					{ID: 101, Filename: "Foo_Bar-1.0.tar.gz", MD5Digest: "b"},
+					{ID: 102, Filename: "Foo_Bar-1.0-py3-none-any.whl", MD5Digest: "c", RequiresPython: ">=3.8,<4"},
+				}},
+			}},
+		},
+	}
+
+	tc := []struct {
+		name     string
+		filename string
+		attrs    string
+	}{
+		{"without requires python", "Foo_Bar-0.9.tar.gz", `#md5=a">`},
+		{"inherited from release", "Foo_Bar-1.0.tar.gz", `#md5=b" data-requires-python="&gt;=3.6" data-yanked="broken">`},
+		{"own requires python", "Foo_Bar-1.0-py3-none-any.whl", `#md5=c" data-requires-python="&gt;=3.8,&lt;4" data-yanked="broken">`},
+	}
+
+	handler, cfg, _ := newTestServer(t, repository.handler)
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, testBasicRequest(cfg, "GET", "/simple/foo-bar/", user))
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("simple detail returned status %v: %v", w.Code, w.Body.String())
+	}
+
+	for _, tt := range tc {
+		t.Run(tt.name, func(st *testing.T) {
+			if !strings.Contains(w.Body.String(), tt.attrs+tt.filename+"</a>") {
+				st.Errorf("link of %v with %q not found in\n%v", tt.filename, tt.attrs, w.Body.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,418 @@
+/*
+Webhooks
+
+Package events (file upload, new version, yank, delete, maintainer change) are sent as json payloads to configured
+webhooks. Event is built with NewWebhookEvent and fired with Fire method, which stores delivery for every subscribed
+webhook. Deliveries are sent in background by WebhookDispatcher and retried with exponential backoff.
+
+	NewWebhookEvent(WEBHOOK_EVENT_VERSION_CREATE).
+		Actor(user).
+		Package(pack).
+		Version(version).
+		Fire(cfg)
+
+When webhook has secret, payload is signed with hmac sha256 and signature is sent in X-Gopypi-Signature header as
+"sha256=<hex digest>".
+*/
+package core
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/uber-go/zap"
+)
+
+/*
+WebhookUser is user as described in webhook payload
+*/
+type WebhookUser struct {
+	ID       uint   `json:"id"`
+	Username string `json:"username"`
+}
+
+/*
+WebhookPackage is package as described in webhook payload
+*/
+type WebhookPackage struct {
+	ID   uint   `json:"id"`
+	Name string `json:"name"`
+}
+
+/*
+WebhookVersion is package version as described in webhook payload
+*/
+type WebhookVersion struct {
+	ID           uint      `json:"id"`
+	Version      string    `json:"version"`
+	Summary      string    `json:"summary"`
+	HomePage     string    `json:"home_page"`
+	Yanked       bool      `json:"yanked"`
+	YankedReason string    `json:"yanked_reason,omitempty"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+/*
+WebhookFile is package version file as described in webhook payload
+*/
+type WebhookFile struct {
+	ID          uint      `json:"id"`
+	Filename    string    `json:"filename"`
+	MD5Digest   string    `json:"md5_digest"`
+	DownloadURL string    `json:"download_url"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+/*
+WebhookPayload is json body sent to webhook
+*/
+type WebhookPayload struct {
+	Event      string          `json:"event"`
+	CreatedAt  time.Time       `json:"created_at"`
+	Actor      *WebhookUser    `json:"actor,omitempty"`
+	Package    *WebhookPackage `json:"package,omitempty"`
+	Version    *WebhookVersion `json:"version,omitempty"`
+	File       *WebhookFile    `json:"file,omitempty"`
+	Maintainer *WebhookUser    `json:"maintainer,omitempty"`
+}
+
+/*
+NewWebhookEvent returns new webhook event
+*/
+func NewWebhookEvent(event string) *WebhookEvent {
+	return &WebhookEvent{
+		payload: WebhookPayload{
+			Event:     event,
+			CreatedAt: time.Now().UTC(),
+		},
+	}
+}
+
+/*
+WebhookEvent builds webhook payload
+*/
+type WebhookEvent struct {
+	payload WebhookPayload
+}
+
+/*
+Actor sets user that caused event
+*/
+func (w *WebhookEvent) Actor(user User) *WebhookEvent {
+	w.payload.Actor = &WebhookUser{ID: user.ID, Username: user.Username}
+	return w
+}
+
+/*
+Package sets package of event
+*/
+func (w *WebhookEvent) Package(pack Package) *WebhookEvent {
+	w.payload.Package = &WebhookPackage{ID: pack.ID, Name: pack.Name}
+	return w
+}
+
+/*
+Version sets package version of event
+*/
+func (w *WebhookEvent) Version(version PackageVersion) *WebhookEvent {
+	w.payload.Version = &WebhookVersion{
+		ID:           version.ID,
+		Version:      version.Version,
+		Summary:      version.Summary,
+		HomePage:     version.HomePage,
+		Yanked:       version.Yanked,
+		YankedReason: version.YankedReason,
+		CreatedAt:    version.CreatedAt,
+	}
+	return w
+}
+
+/*
+File sets package version file of event, download url is built with config router
+*/
+func (w *WebhookEvent) File(cfg Config, file PackageVersionFile) *WebhookEvent {
+	w.payload.File = &WebhookFile{
+		ID:          file.ID,
+		Filename:    file.Filename,
+		MD5Digest:   file.MD5Digest,
+		DownloadURL: strings.TrimRight(cfg.Core().Host(), "/") + cfg.Manager().PackageVersionFile().GetDownloadURL(&file),
+		CreatedAt:   file.CreatedAt,
+	}
+	return w
+}
+
+/*
+Maintainer sets maintainer that was added or removed
+*/
+func (w *WebhookEvent) Maintainer(user User) *WebhookEvent {
+	w.payload.Maintainer = &WebhookUser{ID: user.ID, Username: user.Username}
+	return w
+}
+
+/*
+Fire stores delivery for all webhooks subscribed to event and wakes up dispatcher. Errors are logged and returned,
+callers usually ignore them.
+*/
+func (w *WebhookEvent) Fire(cfg Config) (err error) {
+	packageID := uint(0)
+	if w.payload.Package != nil {
+		packageID = w.payload.Package.ID
+	}
+
+	webhooks := []Webhook{}
+	if err = cfg.Manager().Webhook().ListForEvent(&webhooks, w.payload.Event, packageID).Error; err != nil {
+		w.logError(cfg, err)
+		return
+	}
+
+	return w.FireTo(cfg, webhooks...)
+}
+
+/*
+FireTo stores delivery for given webhooks regardless of their subscriptions and wakes up dispatcher.
+*/
+func (w *WebhookEvent) FireTo(cfg Config, webhooks ...Webhook) (err error) {
+	if len(webhooks) == 0 {
+		return
+	}
+
+	var body []byte
+	if body, err = json.Marshal(w.payload); err != nil {
+		w.logError(cfg, err)
+		return
+	}
+
+	for _, webhook := range webhooks {
+		if _, err = cfg.Manager().Webhook().CreateDelivery(webhook, w.payload.Event, string(body)); err != nil {
+			w.logError(cfg, err)
+			return
+		}
+	}
+
+	cfg.Webhooks().Dispatcher().Notify()
+
+	return
+}
+
+/*
+logError logs error of firing event
+*/
+func (w *WebhookEvent) logError(cfg Config, err error) {
+	cfg.Logger().Error("cannot fire webhook event",
+		zap.String("event", w.payload.Event),
+		zap.String("error", err.Error()),
+	)
+}
+
+/*
+WebhookSignature returns signature of payload for given secret
+*/
+func WebhookSignature(secret string, payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(payload)
+	return fmt.Sprintf("sha256=%x", mac.Sum(nil))
+}
+
+/*
+NewWebhookDispatcher returns dispatcher that sends pending deliveries
+*/
+func NewWebhookDispatcher(timeout time.Duration) *WebhookDispatcher {
+	return &WebhookDispatcher{
+		client: &http.Client{Timeout: timeout},
+		notify: make(chan struct{}, 1),
+	}
+}
+
+/*
+WebhookDispatcher sends pending webhook deliveries. It implements Task interface, so it can be run once (e.g. from
+command line) or started as background goroutine with Start.
+*/
+type WebhookDispatcher struct {
+	client *http.Client
+	notify chan struct{}
+	once   sync.Once
+}
+
+/*
+Start runs dispatcher in background, pending deliveries are checked every poll interval and when notified
+*/
+func (w *WebhookDispatcher) Start(cfg Config) {
+	w.once.Do(func() {
+		go func() {
+			interval := cfg.Webhooks().PollInterval()
+			if interval <= 0 {
+				interval = WEBHOOK_POLL_INTERVAL * time.Second
+			}
+
+			ticker := time.NewTicker(interval)
+			defer ticker.Stop()
+
+			for {
+				if err := w.Run(cfg); err != nil {
+					cfg.Logger().Error("webhook dispatcher failed", zap.String("error", err.Error()))
+				}
+
+				select {
+				case <-ticker.C:
+				case <-w.notify:
+				}
+			}
+		}()
+	})
+}
+
+/*
+Notify wakes up dispatcher, it never blocks
+*/
+func (w *WebhookDispatcher) Notify() {
+	select {
+	case w.notify <- struct{}{}:
+	default:
+	}
+}
+
+/*
+Run sends all deliveries that are due
+*/
+func (w *WebhookDispatcher) Run(cfg Config) (err error) {
+	manager := cfg.Manager().Webhook()
+
+	for {
+		deliveries := []WebhookDelivery{}
+		if err = manager.ListDue(&deliveries, WEBHOOK_DISPATCH_BATCH).Error; err != nil {
+			return
+		}
+
+		for _, delivery := range deliveries {
+			// other gopypi instance can process the same delivery
+			if !manager.Claim(delivery, time.Now().Add(2*cfg.Webhooks().Timeout())) {
+				continue
+			}
+			if err = w.Deliver(cfg, &delivery); err != nil {
+				return
+			}
+		}
+
+		if len(deliveries) < WEBHOOK_DISPATCH_BATCH {
+			return
+		}
+	}
+}
+
+/*
+Deliver makes single delivery attempt and stores its result. Returned error means that result could not be stored,
+failed delivery is not an error.
+*/
+func (w *WebhookDispatcher) Deliver(cfg Config, delivery *WebhookDelivery) (err error) {
+	webhook := Webhook{}
+	if cfg.DB().First(&webhook, "id = ?", delivery.WebhookID).RecordNotFound() || !webhook.IsActive {
+		delivery.Status = WEBHOOK_DELIVERY_FAILED
+		delivery.Error = "webhook is disabled or deleted"
+		return w.save(cfg, delivery)
+	}
+
+	started := time.Now()
+	status, body, errSend := w.send(webhook, delivery)
+
+	delivery.Attempts++
+	delivery.Duration = int64(time.Since(started) / time.Millisecond)
+	delivery.ResponseStatus = status
+	delivery.ResponseBody = body
+	delivery.Error = ""
+
+	if errSend == nil && status >= 200 && status < 300 {
+		now := time.Now()
+		delivery.Status = WEBHOOK_DELIVERY_SUCCESS
+		delivery.DeliveredAt = &now
+		return w.save(cfg, delivery)
+	}
+
+	if errSend != nil {
+		delivery.Error = StringTruncate(errSend.Error(), 512)
+	} else {
+		delivery.Error = fmt.Sprintf("webhook returned status %v", status)
+	}
+
+	if delivery.Attempts >= cfg.Webhooks().MaxAttempts() {
+		delivery.Status = WEBHOOK_DELIVERY_FAILED
+	} else {
+		delivery.NextAttemptAt = time.Now().Add(w.backoff(cfg, delivery.Attempts))
+	}
+
+	return w.save(cfg, delivery)
+}
+
+/*
+save stores result of delivery attempt. When delivery cannot be saved, at least its status, attempts and next attempt
+are stored, so delivery is not sent again over and over.
+*/
+func (w *WebhookDispatcher) save(cfg Config, delivery *WebhookDelivery) (err error) {
+	if err = cfg.DB().Save(delivery).Error; err == nil {
+		return
+	}
+
+	cfg.DB().Model(&WebhookDelivery{ID: delivery.ID}).UpdateColumns(map[string]interface{}{
+		"status":          delivery.Status,
+		"attempts":        delivery.Attempts,
+		"next_attempt_at": delivery.NextAttemptAt,
+		"delivered_at":    delivery.DeliveredAt,
+		"response_status": delivery.ResponseStatus,
+		"response_body":   "",
+		"error":           StringTruncate("cannot store delivery: "+err.Error(), 512),
+	})
+	return
+}
+
+/*
+send posts payload to webhook url
+*/
+func (w *WebhookDispatcher) send(webhook Webhook, delivery *WebhookDelivery) (status int, body string, err error) {
+	var request *http.Request
+	if request, err = http.NewRequest("POST", webhook.URL, bytes.NewReader([]byte(delivery.Payload))); err != nil {
+		return
+	}
+
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set("User-Agent", "gopypi/"+VERSION)
+	request.Header.Set(WEBHOOK_HEADER_EVENT, delivery.Event)
+	request.Header.Set(WEBHOOK_HEADER_DELIVERY, fmt.Sprintf("%v", delivery.ID))
+	if webhook.Secret != "" {
+		request.Header.Set(WEBHOOK_HEADER_SIGNATURE, WebhookSignature(webhook.Secret, []byte(delivery.Payload)))
+	}
+
+	var resp *http.Response
+	if resp, err = w.client.Do(request); err != nil {
+		return
+	}
+	defer resp.Body.Close()
+
+	content, _ := ioutil.ReadAll(io.LimitReader(resp.Body, WEBHOOK_RESPONSE_BODY_LENGTH))
+
+	// body is stored in text column, limit can cut rune and endpoint can return binary data (postgres also rejects NUL)
+	body = strings.Replace(strings.ToValidUTF8(string(content), "\uFFFD"), "\x00", "", -1)
+
+	return resp.StatusCode, body, nil
+}
+
+/*
+backoff returns delay before next attempt, delay doubles with every attempt up to maximum
+*/
+func (w *WebhookDispatcher) backoff(cfg Config, attempts int) time.Duration {
+	delay := cfg.Webhooks().RetryDelay()
+	for i := 1; i < attempts && delay < cfg.Webhooks().MaxRetryDelay(); i++ {
+		delay *= 2
+	}
+	if delay > cfg.Webhooks().MaxRetryDelay() {
+		delay = cfg.Webhooks().MaxRetryDelay()
+	}
+	return delay
+}
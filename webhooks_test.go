@@ -0,0 +1,118 @@
+package core
+
+import (
+	"database/sql/driver"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+)
+
+/*
+testWebhookConfig returns config with webhook dispatcher settings and database answered by handler
+*/
+func testWebhookConfig(t *testing.T, handler func(query string, args []driver.Value) testDBResult) (*config, *testDB) {
+	cfg, fake := newTestConfig(t, handler)
+	cfg.wc = &webhooksConfig{
+		timeout:       time.Second,
+		maxAttempts:   5,
+		retryDelay:    time.Minute,
+		maxRetryDelay: time.Hour,
+	}
+	return cfg, fake
+}
+
+/*
+testWebhookRow returns active webhook with given url
+*/
+func testWebhookRow(url string) testDBResult {
+	return testDBResult{
+		Columns: []string{"id", "name", "url", "is_active"},
+		Rows:    [][]driver.Value{{int64(1), "hook", url, true}},
+	}
+}
+
+func TestWebhookDispatcherResponseBody(t *testing.T) {
+	tc := []struct {
+		name string
+		body string
+		out  string
+	}{
+		{"text", "ok", "ok"},
+		{"rune cut by limit", strings.Repeat("a", WEBHOOK_RESPONSE_BODY_LENGTH-1) + "é", strings.Repeat("a", WEBHOOK_RESPONSE_BODY_LENGTH-1) + "�"},
+		{"binary", "\xff\xfe\x00\x01ok", "�\x01ok"},
+		{"nul", "a\x00b", "ab"},
+	}
+
+	for _, tt := range tc {
+		t.Run(tt.name, func(st *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Write([]byte(tt.body))
+			}))
+			defer server.Close()
+
+			cfg, _ := testWebhookConfig(st, func(query string, args []driver.Value) testDBResult {
+				if strings.Contains(query, `FROM "webhook"`) {
+					return testWebhookRow(server.URL)
+				}
+				return testDBResult{RowsAffected: 1}
+			})
+
+			delivery := WebhookDelivery{ID: 1, WebhookID: 1, Status: WEBHOOK_DELIVERY_PENDING}
+			if err := NewWebhookDispatcher(time.Second).Deliver(cfg, &delivery); err != nil {
+				st.Fatalf("Deliver returned error: %v", err)
+			}
+			if !utf8.ValidString(delivery.ResponseBody) || strings.Contains(delivery.ResponseBody, "\x00") {
+				st.Errorf("Deliver stored invalid response body %q", delivery.ResponseBody)
+			}
+			if delivery.ResponseBody != tt.out {
+				st.Errorf("Deliver stored response body %q, expected %q", delivery.ResponseBody, tt.out)
+			}
+		})
+	}
+}
+
+func TestWebhookDispatcherSaveFailure(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	updates := 0
+	cfg, fake := testWebhookConfig(t, func(query string, args []driver.Value) testDBResult {
+		if strings.Contains(query, `FROM "webhook"`) {
+			return testWebhookRow(server.URL)
+		}
+		if strings.HasPrefix(query, "UPDATE") {
+			// full save of delivery fails
+			if updates++; updates == 1 {
+				return testDBResult{Err: errors.New("invalid byte sequence for encoding UTF8")}
+			}
+		}
+		return testDBResult{RowsAffected: 1}
+	})
+
+	claimed := time.Now()
+	delivery := WebhookDelivery{ID: 1, WebhookID: 1, Status: WEBHOOK_DELIVERY_PENDING, NextAttemptAt: claimed}
+	if err := NewWebhookDispatcher(time.Second).Deliver(cfg, &delivery); err == nil {
+		t.Fatalf("Deliver should return error of failed save")
+	}
+
+	queries := fake.Queries("UPDATE")
+	if len(queries) != 2 || !strings.Contains(queries[1], "next_attempt_at") || !strings.Contains(queries[1], "attempts") {
+		t.Fatalf("Deliver did not store result of attempt after failed save: %q", queries)
+	}
+
+	stored := false
+	for _, arg := range fake.args[len(fake.args)-1] {
+		if next, ok := arg.(time.Time); ok && next.After(claimed.Add(30*time.Second)) {
+			stored = true
+		}
+	}
+	if !stored {
+		t.Errorf("Deliver did not move next attempt forward after failed save")
+	}
+}
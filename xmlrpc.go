@@ -2,9 +2,9 @@
 package core
 
 import (
-	"strings"
+	"net/http"
 
-	"github.com/jinzhu/gorm"
+	"github.com/phonkee/go-xmlrpc"
 )
 
 /*
@@ -22,35 +22,65 @@ SearchService xml rpc service for searching packages
 */
 type SearchService struct {
 	Config Config
+
+	// User is user of request, search returns nothing when user cannot read default index
+	User User
 }
 
 /*
-search xml rpc method
+NewSearchHandler returns xml rpc handler that searches packages as user of request (set by authentication middleware)
 */
-func (s *SearchService) search(query string) (result []SearchResult, err error) {
-	query = NormalizePackageName(strings.TrimSpace(query))
+func NewSearchHandler(config Config) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, _ := ContextGetTokenUser(r.Context())
+
+		handler := xmlrpc.NewHandler()
+		if err := handler.AddService(&SearchService{Config: config, User: user}, ""); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		handler.ServeHTTP(w, r)
+	})
+}
 
-	packages := []Package{}
+/*
+search xml rpc method, searches packages listed in /simple (default index and its bases), _pypi_ordering is higher for
+more relevant packages
+*/
+func (s *SearchService) search(query string) (result []SearchResult, err error) {
+	var (
+		index Index
+		ids   []uint
+		hits  []SearchHit
+	)
+	result = []SearchResult{}
 
-	if err = s.Config.DB().Preload("Versions", func(db *gorm.DB) *gorm.DB {
-		return db.Order("created_at DESC")
-	}).Find(&packages, "name LIKE ?", "%"+query).Error; err != nil {
+	// same as /simple, user who cannot read default index finds nothing
+	if index, err = s.Config.Manager().Index().Default(); err != nil {
+		return
+	}
+	if !s.Config.Manager().Index().CanRead(index, s.User) {
+		return
+	}
+	if ids, err = s.Config.Manager().Index().ResolveIDs(index); err != nil {
 		return
 	}
 
-	result = []SearchResult{}
+	if hits, err = s.Config.Manager().Search().Search(query, ids); err != nil {
+		if err == ErrSearchQueryEmpty {
+			err = nil
+		}
+		return
+	}
 
 	// prepare result
-	for _, pack := range packages {
-		for _, version := range pack.Versions {
-			_ = version
-			result = append(result, SearchResult{
-				name:           pack.Name,
-				version:        version.Version,
-				summary:        version.Description,
-				_pypi_ordering: 999,
-			})
-		}
+	for i, hit := range hits {
+		result = append(result, SearchResult{
+			name:           hit.Name,
+			version:        hit.Version,
+			summary:        hit.Summary,
+			_pypi_ordering: len(hits) - i,
+		})
 	}
 
 	return